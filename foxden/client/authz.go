@@ -0,0 +1,31 @@
+package client
+
+// CHESComputing foxden client: authz module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"net/http"
+)
+
+// AuthzClient represents client of FOXDEN Authz service
+type AuthzClient struct {
+	client *Client
+	url    string
+}
+
+// Authz returns client of FOXDEN Authz service
+func (c *Client) Authz() *AuthzClient {
+	return &AuthzClient{client: c, url: c.URLs.Authz}
+}
+
+// TrustedClient checks if client with given user, ips and macs is trusted by
+// Authz service, the request is sent without token since it is used to obtain one
+func (a *AuthzClient) TrustedClient(ctx context.Context, user string, ips, macs []string) (*Response, error) {
+	if err := serviceUrl("Authz", a.url); err != nil {
+		return nil, err
+	}
+	rec := map[string]any{"user": user, "ips": ips, "macs": macs}
+	return a.client.sendJSON(ctx, "Authz", http.MethodPost, a.url+"/trusted_client", NoScope, rec)
+}
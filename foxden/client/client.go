@@ -0,0 +1,277 @@
+// Package client provides typed Go clients of FOXDEN services.
+//
+// All clients share common Client which holds urls of FOXDEN services, HTTP
+// client and source of access tokens, e.g.
+//
+//	c := client.New(client.URLs{MetaData: "https://foxden.host/meta"}, client.EnvTokens())
+//	records, err := c.MetaData().Search(ctx, "{}", client.SearchOptions{Limit: 10})
+//
+// Failed requests return *Error which carries HTTP status code along with
+// FOXDEN service response.
+package client
+
+// CHESComputing foxden client: core module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// URLs represents urls of FOXDEN services
+type URLs struct {
+	Frontend          string
+	MetaData          string
+	UserMetaData      string
+	DataBookkeeping   string
+	SpecScans         string
+	DataManagement    string
+	MLHub             string
+	DOIService        string
+	FabricCatalog     string
+	FabricDataService string
+	Discovery         string
+	Authz             string
+}
+
+// Client represents client of FOXDEN services
+type Client struct {
+	URLs       URLs
+	HTTPClient *http.Client              // HTTP client, http.DefaultClient is used if it is not set
+	Tokens     TokenSource               // source of access tokens, requests are sent without token if it is not set
+	Name       string                    // client name passed to FOXDEN services
+	Trace      func(method, rurl string) // optional callback called before every HTTP request
+}
+
+// New creates new client of FOXDEN services
+func New(urls URLs, tokens TokenSource) *Client {
+	return &Client{URLs: urls, Tokens: tokens, Name: "foxden"}
+}
+
+// Error represents error of FOXDEN service request
+type Error struct {
+	Service    string                    // FOXDEN service name
+	Method     string                    // HTTP method
+	Url        string                    // request url
+	StatusCode int                       // HTTP status code, zero if request did not reach the service
+	Response   *services.ServiceResponse // service response if service provided one
+	Body       []byte                    // raw response body
+	Err        error                     // underlying error
+}
+
+// Error implements error interface
+func (e *Error) Error() string {
+	msg := e.Service
+	if e.Method != "" {
+		msg = fmt.Sprintf("%s %s %s", msg, e.Method, e.Url)
+	}
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s: HTTP %d %s", msg, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Response != nil && e.Response.Error != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Response.Error)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns HTTP status code of given error or zero if error
+// does not come from FOXDEN service
+func StatusCode(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// Response represents response of FOXDEN service to write requests
+type Response struct {
+	StatusCode int
+	Body       []byte
+	services.ServiceResponse
+}
+
+// request represents single HTTP request to FOXDEN service
+type request struct {
+	service string
+	method  string
+	rurl    string
+	scope   Scope
+	ctype   string
+	body    []byte
+	header  http.Header
+}
+
+// helper function to get HTTP client
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// helper function to perform HTTP request, it returns HTTP status code and
+// response body, responses with non 2xx status codes are returned as *Error
+func (c *Client) do(ctx context.Context, r request) (int, []byte, error) {
+	resp, err := c.open(ctx, r)
+	if err != nil {
+		return StatusCode(err), errorBody(err), err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, body, r.fail(resp.StatusCode, body, err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// helper function to perform HTTP request and return HTTP response with open
+// body, e.g. to stream large responses, the caller should close response body,
+// responses with non 2xx status codes are returned as *Error
+func (c *Client) open(ctx context.Context, r request) (*http.Response, error) {
+	var reader io.Reader
+	if r.body != nil {
+		// bytes.Reader allows HTTP transport to replay request body on retries
+		reader = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.rurl, reader)
+	if err != nil {
+		return nil, r.fail(0, nil, err)
+	}
+	for key, vals := range r.header {
+		for _, val := range vals {
+			req.Header.Add(key, val)
+		}
+	}
+	if r.ctype != "" {
+		req.Header.Set("Content-Type", r.ctype)
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	if r.scope != NoScope && c.Tokens != nil {
+		token, err := c.Tokens.Token(ctx, r.scope)
+		if err != nil {
+			return nil, r.fail(0, nil, err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		}
+	}
+	if c.Trace != nil {
+		c.Trace(r.method, r.rurl)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, r.fail(0, nil, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return nil, r.fail(resp.StatusCode, body, err)
+	}
+	return resp, nil
+}
+
+// helper function to build *Error of given request, service response is
+// decoded from response body if service provided one
+func (r request) fail(code int, body []byte, err error) error {
+	e := &Error{Service: r.service, Method: r.method, Url: r.rurl, StatusCode: code, Body: body, Err: err}
+	var resp services.ServiceResponse
+	if len(body) > 0 && json.Unmarshal(body, &resp) == nil {
+		e.Response = &resp
+	}
+	return e
+}
+
+// helper function to get response body of given error
+func errorBody(err error) []byte {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Body
+	}
+	return nil
+}
+
+// helper function to marshal request payload, byte slices are sent as is
+func payload(in any) ([]byte, error) {
+	switch v := in.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case json.RawMessage:
+		return v, nil
+	}
+	return json.Marshal(in)
+}
+
+// helper function to make JSON request and decode its response into out
+func (c *Client) call(ctx context.Context, service, method, rurl string, scope Scope, in, out any) error {
+	r := request{service: service, method: method, rurl: rurl, scope: scope}
+	if in != nil {
+		data, err := payload(in)
+		if err != nil {
+			return &Error{Service: service, Method: method, Url: rurl, Err: err}
+		}
+		r.body = data
+		r.ctype = "application/json"
+	}
+	code, body, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return &Error{Service: service, Method: method, Url: rurl, StatusCode: code, Body: body, Err: err}
+	}
+	return nil
+}
+
+// helper function to make write request and return service response
+func (c *Client) send(ctx context.Context, r request) (*Response, error) {
+	code, body, err := c.do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{StatusCode: code, Body: body}
+	// not all FOXDEN services reply with service response
+	json.Unmarshal(body, &resp.ServiceResponse)
+	return resp, nil
+}
+
+// helper function to make JSON write request
+func (c *Client) sendJSON(ctx context.Context, service, method, rurl string, scope Scope, in any) (*Response, error) {
+	data, err := payload(in)
+	if err != nil {
+		return nil, &Error{Service: service, Method: method, Url: rurl, Err: err}
+	}
+	r := request{service: service, method: method, rurl: rurl, scope: scope, ctype: "application/json", body: data}
+	return c.send(ctx, r)
+}
+
+// helper function to check that url of given service is configured
+func serviceUrl(service, rurl string) error {
+	if rurl == "" {
+		return &Error{Service: service, Err: errors.New("service url is not configured")}
+	}
+	return nil
+}
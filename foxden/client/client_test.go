@@ -0,0 +1,259 @@
+package client_test
+
+// CHESComputing foxden client: tests of core and meta-data modules
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	services "github.com/CHESSComputing/golib/services"
+	client "github.com/CHESSComputing/gotools/foxden/client"
+	mock "github.com/CHESSComputing/gotools/foxden/mock"
+)
+
+// helper function to start mock server and create client of its services
+func newClient(t *testing.T, tokens client.TokenSource) (*client.Client, *mock.Server) {
+	t.Helper()
+	srv, err := mock.NewServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return client.New(mock.URLs(ts.URL), tokens), srv
+}
+
+// helper function to create test meta-data record
+func metaRecord(did, btr, cycle string) services.MetaRecord {
+	rec := map[string]any{"did": did, "btr": btr, "cycle": cycle, "beamline": "3a"}
+	return services.MetaRecord{Schema: "test", Record: rec}
+}
+
+// TestMetaData tests add, search, count, update and delete APIs of MetaData client
+func TestMetaData(t *testing.T) {
+	c, _ := newClient(t, nil)
+	ctx := context.Background()
+	meta := c.MetaData()
+	for _, rec := range []services.MetaRecord{
+		metaRecord("/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1", "abc-123", "2024-1"),
+		metaRecord("/beamline=3a/btr=abc-123/cycle=2024-2/sample_name=s2", "abc-123", "2024-2"),
+		metaRecord("/beamline=3a/btr=xyz-456/cycle=2024-2/sample_name=s3", "xyz-456", "2024-2"),
+	} {
+		if _, err := meta.Add(ctx, rec); err != nil {
+			t.Fatalf("unable to add record %v: %v", rec.Record["did"], err)
+		}
+	}
+
+	// adding existing record should fail
+	_, err := meta.Add(ctx, metaRecord("/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1", "abc-123", "2024-1"))
+	if err == nil {
+		t.Error("duplicate record was added")
+	}
+
+	nrecords, err := meta.Count(ctx, "{}")
+	if err != nil || nrecords != 3 {
+		t.Fatalf("wrong number of records %d, error %v", nrecords, err)
+	}
+	nrecords, err = meta.Count(ctx, `{"btr":"abc-123"}`)
+	if err != nil || nrecords != 2 {
+		t.Errorf("wrong number of btr records %d, error %v", nrecords, err)
+	}
+
+	records, err := meta.Search(ctx, `{"cycle":"2024-2"}`, client.SearchOptions{SortKeys: []string{"did"}, SortOrder: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0]["btr"] != "xyz-456" {
+		t.Errorf("wrong search results %v", records)
+	}
+	records, err = meta.Search(ctx, "{}", client.SearchOptions{Idx: 1, Limit: 1, SortKeys: []string{"did"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0]["did"] != "/beamline=3a/btr=abc-123/cycle=2024-2/sample_name=s2" {
+		t.Errorf("wrong page of search results %v", records)
+	}
+
+	did := "/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1"
+	mrec := metaRecord(did, "abc-123", "2024-3")
+	if _, err := meta.Update(ctx, mrec); err != nil {
+		t.Fatalf("unable to update record: %v", err)
+	}
+	rec, err := meta.Record(ctx, did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec["cycle"] != "2024-3" {
+		t.Errorf("record was not updated %v", rec)
+	}
+
+	if _, err := meta.Delete(ctx, did, "test"); err != nil {
+		t.Fatalf("unable to delete record: %v", err)
+	}
+	if _, err := meta.Record(ctx, did); client.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("deleted record is found, error %v", err)
+	}
+	if _, err := meta.Delete(ctx, did, "test"); client.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("wrong status of deleting non-existing record, error %v", err)
+	}
+	if nrecords, err := meta.Count(ctx, "{}"); err != nil || nrecords != 2 {
+		t.Errorf("wrong number of records after deletion %d, error %v", nrecords, err)
+	}
+}
+
+// TestUserMetaData tests add, search, count, update and delete APIs of UserMetaData client
+func TestUserMetaData(t *testing.T) {
+	c, _ := newClient(t, nil)
+	ctx := context.Background()
+	umeta := c.UserMetaData()
+	did := "/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1"
+	if _, err := umeta.Add(ctx, map[string]any{"did": did, "note": "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := umeta.Add(ctx, map[string]any{"note": "no did"}); err == nil {
+		t.Error("record without did was added")
+	}
+	if _, err := umeta.Update(ctx, map[string]any{"did": did, "note": "second"}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := umeta.Search(ctx, `{"note":"second"}`, client.SearchOptions{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("wrong search results %v, error %v", records, err)
+	}
+	if _, err := umeta.Delete(ctx, did, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if nrecords, err := umeta.Count(ctx, "{}"); err != nil || nrecords != 0 {
+		t.Errorf("wrong number of records after deletion %d, error %v", nrecords, err)
+	}
+}
+
+// TestScopes tests that client sends tokens of scope required by requests
+func TestScopes(t *testing.T) {
+	c, srv := newClient(t, nil)
+	srv.Auth = true
+	ctx := context.Background()
+	meta := c.MetaData()
+	rec := metaRecord("/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1", "abc-123", "2024-1")
+
+	// requests without token are not authorized
+	if _, err := meta.Count(ctx, "{}"); client.StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("wrong status of request without token, error %v", err)
+	}
+
+	tokens := client.StaticTokens{}
+	for _, scope := range []client.Scope{client.ReadScope, client.WriteScope} {
+		token, err := srv.Token("test", string(scope), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[scope] = token
+	}
+	// token of read scope does not allow to add records
+	c.Tokens = client.StaticTokens{client.ReadScope: tokens[client.ReadScope], client.WriteScope: tokens[client.ReadScope]}
+	if _, err := meta.Add(ctx, rec); client.StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("record was added with read token, error %v", err)
+	}
+
+	c.Tokens = tokens
+	if _, err := meta.Add(ctx, rec); err != nil {
+		t.Fatalf("unable to add record with write token: %v", err)
+	}
+	if nrecords, err := meta.Count(ctx, "{}"); err != nil || nrecords != 1 {
+		t.Errorf("wrong number of records %d, error %v", nrecords, err)
+	}
+	// client does not have delete token
+	did := rec.Record["did"].(string)
+	if _, err := meta.Delete(ctx, did, "test"); err == nil {
+		t.Error("record was deleted without delete token")
+	}
+}
+
+// TestServiceUrl tests that requests to services without url are rejected
+func TestServiceUrl(t *testing.T) {
+	c := client.New(client.URLs{}, nil)
+	if _, err := c.MetaData().Count(context.Background(), "{}"); err == nil {
+		t.Error("request was sent to service without url")
+	}
+}
+
+// TestTemplates tests add, list and delete APIs of template meta-data records
+func TestTemplates(t *testing.T) {
+	c, _ := newClient(t, nil)
+	ctx := context.Background()
+	meta := c.MetaData()
+	did := "/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1"
+	if _, err := meta.AddTemplate(ctx, map[string]any{"did": did, "tmpl_schema": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := meta.UpdateTemplate(ctx, map[string]any{"did": did, "tmpl_schema": "test", "btr": "abc-123"}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := meta.Templates(ctx)
+	if err != nil || len(records) != 1 || records[0]["btr"] != "abc-123" {
+		t.Fatalf("wrong template records %v, error %v", records, err)
+	}
+	if _, err := meta.DeleteTemplate(ctx, did, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := meta.Templates(ctx); err != nil || len(records) != 0 {
+		t.Errorf("wrong template records after deletion %v, error %v", records, err)
+	}
+}
+
+// TestSync tests streaming of records from one service and their injection into another
+func TestSync(t *testing.T) {
+	c, _ := newClient(t, nil)
+	dst, _ := newClient(t, nil)
+	ctx := context.Background()
+	for _, rec := range []services.MetaRecord{
+		metaRecord("/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1", "abc-123", "2024-1"),
+		metaRecord("/beamline=3a/btr=xyz-456/cycle=2024-2/sample_name=s2", "xyz-456", "2024-2"),
+	} {
+		if _, err := c.MetaData().Add(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// records are injected into UserMetaData service which accepts plain records
+	sc := c.Sync(c.URLs.MetaData, dst.URLs.UserMetaData+"/record")
+	var nrecords int
+	err := sc.Records(ctx, `{"btr":"abc-123"}`, func(rec map[string]any) error {
+		nrecords++
+		_, err := sc.Inject(ctx, rec)
+		return err
+	}, func(err error) {
+		t.Error(err)
+	})
+	if err != nil || nrecords != 1 {
+		t.Fatalf("wrong number of synced records %d, error %v", nrecords, err)
+	}
+	if n, err := dst.UserMetaData().Count(ctx, `{"btr":"abc-123"}`); err != nil || n != 1 {
+		t.Errorf("wrong number of injected records %d, error %v", n, err)
+	}
+}
+
+// TestDiscovery tests Discovery search and query language keys
+func TestDiscovery(t *testing.T) {
+	c, _ := newClient(t, nil)
+	ctx := context.Background()
+	if _, err := c.MetaData().Add(ctx, metaRecord("/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=s1", "abc-123", "2024-1")); err != nil {
+		t.Fatal(err)
+	}
+	records, err := c.Discovery().Search(ctx, `{"btr":"abc-123"}`, client.SearchOptions{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("wrong search results %v, error %v", records, err)
+	}
+	// mock server does not provide DataManagement service
+	if _, err := c.SearchKeys(ctx); err == nil {
+		t.Error("keys were fetched without DataManagement url")
+	}
+	c.URLs.DataManagement = c.URLs.MetaData
+	keys, err := c.SearchKeys(ctx)
+	if err != nil || len(keys) == 0 {
+		t.Errorf("wrong search keys %v, error %v", keys, err)
+	}
+}
@@ -0,0 +1,270 @@
+package client
+
+// CHESComputing foxden client: token encryption module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vkuznet/cryptoutils"
+)
+
+// header of encrypted token files, it is followed by key source and cipher
+const encryptedHeader = "FOXDEN-ENCRYPTED-TOKEN v1"
+
+// sources of keys used to encrypt token files
+const (
+	HostKey       = "host"       // key derived from per-user random key, host and user information
+	PassphraseKey = "passphrase" // key derived from user passphrase
+)
+
+// Passphrase provides passphrase of encrypted token files, by default it is
+// read from FOXDEN_TOKEN_PASSPHRASE environment, applications may replace it,
+// e.g. to prompt user for the passphrase
+var Passphrase = func() (string, error) {
+	if val := os.Getenv("FOXDEN_TOKEN_PASSPHRASE"); val != "" {
+		return val, nil
+	}
+	return "", errors.New("token file is encrypted with passphrase, please set FOXDEN_TOKEN_PASSPHRASE env")
+}
+
+// HostKeyFile returns file of random per-user key used by host key encryption,
+// by default it is $HOME/.foxden.key and it can be changed via FOXDEN_TOKEN_KEY
+// environment
+func HostKeyFile() string {
+	if fname := os.Getenv("FOXDEN_TOKEN_KEY"); fname != "" {
+		return fname
+	}
+	return filepath.Join(os.Getenv("HOME"), ".foxden.key")
+}
+
+// helper function to read random per-user key, the key is created with 0600
+// permissions if it does not exist and create flag is set, key which can be
+// read by other users is rejected
+func hostKey(create bool) (string, error) {
+	fname := HostKeyFile()
+	info, err := os.Stat(fname)
+	if errors.Is(err, os.ErrNotExist) {
+		if !create {
+			return "", nil
+		}
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return "", err
+		}
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			// key was created by concurrent command
+			return hostKey(false)
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		return hostKey(false)
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("key file %s is accessible by other users, please restrict its permissions to 0600", fname)
+	}
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("key file %s is empty", fname)
+	}
+	return key, nil
+}
+
+// helper function to derive host secret from given key, host name, machine
+// id and user information, empty key provides secret of token files
+// encrypted before per-user keys were introduced
+func hostSecret(key string) (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	parts := []string{host, fmt.Sprintf("%d", os.Getuid()), os.Getenv("HOME")}
+	for _, fname := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(fname); err == nil {
+			parts = append(parts, strings.TrimSpace(string(data)))
+			break
+		}
+	}
+	if key != "" {
+		parts = append(parts, key)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, ":")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HostSecret returns secret derived from random per-user key kept in
+// HostKeyFile, which is created on first use, along with host name, machine
+// id and user information. Token files encrypted with it can be decrypted
+// only by the same user on the same host who can read the key file, i.e.
+// host key encryption protects copies of token files, e.g. in backups, but
+// not token files of a user whose key file is exposed, use passphrase for it
+func HostSecret() (string, error) {
+	key, err := hostKey(true)
+	if err != nil {
+		return "", err
+	}
+	return hostSecret(key)
+}
+
+// helper function to get secret of given key source
+func tokenSecret(source string) (string, error) {
+	switch source {
+	case HostKey:
+		return HostSecret()
+	case PassphraseKey:
+		return Passphrase()
+	}
+	return "", fmt.Errorf("unsupported token key source %q", source)
+}
+
+// helper function to get secrets which may decrypt token of given key
+// source, host key tokens encrypted without per-user key are still accepted
+func decryptSecrets(source string) ([]string, error) {
+	if source != HostKey {
+		secret, err := tokenSecret(source)
+		return []string{secret}, err
+	}
+	var secrets []string
+	key, err := hostKey(false)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		secret, err := hostSecret(key)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	secret, err := hostSecret("")
+	if err != nil {
+		return nil, err
+	}
+	return append(secrets, secret), nil
+}
+
+// Encrypted checks if given token file content is encrypted and returns its
+// key source and cipher
+func Encrypted(data []byte) (string, string, bool) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	rest, ok := strings.CutPrefix(string(line), encryptedHeader+" ")
+	if !ok {
+		return "", "", false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// EncryptToken encrypts token with key of given source, e.g. HostKey or
+// PassphraseKey, and cipher (aes or nacl) and returns content of token file
+func EncryptToken(token, source, cipher string) ([]byte, error) {
+	if cipher == "" {
+		cipher = "aes"
+	}
+	secret, err := tokenSecret(source)
+	if err != nil {
+		return nil, err
+	}
+	data, err := cryptoutils.Encrypt([]byte(token), secret, cipher)
+	if err != nil {
+		return nil, err
+	}
+	out := fmt.Sprintf("%s %s %s\n%s\n", encryptedHeader, source, cipher, hex.EncodeToString(data))
+	return []byte(out), nil
+}
+
+// DecryptToken decrypts content of encrypted token file
+func DecryptToken(data []byte) (string, error) {
+	source, cipher, ok := Encrypted(data)
+	if !ok {
+		return "", errors.New("token is not encrypted")
+	}
+	secrets, err := decryptSecrets(source)
+	if err != nil {
+		return "", err
+	}
+	_, body, _ := bytes.Cut(data, []byte("\n"))
+	edata, err := hex.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted token: %w", err)
+	}
+	for _, secret := range secrets {
+		var token []byte
+		if token, err = cryptoutils.Decrypt(edata, secret, cipher); err == nil {
+			return strings.TrimSpace(string(token)), nil
+		}
+	}
+	return "", fmt.Errorf("unable to decrypt token with %s key: %w", source, err)
+}
+
+// ReadTokenFile reads token from given file, encrypted tokens are decrypted
+func ReadTokenFile(fname string) (string, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return "", err
+	}
+	if _, _, ok := Encrypted(data); ok {
+		return DecryptToken(data)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// WriteTokenFile writes token into given file with 0600 permissions, the
+// token is encrypted if key source is provided
+func WriteTokenFile(fname, token, source, cipher string) error {
+	data := []byte(token)
+	if source != "" {
+		var err error
+		if data, err = EncryptToken(token, source, cipher); err != nil {
+			return err
+		}
+	}
+	// write token into temporary file first, it is created with 0600
+	// permissions and replaces existing file regardless of its permissions
+	tmp, err := os.CreateTemp(filepath.Dir(fname), ".foxden-token-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), fname); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package client_test
+
+// CHESComputing foxden client: tests of token encryption module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	client "github.com/CHESSComputing/gotools/foxden/client"
+)
+
+// helper function to use per-user key and passphrase of temporary area
+func cryptSetup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("FOXDEN_TOKEN_KEY", filepath.Join(dir, ".foxden.key"))
+	t.Setenv("FOXDEN_TOKEN_PASSPHRASE", "test passphrase")
+	return dir
+}
+
+// TestEncryptToken tests encryption and decryption of tokens with host key and passphrase
+func TestEncryptToken(t *testing.T) {
+	cryptSetup(t)
+	token := "header.payload.signature"
+	for _, source := range []string{client.HostKey, client.PassphraseKey} {
+		for _, cipher := range []string{"", "aes", "nacl"} {
+			data, err := client.EncryptToken(token, source, cipher)
+			if err != nil {
+				t.Fatalf("%s %s: %v", source, cipher, err)
+			}
+			if strings.Contains(string(data), token) {
+				t.Errorf("%s %s: encrypted token contains plain token", source, cipher)
+			}
+			src, cph, ok := client.Encrypted(data)
+			if !ok || src != source || (cipher != "" && cph != cipher) {
+				t.Errorf("%s %s: wrong header %s %s %v", source, cipher, src, cph, ok)
+			}
+			if val, err := client.DecryptToken(data); err != nil || val != token {
+				t.Errorf("%s %s: wrong decrypted token %q, error %v", source, cipher, val, err)
+			}
+		}
+	}
+	if _, err := client.DecryptToken([]byte(token)); err == nil {
+		t.Error("plain token was decrypted")
+	}
+	if _, err := client.EncryptToken(token, "unknown", "aes"); err == nil {
+		t.Error("token was encrypted with unknown key source")
+	}
+
+	// tokens can not be decrypted with other passphrase
+	data, err := client.EncryptToken(token, client.PassphraseKey, "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FOXDEN_TOKEN_PASSPHRASE", "other passphrase")
+	if _, err := client.DecryptToken(data); err == nil {
+		t.Error("token was decrypted with wrong passphrase")
+	}
+}
+
+// TestHostKey tests that host secret depends on random per-user key
+func TestHostKey(t *testing.T) {
+	cryptSetup(t)
+	token := "header.payload.signature"
+	data, err := client.EncryptToken(token, client.HostKey, "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(client.HostKeyFile())
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("wrong key file %v, error %v", info, err)
+	}
+	secret, err := client.HostSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other user key provides other secret and does not decrypt the token
+	cryptSetup(t)
+	if other, err := client.HostSecret(); err != nil || other == secret {
+		t.Errorf("host secret does not depend on user key, error %v", err)
+	}
+	if _, err := client.DecryptToken(data); err == nil {
+		t.Error("token was decrypted with other user key")
+	}
+
+	// key readable by other users is rejected
+	if err := os.Chmod(client.HostKeyFile(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.EncryptToken(token, client.HostKey, "aes"); err == nil {
+		t.Error("token was encrypted with key readable by other users")
+	}
+}
+
+// TestWriteTokenFile tests that token files are written with 0600 permissions
+func TestWriteTokenFile(t *testing.T) {
+	dir := cryptSetup(t)
+	token := "header.payload.signature"
+	fname := filepath.Join(dir, "token")
+	if err := os.WriteFile(fname, []byte("old token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{"", client.HostKey, client.PassphraseKey} {
+		if err := client.WriteTokenFile(fname, token, source, "aes"); err != nil {
+			t.Fatalf("%q: %v", source, err)
+		}
+		info, err := os.Stat(fname)
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%q: wrong token file %v, error %v", source, info, err)
+		}
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := client.Encrypted(data); ok != (source != "") {
+			t.Errorf("%q: wrong encryption of token file", source)
+		}
+		if val, err := client.ReadTokenFile(fname); err != nil || val != token {
+			t.Errorf("%q: wrong token %q, error %v", source, val, err)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, ".foxden-token-*")); len(files) != 0 {
+		t.Errorf("temporary token files are left %v", files)
+	}
+	if err := client.WriteTokenFile(filepath.Join(dir, "missing", "token"), token, "", ""); err == nil {
+		t.Error("token was written into missing directory")
+	}
+}
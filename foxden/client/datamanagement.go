@@ -0,0 +1,147 @@
+package client
+
+// CHESComputing foxden client: DataManagement module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// StorageRecord represents Storage record returned by DataManagement service
+type StorageRecord struct {
+	Status string `json:"status"`
+	Data   any    `json:"data"`
+}
+
+// UploadRecord represents upload record returned by DataManagement service
+type UploadRecord struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Msg    string `json:"msg"`
+	Object any    `json:"object"`
+}
+
+// DataRecord represents data entry of a dataset returned by DataManagement service
+type DataRecord struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+}
+
+// DataManagementClient represents client of FOXDEN DataManagement (S3) service
+type DataManagementClient struct {
+	client *Client
+	url    string
+}
+
+// DataManagement returns client of FOXDEN DataManagement service
+func (c *Client) DataManagement() *DataManagementClient {
+	return &DataManagementClient{client: c, url: c.URLs.DataManagement}
+}
+
+// helper function to get storage url of given path
+func (d *DataManagementClient) storageUrl(path string) string {
+	if path == "" {
+		return fmt.Sprintf("%s/storage", d.url)
+	}
+	return fmt.Sprintf("%s/storage/%s", d.url, path)
+}
+
+// Storage lists content of s3 storage, path can be empty, storage name or storage/bucket
+func (d *DataManagementClient) Storage(ctx context.Context, path string) (*StorageRecord, error) {
+	if err := serviceUrl("DataManagement", d.url); err != nil {
+		return nil, err
+	}
+	var rec StorageRecord
+	err := d.client.call(ctx, "DataManagement", http.MethodGet, d.storageUrl(path), ReadScope, nil, &rec)
+	return &rec, err
+}
+
+// CreateBucket creates new bucket, bucket should be provided as storage/bucket
+func (d *DataManagementClient) CreateBucket(ctx context.Context, bucket string) (*StorageRecord, error) {
+	if err := serviceUrl("DataManagement", d.url); err != nil {
+		return nil, err
+	}
+	var rec StorageRecord
+	err := d.client.call(ctx, "DataManagement", http.MethodPost, d.storageUrl(bucket), WriteScope, []byte{}, &rec)
+	return &rec, err
+}
+
+// DeleteBucket deletes bucket or object, path should be provided as storage/bucket
+func (d *DataManagementClient) DeleteBucket(ctx context.Context, path string) (*StorageRecord, error) {
+	if err := serviceUrl("DataManagement", d.url); err != nil {
+		return nil, err
+	}
+	var rec StorageRecord
+	err := d.client.call(ctx, "DataManagement", http.MethodDelete, d.storageUrl(path), DeleteScope, nil, &rec)
+	return &rec, err
+}
+
+// Upload uploads content of given reader as object with given name into a bucket
+func (d *DataManagementClient) Upload(ctx context.Context, bucket, name string, reader io.Reader) (*UploadRecord, error) {
+	if err := serviceUrl("DataManagement", d.url); err != nil {
+		return nil, err
+	}
+	rurl := d.storageUrl(fmt.Sprintf("%s/%s", bucket, name))
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("file", name)
+	if err == nil {
+		_, err = io.Copy(fw, reader)
+	}
+	w.Close()
+	if err != nil {
+		return nil, &Error{Service: "DataManagement", Method: http.MethodPost, Url: rurl, Err: err}
+	}
+	r := request{
+		service: "DataManagement",
+		method:  http.MethodPost,
+		rurl:    rurl,
+		scope:   WriteScope,
+		ctype:   w.FormDataContentType(),
+		body:    buf.Bytes(),
+	}
+	code, body, err := d.client.do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	var rec UploadRecord
+	if err := json.Unmarshal(body, &rec); err != nil {
+		return nil, &Error{Service: "DataManagement", Method: http.MethodPost, Url: rurl, StatusCode: code, Body: body, Err: err}
+	}
+	return &rec, nil
+}
+
+// Data returns data entries of given dataset
+func (d *DataManagementClient) Data(ctx context.Context, did string) ([]DataRecord, error) {
+	if err := serviceUrl("DataManagement", d.url); err != nil {
+		return nil, err
+	}
+	var records []DataRecord
+	rurl := fmt.Sprintf("%s/data?did=%s", d.url, url.QueryEscape(did))
+	err := d.client.call(ctx, "DataManagement", http.MethodGet, rurl, ReadScope, nil, &records)
+	return records, err
+}
+
+// Files returns files of given dataset matching given regular expression pattern,
+// pattern "all" matches all files
+func (d *DataManagementClient) Files(ctx context.Context, did, pattern string) ([]string, error) {
+	if err := serviceUrl("DataManagement", d.url); err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("did", did)
+	params.Set("pattern", pattern)
+	var files []string
+	rurl := fmt.Sprintf("%s/files?%s", d.url, params.Encode())
+	err := d.client.call(ctx, "DataManagement", http.MethodGet, rurl, ReadScope, nil, &files)
+	return files, err
+}
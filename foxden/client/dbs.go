@@ -0,0 +1,62 @@
+package client
+
+// CHESComputing foxden client: provenance module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DataBookkeepingClient represents client of FOXDEN DataBookkeeping (provenance) service
+type DataBookkeepingClient struct {
+	client *Client
+	url    string
+}
+
+// DataBookkeeping returns client of FOXDEN DataBookkeeping service
+func (c *Client) DataBookkeeping() *DataBookkeepingClient {
+	return &DataBookkeepingClient{client: c, url: c.URLs.DataBookkeeping}
+}
+
+// Records returns provenance records of given API, e.g. datasets, files,
+// parents or children, matching given parameters
+func (d *DataBookkeepingClient) Records(ctx context.Context, api string, params url.Values) ([]map[string]any, error) {
+	if err := serviceUrl("DataBookkeeping", d.url); err != nil {
+		return nil, err
+	}
+	rurl := fmt.Sprintf("%s/%s", d.url, api)
+	if len(params) > 0 {
+		rurl = fmt.Sprintf("%s?%s", rurl, params.Encode())
+	}
+	var records []map[string]any
+	err := d.client.call(ctx, "DataBookkeeping", http.MethodGet, rurl, ReadScope, nil, &records)
+	return records, err
+}
+
+// helper function to add provenance record via given API
+func (d *DataBookkeepingClient) add(ctx context.Context, api string, rec any) (*Response, error) {
+	if err := serviceUrl("DataBookkeeping", d.url); err != nil {
+		return nil, err
+	}
+	rurl := fmt.Sprintf("%s/%s", d.url, api)
+	return d.client.sendJSON(ctx, "DataBookkeeping", http.MethodPost, rurl, WriteScope, rec)
+}
+
+// AddDataset adds provenance dataset record
+func (d *DataBookkeepingClient) AddDataset(ctx context.Context, rec any) (*Response, error) {
+	return d.add(ctx, "dataset", rec)
+}
+
+// AddFile adds provenance file record
+func (d *DataBookkeepingClient) AddFile(ctx context.Context, rec any) (*Response, error) {
+	return d.add(ctx, "file", rec)
+}
+
+// AddParent adds provenance parent record
+func (d *DataBookkeepingClient) AddParent(ctx context.Context, rec any) (*Response, error) {
+	return d.add(ctx, "parent", rec)
+}
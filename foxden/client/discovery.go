@@ -0,0 +1,73 @@
+package client
+
+// CHESComputing foxden client: discovery module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// DiscoveryClient represents client of FOXDEN Discovery service
+type DiscoveryClient struct {
+	client *Client
+	url    string
+}
+
+// Discovery returns client of FOXDEN Discovery service
+func (c *Client) Discovery() *DiscoveryClient {
+	return &DiscoveryClient{client: c, url: c.URLs.Discovery}
+}
+
+// Search returns records of FOXDEN services matching given query
+func (d *DiscoveryClient) Search(ctx context.Context, query string, opts SearchOptions) ([]map[string]any, error) {
+	var response services.ServiceResponse
+	rurl := d.url + "/search"
+	if err := d.client.search(ctx, "Discovery", d.url, query, opts, &response); err != nil {
+		return nil, err
+	}
+	// Discovery service may report errors of underlying services within its response
+	if response.HttpCode >= 400 {
+		return nil, &Error{Service: "Discovery", Method: http.MethodPost, Url: rurl, StatusCode: response.HttpCode, Response: &response}
+	}
+	return response.Results.Records, nil
+}
+
+// SearchKeys returns sorted list of query language keys of FOXDEN
+// DataBookkeeping, DataManagement, MetaData and SpecScans services
+func (c *Client) SearchKeys(ctx context.Context) ([]string, error) {
+	srvs := []struct {
+		name string
+		url  string
+	}{
+		{"DataBookkeeping", c.URLs.DataBookkeeping},
+		{"DataManagement", c.URLs.DataManagement},
+		{"MetaData", c.URLs.MetaData},
+		{"SpecScans", c.URLs.SpecScans},
+	}
+	keys := make(map[string]bool)
+	for _, svc := range srvs {
+		if err := serviceUrl(svc.name, svc.url); err != nil {
+			return nil, err
+		}
+		var records []string
+		rurl := fmt.Sprintf("%s/qlkeys", svc.url)
+		if err := c.call(ctx, svc.name, http.MethodGet, rurl, ReadScope, nil, &records); err != nil {
+			return nil, err
+		}
+		for _, key := range records {
+			keys[key] = true
+		}
+	}
+	var skeys []string
+	for key := range keys {
+		skeys = append(skeys, key)
+	}
+	sort.Strings(skeys)
+	return skeys, nil
+}
@@ -0,0 +1,119 @@
+package client
+
+// CHESComputing foxden client: DOI module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DOIRecord represents DOI record of DOIService
+type DOIRecord struct {
+	Doi            string `json:"doi"`
+	DoiUrl         string `json:"doi_url"`
+	Did            string `json:"did"`
+	Description    string `json:"description"`
+	Provider       string `json:"doi_provider"`
+	Published      string `json:"doi_created_at"`
+	Public         bool   `json:"doi_public"`
+	AccessMetadata bool   `json:"doi_access_metadata"`
+}
+
+// PublishRequest represents request to publish DOI of a dataset
+type PublishRequest struct {
+	Did             string   // dataset did
+	Provider        string   // DOI provider
+	Description     string   // DOI description
+	Schema          string   // FOXDEN schema of the dataset
+	Parents         []string // dids of parent datasets
+	Draft           bool     // publish draft DOI
+	PublishMetadata bool     // publish dataset meta-data along with DOI
+}
+
+// DOIClient represents client of FOXDEN DOIService, DOIs are published
+// through FOXDEN Frontend
+type DOIClient struct {
+	client   *Client
+	url      string
+	frontend string
+}
+
+// DOI returns client of FOXDEN DOIService
+func (c *Client) DOI() *DOIClient {
+	return &DOIClient{client: c, url: c.URLs.DOIService, frontend: c.URLs.Frontend}
+}
+
+// Records returns raw DOI records of given DOI
+func (d *DOIClient) Records(ctx context.Context, doi string) ([]map[string]any, error) {
+	var records []map[string]any
+	err := d.search(ctx, doi, &records)
+	return records, err
+}
+
+// Search returns DOI records of given DOI
+func (d *DOIClient) Search(ctx context.Context, doi string) ([]DOIRecord, error) {
+	var records []DOIRecord
+	err := d.search(ctx, doi, &records)
+	return records, err
+}
+
+// helper function to search DOI records
+func (d *DOIClient) search(ctx context.Context, doi string, out any) error {
+	if err := serviceUrl("DOIService", d.url); err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("doi", doi)
+	r := request{
+		service: "DOIService",
+		method:  http.MethodPost,
+		rurl:    d.url + "/search",
+		scope:   NoScope,
+		ctype:   "application/x-www-form-urlencoded",
+		body:    []byte(form.Encode()),
+	}
+	code, body, err := d.client.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return &Error{Service: "DOIService", Method: r.method, Url: r.rurl, StatusCode: code, Body: body, Err: err}
+	}
+	return nil
+}
+
+// Publish publishes DOI of a dataset
+func (d *DOIClient) Publish(ctx context.Context, req PublishRequest) (*Response, error) {
+	if err := serviceUrl("Frontend", d.frontend); err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("did", req.Did)
+	form.Set("doiprovider", req.Provider)
+	form.Set("description", req.Description)
+	form.Set("schema", req.Schema)
+	// checkbox values should be sent as "on" if checked, otherwise omitted
+	if req.Draft {
+		form.Set("draft", "on")
+	}
+	if req.PublishMetadata {
+		form.Set("publishmetadata", "on")
+	}
+	for _, p := range req.Parents {
+		form.Add("doi_parents_dids", strings.Trim(p, " "))
+	}
+	r := request{
+		service: "Frontend",
+		method:  http.MethodPost,
+		rurl:    d.frontend + "/publish",
+		scope:   WriteScope,
+		ctype:   "application/x-www-form-urlencoded",
+		body:    []byte(form.Encode()),
+	}
+	return d.client.send(ctx, r)
+}
@@ -0,0 +1,123 @@
+package client
+
+// CHESComputing foxden client: FabricNode module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	utils "github.com/CHESSComputing/golib/utils"
+)
+
+// IngestRecord represents ingest record from FabricNode
+type IngestRecord struct {
+	Ingested int    `json:"ingested"`
+	Did      string `json:"did"`
+	GraphIRI string `json:"graphIRI"`
+}
+
+// HealthRecord represents health status of FabricNode service
+type HealthRecord struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// FabricNodeClient represents client of FOXDEN FabricNode catalog and data services
+type FabricNodeClient struct {
+	client  *Client
+	catalog string
+	data    string
+}
+
+// FabricNode returns client of FOXDEN FabricNode services
+func (c *Client) FabricNode() *FabricNodeClient {
+	return &FabricNodeClient{client: c, catalog: c.URLs.FabricCatalog, data: c.URLs.FabricDataService}
+}
+
+// Health returns health status of FabricNode catalog and data services
+func (f *FabricNodeClient) Health(ctx context.Context) []HealthRecord {
+	services := []struct {
+		name, service, url string
+	}{
+		{"catalog-service", "FabricCatalog", f.catalog},
+		{"data-service", "FabricDataService", f.data},
+	}
+	var results []HealthRecord
+	for _, svc := range services {
+		rec := HealthRecord{Service: svc.name, Status: "error"}
+		var body map[string]any
+		err := serviceUrl(svc.service, svc.url)
+		if err == nil {
+			err = f.client.call(ctx, svc.service, http.MethodGet, svc.url+"/health", ReadScope, nil, &body)
+		}
+		if err != nil {
+			rec.Error = err.Error()
+			var e *Error
+			if errors.As(err, &e) && e.StatusCode != 0 {
+				rec.Error = fmt.Sprintf("HTTP %d", e.StatusCode)
+				if e.Response != nil && e.Response.Error != "" {
+					rec.Error = e.Response.Error
+				}
+			}
+		} else if s, ok := body["status"].(string); ok && s == "ok" {
+			rec.Status = "ok"
+		} else if msg, ok := body["error"].(string); ok {
+			rec.Error = msg
+		} else {
+			rec.Error = fmt.Sprintf("unexpected status %v", body["status"])
+		}
+		results = append(results, rec)
+	}
+	return results
+}
+
+// helper function to get url of dataset on FabricNode data service
+func (f *FabricNodeClient) datasetUrl(did, api string) (string, error) {
+	if err := serviceUrl("FabricDataService", f.data); err != nil {
+		return "", err
+	}
+	bl := utils.GetBeamline(did)
+	if bl == "" {
+		return "", fmt.Errorf("cannot extract beamline from DID %q", did)
+	}
+	return fmt.Sprintf("%s/beamlines/%s/datasets/%s/%s", f.data, bl, url.PathEscape(did), api), nil
+}
+
+// SPARQL returns SPARQL results of named graph of given dataset, beamline is extracted from the did
+func (f *FabricNodeClient) SPARQL(ctx context.Context, did string) (map[string]any, error) {
+	rurl, err := f.datasetUrl(did, "sparql")
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	err = f.client.call(ctx, "FabricDataService", http.MethodGet, rurl, ReadScope, nil, &data)
+	return data, err
+}
+
+// Catalog returns catalog of datasets of given beamline
+func (f *FabricNodeClient) Catalog(ctx context.Context, beamline string) (map[string]any, error) {
+	if err := serviceUrl("FabricCatalog", f.catalog); err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	rurl := fmt.Sprintf("%s/catalog/beamlines/%s/datasets", f.catalog, beamline)
+	err := f.client.call(ctx, "FabricCatalog", http.MethodGet, rurl, ReadScope, nil, &data)
+	return data, err
+}
+
+// Ingest ingests FOXDEN dataset of given did into FabricNode
+func (f *FabricNodeClient) Ingest(ctx context.Context, did string) (*IngestRecord, error) {
+	rurl, err := f.datasetUrl(did, "foxden/ingest")
+	if err != nil {
+		return nil, err
+	}
+	var rec IngestRecord
+	err = f.client.call(ctx, "FabricDataService", http.MethodPost, rurl, WriteScope, []byte{}, &rec)
+	return &rec, err
+}
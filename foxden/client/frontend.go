@@ -0,0 +1,23 @@
+package client
+
+// CHESComputing foxden client: frontend module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"net/http"
+
+	beamlines "github.com/CHESSComputing/golib/beamlines"
+)
+
+// Schemas returns FOXDEN schemas provided by Frontend service, every
+// record maps schema name to its schema records
+func (c *Client) Schemas(ctx context.Context) ([]map[string][]beamlines.SchemaRecord, error) {
+	if err := serviceUrl("Frontend", c.URLs.Frontend); err != nil {
+		return nil, err
+	}
+	var records []map[string][]beamlines.SchemaRecord
+	err := c.call(ctx, "Frontend", http.MethodGet, c.URLs.Frontend+"/schemas", ReadScope, nil, &records)
+	return records, err
+}
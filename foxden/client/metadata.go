@@ -0,0 +1,224 @@
+package client
+
+// CHESComputing foxden client: meta-data module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// SearchOptions represents pagination and sorting options of search requests
+type SearchOptions struct {
+	Idx       int
+	Limit     int
+	SortKeys  []string
+	SortOrder int
+}
+
+// helper function to search records of FOXDEN meta-data services
+func (c *Client) search(ctx context.Context, service, rurl, query string, opts SearchOptions, out any) error {
+	if err := serviceUrl(service, rurl); err != nil {
+		return err
+	}
+	if query == "" {
+		query = "{}"
+	}
+	rec := services.ServiceRequest{
+		Client: c.Name,
+		ServiceQuery: services.ServiceQuery{
+			Query:     query,
+			Idx:       opts.Idx,
+			Limit:     opts.Limit,
+			SortKeys:  opts.SortKeys,
+			SortOrder: opts.SortOrder,
+		},
+	}
+	return c.call(ctx, service, http.MethodPost, rurl+"/search", ReadScope, rec, out)
+}
+
+// helper function to count records of FOXDEN meta-data services
+func (c *Client) count(ctx context.Context, service, rurl, query string) (int, error) {
+	if err := serviceUrl(service, rurl); err != nil {
+		return 0, err
+	}
+	if query == "" {
+		query = "{}"
+	}
+	rec := services.ServiceRequest{
+		Client:       c.Name,
+		ServiceQuery: services.ServiceQuery{Query: query},
+	}
+	var nrecords int
+	err := c.call(ctx, service, http.MethodPost, rurl+"/count", ReadScope, rec, &nrecords)
+	return nrecords, err
+}
+
+// helper function to delete record of FOXDEN meta-data services
+func (c *Client) deleteRecord(ctx context.Context, service, rurl, did, user string) (*Response, error) {
+	if err := serviceUrl(service, rurl); err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("did", did)
+	params.Set("user", user)
+	r := request{
+		service: service,
+		method:  http.MethodDelete,
+		rurl:    fmt.Sprintf("%s/record?%s", rurl, params.Encode()),
+		scope:   DeleteScope,
+	}
+	return c.send(ctx, r)
+}
+
+// MetaDataClient represents client of FOXDEN MetaData service
+type MetaDataClient struct {
+	client *Client
+	url    string
+}
+
+// MetaData returns client of FOXDEN MetaData service
+func (c *Client) MetaData() *MetaDataClient {
+	return &MetaDataClient{client: c, url: c.URLs.MetaData}
+}
+
+// Search returns meta-data records matching given query
+func (m *MetaDataClient) Search(ctx context.Context, query string, opts SearchOptions) ([]map[string]any, error) {
+	var records []map[string]any
+	err := m.client.search(ctx, "MetaData", m.url, query, opts, &records)
+	return records, err
+}
+
+// Count returns number of meta-data records matching given query
+func (m *MetaDataClient) Count(ctx context.Context, query string) (int, error) {
+	return m.client.count(ctx, "MetaData", m.url, query)
+}
+
+// Record returns meta-data record of given did
+func (m *MetaDataClient) Record(ctx context.Context, did string) (map[string]any, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	var data any
+	rurl := fmt.Sprintf("%s/record?did=%s", m.url, url.QueryEscape(did))
+	if err := m.client.call(ctx, "MetaData", http.MethodGet, rurl, ReadScope, nil, &data); err != nil {
+		return nil, err
+	}
+	// service may return either single record or list of records
+	switch v := data.(type) {
+	case map[string]any:
+		return v, nil
+	case []any:
+		if len(v) > 0 {
+			if rec, ok := v[0].(map[string]any); ok {
+				return rec, nil
+			}
+		}
+	}
+	return nil, &Error{Service: "MetaData", Method: http.MethodGet, Url: rurl, StatusCode: http.StatusNotFound, Err: fmt.Errorf("no record found for did=%s", did)}
+}
+
+// Add adds new meta-data record
+func (m *MetaDataClient) Add(ctx context.Context, rec services.MetaRecord) (*Response, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "MetaData", http.MethodPost, m.url, WriteScope, rec)
+}
+
+// Update updates existing meta-data record
+func (m *MetaDataClient) Update(ctx context.Context, rec services.MetaRecord) (*Response, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "MetaData", http.MethodPut, m.url, WriteScope, rec)
+}
+
+// Delete deletes meta-data record of given did on behalf of given user
+func (m *MetaDataClient) Delete(ctx context.Context, did, user string) (*Response, error) {
+	return m.client.deleteRecord(ctx, "MetaData", m.url, did, user)
+}
+
+// Templates returns all template meta-data records, /tmpl/records API does
+// not support pagination and always returns all records
+func (m *MetaDataClient) Templates(ctx context.Context) ([]map[string]any, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	var records []map[string]any
+	err := m.client.call(ctx, "MetaData", http.MethodGet, m.url+"/tmpl/records", ReadScope, nil, &records)
+	return records, err
+}
+
+// AddTemplate adds new template meta-data record
+func (m *MetaDataClient) AddTemplate(ctx context.Context, rec map[string]any) (*Response, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "MetaData", http.MethodPost, m.url+"/tmpl/record", WriteScope, rec)
+}
+
+// UpdateTemplate updates existing template meta-data record
+func (m *MetaDataClient) UpdateTemplate(ctx context.Context, rec map[string]any) (*Response, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "MetaData", http.MethodPut, m.url+"/tmpl/record", WriteScope, rec)
+}
+
+// DeleteTemplate deletes template meta-data record of given did on behalf of given user
+func (m *MetaDataClient) DeleteTemplate(ctx context.Context, did, user string) (*Response, error) {
+	if err := serviceUrl("MetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.deleteRecord(ctx, "MetaData", m.url+"/tmpl", did, user)
+}
+
+// UserMetaDataClient represents client of FOXDEN UserMetaData service
+type UserMetaDataClient struct {
+	client *Client
+	url    string
+}
+
+// UserMetaData returns client of FOXDEN UserMetaData service
+func (c *Client) UserMetaData() *UserMetaDataClient {
+	return &UserMetaDataClient{client: c, url: c.URLs.UserMetaData}
+}
+
+// Search returns user meta-data records matching given query
+func (m *UserMetaDataClient) Search(ctx context.Context, query string, opts SearchOptions) ([]map[string]any, error) {
+	var records []map[string]any
+	err := m.client.search(ctx, "UserMetaData", m.url, query, opts, &records)
+	return records, err
+}
+
+// Count returns number of user meta-data records matching given query
+func (m *UserMetaDataClient) Count(ctx context.Context, query string) (int, error) {
+	return m.client.count(ctx, "UserMetaData", m.url, query)
+}
+
+// Add adds new user meta-data record, the record should contain did
+func (m *UserMetaDataClient) Add(ctx context.Context, rec map[string]any) (*Response, error) {
+	if err := serviceUrl("UserMetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "UserMetaData", http.MethodPost, m.url+"/record", WriteScope, rec)
+}
+
+// Update updates existing user meta-data record
+func (m *UserMetaDataClient) Update(ctx context.Context, rec map[string]any) (*Response, error) {
+	if err := serviceUrl("UserMetaData", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "UserMetaData", http.MethodPut, m.url+"/record", WriteScope, rec)
+}
+
+// Delete deletes user meta-data record of given did on behalf of given user
+func (m *UserMetaDataClient) Delete(ctx context.Context, did, user string) (*Response, error) {
+	return m.client.deleteRecord(ctx, "UserMetaData", m.url, did, user)
+}
@@ -0,0 +1,158 @@
+package client
+
+// CHESComputing foxden client: MLHub module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// MLModel represents ML model of MLHub service
+type MLModel struct {
+	Model   string `json:"model"`
+	Type    string `json:"type"`
+	Backend string `json:"backend,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// helper function to write model fields into multipart form
+func (m MLModel) writeFields(w *multipart.Writer) error {
+	fields := [][2]string{
+		{"model", m.Model},
+		{"type", m.Type},
+		{"backend", m.Backend},
+		{"version", m.Version},
+	}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MLHubClient represents client of FOXDEN MLHub service
+type MLHubClient struct {
+	client *Client
+	url    string
+}
+
+// MLHub returns client of FOXDEN MLHub service
+func (c *Client) MLHub() *MLHubClient {
+	return &MLHubClient{client: c, url: c.URLs.MLHub}
+}
+
+// Get returns records of given MLHub end-point
+func (m *MLHubClient) Get(ctx context.Context, endpoint string) ([]map[string]any, error) {
+	if err := serviceUrl("MLHub", m.url); err != nil {
+		return nil, err
+	}
+	var records []map[string]any
+	rurl := fmt.Sprintf("%s/%s", m.url, endpoint)
+	err := m.client.call(ctx, "MLHub", http.MethodGet, rurl, ReadScope, nil, &records)
+	return records, err
+}
+
+// Models returns list of ML models
+func (m *MLHubClient) Models(ctx context.Context) ([]map[string]any, error) {
+	return m.Get(ctx, "models")
+}
+
+// Predict performs ML inference for given JSON input and returns raw MLHub response
+func (m *MLHubClient) Predict(ctx context.Context, input any) ([]byte, error) {
+	if err := serviceUrl("MLHub", m.url); err != nil {
+		return nil, err
+	}
+	data, err := payload(input)
+	if err != nil {
+		return nil, err
+	}
+	r := request{
+		service: "MLHub",
+		method:  http.MethodPost,
+		rurl:    m.url + "/predict",
+		scope:   ReadScope,
+		ctype:   "application/json",
+		body:    data,
+	}
+	_, body, err := m.client.do(ctx, r)
+	return body, err
+}
+
+// PredictFile performs ML inference of given model for file input, e.g. image,
+// and returns raw MLHub response
+func (m *MLHubClient) PredictFile(ctx context.Context, model MLModel, name string, reader io.Reader) ([]byte, error) {
+	if err := serviceUrl("MLHub", m.url); err != nil {
+		return nil, err
+	}
+	rurl := m.url + "/predict"
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	err := model.writeFields(w)
+	if err == nil {
+		var fw io.Writer
+		if fw, err = w.CreateFormFile("image", name); err == nil {
+			_, err = io.Copy(fw, reader)
+		}
+	}
+	w.Close()
+	if err != nil {
+		return nil, &Error{Service: "MLHub", Method: http.MethodPost, Url: rurl, Err: err}
+	}
+	r := request{
+		service: "MLHub",
+		method:  http.MethodPost,
+		rurl:    rurl,
+		scope:   ReadScope,
+		ctype:   w.FormDataContentType(),
+		body:    buf.Bytes(),
+	}
+	_, body, err := m.client.do(ctx, r)
+	return body, err
+}
+
+// Upload uploads ML model bundle
+func (m *MLHubClient) Upload(ctx context.Context, model MLModel, name string, reader io.Reader) (*Response, error) {
+	if err := serviceUrl("MLHub", m.url); err != nil {
+		return nil, err
+	}
+	rurl := m.url + "/upload"
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("file", name)
+	if err == nil {
+		if _, err = io.Copy(fw, reader); err == nil {
+			err = model.writeFields(w)
+		}
+	}
+	w.Close()
+	if err != nil {
+		return nil, &Error{Service: "MLHub", Method: http.MethodPost, Url: rurl, Err: err}
+	}
+	r := request{
+		service: "MLHub",
+		method:  http.MethodPost,
+		rurl:    rurl,
+		scope:   WriteScope,
+		ctype:   w.FormDataContentType(),
+		body:    buf.Bytes(),
+	}
+	return m.client.send(ctx, r)
+}
+
+// Delete deletes ML model
+func (m *MLHubClient) Delete(ctx context.Context, model MLModel) (*Response, error) {
+	if err := serviceUrl("MLHub", m.url); err != nil {
+		return nil, err
+	}
+	return m.client.sendJSON(ctx, "MLHub", http.MethodDelete, m.url+"/delete", DeleteScope, model)
+}
@@ -0,0 +1,40 @@
+package client
+
+// CHESComputing foxden client: SpecScans module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"net/http"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// SpecScansClient represents client of FOXDEN SpecScans service
+type SpecScansClient struct {
+	client *Client
+	url    string
+}
+
+// SpecScans returns client of FOXDEN SpecScans service
+func (c *Client) SpecScans() *SpecScansClient {
+	return &SpecScansClient{client: c, url: c.URLs.SpecScans}
+}
+
+// Search returns SpecScans records matching given query
+func (s *SpecScansClient) Search(ctx context.Context, query string, opts SearchOptions) ([]map[string]any, error) {
+	var response services.ServiceResponse
+	if err := s.client.search(ctx, "SpecScans", s.url, query, opts, &response); err != nil {
+		return nil, err
+	}
+	return response.Results.Records, nil
+}
+
+// Add adds SpecScans record or list of records
+func (s *SpecScansClient) Add(ctx context.Context, records any) (*Response, error) {
+	if err := serviceUrl("SpecScans", s.url); err != nil {
+		return nil, err
+	}
+	return s.client.sendJSON(ctx, "SpecScans", http.MethodPost, s.url+"/add", WriteScope, records)
+}
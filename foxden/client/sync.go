@@ -0,0 +1,87 @@
+package client
+
+// CHESComputing foxden client: sync module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SyncClient represents client which copies records between FOXDEN services,
+// e.g. between MetaData services of different FOXDEN instances
+type SyncClient struct {
+	client *Client
+	src    string
+	dst    string
+}
+
+// Sync returns client which reads records from /records API of source url
+// and injects them into destination url
+func (c *Client) Sync(src, dst string) *SyncClient {
+	return &SyncClient{client: c, src: src, dst: dst}
+}
+
+// Records streams records of source service matching given spec, records
+// are read in ndjson data-format and passed to given function one by one,
+// errors of records which do not represent JSON objects are passed to onError
+// if it is provided
+func (s *SyncClient) Records(ctx context.Context, spec string, fn func(map[string]any) error, onError func(error)) error {
+	if err := serviceUrl("Sync", s.src); err != nil {
+		return err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	r := request{
+		service: "Sync",
+		method:  http.MethodGet,
+		rurl:    fmt.Sprintf("%s/records", s.src),
+		scope:   ReadScope,
+		ctype:   "application/x-ndjson",
+		body:    data,
+		header:  http.Header{"Accept": []string{"application/x-ndjson"}},
+	}
+	resp, err := s.client.open(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var record map[string]any
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var terr *json.UnmarshalTypeError
+		if errors.As(err, &terr) {
+			// record is consumed by decoder and we may proceed with next one
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		if err != nil {
+			// stream is broken and can not be decoded further
+			return r.fail(resp.StatusCode, nil, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// Inject submits given record to destination service
+func (s *SyncClient) Inject(ctx context.Context, rec map[string]any) (*Response, error) {
+	if err := serviceUrl("Sync", s.dst); err != nil {
+		return nil, err
+	}
+	return s.client.sendJSON(ctx, "Sync", http.MethodPost, s.dst, WriteScope, rec)
+}
@@ -0,0 +1,89 @@
+package client
+
+// CHESComputing foxden client: token module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Scope represents scope of FOXDEN access token
+type Scope string
+
+// scopes of FOXDEN access tokens
+const (
+	NoScope     Scope = ""
+	ReadScope   Scope = "read"
+	WriteScope  Scope = "write"
+	DeleteScope Scope = "delete"
+)
+
+// TokenSource provides access tokens for given scope
+type TokenSource interface {
+	Token(ctx context.Context, scope Scope) (string, error)
+}
+
+// TokenFunc adapts ordinary function to TokenSource interface
+type TokenFunc func(ctx context.Context, scope Scope) (string, error)
+
+// Token implements TokenSource interface
+func (f TokenFunc) Token(ctx context.Context, scope Scope) (string, error) {
+	return f(ctx, scope)
+}
+
+// StaticTokens represents fixed tokens for every scope
+type StaticTokens map[Scope]string
+
+// Token implements TokenSource interface
+func (t StaticTokens) Token(ctx context.Context, scope Scope) (string, error) {
+	if token, ok := t[scope]; ok {
+		return token, nil
+	}
+	return "", fmt.Errorf("no %s token", scope)
+}
+
+// tokenEnvs represents environment variables of tokens of given scope
+var tokenEnvs = map[Scope]string{
+	ReadScope:   "FOXDEN_TOKEN",
+	WriteScope:  "FOXDEN_WRITE_TOKEN",
+	DeleteScope: "FOXDEN_DELETE_TOKEN",
+}
+
+// EnvTokens returns token source which reads tokens in the same way as foxden
+// CLI, i.e. from FOXDEN_TOKEN, FOXDEN_WRITE_TOKEN and FOXDEN_DELETE_TOKEN
+// environment variables, which contain either token or token file name, or from
+// $HOME/.foxden.<scope>.token files
+func EnvTokens() TokenSource {
+	return TokenFunc(func(ctx context.Context, scope Scope) (string, error) {
+		env := tokenEnvs[scope]
+		if token := ReadToken(os.Getenv(env)); token != "" {
+			return token, nil
+		}
+		fname := filepath.Join(os.Getenv("HOME"), fmt.Sprintf(".foxden.%s.token", scope))
+		if token := ReadToken(fname); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("no %s token, please put it into %s env or %s file", scope, env, fname)
+	})
+}
+
+// ReadToken returns token from given value which is either token itself or name
+// of file with token, encrypted token files are decrypted
+func ReadToken(val string) string {
+	if val == "" {
+		return ""
+	}
+	if _, err := os.Stat(val); err == nil {
+		token, err := ReadTokenFile(val)
+		if err != nil {
+			return ""
+		}
+		return token
+	}
+	return strings.TrimSpace(val)
+}
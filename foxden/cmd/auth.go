@@ -30,8 +30,13 @@ var envTokens []string = []string{"FOXDEN_TOKEN", "FOXDEN_WRITE_TOKEN", "FOXDEN_
 func authUsage() string {
 	var out string
 	out += fmt.Sprintf("\nfoxden token create <scope: read|write|delete> [options]\n")
-	out += fmt.Sprintf("foxden token view [options]\n\n")
-	out += fmt.Sprintf("options: --kfile=keytab\n")
+	out += fmt.Sprintf("foxden token view [options]\n")
+	out += fmt.Sprintf("foxden token login --device [--scope=read,write,delete]\n")
+	out += fmt.Sprintf("foxden token daemon [--keytab=file] [--scopes=read,write] [--refresh-before=10m]\n")
+	out += fmt.Sprintf("foxden token status [--json] [--scope=read,write,delete] [--margin=duration]\n")
+	out += fmt.Sprintf("foxden token lock [--passphrase] [--cipher=aes|nacl] [--token=file]\n")
+	out += fmt.Sprintf("foxden token unlock [--token=file]\n\n")
+	out += fmt.Sprintf("options: --kfile=<kerberos ticket file or keytab>\n")
 	out += fmt.Sprintf("         --token=<token or file>\n")
 	out += fmt.Sprintf("         --ofile=<output fila name>\n")
 	out += fmt.Sprintf("         --expires=<seconds>\n")
@@ -45,6 +50,13 @@ func authUsage() string {
 	out += fmt.Sprintf("# generate read token with long expiration, e.g. 6 hours (21600 seconds)\n")
 	out += fmt.Sprintf("foxden token create read --expires=21600\n")
 	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# generate read token without kinit, if there is no kerberos ticket file the command\n")
+	out += fmt.Sprintf("# asks for kerberos user name (user or user@REALM) and password\n")
+	out += fmt.Sprintf("foxden token create read\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# generate write token non-interactively from /path/keytab file\n")
+	out += fmt.Sprintf("foxden token create write --kfile=/path/keytab\n")
+	out += fmt.Sprintf("\n")
 	out += fmt.Sprintf("# generate read token from specific /path/keytab file and store it to $HOME/.foxden.read.token\n")
 	out += fmt.Sprintf("foxden token create read --kfile=/path/keytab\n")
 	out += fmt.Sprintf("\n")
@@ -66,6 +78,41 @@ func authUsage() string {
 	out += fmt.Sprintf("# view existing token stored in %s\n", envTokens)
 	out += fmt.Sprintf("foxden token view\n")
 	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# login without Kerberos: approve login in a browser and store read, write and delete tokens\n")
+	out += fmt.Sprintf("foxden token login --device\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# login and obtain read token only\n")
+	out += fmt.Sprintf("foxden token login --device --scope=read\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# keep read and write tokens in $HOME fresh on acquisition host using keytab,\n")
+	out += fmt.Sprintf("# daemon status is written to $HOME/.foxden.daemon.json and served on unix socket\n")
+	out += fmt.Sprintf("foxden token daemon --keytab=/path/keytab --scopes=read,write --refresh-before=10m \\\n")
+	out += fmt.Sprintf("    --socket=$HOME/.foxden.daemon.sock\n")
+	out += fmt.Sprintf("curl --unix-socket $HOME/.foxden.daemon.sock http://localhost/status\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# check FOXDEN tokens, exit code is 0 if tokens are valid, 2 if token is missing,\n")
+	out += fmt.Sprintf("# 3 if token is expired, 4 if token expires within margin and 5 if token is invalid\n")
+	out += fmt.Sprintf("foxden token status\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# check write token in cron job and alert if it expires within 1 hour\n")
+	out += fmt.Sprintf("foxden token status --scope=write --margin=1h --json || mail -s 'renew FOXDEN token' user\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# encrypt token files in $HOME with host key, it is derived from random per-user\n")
+	out += fmt.Sprintf("# key $HOME/.foxden.key (or FOXDEN_TOKEN_KEY env), created on first lock, host\n")
+	out += fmt.Sprintf("# and user information; it protects copies of token files, e.g. in backups, but\n")
+	out += fmt.Sprintf("# anyone who can read the key file can decrypt tokens, use passphrase for it\n")
+	out += fmt.Sprintf("foxden token lock\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# encrypt /tmp/token file with passphrase and NaCl cipher, the passphrase\n")
+	out += fmt.Sprintf("# is asked once per command or read from FOXDEN_TOKEN_PASSPHRASE env\n")
+	out += fmt.Sprintf("foxden token lock --passphrase --cipher=nacl --token=/tmp/token\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# decrypt token files in $HOME\n")
+	out += fmt.Sprintf("foxden token unlock\n")
+	out += fmt.Sprintf("\n")
+	out += fmt.Sprintf("# new token files are encrypted if FOXDEN_TOKEN_ENCRYPTION=host|passphrase env is set\n")
+	out += fmt.Sprintf("FOXDEN_TOKEN_ENCRYPTION=host foxden token create write\n")
+	out += fmt.Sprintf("\n")
 	out += fmt.Sprintf("# generate test token\n")
 	out += fmt.Sprintf("foxden token test\n")
 	return out
@@ -86,13 +133,23 @@ func keyFile() string {
 
 func requestToken(scope, kfile string, expires int) (string, error) {
 	if kfile == "" {
-		kfile = keyFile()
+		// use default kerberos ticket file if it exists, otherwise user
+		// is asked for kerberos password
+		if _, err := os.Stat(keyFile()); err == nil {
+			kfile = keyFile()
+		}
 	}
 	if os.Getenv("FOXDEN_DEBUG") != "" {
 		fmt.Println("request token from", kfile)
 	}
-	var token string
 	user, ticket := getKerberosTicket(kfile)
+	return authorizeTicket(scope, user, ticket, expires)
+}
+
+// helper function to obtain token of given scope from Authz service for
+// kerberos ticket of the user
+func authorizeTicket(scope, user string, ticket []byte, expires int) (string, error) {
+	var token string
 	rec := authz.Kerberos{
 		User:    user,
 		Scope:   scope,
@@ -143,17 +200,8 @@ func generateToken(fname, keyFileName string, expires int) error {
 	// check if user has default read token
 	if _, err := os.Stat(fname); err == nil {
 		// file exists, let's read token and check its validity
-		file, err := os.Open(fname)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer file.Close()
-		data, _ := io.ReadAll(file)
-		token := string(data)
-		claims, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
-		rclaims := claims.RegisteredClaims
-		etime := rclaims.ExpiresAt
-		if etime.After(time.Now()) {
+		token := readToken(fname)
+		if token != "" && !tokenExpiring(token, tokenMargin()) {
 			return nil
 		}
 	}
@@ -176,12 +224,19 @@ func generateToken(fname, keyFileName string, expires int) error {
 		// check if user has kerberos file in place, i.e. /tmp/krb5cc_<uid>
 		kfile = keyFile()
 		if _, err := os.Stat(kfile); os.IsNotExist(err) {
-			fmt.Printf("No kerberos ticket file %s found, please run:\n", kfile)
-			fmt.Printf("# in (ba)sh environment, export KRB5CCNAME=FILE:%s\n", kfile)
-			fmt.Printf("# in (t)csh environment, setenv KRB5CCNAME FILE:%s\n", kfile)
-			fmt.Println("kinit")
-			fmt.Println("")
-			return err
+			if interactive() {
+				// obtain kerberos ticket with user name and password
+				fmt.Printf("No kerberos ticket file %s found, please provide kerberos credentials\n", kfile)
+				kfile = ""
+			} else {
+				fmt.Printf("No kerberos ticket file %s found, please run:\n", kfile)
+				fmt.Printf("# in (ba)sh environment, export KRB5CCNAME=FILE:%s\n", kfile)
+				fmt.Printf("# in (t)csh environment, setenv KRB5CCNAME FILE:%s\n", kfile)
+				fmt.Println("kinit")
+				fmt.Println("# or use keytab file, e.g. foxden token create read --kfile=/path/keytab")
+				fmt.Println("")
+				return err
+			}
 		}
 	}
 
@@ -189,31 +244,25 @@ func generateToken(fname, keyFileName string, expires int) error {
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(fname)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
-	file.Write([]byte(token))
-	return nil
+	return saveToken(fname, token)
 }
 
 func inspectAllTokens(tkn string) {
 	var token string
 	if tkn != "" {
-		token = utils.ReadToken(tkn)
+		token = readToken(tkn)
 		inspectToken(token)
 		return
 	}
-	rfile := fmt.Sprintf("%s/.foxden.read.token", os.Getenv("HOME"))
-	wfile := fmt.Sprintf("%s/.foxden.write.token", os.Getenv("HOME"))
-	dfile := fmt.Sprintf("%s/.foxden.delete.token", os.Getenv("HOME"))
+	rfile := tokenFile("read")
+	wfile := tokenFile("write")
+	dfile := tokenFile("delete")
 	found := false
 	for _, tfile := range []string{rfile, wfile, dfile} {
 		if _, err := os.Stat(tfile); os.IsNotExist(err) {
 			continue
 		}
-		token = utils.ReadToken(tfile)
+		token = readToken(tfile)
 		if token != "" {
 			fmt.Println("")
 			fmt.Println(tfile)
@@ -221,8 +270,9 @@ func inspectAllTokens(tkn string) {
 			found = true
 		}
 	}
-	for _, env := range envTokens {
-		token = utils.ReadToken(os.Getenv(env))
+	for _, src := range tokenSources() {
+		env, val := envToken(src)
+		token = readToken(val)
 		if token != "" {
 			s := fmt.Sprintf("%s: %s", env, token)
 			fmt.Println("")
@@ -315,88 +365,181 @@ func authCommand() *cobra.Command {
 		Use:   "token",
 		Short: "foxden token commands",
 		Long:  "foxden token commands: valid token is required to access FOXDEN services\n" + doc + "\n" + authUsage(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				authUsage()
+			} else {
+				fmt.Println("ERROR: wrong argument(s), please see --help")
+			}
+		},
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "view",
+		Short: "inspect FOXDEN tokens",
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			tkn, _ := cmd.Flags().GetString("token")
+			inspectAllTokens(tkn)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "test",
+		Short: "generate test token",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			customClaims := make(map[string]any)
+			customClaims["user"] = "test"
+			customClaims["scope"] = "read"
+			customClaims["userid"] = 123
+			p := TokenParameters{
+				Iss: "FOXDEN-Issuer", Sub: "FOXDEN-Sub", Aud: "FOXDEN-Aud", Secret: "FOXDEN-salt",
+				CustomClaims:      customClaims,
+				ExpirationMinutes: 60,
+			}
+			generateTestToken(p)
+		},
+	})
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "login to FOXDEN and obtain tokens",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			device, _ := cmd.Flags().GetBool("device")
+			scopes, _ := cmd.Flags().GetStringSlice("scope")
+			ofile, _ := cmd.Flags().GetString("ofile")
+			expires, _ := cmd.Flags().GetInt("expires")
+			if !device {
+				exit("please provide login method, e.g. --device", errors.New("no login method"))
+			}
+			deviceLogin(scopes, ofile, expires)
+		},
+	}
+	loginCmd.Flags().Bool("device", false, "login via OAuth device authorization in a browser")
+	loginCmd.Flags().StringSlice("scope", []string{"read", "write", "delete"}, "token scopes to obtain")
+	cmd.AddCommand(loginCmd)
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "keep FOXDEN token files fresh",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var opts DaemonOptions
+			opts.Keytab, _ = cmd.Flags().GetString("keytab")
+			opts.Scopes, _ = cmd.Flags().GetStringSlice("scopes")
+			opts.RefreshBefore, _ = cmd.Flags().GetDuration("refresh-before")
+			opts.Interval, _ = cmd.Flags().GetDuration("interval")
+			opts.Expires, _ = cmd.Flags().GetInt("expires")
+			opts.MaxFailures, _ = cmd.Flags().GetInt("max-failures")
+			opts.StatusFile, _ = cmd.Flags().GetString("status-file")
+			opts.Socket, _ = cmd.Flags().GetString("socket")
+			runTokenDaemon(opts)
+		},
+	}
+	daemonCmd.Flags().String("keytab", "", "keytab file to obtain kerberos tickets (default kerberos ticket file)")
+	daemonCmd.Flags().StringSlice("scopes", []string{"read", "write"}, "token scopes to keep fresh")
+	daemonCmd.Flags().Duration("refresh-before", 10*time.Minute, "renew tokens which expire within given duration")
+	daemonCmd.Flags().Duration("interval", time.Minute, "interval between token checks")
+	daemonCmd.Flags().Int("max-failures", 5, "exit after given number of consecutive renewal failures")
+	daemonCmd.Flags().String("status-file", daemonStatusFile(), "file to write daemon status, empty value disables it")
+	daemonCmd.Flags().String("socket", "", "unix socket to serve daemon status")
+	cmd.AddCommand(daemonCmd)
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "report status of FOXDEN tokens",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			scopes, _ := cmd.Flags().GetStringSlice("scope")
+			margin, _ := cmd.Flags().GetDuration("margin")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			tokenStatusReport(scopes, margin, jsonOutput)
+		},
+	}
+	statusCmd.Flags().StringSlice("scope", nil, "token scopes to check: read, write, delete (default all)")
+	statusCmd.Flags().Duration("margin", 0, "report tokens which expire within given duration (default FOXDEN_TOKEN_MARGIN or 5m)")
+	statusCmd.Flags().Bool("json", false, "output token status in JSON format")
+	cmd.AddCommand(statusCmd)
+	lockCmd := &cobra.Command{
+		Use:   "lock",
+		Short: "encrypt FOXDEN token files",
+		Long:  "encrypt FOXDEN token files\n\nHost key is derived from random per-user key $HOME/.foxden.key (or FOXDEN_TOKEN_KEY\nenv), created with 0600 permissions on first lock, host and user information. It\nprotects copies of token files, e.g. in backups, while anyone who can read the key\nfile, e.g. the same user or root, can decrypt tokens, use --passphrase for it",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			tkn, _ := cmd.Flags().GetString("token")
+			passphrase, _ := cmd.Flags().GetBool("passphrase")
+			cipher, _ := cmd.Flags().GetString("cipher")
+			lockTokens(tkn, passphrase, cipher)
+		},
+	}
+	lockCmd.Flags().Bool("passphrase", false, "encrypt tokens with passphrase instead of host key")
+	lockCmd.Flags().String("cipher", "aes", "cipher to use: aes or nacl")
+	cmd.AddCommand(lockCmd)
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unlock",
+		Short: "decrypt FOXDEN token files",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			tkn, _ := cmd.Flags().GetString("token")
+			unlockTokens(tkn)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:       "create [read|write|delete]",
+		Short:     "create FOXDEN token with given scope",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"read", "write", "delete"},
+		Run: func(cmd *cobra.Command, args []string) {
 			kfile, _ := cmd.Flags().GetString("kfile")
 			ofile, _ := cmd.Flags().GetString("ofile")
 			expires, _ := cmd.Flags().GetInt("expires")
-			if len(args) == 0 {
-				authUsage()
-			} else if args[0] == "view" {
-				inspectAllTokens(tkn)
-			} else if args[0] == "test" {
-				customClaims := make(map[string]any)
-				customClaims["user"] = "test"
-				customClaims["scope"] = "read"
-				customClaims["userid"] = 123
-				p := TokenParameters{
-					Iss: "FOXDEN-Issuer", Sub: "FOXDEN-Sub", Aud: "FOXDEN-Aud", Secret: "FOXDEN-salt",
-					CustomClaims:      customClaims,
-					ExpirationMinutes: 60,
-				}
-				generateTestToken(p)
-			} else if args[0] == "create" {
-				attr := "read"
-				if len(args) > 1 {
-					attr = args[1]
-				}
-				var token, tokenKind string
-				tokenEnv := "FOXDEN_TOKEN"
-				var err error
-				if attr == "write" {
-					tokenKind = "write"
-					tokenEnv = "FOXDEN_WRITE_TOKEN"
-				} else if attr == "delete" {
-					tokenKind = "delete"
-					tokenEnv = "FOXDEN_DELETE_TOKEN"
-				} else {
-					tokenKind = attr
-				}
-				if tokenKind == "read" {
-					fname := fmt.Sprintf("%s/.foxden.read.token", os.Getenv("HOME"))
-					if ofile != "" {
-						fname = ofile
-					}
-					err := generateToken(fname, kfile, expires)
-					exit("unable to generate user access token", err)
-					return
+			attr := "read"
+			if len(args) > 0 {
+				attr = args[0]
+			}
+			var token, tokenKind string
+			tokenEnv := "FOXDEN_TOKEN"
+			var err error
+			if attr == "write" {
+				tokenKind = "write"
+				tokenEnv = "FOXDEN_WRITE_TOKEN"
+			} else if attr == "delete" {
+				tokenKind = "delete"
+				tokenEnv = "FOXDEN_DELETE_TOKEN"
+			} else {
+				tokenKind = attr
+			}
+			if tokenKind == "read" {
+				fname := tokenFile("read")
+				if ofile != "" {
+					fname = ofile
 				}
-				token, err = requestToken(tokenKind, kfile, expires)
-				if err != nil {
-					exit("unable to get valid token", err)
+				err := generateToken(fname, kfile, expires)
+				exit("unable to generate user access token", err)
+				return
+			}
+			token, err = requestToken(tokenKind, kfile, expires)
+			if err != nil {
+				exit("unable to get valid token", err)
+			}
+			if tokenKind == "write" {
+				fname := tokenFile("write")
+				if ofile != "" {
+					fname = ofile
 				}
-				if tokenKind == "write" {
-					fname := fmt.Sprintf("%s/.foxden.write.token", os.Getenv("HOME"))
-					if ofile != "" {
-						fname = ofile
-					}
-					file, err := os.Create(fname)
-					if err != nil {
-						log.Fatal(err)
-					}
-					defer file.Close()
-					file.Write([]byte(token))
-				} else if tokenKind == "delete" {
-					fname := fmt.Sprintf("%s/.foxden.delete.token", os.Getenv("HOME"))
-					if ofile != "" {
-						fname = ofile
-					}
-					file, err := os.Create(fname)
-					if err != nil {
-						log.Fatal(err)
-					}
-					defer file.Close()
-					file.Write([]byte(token))
-				} else {
-					fmt.Println(token)
-					fmt.Printf("\nSet %s env variable with this token to re-use it in other commands\n", tokenEnv)
+				err := saveToken(fname, token)
+				exit("unable to write token file", err)
+			} else if tokenKind == "delete" {
+				fname := tokenFile("delete")
+				if ofile != "" {
+					fname = ofile
 				}
+				err := saveToken(fname, token)
+				exit("unable to write token file", err)
 			} else {
-				fmt.Println("ERROR: wrong argument(s), please see --help")
+				fmt.Println(token)
+				fmt.Printf("\nSet %s env variable with this token to re-use it in other commands\n", contextEnv(tokenEnv))
 			}
 		},
-	}
-	cmd.PersistentFlags().String("kfile", "", "Kerberos file to use")
+	})
+	cmd.PersistentFlags().String("kfile", "", "Kerberos ticket file or keytab to use")
 	cmd.PersistentFlags().String("token", "", "token file or token string")
 	cmd.PersistentFlags().String("ofile", "", "output file to write to")
 	cmd.PersistentFlags().Int("expires", 3600, "token expiration in seconds (default 1h)")
@@ -0,0 +1,348 @@
+package cmd
+
+// CHESComputing foxden tool: HTTP record/replay module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// _recordFile and _replayFile represent cassette files of --record and --replay options
+var _recordFile, _replayFile string
+
+// redacted value of sensitive headers and body attributes
+const redacted = "REDACTED"
+
+// sensitive HTTP headers which are never written into cassette
+var cassetteHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// sensitive attributes of JSON and form bodies which are never written into cassette
+var cassetteKeys = []string{"access_token", "refresh_token", "device_code", "token", "ticket", "password", "secret", "client_secret"}
+
+// CassetteBody represents body of recorded request or response, binary
+// bodies are kept in base64 encoding
+type CassetteBody struct {
+	Body     string `json:"body,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// CassetteRequest represents recorded HTTP request
+type CassetteRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	CassetteBody
+}
+
+// CassetteResponse represents recorded HTTP response
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	CassetteBody
+}
+
+// Interaction represents recorded HTTP request/response pair, network
+// errors are recorded instead of response
+type Interaction struct {
+	Request  CassetteRequest   `json:"request"`
+	Response *CassetteResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Cassette represents HTTP interactions recorded during foxden command
+type Cassette struct {
+	Command      string        `json:"command"`
+	Version      string        `json:"version"`
+	Created      string        `json:"created"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// CassetteTransport implements http.RoundTripper which either records HTTP
+// interactions made through base transport into cassette file or replays
+// them from the cassette without accessing the network
+type CassetteTransport struct {
+	Base   http.RoundTripper // base transport, nil in replay mode
+	Replay bool
+
+	mu       sync.Mutex
+	fname    string
+	cassette Cassette
+	used     []bool
+}
+
+// helper function to setup HTTP record or replay mode of shared transport
+func setupCassette(record, replay string) {
+	if record == "" && replay == "" {
+		return
+	}
+	if record != "" && replay != "" {
+		exit("unable to use --record and --replay options together", errors.New("conflicting options"))
+	}
+	if replay != "" {
+		data, err := os.ReadFile(replay)
+		exit("unable to read cassette file", err)
+		t := &CassetteTransport{Replay: true, fname: replay}
+		err = json.Unmarshal(data, &t.cassette)
+		exit(fmt.Sprintf("unable to parse cassette file %s", replay), err)
+		t.used = make([]bool, len(t.cassette.Interactions))
+		http.DefaultTransport = t
+		return
+	}
+	t := &CassetteTransport{
+		Base:  http.DefaultTransport,
+		fname: record,
+		cassette: Cassette{
+			Command: strings.Join(os.Args, " "),
+			Version: versionString(),
+			Created: time.Now().Format(time.RFC3339),
+		},
+	}
+	// write empty cassette to report unwritable file before any request is made
+	err := t.save()
+	exit("unable to write cassette file", err)
+	http.DefaultTransport = t
+}
+
+// helper function to encode body of request or response
+func cassetteBody(data []byte) CassetteBody {
+	if len(data) == 0 {
+		return CassetteBody{}
+	}
+	if !utf8.Valid(data) {
+		return CassetteBody{Body: base64.StdEncoding.EncodeToString(data), Encoding: "base64"}
+	}
+	return CassetteBody{Body: string(redactBody(data))}
+}
+
+// helper function to decode body of recorded request or response
+func (b CassetteBody) bytes() ([]byte, error) {
+	if b.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(b.Body)
+	}
+	return []byte(b.Body), nil
+}
+
+// helper function to redact sensitive attributes of JSON or form encoded
+// body, other bodies are returned as is
+func redactBody(data []byte) []byte {
+	var rec any
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return redactForm(data)
+	}
+	if !redactValue(rec) {
+		return data
+	}
+	if out, err := json.Marshal(rec); err == nil {
+		return out
+	}
+	return data
+}
+
+// helper function to redact sensitive attributes of form encoded body, e.g.
+// OAuth device code and refresh token grants
+func redactForm(data []byte) []byte {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return data
+	}
+	changed := false
+	for key := range values {
+		for _, skey := range cassetteKeys {
+			if strings.EqualFold(key, skey) {
+				values.Set(key, redacted)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return data
+	}
+	return []byte(values.Encode())
+}
+
+// helper function to redact sensitive attributes of JSON value, it returns
+// true if value was changed
+func redactValue(val any) bool {
+	changed := false
+	switch v := val.(type) {
+	case map[string]any:
+		for key, item := range v {
+			for _, skey := range cassetteKeys {
+				if strings.EqualFold(key, skey) && item != nil {
+					v[key] = redacted
+					changed = true
+				}
+			}
+			if v[key] != redacted && redactValue(item) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if redactValue(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// helper function to copy headers without sensitive ones
+func redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	out := header.Clone()
+	for _, key := range cassetteHeaders {
+		if out.Get(key) != "" {
+			out.Set(key, redacted)
+		}
+	}
+	return out
+}
+
+// helper function to read request body and restore it for subsequent use
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// helper function to write cassette into its file
+func (t *CassetteTransport) save() error {
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.fname, data, 0600)
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	creq := CassetteRequest{
+		Method:       req.Method,
+		Url:          req.URL.String(),
+		Header:       redactHeader(req.Header),
+		CassetteBody: cassetteBody(body),
+	}
+	if t.Replay {
+		return t.replay(req, creq)
+	}
+	rec := Interaction{Request: creq}
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		var data []byte
+		data, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		rec.Response = &CassetteResponse{
+			StatusCode:   resp.StatusCode,
+			Header:       redactHeader(resp.Header),
+			CassetteBody: cassetteBody(data),
+		}
+		if err != nil {
+			rec.Error = err.Error()
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, rec)
+	// cassette is saved after every interaction since commands may exit at any point
+	if serr := t.save(); serr != nil && verbose > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: unable to write cassette %s: %v\n", t.fname, serr)
+	}
+	return resp, err
+}
+
+// helper function to find recorded interaction of given request, interactions
+// are used in recorded order and the best match is chosen: the same url and
+// body, the same url, or the same path and query if service host differs
+func (t *CassetteTransport) find(req *http.Request, creq CassetteRequest) (int, bool) {
+	best, bestScore := -1, 0
+	for i, rec := range t.cassette.Interactions {
+		if t.used[i] || rec.Request.Method != creq.Method {
+			continue
+		}
+		score := 0
+		if rec.Request.Url == creq.Url {
+			score = 2
+			if rec.Request.Body == creq.Body {
+				score = 3
+			}
+		} else if rurl, err := req.URL.Parse(rec.Request.Url); err == nil && rurl.RequestURI() == req.URL.RequestURI() {
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+		if score == 3 {
+			break
+		}
+	}
+	return best, best >= 0
+}
+
+// helper function to serve response of given request from the cassette
+func (t *CassetteTransport) replay(req *http.Request, creq CassetteRequest) (*http.Response, error) {
+	t.mu.Lock()
+	idx, ok := t.find(req, creq)
+	if ok {
+		t.used[idx] = true
+	}
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for %s %s in cassette %s", creq.Method, creq.Url, t.fname)
+	}
+	rec := t.cassette.Interactions[idx]
+	if verbose > 0 {
+		fmt.Fprintf(os.Stderr, "replay %s %s (interaction %d)\n", creq.Method, creq.Url, idx+1)
+	}
+	if rec.Response == nil {
+		return nil, fmt.Errorf("replayed error: %s", rec.Error)
+	}
+	data, err := rec.Response.bytes()
+	if err != nil {
+		return nil, err
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Response.StatusCode, http.StatusText(rec.Response.StatusCode)),
+		StatusCode:    rec.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	return resp, nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+// CHESComputing foxden tool: tests of HTTP record/replay module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRedactBody tests redaction of sensitive attributes of JSON and form bodies
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		body   string
+		result string
+	}{
+		{`{"access_token":"abc","expires_in":3600}`, `{"access_token":"REDACTED","expires_in":3600}`},
+		{`{"data":[{"Refresh_Token":"abc","did":"/a=1"}],"secret":null}`, `{"data":[{"Refresh_Token":"REDACTED","did":"/a=1"}],"secret":null}`},
+		{`{"client":{"client_secret":{"value":"abc"}}}`, `{"client":{"client_secret":"REDACTED"}}`},
+		// bodies without sensitive attributes are kept as is
+		{`{"did": "/a=1", "btr": "abc"}`, `{"did": "/a=1", "btr": "abc"}`},
+		{`[1, 2, 3]`, `[1, 2, 3]`},
+		{"grant_type=urn:ietf:params:oauth:grant-type:device_code&device_code=abc&client_id=foxden",
+			"client_id=foxden&device_code=REDACTED&grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code"},
+		{"grant_type=refresh_token&REFRESH_TOKEN=abc", "REFRESH_TOKEN=REDACTED&grant_type=refresh_token"},
+		{"client_id=foxden&scope=read", "client_id=foxden&scope=read"},
+		{"plain text body", "plain text body"},
+		{"bad%zzform&password=abc", "bad%zzform&password=abc"},
+	}
+	for _, tc := range tests {
+		if out := string(redactBody([]byte(tc.body))); out != tc.result {
+			t.Errorf("%s: got %s, expected %s", tc.body, out, tc.result)
+		}
+	}
+}
+
+// helper function to send request through given transport and return its response body
+func cassetteRequest(t *testing.T, rt http.RoundTripper, method, rurl, body string) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest(method, rurl, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer abc")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%d %s", resp.StatusCode, data), nil
+}
+
+// TestCassetteReplay tests that recorded interactions are redacted and
+// replayed in recorded order with the best matching request
+func TestCassetteReplay(t *testing.T) {
+	var nreq int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nreq++
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0xfe, byte(nreq)})
+			return
+		}
+		w.Header().Set("Set-Cookie", "session=abc")
+		fmt.Fprintf(w, `{"access_token":"xyz","body":%q,"n":%d,"path":%q}`, body, nreq, r.URL.RequestURI())
+	}))
+	defer ts.Close()
+
+	fname := filepath.Join(t.TempDir(), "cassette.json")
+	rec := &CassetteTransport{Base: http.DefaultTransport, fname: fname}
+	var recorded []string
+	for _, r := range [][]string{
+		{"GET", "/search?q=1", ""},
+		{"GET", "/search?q=1", ""},
+		{"POST", "/search", `{"query":"a"}`},
+		{"POST", "/search", `{"query":"b"}`},
+		{"GET", "/binary", ""},
+	} {
+		out, err := cassetteRequest(t, rec, r[0], ts.URL+r[1], r[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, out)
+	}
+	// sensitive headers and attributes are not written into cassette
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, val := range []string{"Bearer abc", "session=abc", "xyz"} {
+		if strings.Contains(string(data), val) {
+			t.Errorf("cassette contains sensitive value %s", val)
+		}
+	}
+
+	replay := &CassetteTransport{Replay: true, fname: fname}
+	if err := json.Unmarshal(data, &replay.cassette); err != nil {
+		t.Fatal(err)
+	}
+	replay.used = make([]bool, len(replay.cassette.Interactions))
+	redact := func(s string) string { return strings.Replace(s, `"xyz"`, `"REDACTED"`, 1) }
+	// requests with matching body are preferred, the same requests are served
+	// in recorded order and another service host matches by path and query
+	other := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	tests := []struct {
+		method, url, body, result string
+	}{
+		{"POST", ts.URL + "/search", `{"query":"b"}`, redact(recorded[3])},
+		{"POST", ts.URL + "/search", `{"query":"c"}`, redact(recorded[2])},
+		{"GET", other + "/search?q=1", "", redact(recorded[0])},
+		{"GET", ts.URL + "/search?q=1", "", redact(recorded[1])},
+		{"GET", ts.URL + "/binary", "", recorded[4]},
+	}
+	for _, tc := range tests {
+		out, err := cassetteRequest(t, replay, tc.method, tc.url, tc.body)
+		if err != nil || out != tc.result {
+			t.Errorf("%s %s %s: got %s, expected %s, error %v", tc.method, tc.url, tc.body, out, tc.result, err)
+		}
+	}
+	// all interactions are used and unknown requests are not sent to network
+	for _, url := range []string{ts.URL + "/search?q=1", ts.URL + "/search?q=2"} {
+		if _, err := cassetteRequest(t, replay, "GET", url, ""); err == nil {
+			t.Errorf("%s: request is not recorded", url)
+		}
+	}
+	if nreq != 5 {
+		t.Errorf("wrong number of requests to server %d", nreq)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+// CHESComputing foxden tool: FOXDEN client module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	client "github.com/CHESSComputing/gotools/foxden/client"
+)
+
+// _client represents client of FOXDEN services used by foxden commands
+var _client *client.Client
+
+// _trustedToken keeps token of trusted client obtained from Authz service
+var _trustedToken string
+
+// helper function to provide tokens to FOXDEN client, tokens are obtained in
+// the same way as for all other foxden commands
+func cliToken(ctx context.Context, scope client.Scope) (string, error) {
+	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
+		if _trustedToken == "" || tokenExpiring(_trustedToken, tokenMargin()) {
+			token, err := trustedUser()
+			if err != nil {
+				return "", fmt.Errorf("unable to obtain trusted client token: %w", err)
+			}
+			if token == "" {
+				return "", errors.New("empty trusted client token from Authz service")
+			}
+			_trustedToken = token
+		}
+		return _trustedToken, nil
+	}
+	var token string
+	var err error
+	switch scope {
+	case client.WriteScope:
+		token, err = writeAccessToken()
+	case client.DeleteScope:
+		token, err = deleteAccessToken()
+	default:
+		token, err = accessToken()
+	}
+	if token != "" {
+		// keep scope of the token to refer to its environment in traced requests
+		_tokenScopes.LoadOrStore(token, string(scope))
+	}
+	return token, err
+}
+
+// helper function to get FOXDEN client, it should be used after FOXDEN
+// configuration is loaded
+func foxdenClient() *client.Client {
+	if _client != nil {
+		return _client
+	}
+	s := srvConfig.Config.Services
+	urls := client.URLs{
+		Frontend:          s.FrontendURL,
+		MetaData:          s.MetaDataURL,
+		UserMetaData:      s.UserMetaDataURL,
+		DataBookkeeping:   s.DataBookkeepingURL,
+		SpecScans:         s.SpecScansURL,
+		DataManagement:    s.DataManagementURL,
+		MLHub:             s.MLHubURL,
+		DOIService:        s.DOIServiceURL,
+		FabricCatalog:     s.FabricCatalogURL,
+		FabricDataService: s.FabricDataServiceURL,
+		Discovery:         s.DiscoveryURL,
+		Authz:             s.AuthzURL,
+	}
+	_client = client.New(urls, client.TokenFunc(cliToken))
+	_client.Trace = func(method, rurl string) {
+		if verbose > 0 {
+			fmt.Fprintln(os.Stderr, "HTTP", method, rurl)
+		}
+	}
+	return _client
+}
+
+// helper function to get client of meta-data service with given url, the url
+// may point to any FOXDEN service which provides MetaData search API
+func metaClient(murl string) *client.MetaDataClient {
+	c := *foxdenClient()
+	c.URLs.MetaData = murl
+	return c.MetaData()
+}
+
+// helper function to get service response body from client response or error,
+// commands report failed service responses the same way as successful ones
+func responseBody(resp *client.Response, err error) ([]byte, error) {
+	var cerr *client.Error
+	if errors.As(err, &cerr) && len(cerr.Body) > 0 {
+		return cerr.Body, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,304 @@
+package cmd
+
+// CHESComputing foxden tool: shell completion module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	utils "github.com/CHESSComputing/golib/utils"
+	"github.com/spf13/cobra"
+)
+
+// life time of completion cache entries
+var (
+	didsCacheTTL    = 10 * time.Minute
+	schemasCacheTTL = 24 * time.Hour
+	keysCacheTTL    = 24 * time.Hour
+	bucketsCacheTTL = time.Hour
+)
+
+// number of recent records used to complete dids
+var completionDids = 100
+
+// helper function to return location of completion cache
+func completionCacheDir() string {
+	if dir := os.Getenv("FOXDEN_CACHE"); dir != "" {
+		return dir
+	}
+	if _currentContext != "" {
+		return filepath.Join(os.Getenv("HOME"), ".foxden.cache", _currentContext)
+	}
+	return filepath.Join(os.Getenv("HOME"), ".foxden.cache")
+}
+
+// helper function to get cached completion values, values are re-fetched
+// when cache is older than given ttl and stale cache is used if fetch fails
+func cachedValues(name string, ttl time.Duration, fetch func() ([]string, error)) []string {
+	fname := filepath.Join(completionCacheDir(), name)
+	var cached []string
+	if data, err := os.ReadFile(fname); err == nil {
+		cached = strings.Split(strings.TrimSpace(string(data)), "\n")
+		if info, err := os.Stat(fname); err == nil && time.Since(info.ModTime()) < ttl {
+			return cached
+		}
+	}
+	values, err := fetch()
+	if err != nil || len(values) == 0 {
+		return cached
+	}
+	if err := os.MkdirAll(completionCacheDir(), 0700); err == nil {
+		os.WriteFile(fname, []byte(strings.Join(values, "\n")+"\n"), 0600)
+	}
+	return values
+}
+
+// helper function to check read token for completion requests, completion
+// should never ask for credentials and therefore only existing token is used
+func completionToken() bool {
+	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
+		return true
+	}
+	_noPrompt = true
+	_, val := envToken(tokenSources()[0])
+	token := readToken(val)
+	if token == "" {
+		token = readToken(tokenFile("read"))
+	}
+	return token != ""
+}
+
+// helper function to fetch dids of recent meta-data records
+func fetchDids() ([]string, error) {
+	if !completionToken() {
+		return nil, fmt.Errorf("no read token")
+	}
+	rurl := srvConfig.Config.MetaDataURL
+	records, _, err := getMeta(rurl, "", "{}", []string{"date"}, -1, 0, completionDids)
+	if err != nil {
+		return nil, err
+	}
+	var dids []string
+	for _, rec := range records {
+		if did, ok := rec["did"]; ok {
+			dids = append(dids, fmt.Sprintf("%v", did))
+		}
+	}
+	return dids, nil
+}
+
+// helper function to fetch names of FOXDEN schemas
+func fetchSchemas() ([]string, error) {
+	if !completionToken() {
+		return nil, fmt.Errorf("no read token")
+	}
+	records, err := foxdenClient().Schemas(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var schemas []string
+	for _, rec := range records {
+		for name := range rec {
+			schemas = append(schemas, name)
+		}
+	}
+	schemas = utils.List2Set(schemas)
+	sort.Strings(schemas)
+	return schemas, nil
+}
+
+// helper function to fetch names of storages or buckets of given storage
+func fetchBuckets(storage string) ([]string, error) {
+	if !completionToken() {
+		return nil, fmt.Errorf("no read token")
+	}
+	results, err := foxdenClient().DataManagement().Storage(context.Background(), storage)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	var items []any
+	switch v := results.Data.(type) {
+	case []any:
+		items = v
+	case map[string]any:
+		items = append(items, v)
+	}
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			names = append(names, v)
+		case map[string]any:
+			for _, key := range []string{"name", "Name", "bucket", "Bucket"} {
+				if name, ok := v[key]; ok {
+					names = append(names, fmt.Sprintf("%v", name))
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// helper function to select values matching given prefix
+func completionMatch(values []string, toComplete string) []string {
+	var out []string
+	for _, val := range values {
+		if val != "" && strings.HasPrefix(val, toComplete) {
+			out = append(out, val)
+		}
+	}
+	return out
+}
+
+// helper function to complete dids of recent meta-data records
+func completeDids(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dids := cachedValues("dids", didsCacheTTL, fetchDids)
+	return completionMatch(dids, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// helper function to complete single did argument
+func completeDid(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeDids(cmd, args, toComplete)
+}
+
+// helper function to complete dids of records kept in local trash
+func completeTrashDids(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	records, _ := trashRecords()
+	var dids []string
+	for _, rec := range records {
+		dids = append(dids, rec.Did)
+	}
+	return completionMatch(utils.List2Set(dids), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// helper function to complete FOXDEN schema names
+func completeSchemas(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	schemas := cachedValues("schemas", schemasCacheTTL, fetchSchemas)
+	return completionMatch(schemas, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// helper function to complete query keys, keys are completed without trailing
+// space to allow key:value and key=value forms
+func completeSearchKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	keys := cachedValues("keys", keysCacheTTL, func() ([]string, error) {
+		if !completionToken() {
+			return nil, fmt.Errorf("no read token")
+		}
+		return searchKeys()
+	})
+	return completionMatch(keys, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// helper function to complete query argument
+func completeQuery(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeSearchKeys(cmd, args, toComplete)
+}
+
+// helper function to complete storage/bucket names of s3 storage
+func completeBuckets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	if idx := strings.Index(toComplete, "/"); idx > 0 {
+		storage := toComplete[:idx]
+		buckets := cachedValues("buckets."+storage, bucketsCacheTTL, func() ([]string, error) {
+			return fetchBuckets(storage)
+		})
+		var out []string
+		for _, bucket := range buckets {
+			out = append(out, storage+"/"+bucket)
+		}
+		return completionMatch(out, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	storages := cachedValues("storages", bucketsCacheTTL, func() ([]string, error) {
+		return fetchBuckets("")
+	})
+	var out []string
+	for _, storage := range storages {
+		out = append(out, storage+"/")
+	}
+	return completionMatch(out, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// helper function to provide usage of completion option
+func completionUsage() {
+	fmt.Println("foxden completion <bash|zsh|fish>")
+	fmt.Println("completion suggests sub-commands, flags, dids of recent records, schema names,")
+	fmt.Println("query keys and s3 buckets, suggestions are cached in $HOME/.foxden.cache or FOXDEN_CACHE")
+	fmt.Println("\nExamples:")
+	fmt.Println("\n# load completion into current bash session:")
+	fmt.Println("source <(foxden completion bash)")
+	fmt.Println("\n# install bash completion permanently:")
+	fmt.Println("foxden completion bash > /etc/bash_completion.d/foxden")
+	fmt.Println("\n# install zsh completion, make sure that compinit is enabled in ~/.zshrc:")
+	fmt.Println("foxden completion zsh > \"${fpath[1]}/_foxden\"")
+	fmt.Println("\n# install fish completion:")
+	fmt.Println("foxden completion fish > ~/.config/fish/completions/foxden.fish")
+}
+
+func completionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion",
+		Short: "foxden completion commands",
+		Long:  "foxden completion commands to generate shell completion scripts\n" + doc,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				completionUsage()
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
+			}
+		},
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "bash",
+		Short: "generate bash completion script",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rootCmd.GenBashCompletionV2(os.Stdout, true)
+			exit("unable to generate bash completion", err)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "zsh",
+		Short: "generate zsh completion script",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rootCmd.GenZshCompletion(os.Stdout)
+			exit("unable to generate zsh completion", err)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "fish",
+		Short: "generate fish completion script",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rootCmd.GenFishCompletion(os.Stdout, true)
+			exit("unable to generate fish completion", err)
+		},
+	})
+	cmd.SetUsageFunc(func(*cobra.Command) error {
+		completionUsage()
+		return nil
+	})
+	return cmd
+}
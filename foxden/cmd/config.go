@@ -13,11 +13,37 @@ import (
 
 // helper function to provide usage of config option
 func configUsage() {
-	fmt.Println("foxden config <conifg.yaml>")
+	fmt.Println("foxden config [get-contexts|use-context|current-context] [options]")
+	fmt.Println("options: --context=<name> to use given configuration context")
+	fmt.Println("\nExamples:")
+	fmt.Println("\n# print FOXDEN configuration:")
+	fmt.Println("foxden config")
+	fmt.Println("\n# list contexts defined in Contexts section of FOXDEN configuration:")
+	fmt.Println("foxden config get-contexts")
+	fmt.Println("\n# use dev context in all subsequent foxden commands:")
+	fmt.Println("foxden config use-context dev")
+	fmt.Println("\n# print name of current context:")
+	fmt.Println("foxden config current-context")
+	fmt.Println("\n# use prod context for single command:")
+	fmt.Println("foxden search --context=prod {}")
+	fmt.Println("\nContexts are defined in FOXDEN configuration, e.g.")
+	fmt.Println("CurrentContext: prod")
+	fmt.Println("Contexts:")
+	fmt.Println("  dev:")
+	fmt.Println("    Services:")
+	fmt.Println("      FrontendURL: https://foxden-dev.host")
+	fmt.Println("      MetaDataURL: https://foxden-dev.host/meta")
+	fmt.Println("    Authz:")
+	fmt.Println("      ClientID: client-dev")
+	fmt.Println("    TokenDir: ~/.foxden.dev")
+	fmt.Println("\nToken files of context are stored in TokenDir or as $HOME/.foxden.<context>.<scope>.token")
 }
 
 func printConfig(args []string) {
 	fmt.Printf("Configuration file: %s\n", cfgFile)
+	if _currentContext != "" {
+		fmt.Printf("Configuration context: %s\n", _currentContext)
+	}
 	fmt.Println(srvConfig.Config.String())
 }
 
@@ -31,6 +57,37 @@ func configCommand() *cobra.Command {
 			printConfig(args)
 		},
 	}
+	getCmd := &cobra.Command{
+		Use:         "get-contexts",
+		Short:       "list configuration contexts",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{"context": "optional"},
+		Run: func(cmd *cobra.Command, args []string) {
+			getContexts()
+		},
+	}
+	useCmd := &cobra.Command{
+		Use:               "use-context <name>",
+		Short:             "set default configuration context",
+		Args:              cobra.ExactArgs(1),
+		Annotations:       map[string]string{"context": "optional"},
+		ValidArgsFunction: completeContexts,
+		Run: func(cmd *cobra.Command, args []string) {
+			useContext(args[0])
+		},
+	}
+	currentCmd := &cobra.Command{
+		Use:         "current-context",
+		Short:       "print current configuration context",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{"context": "optional"},
+		Run: func(cmd *cobra.Command, args []string) {
+			currentContext()
+		},
+	}
+	cmd.AddCommand(getCmd)
+	cmd.AddCommand(useCmd)
+	cmd.AddCommand(currentCmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		configUsage()
 		return nil
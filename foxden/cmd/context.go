@@ -0,0 +1,224 @@
+package cmd
+
+// CHESComputing foxden tool: configuration context module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// _context represents name of configuration context provided via --context option
+var _context string
+
+// _currentContext represents configuration context used by foxden command
+var _currentContext string
+
+// ConfigContext represents named set of FOXDEN settings which overwrite
+// settings of FOXDEN configuration, e.g. to switch between dev and
+// production services
+type ConfigContext struct {
+	Services map[string]string `yaml:"Services"` // service urls, e.g. MetaDataURL: https://...
+	Authz    struct {
+		ClientID string `yaml:"ClientID"`
+	} `yaml:"Authz"`
+	DID struct {
+		Attributes string `yaml:"Attributes"`
+		Separator  string `yaml:"Separator"`
+		Divider    string `yaml:"Divider"`
+	} `yaml:"DID"`
+	TokenDir string `yaml:"TokenDir"` // directory of token files of the context
+}
+
+// ConfigContexts represents contexts section of FOXDEN configuration
+type ConfigContexts struct {
+	CurrentContext string                   `yaml:"CurrentContext"`
+	Contexts       map[string]ConfigContext `yaml:"Contexts"`
+}
+
+// helper function to get file which keeps context chosen by use-context
+// command, it is kept outside of configuration which can be shared by users
+func contextFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".foxden.context")
+}
+
+// helper function to read contexts of given FOXDEN configuration file
+func readContexts(fname string) (ConfigContexts, error) {
+	var rec ConfigContexts
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return rec, err
+	}
+	err = yaml.Unmarshal(data, &rec)
+	return rec, err
+}
+
+// helper function to get sorted names of contexts
+func contextNames(contexts ConfigContexts) []string {
+	var names []string
+	for name := range contexts.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// helper function to choose context name, it is defined (in order of
+// precedence) by --context option, FOXDEN_CONTEXT environment, use-context
+// command or CurrentContext of FOXDEN configuration
+func chooseContext(contexts ConfigContexts) string {
+	if _context != "" {
+		return _context
+	}
+	if val := os.Getenv("FOXDEN_CONTEXT"); val != "" {
+		return val
+	}
+	if data, err := os.ReadFile(contextFile()); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			return name
+		}
+	}
+	return contexts.CurrentContext
+}
+
+// helper function to get pointers to service urls of FOXDEN configuration
+func serviceFields(s *srvConfig.Services) map[string]*string {
+	return map[string]*string{
+		"AuthzURL":             &s.AuthzURL,
+		"DOIServiceURL":        &s.DOIServiceURL,
+		"DataBookkeepingURL":   &s.DataBookkeepingURL,
+		"DataManagementURL":    &s.DataManagementURL,
+		"DiscoveryURL":         &s.DiscoveryURL,
+		"FabricCatalogURL":     &s.FabricCatalogURL,
+		"FabricDataServiceURL": &s.FabricDataServiceURL,
+		"FrontendURL":          &s.FrontendURL,
+		"MLHubURL":             &s.MLHubURL,
+		"MetaDataURL":          &s.MetaDataURL,
+		"SpecScansURL":         &s.SpecScansURL,
+		"UserMetaDataURL":      &s.UserMetaDataURL,
+	}
+}
+
+// helper function to apply context of given FOXDEN configuration file to
+// loaded configuration
+func applyContext(fname string) error {
+	contexts, err := readContexts(fname)
+	if err != nil {
+		return err
+	}
+	name := chooseContext(contexts)
+	if name == "" {
+		return nil
+	}
+	ctx, ok := contexts.Contexts[name]
+	if !ok {
+		return fmt.Errorf("unknown context %q, available contexts: %v", name, contextNames(contexts))
+	}
+	fields := serviceFields(&srvConfig.Config.Services)
+	for key, val := range ctx.Services {
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown service %q in context %q", key, name)
+		}
+		*field = val
+	}
+	if ctx.Authz.ClientID != "" {
+		srvConfig.Config.Authz.ClientID = ctx.Authz.ClientID
+	}
+	if ctx.DID.Attributes != "" {
+		srvConfig.Config.DID.Attributes = ctx.DID.Attributes
+	}
+	if ctx.DID.Separator != "" {
+		srvConfig.Config.DID.Separator = ctx.DID.Separator
+	}
+	if ctx.DID.Divider != "" {
+		srvConfig.Config.DID.Divider = ctx.DID.Divider
+	}
+	_currentContext = name
+	return nil
+}
+
+// helper function to check if invoked command can run with unknown context,
+// e.g. use-context command should be able to fix stale context
+func contextOptional() bool {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	return err == nil && cmd.Annotations["context"] == "optional"
+}
+
+// helper function to get directory of token files of current context
+func contextTokenDir() string {
+	if _currentContext == "" {
+		return ""
+	}
+	contexts, err := readContexts(cfgFile)
+	if err != nil {
+		return ""
+	}
+	dir := contexts.Contexts[_currentContext].TokenDir
+	if strings.HasPrefix(dir, "~/") {
+		dir = filepath.Join(os.Getenv("HOME"), dir[2:])
+	}
+	return dir
+}
+
+// helper function to list contexts of FOXDEN configuration
+func getContexts() {
+	contexts, err := readContexts(cfgFile)
+	exit(fmt.Sprintf("unable to read FOXDEN configuration %s", cfgFile), err)
+	names := contextNames(contexts)
+	if len(names) == 0 {
+		fmt.Printf("No contexts defined in %s\n", cfgFile)
+		return
+	}
+	current := chooseContext(contexts)
+	for _, name := range names {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		ctx := contexts.Contexts[name]
+		fmt.Printf("%s %-16s %s\n", marker, name, ctx.Services["FrontendURL"])
+	}
+}
+
+// helper function to set default context of foxden commands
+func useContext(name string) {
+	contexts, err := readContexts(cfgFile)
+	exit(fmt.Sprintf("unable to read FOXDEN configuration %s", cfgFile), err)
+	if _, ok := contexts.Contexts[name]; !ok {
+		exit(fmt.Sprintf("unknown context, available contexts: %v", contextNames(contexts)), errors.New(name))
+	}
+	err = os.WriteFile(contextFile(), []byte(name+"\n"), 0600)
+	exit("unable to write context file", err)
+	fmt.Printf("Switched to context %q\n", name)
+}
+
+// helper function to print name of current context
+func currentContext() {
+	contexts, err := readContexts(cfgFile)
+	exit(fmt.Sprintf("unable to read FOXDEN configuration %s", cfgFile), err)
+	name := chooseContext(contexts)
+	if name == "" {
+		fmt.Println("No context is set")
+		return
+	}
+	fmt.Println(name)
+}
+
+// helper function to complete context names
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	contexts, err := readContexts(cfgFile)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completionMatch(contextNames(contexts), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
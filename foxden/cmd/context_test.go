@@ -0,0 +1,159 @@
+package cmd
+
+// CHESComputing foxden tool: tests of configuration context module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// testContexts represents FOXDEN configuration with contexts used by tests
+const testContexts = `
+CurrentContext: prod
+Contexts:
+  prod:
+    Services:
+      MetaDataURL: https://foxden.host/meta
+  dev:
+    Services:
+      MetaDataURL: https://foxden-dev.host/meta
+    Authz:
+      ClientID: client-dev
+    DID:
+      Attributes: "beamline,btr"
+      Separator: ":"
+    TokenDir: ~/.foxden.dev
+  broken:
+    Services:
+      UnknownURL: https://foxden.host/unknown
+`
+
+// helper function to setup FOXDEN configuration with given content in
+// temporary HOME area, context selection is reset and restored on cleanup
+func contextSetup(t *testing.T, data string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("FOXDEN_CONTEXT", "")
+	fname := writeTestFile(t, filepath.Join(home, "foxden.yaml"), data)
+	config, file, ctx, current := srvConfig.Config, cfgFile, _context, _currentContext
+	t.Cleanup(func() {
+		srvConfig.Config, cfgFile, _context, _currentContext = config, file, ctx, current
+	})
+	srvConfig.Config = &srvConfig.SrvConfig{}
+	srvConfig.Config.Services.MetaDataURL = "https://default.host/meta"
+	srvConfig.Config.Authz.ClientID = "client"
+	cfgFile, _context, _currentContext = fname, "", ""
+	return fname
+}
+
+// TestApplyContext tests selection of context and overwrite of configuration settings
+func TestApplyContext(t *testing.T) {
+	tests := []struct {
+		option  string
+		env     string
+		useCtx  string
+		context string
+		murl    string
+	}{
+		{"", "", "", "prod", "https://foxden.host/meta"},
+		{"", "", "dev", "dev", "https://foxden-dev.host/meta"},
+		{"", "dev", "prod", "dev", "https://foxden-dev.host/meta"},
+		{"prod", "dev", "dev", "prod", "https://foxden.host/meta"},
+	}
+	for _, tc := range tests {
+		fname := contextSetup(t, testContexts)
+		_context = tc.option
+		t.Setenv("FOXDEN_CONTEXT", tc.env)
+		if tc.useCtx != "" {
+			writeTestFile(t, contextFile(), tc.useCtx+"\n")
+		}
+		if err := applyContext(fname); err != nil {
+			t.Errorf("%+v: %v", tc, err)
+			continue
+		}
+		if _currentContext != tc.context || srvConfig.Config.Services.MetaDataURL != tc.murl {
+			t.Errorf("%+v: wrong context %s url %s", tc, _currentContext, srvConfig.Config.Services.MetaDataURL)
+		}
+	}
+
+	fname := contextSetup(t, testContexts)
+	_context = "dev"
+	if err := applyContext(fname); err != nil {
+		t.Fatal(err)
+	}
+	if c := srvConfig.Config; c.Authz.ClientID != "client-dev" || c.DID.Attributes != "beamline,btr" || c.DID.Separator != ":" {
+		t.Errorf("wrong settings of dev context %+v %+v", c.Authz, c.DID)
+	}
+
+	for _, name := range []string{"unknown", "broken"} {
+		fname := contextSetup(t, testContexts)
+		_context = name
+		if err := applyContext(fname); err == nil || _currentContext != "" {
+			t.Errorf("%s context was applied", name)
+		}
+	}
+
+	// configuration without contexts is used as-is
+	fname = contextSetup(t, "MetaDataURL: https://default.host/meta\n")
+	if err := applyContext(fname); err != nil || _currentContext != "" || srvConfig.Config.Services.MetaDataURL != "https://default.host/meta" {
+		t.Errorf("wrong configuration without contexts, context %q, error %v", _currentContext, err)
+	}
+}
+
+// TestTokenFile tests that token files are namespaced by context
+func TestTokenFile(t *testing.T) {
+	tests := []struct {
+		context string
+		tfile   string
+	}{
+		{"", ".foxden.write.token"},
+		{"prod", ".foxden.prod.write.token"},
+		{"dev", ".foxden.dev/.foxden.write.token"},
+	}
+	for _, tc := range tests {
+		contextSetup(t, testContexts)
+		_currentContext = tc.context
+		if tfile, expect := tokenFile("write"), filepath.Join(os.Getenv("HOME"), tc.tfile); tfile != expect {
+			t.Errorf("%q: wrong token file %s, expected %s", tc.context, tfile, expect)
+		}
+	}
+}
+
+// TestEnvToken tests that token environment variables are namespaced by context
+func TestEnvToken(t *testing.T) {
+	src := tokenSources()[1]
+	tests := []struct {
+		context string
+		global  string
+		local   string
+		env     string
+		val     string
+	}{
+		{"", "global", "", "FOXDEN_WRITE_TOKEN", "global"},
+		{"", "", "", "FOXDEN_WRITE_TOKEN", ""},
+		{"dev", "global", "local", "FOXDEN_DEV_WRITE_TOKEN", "local"},
+		{"dev", "", "local", "FOXDEN_DEV_WRITE_TOKEN", "local"},
+		// global token is used along with warning
+		{"dev", "global", "", "FOXDEN_WRITE_TOKEN", "global"},
+		{"dev", "", "", "FOXDEN_DEV_WRITE_TOKEN", ""},
+	}
+	for _, tc := range tests {
+		contextSetup(t, testContexts)
+		_currentContext = tc.context
+		t.Setenv("FOXDEN_WRITE_TOKEN", tc.global)
+		t.Setenv("FOXDEN_DEV_WRITE_TOKEN", tc.local)
+		if env, val := envToken(src); env != tc.env || val != tc.val {
+			t.Errorf("%+v: wrong token env %s=%s", tc, env, val)
+		}
+	}
+	_currentContext = "ml-prod.2"
+	if env := contextEnv("FOXDEN_TOKEN"); env != "FOXDEN_ML_PROD_2_TOKEN" {
+		t.Errorf("wrong context token env %s", env)
+	}
+}
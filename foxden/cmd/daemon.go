@@ -0,0 +1,286 @@
+package cmd
+
+// CHESComputing foxden tool: token daemon module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DaemonOptions represents options of token daemon
+type DaemonOptions struct {
+	Keytab        string        // keytab file, default kerberos ticket file is used if not provided
+	Scopes        []string      // token scopes to keep fresh
+	RefreshBefore time.Duration // renew tokens which expire within this duration
+	Interval      time.Duration // interval between token checks
+	Expires       int           // lifetime of renewed tokens in seconds
+	MaxFailures   int           // number of consecutive renewal failures before daemon exits
+	StatusFile    string        // file to write daemon status
+	Socket        string        // unix socket to serve daemon status
+}
+
+// DaemonScope represents status of token of given scope kept by daemon
+type DaemonScope struct {
+	Scope       string     `json:"scope"`
+	File        string     `json:"file"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastRenewal *time.Time `json:"last_renewal,omitempty"`
+	Renewals    int        `json:"renewals"`
+	Failures    int        `json:"failures"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// DaemonStatus represents status of token daemon
+type DaemonStatus struct {
+	Pid     int           `json:"pid"`
+	Started time.Time     `json:"started"`
+	Updated time.Time     `json:"updated"`
+	Keytab  string        `json:"keytab,omitempty"`
+	Status  string        `json:"status"`
+	Scopes  []DaemonScope `json:"scopes"`
+}
+
+// tokenDaemon keeps token files of given scopes fresh
+type tokenDaemon struct {
+	opts   DaemonOptions
+	mu     sync.Mutex
+	status DaemonStatus
+}
+
+// helper function to get default status file of token daemon
+func daemonStatusFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".foxden.daemon.json")
+}
+
+// helper function to obtain kerberos ticket of the daemon, unlike
+// getKerberosTicket it returns errors instead of exiting since renewal
+// failures are handled by the daemon
+func daemonTicket(kfile string) (string, []byte, error) {
+	if kt, ok := isKeytab(kfile); ok {
+		return keytabTicket(kt)
+	}
+	creds, err := kuserFromCache(kfile)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to get valid kerberos credentials from %s: %w", kfile, err)
+	}
+	ticket, err := os.ReadFile(kfile)
+	if err != nil {
+		return "", nil, err
+	}
+	return creds.UserName(), ticket, nil
+}
+
+// helper function to renew token of given scope if it expires soon
+func (d *tokenDaemon) check(idx int) {
+	d.mu.Lock()
+	st := d.status.Scopes[idx]
+	d.mu.Unlock()
+	token := readToken(st.File)
+	if token != "" && !tokenExpiring(token, d.opts.RefreshBefore) {
+		if exp, _, err := tokenExpiration(token); err == nil && !exp.IsZero() {
+			st.ExpiresAt = &exp
+		}
+		d.update(idx, st)
+		return
+	}
+	err := d.renew(&st)
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+		log.Printf("ERROR: unable to renew %s token %s (failure %d of %d): %v", st.Scope, st.File, st.Failures, d.opts.MaxFailures, err)
+	}
+	d.update(idx, st)
+}
+
+// helper function to request new token of given scope and write it into token file
+func (d *tokenDaemon) renew(st *DaemonScope) error {
+	kfile := d.opts.Keytab
+	if kfile == "" {
+		kfile = keyFile()
+	}
+	user, ticket, err := daemonTicket(kfile)
+	if err != nil {
+		return err
+	}
+	token, err := authorizeTicket(st.Scope, user, ticket, d.opts.Expires)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("empty token from Authz service")
+	}
+	if err := checkTokenScope(token, st.Scope); err != nil {
+		return err
+	}
+	if err := saveToken(st.File, token); err != nil {
+		return err
+	}
+	now := time.Now()
+	st.LastRenewal = &now
+	st.Renewals++
+	st.Failures = 0
+	st.LastError = ""
+	st.ExpiresAt = nil
+	if exp, _, err := tokenExpiration(token); err == nil && !exp.IsZero() {
+		st.ExpiresAt = &exp
+		log.Printf("renewed %s token %s for user %s, expires at %s", st.Scope, st.File, user, exp.Format(time.RFC3339))
+	} else {
+		log.Printf("renewed %s token %s for user %s", st.Scope, st.File, user)
+	}
+	return nil
+}
+
+// helper function to update status of given scope
+func (d *tokenDaemon) update(idx int, st DaemonScope) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Scopes[idx] = st
+	d.status.Updated = time.Now()
+}
+
+// helper function to get copy of daemon status
+func (d *tokenDaemon) snapshot() DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := d.status
+	status.Scopes = append([]DaemonScope(nil), d.status.Scopes...)
+	return status
+}
+
+// helper function to write daemon status into status file
+func (d *tokenDaemon) writeStatus() {
+	if d.opts.StatusFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(d.snapshot(), "", "  ")
+	if err == nil {
+		tmp := d.opts.StatusFile + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, d.opts.StatusFile)
+		}
+	}
+	if err != nil {
+		log.Printf("WARNING: unable to write status file %s: %v", d.opts.StatusFile, err)
+	}
+}
+
+// helper function to serve daemon status on unix socket, e.g.
+// curl --unix-socket ~/.foxden.daemon.sock http://localhost/status
+func (d *tokenDaemon) serve(ctx context.Context) error {
+	if d.opts.Socket == "" {
+		return nil
+	}
+	// remove stale socket of previous daemon
+	os.Remove(d.opts.Socket)
+	listener, err := net.Listen("unix", d.opts.Socket)
+	if err != nil {
+		return err
+	}
+	os.Chmod(d.opts.Socket, 0600)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.snapshot())
+	})
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go srv.Serve(listener)
+	return nil
+}
+
+// helper function to check if any scope reached maximum number of failures
+func (d *tokenDaemon) failed() (DaemonScope, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, st := range d.status.Scopes {
+		if d.opts.MaxFailures > 0 && st.Failures >= d.opts.MaxFailures {
+			return st, true
+		}
+	}
+	return DaemonScope{}, false
+}
+
+// helper function to run token daemon which keeps token files of given
+// scopes fresh until it is interrupted or renewal repeatedly fails
+func runTokenDaemon(opts DaemonOptions) {
+	if len(opts.Scopes) == 0 {
+		exit("no token scopes to renew", errors.New("empty scopes"))
+	}
+	for _, scope := range opts.Scopes {
+		if _, ok := scopeRank[scope]; !ok {
+			exit("unsupported token scope, please use read, write or delete", fmt.Errorf("scope %q", scope))
+		}
+	}
+	if opts.Keytab != "" {
+		if _, ok := isKeytab(opts.Keytab); !ok {
+			exit(fmt.Sprintf("unable to use keytab %s", opts.Keytab), errors.New("not a valid keytab file"))
+		}
+	}
+	if opts.Interval <= 0 || opts.Interval > opts.RefreshBefore/2 {
+		// check tokens at least twice within refresh window
+		opts.Interval = opts.RefreshBefore / 2
+	}
+	if opts.Interval < time.Second {
+		opts.Interval = time.Second
+	}
+	d := &tokenDaemon{opts: opts}
+	d.status = DaemonStatus{Pid: os.Getpid(), Started: time.Now(), Keytab: opts.Keytab, Status: "running"}
+	for _, scope := range opts.Scopes {
+		d.status.Scopes = append(d.status.Scopes, DaemonScope{Scope: scope, File: tokenFile(scope)})
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := d.serve(ctx)
+	exit(fmt.Sprintf("unable to listen on unix socket %s", opts.Socket), err)
+	if opts.Socket != "" {
+		defer os.Remove(opts.Socket)
+	}
+	log.Printf("foxden token daemon started, pid %d, scopes %v, refresh before %s, check interval %s",
+		os.Getpid(), opts.Scopes, opts.RefreshBefore, opts.Interval)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		for idx := range opts.Scopes {
+			d.check(idx)
+		}
+		if st, ok := d.failed(); ok {
+			d.mu.Lock()
+			d.status.Status = "failed"
+			d.mu.Unlock()
+			d.writeStatus()
+			if opts.Socket != "" {
+				// exit does not run deferred functions
+				os.Remove(opts.Socket)
+			}
+			err := fmt.Errorf("%s token renewal failed %d times: %s", st.Scope, st.Failures, st.LastError)
+			exit("foxden token daemon stopped", err)
+		}
+		d.writeStatus()
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			d.status.Status = "stopped"
+			d.mu.Unlock()
+			d.writeStatus()
+			log.Println("foxden token daemon stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,11 +1,9 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 
-	srvConfig "github.com/CHESSComputing/golib/config"
 	"github.com/spf13/cobra"
 )
 
@@ -23,34 +21,10 @@ func dmUsage() {
 	fmt.Println()
 }
 
-// DMRecord represents DataManagement record
-type DMRecord struct {
-	Name  string `json:"name"`
-	Path  string `json:"path"`
-	IsDir bool   `json:"is_dir"`
-}
-
 // helper function to get dm data for given did
 func dmData(did string) {
-	// make HTTP call to DataManagement
-	rurl := fmt.Sprintf("%s/data?did=%s", srvConfig.Config.DataManagementURL, did)
-	// Create a new HTTP request to the target URL
-	resp, err := _httpReadRequest.Get(rurl)
-	if err != nil {
-		exit("unable to query DataManagement service", err)
-		return
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data", err)
-		return
-	}
-	var records []DMRecord
-	err = json.Unmarshal(data, &records)
-	if err != nil {
-		exit("unable to unmarshal data", err)
-	}
+	records, err := foxdenClient().DataManagement().Data(context.Background(), did)
+	exit("unable to query DataManagement service", err)
 	for _, rec := range records {
 		if rec.IsDir {
 			fmt.Println("Dir :", rec.Name)
@@ -62,31 +36,12 @@ func dmData(did string) {
 
 // helper function to get list of files for given did and file extension
 func dmFiles(did, ext string) {
-	// make HTTP call to DataManagement
 	pat := fmt.Sprintf("(?i).*%s$", ext)
 	if ext == "" || ext == "all" {
 		pat = "all"
 	}
-	rurl := fmt.Sprintf("%s/files?did=%s&pattern=%s", srvConfig.Config.DataManagementURL, did, pat)
-
-	// Create a new HTTP request to the target URL
-	resp, err := _httpReadRequest.Get(rurl)
-	if err != nil {
-		exit("unable to query DataManagement service", err)
-		return
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data", err)
-		return
-	}
-	var files []string
-	err = json.Unmarshal(data, &files)
-	if err != nil {
-		exit("unable to unmarshal the data", err)
-		return
-	}
+	files, err := foxdenClient().DataManagement().Files(context.Background(), did, pat)
+	exit("unable to query DataManagement service", err)
 	for _, f := range files {
 		fmt.Println(f)
 	}
@@ -99,20 +54,29 @@ func dmCommand() *cobra.Command {
 		Long:  "foxden data command to access FOXDEN Publication service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			ext, _ := cmd.Flags().GetString("ext")
 			did, _ := cmd.Flags().GetString("did")
-			accessToken()
 			if len(args) == 0 {
+				accessToken()
 				dmData(did)
-			} else if args[0] == "files" {
-				dmFiles(did, ext)
 			} else {
 				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
 			}
 		},
 	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "files",
+		Short: "list data files of given did",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ext, _ := cmd.Flags().GetString("ext")
+			did, _ := cmd.Flags().GetString("did")
+			accessToken()
+			dmFiles(did, ext)
+		},
+	})
 	cmd.PersistentFlags().String("did", "", "did string")
 	cmd.PersistentFlags().String("ext", "", "ext string")
+	cmd.RegisterFlagCompletionFunc("did", completeDids)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		dmUsage()
 		return nil
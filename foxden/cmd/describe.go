@@ -18,13 +18,13 @@ var _metaManager *schema.MetaDataManager
 // helper function to provide usage of describe option
 func describeUsage() {
 	fmt.Println("foxden describe <key>")
-	fmt.Println("options: --show=<description, service, schema, units, data-type>\n")
-	fmt.Println("Examples: \n")
-	fmt.Println("# show full details about beam_energy\n")
+	fmt.Print("options: --show=<description, service, schema, units, data-type>\n\n")
+	fmt.Print("Examples: \n\n")
+	fmt.Print("# show full details about beam_energy\n\n")
 	fmt.Println("foxden describe beam_energy")
-	fmt.Println("# show only units of beam_energy\n")
+	fmt.Print("# show only units of beam_energy\n\n")
 	fmt.Println("foxden describe beam_energy --show=units")
-	fmt.Println("# show which services provides did\n")
+	fmt.Print("# show which services provides did\n\n")
 	fmt.Println("foxden describe did --show=services")
 }
 
@@ -0,0 +1,199 @@
+package cmd
+
+// CHESComputing foxden tool: OAuth device login module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// OAuth grant type of device authorization (RFC 8628)
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// default OAuth client id of foxden tool, it can be changed via FOXDEN_CLIENT_ID env
+const deviceClientID = "foxden"
+
+// DeviceAuthorization represents device authorization response of Authz service
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// OAuthToken represents token response of Authz service
+type OAuthToken struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	Scope            string `json:"scope"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// helper function to get OAuth client id
+func oauthClientID() string {
+	if val := os.Getenv("FOXDEN_CLIENT_ID"); val != "" {
+		return val
+	}
+	return deviceClientID
+}
+
+// helper function to post OAuth form to given Authz endpoint and decode its response
+func oauthPost(path string, form url.Values, rec any) (int, error) {
+	rurl := fmt.Sprintf("%s%s", srvConfig.Config.Services.AuthzURL, path)
+	resp, err := http.PostForm(rurl, form)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return resp.StatusCode, fmt.Errorf("unable to parse Authz response, status %s: %s", resp.Status, data)
+	}
+	return resp.StatusCode, nil
+}
+
+// helper function to start device authorization for given scopes
+func deviceAuthorize(scopes []string) (DeviceAuthorization, error) {
+	var auth DeviceAuthorization
+	form := url.Values{"client_id": {oauthClientID()}, "scope": {strings.Join(scopes, " ")}}
+	status, err := oauthPost("/oauth/device/code", form, &auth)
+	if err != nil {
+		return auth, err
+	}
+	if status != http.StatusOK || auth.DeviceCode == "" {
+		return auth, fmt.Errorf("device authorization is not supported by Authz service, status %d", status)
+	}
+	if auth.Interval <= 0 {
+		auth.Interval = 5
+	}
+	if auth.ExpiresIn <= 0 {
+		auth.ExpiresIn = 600
+	}
+	return auth, nil
+}
+
+// helper function to poll Authz service until user approves device authorization
+func devicePoll(auth DeviceAuthorization) (OAuthToken, error) {
+	var token OAuthToken
+	interval := time.Duration(auth.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	form := url.Values{
+		"grant_type":  {deviceGrantType},
+		"device_code": {auth.DeviceCode},
+		"client_id":   {oauthClientID()},
+	}
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		token = OAuthToken{}
+		if _, err := oauthPost("/oauth/token", form, &token); err != nil {
+			return token, err
+		}
+		switch token.Error {
+		case "":
+			if token.AccessToken == "" {
+				return token, errors.New("empty token from Authz service")
+			}
+			return token, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return token, errors.New("device authorization is denied")
+		case "expired_token":
+			return token, errors.New("device code is expired, please login again")
+		default:
+			return token, fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+		}
+	}
+	return token, errors.New("device code is expired, please login again")
+}
+
+// helper function to obtain token of given scope with refresh token
+func scopeToken(refresh, scope string, expires int) (string, error) {
+	var token OAuthToken
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refresh},
+		"client_id":     {oauthClientID()},
+		"scope":         {scope},
+	}
+	if expires > 0 {
+		form.Set("expires_in", fmt.Sprintf("%d", expires))
+	}
+	if _, err := oauthPost("/oauth/token", form, &token); err != nil {
+		return "", err
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("empty token from Authz service")
+	}
+	return token.AccessToken, nil
+}
+
+// helper function to login to FOXDEN via OAuth device authorization, user
+// approves the login in a browser and tokens of requested scopes are stored
+// in the same way as foxden token create does
+func deviceLogin(scopes []string, ofile string, expires int) {
+	for _, scope := range scopes {
+		if _, ok := scopeRank[scope]; !ok {
+			exit("unsupported token scope, please use read, write or delete", fmt.Errorf("scope %q", scope))
+		}
+	}
+	if ofile != "" && len(scopes) != 1 {
+		exit("--ofile option can be used with single scope only", fmt.Errorf("scopes %v", scopes))
+	}
+	auth, err := deviceAuthorize(scopes)
+	exit("unable to start device login", err)
+	fmt.Println("To login to FOXDEN please open the following url in a browser:")
+	fmt.Println(auth.VerificationURI)
+	fmt.Printf("and enter the code: %s\n", auth.UserCode)
+	if auth.VerificationURIComplete != "" {
+		fmt.Printf("or open %s\n", auth.VerificationURIComplete)
+	}
+	fmt.Printf("\nwaiting for approval (code expires in %s)...\n", time.Duration(auth.ExpiresIn)*time.Second)
+	otoken, err := devicePoll(auth)
+	exit("unable to complete device login", err)
+	for _, scope := range scopes {
+		// access token is issued for the first scope, tokens of other
+		// scopes are obtained with refresh token
+		token := otoken.AccessToken
+		if otoken.Scope != scope {
+			if otoken.RefreshToken == "" {
+				fmt.Fprintf(os.Stderr, "WARNING: Authz service did not provide %s token\n", scope)
+				continue
+			}
+			if token, err = scopeToken(otoken.RefreshToken, scope, expires); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: unable to obtain %s token: %v\n", scope, err)
+				continue
+			}
+		}
+		fname := tokenFile(scope)
+		if ofile != "" {
+			fname = ofile
+		}
+		err := saveToken(fname, token)
+		exit(fmt.Sprintf("unable to write %s token", scope), err)
+		fmt.Printf("%s token is stored in %s\n", scope, fname)
+	}
+}
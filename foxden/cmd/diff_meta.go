@@ -0,0 +1,239 @@
+package cmd
+
+// CHESComputing foxden tool: meta-data diff module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	utils "github.com/CHESSComputing/golib/utils"
+)
+
+// ChangedValue represents old and new value of changed key
+type ChangedValue struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// MetaDiff represents field-level difference between two meta-data records
+type MetaDiff struct {
+	Source  string                  `json:"source"`
+	Target  string                  `json:"target"`
+	Added   map[string]any          `json:"added"`
+	Removed map[string]any          `json:"removed"`
+	Changed map[string]ChangedValue `json:"changed"`
+}
+
+// Empty returns true if there is no difference between records
+func (d *MetaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// diffSkipKeys represents keys managed by FOXDEN MetaData service which are not
+// part of user provided records and are ignored when records are compared
+var diffSkipKeys = []string{"_id"}
+
+// diffDefaultKeys represents keys which are added to a record on submission if
+// record does not provide them, e.g. user and schema of metaAddRecord
+var diffDefaultKeys = []string{"user", "schema"}
+
+// helper function to flatten nested record into dotted keys, e.g. {"a":{"b":1}} -> {"a.b":1}
+// lists are kept as-is and compared as a whole
+func flattenRecord(prefix string, rec map[string]any, out map[string]any) {
+	for key, val := range rec {
+		fkey := key
+		if prefix != "" {
+			fkey = prefix + "." + key
+		}
+		if nrec, ok := val.(map[string]any); ok && len(nrec) > 0 {
+			flattenRecord(fkey, nrec, out)
+			continue
+		}
+		out[fkey] = val
+	}
+}
+
+// helper function to compare two records, old represents stored record and new the updated one
+func diffRecords(oldRec, newRec map[string]any) MetaDiff {
+	diff := MetaDiff{
+		Added:   make(map[string]any),
+		Removed: make(map[string]any),
+		Changed: make(map[string]ChangedValue),
+	}
+	oldFlat := make(map[string]any)
+	newFlat := make(map[string]any)
+	flattenRecord("", oldRec, oldFlat)
+	flattenRecord("", newRec, newFlat)
+	for _, key := range diffSkipKeys {
+		delete(oldFlat, key)
+		delete(newFlat, key)
+	}
+	for key, nval := range newFlat {
+		oval, ok := oldFlat[key]
+		if !ok {
+			diff.Added[key] = nval
+		} else if !reflect.DeepEqual(oval, nval) {
+			diff.Changed[key] = ChangedValue{Old: oval, New: nval}
+		}
+	}
+	for key, oval := range oldFlat {
+		if _, ok := newFlat[key]; !ok {
+			diff.Removed[key] = oval
+		}
+	}
+	return diff
+}
+
+// helper function to fetch single meta-data record for given did
+func fetchMetaRecord(user, did string) map[string]any {
+	rec, err := storedMetaRecord(user, did)
+	exit(fmt.Sprintf("unable to fetch meta-data record for did=%s", did), err)
+	return rec
+}
+
+// helper function to get single meta-data record for given did from MetaData service
+func storedMetaRecord(user, did string) (map[string]any, error) {
+	rurl := srvConfig.Config.MetaDataURL
+	records, _, err := getMeta(rurl, user, "did:"+did, []string{}, 0, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no meta-data record found")
+	}
+	if len(records) > 1 {
+		return nil, errors.New("multiple records found")
+	}
+	return records[0], nil
+}
+
+// helper function to load local meta-data record and obtain its did
+func localMetaRecord(fname, attrs, sep, div string) (string, map[string]any, error) {
+	data, err := readJsonData(fname)
+	if err != nil {
+		return "", nil, err
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		return "", nil, fmt.Errorf("unable to unmarshal data: %w", err)
+	}
+	var did string
+	if val, ok := record["did"]; ok && val != "" {
+		did = fmt.Sprintf("%v", val)
+	} else {
+		did = utils.CreateDID(record, attrs, sep, div)
+		record["did"] = did
+	}
+	return did, record, nil
+}
+
+// helper function to format value for diff output
+func diffValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case map[string]any, []any:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// helper function to print diff report
+func printMetaDiff(diff MetaDiff) {
+	fmt.Printf("--- %s\n", diff.Source)
+	fmt.Printf("+++ %s\n", diff.Target)
+	if diff.Empty() {
+		fmt.Println("records are identical")
+		return
+	}
+	keys := utils.MapKeys(diff.Added)
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("+ %s: %s\n", key, diffValue(diff.Added[key]))
+	}
+	keys = utils.MapKeys(diff.Removed)
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("- %s: %s\n", key, diffValue(diff.Removed[key]))
+	}
+	var ckeys []string
+	for key := range diff.Changed {
+		ckeys = append(ckeys, key)
+	}
+	sort.Strings(ckeys)
+	for _, key := range ckeys {
+		val := diff.Changed[key]
+		fmt.Printf("~ %s: %s -> %s\n", key, diffValue(val.Old), diffValue(val.New))
+	}
+	fmt.Printf("\nadded: %d, removed: %d, changed: %d\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
+
+// helper function to check if given argument is existing local file,
+// dids start with slash as well therefore only file existence is checked
+func isLocalFile(arg string) bool {
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
+// helper function to compare meta-data records, it accepts either
+// local file name which is compared against stored record, or two dids.
+// It follows diff(1) exit codes: 0 if records are identical, 1 if they
+// differ and 2 on errors
+func metaDiffRecord(user string, args []string, attrs, sep, div string, jsonOutput bool) {
+	var diff MetaDiff
+	if len(args) == 1 && isLocalFile(args[0]) {
+		did, record, err := localMetaRecord(args[0], attrs, sep, div)
+		exitCode(2, "unable to read data from input file", err)
+		srvRecord, err := storedMetaRecord(user, did)
+		exitCode(2, fmt.Sprintf("unable to fetch meta-data record for did=%s", did), err)
+		// default attributes are added on submission if they are not present in a record
+		for _, key := range diffDefaultKeys {
+			if _, ok := record[key]; !ok {
+				if val, ok := srvRecord[key]; ok {
+					record[key] = val
+				}
+			}
+		}
+		diff = diffRecords(srvRecord, record)
+		diff.Source = did
+		diff.Target = args[0]
+	} else if len(args) == 1 {
+		_, err := os.Stat(args[0])
+		if err == nil {
+			err = errors.New("is a directory")
+		}
+		exitCode(2, fmt.Sprintf("unable to read record file %s, to compare stored records please provide two dids", args[0]), err)
+	} else if len(args) == 2 {
+		var records []map[string]any
+		for _, did := range args {
+			rec, err := storedMetaRecord(user, did)
+			exitCode(2, fmt.Sprintf("unable to fetch meta-data record for did=%s", did), err)
+			records = append(records, rec)
+		}
+		diff = diffRecords(records[0], records[1])
+		diff.Source = args[0]
+		diff.Target = args[1]
+	} else {
+		metaUsage()
+		exitCode(2, "please provide <file.json> or <did1> <did2>", errors.New("wrong number of arguments"))
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		exitCode(2, "unable to marshal diff record", err)
+		fmt.Println(string(data))
+	} else {
+		printMetaDiff(diff)
+	}
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+// CHESComputing foxden tool: tests of meta-data diff module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDiffRecords tests field-level difference between records
+func TestDiffRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldRec  string
+		newRec  string
+		added   string
+		removed string
+		changed map[string]ChangedValue
+	}{
+		{"identical", `{"did":"/a=1","sample":{"name":"Ti"}}`, `{"sample":{"name":"Ti"},"did":"/a=1"}`, `{}`, `{}`, nil},
+		{"nested keys", `{"did":"/a=1","sample":{"name":"Ti","mass":1}}`, `{"did":"/a=1","sample":{"name":"Cu","size":2}}`,
+			`{"sample.size":2}`, `{"sample.mass":1}`, map[string]ChangedValue{"sample.name": {"Ti", "Cu"}}},
+		{"lists are compared as a whole", `{"detectors":["eiger","pilatus"]}`, `{"detectors":["eiger"]}`,
+			`{}`, `{}`, map[string]ChangedValue{"detectors": {[]any{"eiger", "pilatus"}, []any{"eiger"}}}},
+		{"empty object is a value", `{"sample":{}}`, `{"sample":{"name":"Ti"}}`,
+			`{"sample.name":"Ti"}`, `{"sample":{}}`, nil},
+		{"server keys are ignored", `{"_id":"65a0","did":"/a=1"}`, `{"_id":"65a1","did":"/a=1"}`, `{}`, `{}`, nil},
+		{"server keys of one record are ignored", `{"_id":"65a0","did":"/a=1"}`, `{"did":"/a=1","btr":"abc"}`,
+			`{"btr":"abc"}`, `{}`, nil},
+	}
+	for _, tc := range tests {
+		diff := diffRecords(unmarshalTest(t, tc.oldRec), unmarshalTest(t, tc.newRec))
+		if tc.changed == nil {
+			tc.changed = map[string]ChangedValue{}
+		}
+		if !reflect.DeepEqual(diff.Added, unmarshalTest(t, tc.added)) ||
+			!reflect.DeepEqual(diff.Removed, unmarshalTest(t, tc.removed)) ||
+			!reflect.DeepEqual(diff.Changed, tc.changed) {
+			t.Errorf("%s: wrong diff %+v", tc.name, diff)
+		}
+		if empty := len(tc.added) == 2 && len(tc.removed) == 2 && len(tc.changed) == 0; diff.Empty() != empty {
+			t.Errorf("%s: wrong empty status %v", tc.name, diff.Empty())
+		}
+	}
+}
+
+// TestMetaDiffExitCode tests that meta diff follows diff(1) exit codes, the
+// test runs metaDiffRecord in sub-process since it exits
+func TestMetaDiffExitCode(t *testing.T) {
+	did := "/beamline=3a/btr=abc"
+	if args := os.Getenv("FOXDEN_TEST_DIFF"); args != "" {
+		mockMetaRecord(t, mockServices(t), did)
+		metaDiffRecord("tester", strings.Fields(args), "", "/", "=", true)
+		os.Exit(0)
+	}
+	dir := t.TempDir()
+	same := writeTestFile(t, filepath.Join(dir, "same.json"), `{"did":"/beamline=3a/btr=abc","btr":"abc"}`)
+	other := writeTestFile(t, filepath.Join(dir, "other.json"), `{"did":"/beamline=3a/btr=abc","btr":"xyz"}`)
+	tests := []struct {
+		args string
+		code int
+	}{
+		{same, 0},
+		{other, 1},
+		{did + " " + did, 0},
+		{did + " /beamline=none", 2},
+		{filepath.Join(dir, "missing.json"), 2},
+		{dir, 2},
+		{did + " " + did + " " + did, 2},
+	}
+	for _, tc := range tests {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestMetaDiffExitCode$")
+		cmd.Env = append(os.Environ(), "FOXDEN_TEST_DIFF="+tc.args)
+		out, err := cmd.CombinedOutput()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != tc.code {
+			t.Errorf("%s: wrong exit code %d, expected %d\n%s", tc.args, code, tc.code, out)
+		}
+	}
+}
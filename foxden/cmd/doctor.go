@@ -0,0 +1,414 @@
+package cmd
+
+// CHESComputing foxden tool: doctor module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/jcmturner/gokrb5.v7/credentials"
+)
+
+// statuses of foxden doctor checks
+const (
+	DoctorPass = "pass"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+)
+
+// latency of FOXDEN service above which doctor warns about slow service
+var doctorSlowLatency = 2 * time.Second
+
+// remaining lifetime of TLS certificates and kerberos tickets below which
+// doctor warns about upcoming expiration
+var doctorExpireWarning = 14 * 24 * time.Hour
+var doctorTicketWarning = time.Hour
+
+// _configError represents error of parsing FOXDEN configuration, it is kept
+// for commands which can run without configuration, e.g. foxden doctor
+var _configError error
+
+// DoctorCheck represents result of single foxden doctor check
+type DoctorCheck struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Latency  string `json:"latency,omitempty"`
+}
+
+// DoctorReport represents results of all foxden doctor checks
+type DoctorReport struct {
+	Config  string        `json:"config"`
+	Context string        `json:"context,omitempty"`
+	Status  string        `json:"status"`
+	Checks  []DoctorCheck `json:"checks"`
+}
+
+// helper function to provide usage of doctor command
+func doctorUsage() {
+	fmt.Println("foxden doctor [options]")
+	fmt.Println("options: --json, --timeout=<duration>")
+	fmt.Println("\nChecks FOXDEN configuration, connectivity and TLS of FOXDEN services,")
+	fmt.Println("kerberos configuration and ticket cache, tokens and trusted client status")
+	fmt.Println("\nExamples:")
+	fmt.Println("\n# run all checks and print pass/warn/fail table:")
+	fmt.Println("foxden doctor")
+	fmt.Println("\n# run checks with 5 seconds timeout of service requests and JSON output:")
+	fmt.Println("foxden doctor --timeout=5s --json")
+	fmt.Println("\nThe command exits with code 1 if any check fails")
+}
+
+// helper function to check FOXDEN configuration
+func doctorConfig() []DoctorCheck {
+	check := DoctorCheck{Category: "config", Name: "file"}
+	if cfgFile == "" {
+		check.Status = DoctorFail
+		check.Message = "no configuration, please use --config option or FOXDEN_CONFIG environment"
+		return []DoctorCheck{check}
+	}
+	if _, err := os.Stat(cfgFile); err != nil {
+		check.Status = DoctorFail
+		check.Message = err.Error()
+		return []DoctorCheck{check}
+	}
+	if _configError != nil {
+		check.Status = DoctorFail
+		check.Message = fmt.Sprintf("unable to parse %s: %v", cfgFile, _configError)
+		return []DoctorCheck{check}
+	}
+	check.Status = DoctorPass
+	check.Message = cfgFile
+	checks := []DoctorCheck{check}
+	ctx := DoctorCheck{Category: "config", Name: "context", Status: DoctorPass}
+	if err := applyContext(cfgFile); err != nil {
+		ctx.Status = DoctorFail
+		ctx.Message = err.Error()
+	} else if _currentContext != "" {
+		ctx.Message = _currentContext
+	} else {
+		ctx.Message = "no context is used"
+	}
+	checks = append(checks, ctx)
+	return checks
+}
+
+// helper function to get HTTP transport of doctor checks, it is a copy of
+// underlying transport of foxden commands (without retries, tracing and
+// recording) or a new one if it is not plain HTTP transport
+func doctorTransport() *http.Transport {
+	base := http.DefaultTransport
+	if _transport != nil {
+		base = _transport.Base
+	}
+	if t, ok := base.(*http.Transport); ok {
+		return t.Clone()
+	}
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// helper function to check reachability, TLS validity and latency of
+// FOXDEN service, any HTTP response below 500 means service is reachable
+func doctorService(name, rurl string, timeout time.Duration) DoctorCheck {
+	check := DoctorCheck{Category: "service", Name: name}
+	if rurl == "" {
+		check.Status = DoctorWarn
+		check.Message = "service url is not configured"
+		return check
+	}
+	req, err := http.NewRequest("GET", rurl, nil)
+	if err != nil {
+		check.Status = DoctorFail
+		check.Message = err.Error()
+		return check
+	}
+	// use plain client without retries to measure real latency
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: doctorTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	check.Latency = latency.Round(time.Millisecond).String()
+	if err != nil {
+		check.Status = DoctorFail
+		check.Message = fmt.Sprintf("%s unreachable: %v", rurl, err)
+		return check
+	}
+	resp.Body.Close()
+	var msgs []string
+	check.Status = DoctorPass
+	if resp.StatusCode >= 500 {
+		check.Status = DoctorFail
+	}
+	msgs = append(msgs, fmt.Sprintf("%s %s", rurl, resp.Status))
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		cert := resp.TLS.PeerCertificates[0]
+		remaining := time.Until(cert.NotAfter)
+		msgs = append(msgs, fmt.Sprintf("%s certificate expires %s", tlsVersion(resp.TLS), cert.NotAfter.Format("2006-01-02")))
+		if remaining < doctorExpireWarning && check.Status == DoctorPass {
+			check.Status = DoctorWarn
+		}
+	} else if strings.HasPrefix(rurl, "http://") {
+		msgs = append(msgs, "no TLS")
+	}
+	if latency > doctorSlowLatency {
+		msgs = append(msgs, "slow response")
+		if check.Status == DoctorPass {
+			check.Status = DoctorWarn
+		}
+	}
+	check.Message = strings.Join(msgs, ", ")
+	return check
+}
+
+// helper function to get name of TLS version of the connection
+func tlsVersion(state *tls.ConnectionState) string {
+	switch state.Version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	}
+	return "TLS"
+}
+
+// helper function to check all FOXDEN services concurrently
+func doctorServices(timeout time.Duration) []DoctorCheck {
+	urls := serviceUrls()
+	var names []string
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]DoctorCheck, len(names))
+	var wg sync.WaitGroup
+	for idx, name := range names {
+		wg.Add(1)
+		go func(idx int, name string) {
+			defer wg.Done()
+			checks[idx] = doctorService(name, urls[name], timeout)
+		}(idx, name)
+	}
+	wg.Wait()
+	return checks
+}
+
+// helper function to check kerberos configuration and ticket cache
+func doctorKerberos() []DoctorCheck {
+	kconf := srvConfig.Config.Kerberos.Krb5Conf
+	if kconf == "" {
+		kconf = "/etc/krb5.conf"
+	}
+	conf := DoctorCheck{Category: "kerberos", Name: "krb5.conf", Status: DoctorPass, Message: kconf}
+	if _, err := krb5Config(); err != nil {
+		conf.Status = DoctorFail
+		conf.Message = fmt.Sprintf("unable to load %s: %v", kconf, err)
+	}
+	kfile := keyFile()
+	cache := DoctorCheck{Category: "kerberos", Name: "ticket cache"}
+	ccache, err := credentials.LoadCCache(kfile)
+	if err != nil {
+		cache.Status = DoctorWarn
+		cache.Message = fmt.Sprintf("no valid ticket cache %s, use kinit or foxden token create: %v", kfile, err)
+		return []DoctorCheck{conf, cache}
+	}
+	principal := fmt.Sprintf("%s@%s", ccache.GetClientPrincipalName().PrincipalNameString(), ccache.GetClientRealm())
+	var endTime time.Time
+	for _, cred := range ccache.GetEntries() {
+		if strings.HasPrefix(cred.Server.PrincipalName.PrincipalNameString(), "krbtgt/") && cred.EndTime.After(endTime) {
+			endTime = cred.EndTime
+		}
+	}
+	remaining := time.Until(endTime).Round(time.Second)
+	switch {
+	case endTime.IsZero():
+		cache.Status = DoctorWarn
+		cache.Message = fmt.Sprintf("%s: no ticket granting ticket for %s", kfile, principal)
+	case remaining <= 0:
+		cache.Status = DoctorWarn
+		cache.Message = fmt.Sprintf("%s: ticket of %s expired at %s", kfile, principal, endTime.Format(time.RFC3339))
+	case remaining < doctorTicketWarning:
+		cache.Status = DoctorWarn
+		cache.Message = fmt.Sprintf("%s: ticket of %s expires in %s", kfile, principal, remaining)
+	default:
+		cache.Status = DoctorPass
+		cache.Message = fmt.Sprintf("%s: ticket of %s expires in %s", kfile, principal, remaining)
+	}
+	return []DoctorCheck{conf, cache}
+}
+
+// helper function to check scope and expiration of FOXDEN tokens
+func doctorTokens() []DoctorCheck {
+	var checks []DoctorCheck
+	margin := tokenMargin()
+	for _, src := range tokenSources() {
+		status := tokenStatus(src, margin)
+		check := DoctorCheck{Category: "token", Name: src.Scope}
+		switch status.Status {
+		case TokenOK:
+			check.Status = DoctorPass
+		case TokenExpiring, TokenMissing:
+			check.Status = DoctorWarn
+		default:
+			check.Status = DoctorFail
+		}
+		var msgs []string
+		if status.Status == TokenMissing {
+			msgs = append(msgs, fmt.Sprintf("no %s token, use foxden token create %s", src.Scope, src.Scope))
+		} else {
+			msgs = append(msgs, fmt.Sprintf("%s, source %s", status.Status, status.Source))
+		}
+		if status.User != "" {
+			msgs = append(msgs, fmt.Sprintf("user %s", status.User))
+		}
+		if status.TokenScope != "" {
+			msgs = append(msgs, fmt.Sprintf("scope %s", status.TokenScope))
+			if scopeRank[status.TokenScope] < scopeRank[src.Scope] {
+				check.Status = DoctorFail
+				msgs = append(msgs, fmt.Sprintf("%s scope is required", src.Scope))
+			}
+		}
+		if status.Remaining != "" {
+			msgs = append(msgs, fmt.Sprintf("remaining %s", status.Remaining))
+		}
+		if status.Error != "" {
+			msgs = append(msgs, status.Error)
+		}
+		check.Message = strings.Join(msgs, ", ")
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// helper function to check trusted client status
+func doctorTrustedClient() DoctorCheck {
+	check := DoctorCheck{Category: "trusted", Name: "client", Status: DoctorPass}
+	if os.Getenv("FOXDEN_TRUSTED_CLIENT") == "" {
+		check.Message = "FOXDEN_TRUSTED_CLIENT is not set, tokens are used"
+		return check
+	}
+	user, err := trustedClientUser()
+	if err != nil {
+		check.Status = DoctorFail
+		check.Message = err.Error()
+		return check
+	}
+	if user == "" {
+		check.Status = DoctorFail
+		check.Message = "FOXDEN_TRUSTED_CLIENT is set but this client is not trusted by FOXDEN"
+		return check
+	}
+	check.Message = fmt.Sprintf("trusted user %s", user)
+	return check
+}
+
+// helper function to run all doctor checks
+func runDoctor(timeout time.Duration, jsonOutput bool) {
+	report := DoctorReport{Config: cfgFile}
+	report.Checks = doctorConfig()
+	configOK := true
+	for _, check := range report.Checks {
+		if check.Status == DoctorFail {
+			configOK = false
+		}
+	}
+	if configOK {
+		report.Context = _currentContext
+		report.Checks = append(report.Checks, doctorServices(timeout)...)
+		report.Checks = append(report.Checks, doctorKerberos()...)
+		report.Checks = append(report.Checks, doctorTokens()...)
+		report.Checks = append(report.Checks, doctorTrustedClient())
+	}
+	report.Status = DoctorPass
+	for _, check := range report.Checks {
+		if check.Status == DoctorFail {
+			report.Status = DoctorFail
+			break
+		}
+		if check.Status == DoctorWarn {
+			report.Status = DoctorWarn
+		}
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		exit("unable to marshal doctor report", err)
+		fmt.Println(string(data))
+	} else {
+		printDoctorReport(report)
+	}
+	if report.Status == DoctorFail {
+		os.Exit(1)
+	}
+}
+
+// helper function to print doctor report as a table
+func printDoctorReport(report DoctorReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tCATEGORY\tCHECK\tLATENCY\tDETAILS")
+	for _, check := range report.Checks {
+		latency := check.Latency
+		if latency == "" {
+			latency = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			strings.ToUpper(check.Status), check.Category, check.Name, latency, check.Message)
+	}
+	w.Flush()
+	counts := make(map[string]int)
+	for _, check := range report.Checks {
+		counts[check.Status]++
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n", counts[DoctorPass], counts[DoctorWarn], counts[DoctorFail])
+}
+
+func doctorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "foxden doctor command",
+		Long:  "foxden doctor command to diagnose FOXDEN environment and connectivity\n" + doc,
+		Args:  cobra.NoArgs,
+		// doctor reports configuration and context problems itself
+		Annotations: map[string]string{"config": "optional", "context": "optional"},
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			if timeout <= 0 {
+				exit("invalid timeout", errors.New("timeout should be positive"))
+			}
+			_noPrompt = true
+			runDoctor(timeout, jsonOutput)
+		},
+	}
+	cmd.Flags().Bool("json", false, "json output")
+	cmd.Flags().Duration("timeout", 10*time.Second, "timeout of service requests")
+	cmd.SetUsageFunc(func(*cobra.Command) error {
+		doctorUsage()
+		return nil
+	})
+	return cmd
+}
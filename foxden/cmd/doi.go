@@ -1,59 +1,31 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"strings"
 
 	srvConfig "github.com/CHESSComputing/golib/config"
 	services "github.com/CHESSComputing/golib/services"
 	utils "github.com/CHESSComputing/golib/utils"
+	client "github.com/CHESSComputing/gotools/foxden/client"
 	"github.com/spf13/cobra"
 )
 
-// DOIServiceResponse represents response structure of DOIService record
-type DOIRecord struct {
-	Doi            string `json:"doi"`
-	DoiUrl         string `json:"doi_url"`
-	Did            string `json:"did"`
-	Description    string `json:"description"`
-	Provider       string `json:"doi_provider"`
-	Published      string `json:"doi_created_at"`
-	Public         bool   `json:"doi_public"`
-	AccessMetadata bool   `json:"doi_access_metadata"`
-}
-
 // helper function to fetch DOI records
-func doiView(doi string, jsonOutput bool) {
-	form := url.Values{}
-	form.Set("doi", doi)
-
-	// Convert form form to encoded format
-	reqBody := bytes.NewBuffer([]byte(form.Encode()))
-	// Create GET request to FOXDEN DOIService
-	rurl := fmt.Sprintf("%s/search", srvConfig.Config.Services.DOIServiceURL)
-	req, err := http.NewRequest("POST", rurl, reqBody)
-	msg := fmt.Sprintf("fail %s unable to fetch data from FOXDEN DOIService", rurl)
-	exit(msg, err)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	exit("unable to do HTTP request", err)
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	var records []DOIRecord
-	err = json.Unmarshal(data, &records)
-	exit("unable to read data from DOIService", err)
-	if jsonOutput {
-		fmt.Println(string(utils.FormatJsonRecords(data)))
+func doiView(doi string, opts OutputOptions) {
+	c := foxdenClient().DOI()
+	if !opts.Default() {
+		rmaps, err := c.Records(context.Background(), doi)
+		exit("unable to read data from DOIService", err)
+		writeRecords(rmaps, opts)
 		return
 	}
+	records, err := c.Search(context.Background(), doi)
+	exit("unable to read data from DOIService", err)
 	for _, rec := range records {
 		fmt.Println("---")
 		rtype := "Draft"
@@ -91,44 +63,31 @@ func doiPublish(did, provider, description, parents string, draft, metadata, jso
 		}
 	}
 
-	// Define form data
-	form := url.Values{}
-	form.Set("did", did)
-	form.Set("doiprovider", provider)
-	form.Set("description", description)
-	form.Set("schema", schema)
-
-	// Checkbox values should be sent as "on" if checked, otherwise omitted
-	if draft {
-		form.Set("draft", "on")
-	}
-	if metadata {
-		form.Set("publishmetadata", "on")
+	req := client.PublishRequest{
+		Did:             did,
+		Provider:        provider,
+		Description:     description,
+		Schema:          schema,
+		Draft:           draft,
+		PublishMetadata: metadata,
 	}
 	// add parents if they are provided
 	if parents != "" {
-		for _, p := range strings.Split(parents, ",") {
-			form.Add("doi_parents_dids", strings.Trim(p, " "))
-		}
+		req.Parents = strings.Split(parents, ",")
 	}
-
-	// Convert form form to encoded format
-	reqBody := bytes.NewBuffer([]byte(form.Encode()))
-
-	// Create POST request
-	rurl = fmt.Sprintf("%s/publish", srvConfig.Config.Services.FrontendURL)
-	hmap := make(map[string][]string)
-	hmap["Accept"] = []string{"application/json"}
-	_httpWriteRequest.Headers = hmap
-	resp, err := _httpWriteRequest.Post(rurl, "application/x-www-form-urlencoded", reqBody)
-	msg := fmt.Sprintf("fail %s unable to fetch data from FOXDEN Frontend service", rurl)
-	exit(msg, err)
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	exit("unable to read data from meta-data service", err)
+	resp, err := foxdenClient().DOI().Publish(context.Background(), req)
+	var cerr *client.Error
+	if errors.As(err, &cerr) && cerr.StatusCode != 0 {
+		// service replied with an error, show its response
+		resp = &client.Response{StatusCode: cerr.StatusCode, Body: cerr.Body}
+	} else {
+		msg := fmt.Sprintf("fail %s/publish unable to fetch data from FOXDEN Frontend service", srvConfig.Config.Services.FrontendURL)
+		exit(msg, err)
+	}
+	data := resp.Body
 
 	// Print response status
-	fmt.Printf("Response Status: %v\n", resp.Status)
+	fmt.Printf("Response Status: %d %s\n", resp.StatusCode, http.StatusText(resp.StatusCode))
 	if jsonOutput {
 		fmt.Println(string(utils.FormatJson(data)))
 	} else {
@@ -152,9 +111,12 @@ func doiUsage() {
 	fmt.Println("         --public (make DOI public record)")
 	fmt.Println("         --hideMetadata (hide metadata from DOI publication)")
 	fmt.Println("         --json (output in json data-format)")
+	fmt.Println("         --output=<table|wide|json|ndjson|yaml|csv|template> --fields=<keys> --template=<template>")
 	fmt.Println("\nExamples:")
 	fmt.Println("\n# list documents from DOI provider:")
 	fmt.Println("foxden doi ls <doi>")
+	fmt.Println("\n# list documents from DOI provider as a table with selected fields:")
+	fmt.Println("foxden doi ls <doi> --output=table --fields=doi,did,doi_created_at")
 	fmt.Println("\n# get details of document id:")
 	fmt.Println("foxden doi view <doi>")
 	fmt.Println("\n# publish metadata:")
@@ -171,43 +133,65 @@ func doiCommand() *cobra.Command {
 		Long:  "foxden doi command to access FOXDEN Publication service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			//             tkn, _ := cmd.Flags().GetString("ztoken")
+			if len(args) == 0 {
+				doiUsage()
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
+			}
+		},
+	}
+	lsCmd := &cobra.Command{
+		Use:   "ls [doi]",
+		Short: "list documents from DOI provider",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := outputOptions(cmd, "doi", "did", "doi_url", "doi_created_at", "doi_public")
+			var pat string
+			if len(args) == 1 {
+				pat = args[0]
+			}
+			doiView(pat, opts)
+		},
+	}
+	publishCmd := &cobra.Command{
+		Use:         "publish <did>",
+		Short:       "publish meta-data of given did",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("read", "write"),
+		Run: func(cmd *cobra.Command, args []string) {
 			provider, _ := cmd.Flags().GetString("provider")
 			description, _ := cmd.Flags().GetString("description")
 			parents, _ := cmd.Flags().GetString("parents")
 			publicDoi, _ := cmd.Flags().GetBool("public")
 			hideMetadata, _ := cmd.Flags().GetBool("hideMetadata")
 			jsonOutput, _ := cmd.Flags().GetBool("json")
-			if len(args) == 0 {
-				doiUsage()
-			} else if args[0] == "ls" {
-				var pat string
-				if len(args) == 2 {
-					pat = args[1]
-				}
-				doiView(pat, jsonOutput)
-			} else if args[0] == "publish" {
-				accessToken()
-				writeToken()
-				did := args[1]
-				draft := !publicDoi
-				publishMetadata := !hideMetadata
-				doiPublish(did, provider, description, parents, draft, publishMetadata, jsonOutput)
-			} else if args[0] == "view" {
-				accessToken()
-				doi := args[1]
-				doiView(doi, jsonOutput)
-			} else {
-				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
-			}
+			accessToken()
+			draft := !publicDoi
+			publishMetadata := !hideMetadata
+			doiPublish(args[0], provider, description, parents, draft, publishMetadata, jsonOutput)
+		},
+	}
+	publishCmd.ValidArgsFunction = completeDid
+	viewCmd := &cobra.Command{
+		Use:   "view <doi>",
+		Short: "get details of document id",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := outputOptions(cmd, "doi", "did", "doi_url", "doi_created_at", "doi_public")
+			accessToken()
+			doiView(args[0], opts)
 		},
 	}
+	cmd.AddCommand(lsCmd, publishCmd, viewCmd)
 	cmd.PersistentFlags().String("provider", "Datacite", "DOI provider, default Datacite")
 	cmd.PersistentFlags().String("description", "", "dataset description for DOI publication")
 	cmd.PersistentFlags().String("parents", "parents", "Add comma separated list of parents with your publication")
 	cmd.PersistentFlags().Bool("public", false, "make public DOI")
 	cmd.PersistentFlags().Bool("hideMetadata", false, "do not publish metadata in DOI publication")
 	cmd.PersistentFlags().Bool("json", false, "json output")
+	cmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions([]string{"Datacite", "Zenodo", "MaterialCommons"}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("parents", completeDids)
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		doiUsage()
 		return nil
@@ -0,0 +1,319 @@
+package cmd
+
+// CHESComputing foxden tool: meta-data export module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// RecordWriter defines interface to stream records into specific data-format
+type RecordWriter interface {
+	Write(rec map[string]any) error
+	Close() error
+}
+
+// NdjsonWriter writes records in NDJSON data-format
+type NdjsonWriter struct {
+	enc *json.Encoder
+}
+
+// Write implements RecordWriter interface
+func (w *NdjsonWriter) Write(rec map[string]any) error {
+	return w.enc.Encode(rec)
+}
+
+// Close implements RecordWriter interface
+func (w *NdjsonWriter) Close() error {
+	return nil
+}
+
+// JsonWriter writes records as JSON list
+type JsonWriter struct {
+	w     io.Writer
+	nrecs int
+}
+
+// Write implements RecordWriter interface
+func (w *JsonWriter) Write(rec map[string]any) error {
+	data, err := json.MarshalIndent(rec, " ", " ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n "
+	if w.nrecs == 0 {
+		sep = "[\n "
+	}
+	if _, err := io.WriteString(w.w, sep); err != nil {
+		return err
+	}
+	_, err = w.w.Write(data)
+	w.nrecs++
+	return err
+}
+
+// Close implements RecordWriter interface
+func (w *JsonWriter) Close() error {
+	if w.nrecs == 0 {
+		_, err := io.WriteString(w.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n]\n")
+	return err
+}
+
+// CsvWriter writes flatten records in CSV data-format, if fields are not
+// provided flatten records are spooled into temporary NDJSON file to build
+// CSV header from keys of all records and CSV rows are written on Close
+type CsvWriter struct {
+	w      *csv.Writer
+	fields []string
+	keys   map[string]bool
+	spool  *os.File
+	enc    *json.Encoder
+}
+
+// Write implements RecordWriter interface
+func (w *CsvWriter) Write(rec map[string]any) error {
+	flat := make(map[string]any)
+	flattenRecord("", rec, flat)
+	if len(w.fields) == 0 {
+		return w.spoolRow(flat)
+	}
+	return w.writeRow(flat)
+}
+
+// helper function to spool flatten record into temporary file
+func (w *CsvWriter) spoolRow(flat map[string]any) error {
+	if w.spool == nil {
+		file, err := os.CreateTemp("", "foxden-export-*.ndjson")
+		if err != nil {
+			return err
+		}
+		w.spool = file
+		w.enc = json.NewEncoder(file)
+		w.keys = make(map[string]bool)
+	}
+	for key := range flat {
+		w.keys[key] = true
+	}
+	return w.enc.Encode(flat)
+}
+
+// helper function to write flatten record as CSV row
+func (w *CsvWriter) writeRow(flat map[string]any) error {
+	row := make([]string, len(w.fields))
+	for i, key := range w.fields {
+		if val, ok := flat[key]; ok {
+			row[i] = csvValue(val)
+		}
+	}
+	return w.w.Write(row)
+}
+
+// helper function to write spooled records, keys of all records are used as CSV header
+func (w *CsvWriter) writeSpool() error {
+	defer os.Remove(w.spool.Name())
+	defer w.spool.Close()
+	for key := range w.keys {
+		w.fields = append(w.fields, key)
+	}
+	sort.Strings(w.fields)
+	if err := w.w.Write(w.fields); err != nil {
+		return err
+	}
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(w.spool)
+	for {
+		var flat map[string]any
+		if err := dec.Decode(&flat); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := w.writeRow(flat); err != nil {
+			return err
+		}
+	}
+}
+
+// Close implements RecordWriter interface
+func (w *CsvWriter) Close() error {
+	if w.spool != nil {
+		err := w.writeSpool()
+		w.spool = nil
+		if err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// TarWriter writes every record as individual JSON file into gzipped tarball
+type TarWriter struct {
+	gz    *gzip.Writer
+	tw    *tar.Writer
+	nrecs int
+}
+
+// Write implements RecordWriter interface
+func (w *TarWriter) Write(rec map[string]any) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	w.nrecs++
+	name := fmt.Sprintf("record-%06d.json", w.nrecs)
+	if did, ok := rec["did"]; ok {
+		name = didFileName(fmt.Sprintf("%v", did)) + ".json"
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = w.tw.Write(data)
+	return err
+}
+
+// Close implements RecordWriter interface
+func (w *TarWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// helper function to convert did into file name, e.g.
+// /beamline=3a/btr=123/cycle=2024-1 -> beamline=3a_btr=123_cycle=2024-1
+func didFileName(did string) string {
+	name := strings.Trim(did, "/")
+	name = strings.NewReplacer("/", "_", " ", "_", ":", "_").Replace(name)
+	if name == "" {
+		name = "record"
+	}
+	return name
+}
+
+// helper function to convert record value into CSV cell
+func csvValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v))
+		}
+		return fmt.Sprintf("%v", v)
+	case []any, map[string]any:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// helper function to create record writer for given format
+func newRecordWriter(w io.Writer, format string, fields []string) (RecordWriter, error) {
+	switch format {
+	case "ndjson":
+		return &NdjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "json":
+		return &JsonWriter{w: w}, nil
+	case "csv":
+		cw := &CsvWriter{w: csv.NewWriter(w), fields: fields}
+		if len(fields) > 0 {
+			if err := cw.w.Write(fields); err != nil {
+				return nil, err
+			}
+		}
+		return cw, nil
+	case "tar":
+		gz := gzip.NewWriter(w)
+		return &TarWriter{gz: gz, tw: tar.NewWriter(gz)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format '%s', please use one of ndjson, csv, json, tar", format)
+}
+
+// helper function to export meta-data records matching given query
+// records are fetched page by page and streamed to the output
+func metaExportRecords(user, spec string, skeys []string, sorder, pageSize int, format, fname, fields string) {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	if format == "" {
+		format = "ndjson"
+		if strings.HasSuffix(fname, ".csv") {
+			format = "csv"
+		} else if strings.HasSuffix(fname, ".tar.gz") || strings.HasSuffix(fname, ".tgz") {
+			format = "tar"
+		} else if strings.HasSuffix(fname, ".json") {
+			format = "json"
+		}
+	}
+	var columns []string
+	if fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				columns = append(columns, f)
+			}
+		}
+		if format != "csv" {
+			exit("--fields option is only supported with csv format", errors.New("wrong option"))
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if fname != "" && fname != "-" {
+		file, err := os.Create(fname)
+		exit(fmt.Sprintf("unable to create %s", fname), err)
+		defer file.Close()
+		out = file
+	} else if format == "tar" {
+		exit("tar format requires --out=<file.tar.gz> option", errors.New("no output file"))
+	}
+	writer, err := newRecordWriter(out, format, columns)
+	exit("unable to create record writer", err)
+
+	rurl := srvConfig.Config.MetaDataURL
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		return getMeta(rurl, user, spec, skeys, sorder, idx, limit)
+	}
+	pager := NewPager(fetch, 0, 0, pageSize, true)
+	for pager.Next() {
+		for _, rec := range pager.Records() {
+			err = writer.Write(rec)
+			exit("unable to write record", err)
+		}
+		fmt.Fprintf(os.Stderr, "exported %d/%d records\r", pager.Count(), pager.Total())
+	}
+	exit("unable to fetch meta-data records", pager.Err())
+	fmt.Fprintln(os.Stderr)
+	err = writer.Close()
+	exit("unable to finalize output", err)
+	if fname != "" && fname != "-" {
+		fmt.Fprintf(os.Stderr, "SUCCESS: %d records exported to %s\n", pager.Count(), fname)
+	}
+}
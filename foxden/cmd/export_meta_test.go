@@ -0,0 +1,130 @@
+package cmd
+
+// CHESComputing foxden tool: tests of meta-data export module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// helper function to provide records used by export tests
+func exportRecords(t *testing.T) []map[string]any {
+	t.Helper()
+	return []map[string]any{
+		unmarshalTest(t, `{"did":"/beamline=3a/btr=abc","cycle":"2024-1","sample":{"name":"Ti","mass":1.5}}`),
+		unmarshalTest(t, `{"did":"/beamline=3b/btr=xyz","date":1700000000,"detectors":["eiger","pilatus"]}`),
+	}
+}
+
+// helper function to write records with writer of given format
+func exportWrite(t *testing.T, format string, fields []string, records []map[string]any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newRecordWriter(&buf, format, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// TestRecordWriters tests NDJSON, JSON and CSV record writers
+func TestRecordWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		fields []string
+		result string
+	}{
+		{"ndjson", nil,
+			`{"cycle":"2024-1","did":"/beamline=3a/btr=abc","sample":{"mass":1.5,"name":"Ti"}}
+{"date":1700000000,"detectors":["eiger","pilatus"],"did":"/beamline=3b/btr=xyz"}
+`},
+		{"csv", []string{"did", "sample.name", "date"},
+			`did,sample.name,date
+/beamline=3a/btr=abc,Ti,
+/beamline=3b/btr=xyz,,1700000000
+`},
+		// without fields CSV header is built from keys of all records
+		{"csv", nil,
+			`cycle,date,detectors,did,sample.mass,sample.name
+2024-1,,,/beamline=3a/btr=abc,1.5,Ti
+,1700000000,"[""eiger"",""pilatus""]",/beamline=3b/btr=xyz,,
+`},
+	}
+	for _, tc := range tests {
+		if out := exportWrite(t, tc.format, tc.fields, exportRecords(t)); out != tc.result {
+			t.Errorf("%s %v: got\n%s\nexpected\n%s", tc.format, tc.fields, out, tc.result)
+		}
+	}
+	if out := exportWrite(t, "json", nil, nil); out != "[]\n" {
+		t.Errorf("wrong JSON output of empty records %q", out)
+	}
+	if out := exportWrite(t, "csv", nil, nil); out != "" {
+		t.Errorf("wrong CSV output of empty records %q", out)
+	}
+	if _, err := newRecordWriter(io.Discard, "xml", nil); err == nil {
+		t.Error("writer of unsupported format was created")
+	}
+}
+
+// TestCsvWriterSpool tests that CSV writer without fields removes its spool file
+func TestCsvWriterSpool(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	exportWrite(t, "csv", nil, exportRecords(t))
+	files, err := filepath.Glob(filepath.Join(tmp, "*"))
+	if err != nil || len(files) != 0 {
+		t.Errorf("spool files are left %v, error %v", files, err)
+	}
+}
+
+// TestTarWriter tests that every record is written as JSON file into tarball
+func TestTarWriter(t *testing.T) {
+	records := exportRecords(t)
+	records = append(records, map[string]any{"btr": "no-did"})
+	out := exportWrite(t, "tar", nil, records)
+	gz, err := gzip.NewReader(bytes.NewBufferString(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec := unmarshalTest(t, string(data)); !reflect.DeepEqual(rec, records[len(names)]) {
+			t.Errorf("%s: got %v, expected %v", hdr.Name, rec, records[len(names)])
+		}
+		names = append(names, hdr.Name)
+	}
+	expect := []string{"beamline=3a_btr=abc.json", "beamline=3b_btr=xyz.json", "record-000003.json"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Errorf("wrong tarball files %v, expected %v", names, expect)
+	}
+	if name := didFileName("/"); name != "record" {
+		t.Errorf("wrong file name of empty did %s", name)
+	}
+}
@@ -5,25 +5,16 @@ package cmd
 // import (
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
 	"os"
 	"strings"
 
-	srvConfig "github.com/CHESSComputing/golib/config"
 	"github.com/CHESSComputing/golib/utils"
 	"github.com/spf13/cobra"
 )
 
-// IngestRecord represents ingest record from FabricNode
-type IngestRecord struct {
-	Ingested int    `json:"ingested"`
-	Did      string `json:"did"`
-	GraphIRI string `json:"graphIRI"`
-}
-
 // helper function to provide fabric usage info
 func fabricUsage() {
 	fmt.Println("foxden fabric <ls|ingest|health|sparql|catalog> [options]")
@@ -32,6 +23,9 @@ func fabricUsage() {
 	fmt.Println("# list datasets for a beamline in the catalog:")
 	fmt.Println("foxden fabric ls <beamline>")
 	fmt.Println()
+	fmt.Println("# list datasets for a beamline as a table, other formats: wide, json, ndjson, yaml, csv, template")
+	fmt.Println("foxden fabric ls <beamline> --output=table")
+	fmt.Println()
 	fmt.Println("# ingest a single DID into FabricNode:")
 	fmt.Println("foxden fabric ingest <did>")
 	fmt.Println()
@@ -53,69 +47,12 @@ func fabricUsage() {
 
 // helper function to check health of FabricNode services
 func fabricHealth(jsonOutput bool) {
-	type healthResult struct {
-		Service string `json:"service"`
-		Status  string `json:"status"`
-		Error   string `json:"error,omitempty"`
-	}
-
-	services := []struct {
-		name string
-		url  func() string
-	}{
-		{
-			"catalog-service",
-			func() string {
-				return fmt.Sprintf("%s/health", srvConfig.Config.Services.FabricCatalogURL)
-			},
-		},
-		{
-			"data-service",
-			func() string {
-				return fmt.Sprintf("%s/health", srvConfig.Config.Services.FabricDataServiceURL)
-			},
-		},
-	}
-
-	var results []healthResult
+	results := foxdenClient().FabricNode().Health(context.Background())
 	allOK := true
-
-	for _, svc := range services {
-		rurl := svc.url()
-		if verbose > 0 {
-			fmt.Println("HTTP GET", rurl)
-		}
-		resp, err := _httpReadRequest.Get(rurl)
-		if err != nil {
-			results = append(results, healthResult{Service: svc.name, Status: "error", Error: err.Error()})
-			allOK = false
-			continue
-		}
-		defer resp.Body.Close()
-
-		var body map[string]any
-		if decErr := json.NewDecoder(resp.Body).Decode(&body); decErr != nil {
-			results = append(results, healthResult{Service: svc.name, Status: "error", Error: decErr.Error()})
-			allOK = false
-			continue
-		}
-
-		status := "error"
-		if resp.StatusCode == 200 {
-			if s, ok := body["status"].(string); ok && s == "ok" {
-				status = "ok"
-			}
-		}
-		r := healthResult{Service: svc.name, Status: status}
-		if status != "ok" {
+	for _, r := range results {
+		if r.Status != "ok" {
 			allOK = false
-			if msg, ok := body["error"].(string); ok {
-				r.Error = msg
-			} else {
-				r.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
-			}
 		}
-		results = append(results, r)
 	}
 
 	if jsonOutput {
@@ -142,39 +79,13 @@ func fabricHealth(jsonOutput bool) {
 
 // helper function to run SPARQL verification for a dataset.
 // The beamline is extracted from the DID itself.
-func fabricSPARQL(args []string, jsonOutput bool, limit int) {
-	if len(args) < 2 {
-		fmt.Println("ERROR: sparql requires <did>")
-		fmt.Println("Usage: foxden fabric sparql <did>")
-		os.Exit(1)
-	}
-	did := args[1]
-
+func fabricSPARQL(did string, jsonOutput bool, limit int) {
 	bl := utils.GetBeamline(did)
-	if bl == "" {
-		fmt.Printf("ERROR: cannot extract beamline from DID %q\n", did)
-		os.Exit(1)
-	}
-
-	encodedDid := url.PathEscape(did)
-	rurl := fmt.Sprintf("%s/beamlines/%s/datasets/%s/sparql",
-		srvConfig.Config.Services.FabricDataServiceURL, bl, encodedDid)
-	if verbose > 0 {
-		fmt.Println("HTTP GET", rurl)
-	}
-
-	resp, err := _httpReadRequest.Get(rurl)
+	data, err := foxdenClient().FabricNode().SPARQL(context.Background(), did)
 	if err != nil {
 		fmt.Println("ERROR:", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-
-	var data map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		fmt.Println("ERROR decoding response:", err)
-		os.Exit(1)
-	}
 
 	if jsonOutput {
 		if val, err := json.MarshalIndent(data, "", "  "); err == nil {
@@ -228,32 +139,12 @@ func termValue(binding map[string]any, key string) string {
 }
 
 // helper function to verify the catalog for a given beamline
-func fabricCatalog(args []string, jsonOutput bool) {
-	if len(args) < 2 {
-		fmt.Println("ERROR: catalog requires <beamline>")
-		fmt.Println("Usage: foxden fabric catalog <beamline>")
-		os.Exit(1)
-	}
-	bl := args[1]
-
-	rurl := fmt.Sprintf("%s/catalog/beamlines/%s/datasets",
-		srvConfig.Config.Services.FabricCatalogURL, bl)
-	if verbose > 0 {
-		fmt.Println("HTTP GET", rurl)
-	}
-
-	resp, err := _httpReadRequest.Get(rurl)
+func fabricCatalog(bl string, jsonOutput bool) {
+	data, err := foxdenClient().FabricNode().Catalog(context.Background(), bl)
 	if err != nil {
 		fmt.Println("ERROR:", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-
-	var data map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		fmt.Println("ERROR decoding response:", err)
-		os.Exit(1)
-	}
 
 	if jsonOutput {
 		if val, err := json.MarshalIndent(data, "", "  "); err == nil {
@@ -292,36 +183,33 @@ func fabricCatalog(args []string, jsonOutput bool) {
 }
 
 // helper function to list content of a bucket on s3 storage
-func fabricList(args []string, jsonOutput bool) {
-	// args contains [ls beamline]
-	if args[0] != "ls" {
-		fmt.Println("ERROR: wrong action", args)
-		os.Exit(1)
-	}
+func fabricList(bl string, opts OutputOptions) {
 	// get beamlines datasets from fabric node
-	bl := args[1]
-	rurl := fmt.Sprintf("%s/catalog/beamlines/%s/datasets", srvConfig.Config.Services.FabricCatalogURL, bl)
-	if verbose > 0 {
-		fmt.Println("HTTP GET", rurl)
-	}
-	resp, err := _httpReadRequest.Get(rurl)
+	data, err := foxdenClient().FabricNode().Catalog(context.Background(), bl)
 	if err != nil {
 		fmt.Println("ERROR:", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	var data map[string]any
-	if err := dec.Decode(&data); err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	if jsonOutput {
+	if opts.Format == "json" && len(opts.Fields) == 0 {
 		if val, err := json.MarshalIndent(data, "", " "); err == nil {
 			fmt.Println(string(val))
 		}
 		return
 	}
+	if !opts.Default() {
+		// list individual datasets of the catalog if they are present
+		records := []map[string]any{data}
+		if datasets, ok := data["dcat:dataset"].([]any); ok {
+			records = nil
+			for _, ds := range datasets {
+				if dsm, ok := ds.(map[string]any); ok {
+					records = append(records, dsm)
+				}
+			}
+		}
+		writeRecords(records, opts)
+		return
+	}
 	printMap(data)
 }
 
@@ -354,29 +242,11 @@ func readDIDsFromFile(path string) ([]string, error) {
 
 // ingestOneDID posts a single DID to the data-service ingest endpoint.
 func ingestOneDID(did string) {
-	bl := utils.GetBeamline(did)
-	if bl == "" {
-		fmt.Printf("ERROR: cannot extract beamline from DID %q\n", did)
-		os.Exit(1)
-	}
-	encodedDid := url.PathEscape(did)
-	rurl := fmt.Sprintf("%s/beamlines/%s/datasets/%s/foxden/ingest",
-		srvConfig.Config.Services.FabricDataServiceURL, bl, encodedDid)
-	if verbose > 0 {
-		fmt.Println("HTTP POST", rurl)
-	}
-	resp, err := _httpWriteRequest.Post(rurl, "", bytes.NewBuffer([]byte{}))
+	result, err := foxdenClient().FabricNode().Ingest(context.Background(), did)
 	if err != nil {
 		fmt.Println("ERROR:", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-
-	var result IngestRecord
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		fmt.Println("ERROR decoding response:", err)
-		os.Exit(1)
-	}
 	fmt.Printf("  ingested=%d did=%s graphIRI=%s\n", result.Ingested, result.Did, result.GraphIRI)
 }
 
@@ -384,18 +254,7 @@ func ingestOneDID(did string) {
 // The second argument is resolved in this order:
 //  1. If the path exists on disk → treat as a file containing one DID per line.
 //  2. Otherwise → treat as a literal DID string (must start with /beamline=).
-func fabricIngest(args []string) {
-	if len(args) != 2 {
-		fmt.Println("ERROR: wrong number of arguments")
-		fabricUsage()
-		os.Exit(1)
-	}
-	if args[0] != "ingest" {
-		fmt.Println("ERROR: wrong action", args)
-		os.Exit(1)
-	}
-
-	arg := args[1]
+func fabricIngest(arg string) {
 
 	// 1. Check whether the argument is an existing file first.
 	if _, err := os.Stat(arg); err == nil {
@@ -433,40 +292,76 @@ func fabricCommand() *cobra.Command {
 		Long:  "foxden fabric commands to access CHESS FabricNode service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonOutput, _ := cmd.Flags().GetBool("json")
-			limit, _ := cmd.Flags().GetInt("limit")
 			if len(args) == 0 {
 				fabricUsage()
 				return
 			}
-			switch args[0] {
-			case "ls":
-				if len(args) < 2 {
-					fmt.Println("ERROR: ls requires <beamline>")
-					os.Exit(1)
-				}
-				accessToken()
-				fabricList(args, jsonOutput)
-			case "ingest":
-				writeToken()
-				fabricIngest(args)
-			case "health":
-				accessToken()
-				fabricHealth(jsonOutput)
-			case "sparql":
-				accessToken()
-				fabricSPARQL(args, jsonOutput, limit)
-			case "catalog":
-				accessToken()
-				fabricCatalog(args, jsonOutput)
-			default:
-				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
-				fabricUsage()
-			}
+			fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
+			fabricUsage()
+		},
+	}
+	lsCmd := &cobra.Command{
+		Use:   "ls <beamline>",
+		Short: "list datasets of a beamline in the catalog",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			accessToken()
+			fabricList(args[0], outputOptions(cmd, "@id", "dct:title"))
+		},
+	}
+	ingestCmd := &cobra.Command{
+		Use:         "ingest <did|dids-file>",
+		Short:       "ingest DID or file of DIDs into FabricNode",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			fabricIngest(args[0])
+		},
+	}
+	ingestCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// complete dids and fall back to files when no did matches
+		dids, directive := completeDid(cmd, args, toComplete)
+		if len(args) == 0 && len(dids) == 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return dids, directive
+	}
+	healthCmd := &cobra.Command{
+		Use:   "health",
+		Short: "check health of data-service and catalog-service",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			accessToken()
+			fabricHealth(jsonOutput)
+		},
+	}
+	sparqlCmd := &cobra.Command{
+		Use:   "sparql <did>",
+		Short: "run SPARQL verification for a dataset",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			limit, _ := cmd.Flags().GetInt("limit")
+			accessToken()
+			fabricSPARQL(args[0], jsonOutput, limit)
+		},
+	}
+	sparqlCmd.ValidArgsFunction = completeDid
+	catalogCmd := &cobra.Command{
+		Use:   "catalog <beamline>",
+		Short: "verify catalog entry for a beamline",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			accessToken()
+			fabricCatalog(args[0], jsonOutput)
 		},
 	}
+	cmd.AddCommand(lsCmd, ingestCmd, healthCmd, sparqlCmd, catalogCmd)
 	cmd.PersistentFlags().Bool("json", false, "json output")
 	cmd.PersistentFlags().Int("limit", 5, "number of SPARQL triples to display (0 = all)")
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		fabricUsage()
 		return nil
@@ -1,13 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"strconv"
 
 	"github.com/CHESSComputing/golib/beamlines"
-	srvConfig "github.com/CHESSComputing/golib/config"
 )
 
 // MetadataParameters holds all parameters we may need to generate metadata record
@@ -101,18 +100,8 @@ func GenerateRecord(schema []beamlines.SchemaRecord) (map[string]any, error) {
 // helper function to generate metadata record
 func generateMetadataRecord(rec MetadataParameters) {
 	// fetch FOXDEN schemas
-	rurl := fmt.Sprintf("%s/schemas", srvConfig.Config.Services.FrontendURL)
-	resp, err := _httpReadRequest.Get(rurl)
-	if err != nil {
-		exit("unable to fetch schemas from FOXDEN service", err)
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from meta-data service", err)
-	}
-	var schemaRecords []map[string][]beamlines.SchemaRecord
-	err = json.Unmarshal(data, &schemaRecords)
+	schemaRecords, err := foxdenClient().Schemas(context.Background())
+	exit("unable to fetch schemas from FOXDEN service", err)
 
 	// generate metadata record
 	for _, schemaMap := range schemaRecords {
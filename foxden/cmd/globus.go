@@ -1,11 +1,9 @@
 package cmd
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	globus "github.com/CHESSComputing/golib/globus"
@@ -16,9 +14,12 @@ import (
 func globusUsage() {
 	fmt.Println("foxden globus <ls|search|link> [options]")
 	fmt.Println("options: --scope=<globus_scopes> --json")
+	fmt.Println("         --output=<table|wide|json|ndjson|yaml|csv|template> --fields=<keys> --template=<template>")
 	fmt.Println("\nExamples:")
 	fmt.Println("\n# search Globus records within CHESS pattern:")
 	fmt.Println("foxden globus search CHESS")
+	fmt.Println("\n# show Globus records within CHESS pattern as a table:")
+	fmt.Println("foxden globus search CHESS --output=table")
 	fmt.Println("\n# list all globus data records:")
 	fmt.Println("foxden globus ls <id:/path>")
 	fmt.Println("\n# create globus data link:")
@@ -68,15 +69,10 @@ func globusListRecord(token, spec string, jsonOutput bool) {
 
 }
 
-func globusSearch(token, pat string, jsonOutput bool) {
+func globusSearch(token, pat string, opts OutputOptions) {
 	records := globus.Search(token, pat)
-	if jsonOutput {
-		if data, err := json.MarshalIndent(records, "", " "); err == nil {
-			fmt.Println(string(data))
-		} else {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
-		}
+	if !opts.Default() {
+		writeRecords(toRecords(records), opts)
 		return
 	}
 
@@ -97,6 +93,25 @@ func globusLink(pat, path string) {
 	fmt.Println(gurl)
 }
 
+// helper function to setup globus options and obtain globus transfer token
+func globusToken(cmd *cobra.Command) (string, bool) {
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	if jsonOutput {
+		// set _jsonOutputError to properly handle error output in JSON format
+		_jsonOutputError = true
+	}
+	verbose, _ := cmd.Flags().GetInt("verbose")
+	if verbose > 0 {
+		globus.Verbose = verbose
+	}
+	scopes := []string{"urn:globus:auth:scope:transfer.api.globus.org:all"}
+	token, err := globus.Token(scopes)
+	if err != nil {
+		log.Fatalf("ERROR: unable to get globus token with scopes=%v, error=%v", scopes, err)
+	}
+	return token, jsonOutput
+}
+
 func globusCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "globus",
@@ -104,53 +119,56 @@ func globusCommand() *cobra.Command {
 		Long:  "foxden Globus commands to access Globus services through FOXDEN\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonOutput, _ := cmd.Flags().GetBool("json")
-			if jsonOutput {
-				// set _jsonOutputError to properly handle error output in JSON format
-				_jsonOutputError = true
-			}
-			verbose, _ := cmd.Flags().GetInt("verbose")
-			if verbose > 0 {
-				globus.Verbose = verbose
-			}
-			scopes := []string{"urn:globus:auth:scope:transfer.api.globus.org:all"}
 			if len(args) == 0 {
 				globusUsage()
-			} else if args[0] == "ls" {
-				token, err := globus.Token(scopes)
-				if err != nil {
-					log.Fatalf("ERROR: unable to get globus token with scopes=%v, error=%v", scopes, err)
-				}
-				if len(args) == 2 {
-					eid := strings.Split(args[1], ":")[0]
-					globusListRecord(token, eid, jsonOutput)
-				} else {
-					globusListRecord(token, "", jsonOutput)
-				}
-			} else if args[0] == "search" {
-				pat := ""
-				if len(args) == 2 {
-					pat = args[1]
-				}
-				token, err := globus.Token(scopes)
-				if err != nil {
-					log.Fatalf("ERROR: unable to get globus token with scopes=%v, error=%v", scopes, err)
-				}
-				globusSearch(token, pat, jsonOutput)
-			} else if args[0] == "link" {
-				pat := "CHESS Raw"
-				path := ""
-				if len(args) == 2 {
-					path = args[1]
-				}
-				globusLink(pat, path)
 			} else {
 				fmt.Printf("WARNING: unsupported option(s) %+v", args)
 			}
 		},
 	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "ls [endpoint]",
+		Short: "list Globus endpoint content",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			token, jsonOutput := globusToken(cmd)
+			if len(args) == 1 {
+				eid := strings.Split(args[0], ":")[0]
+				globusListRecord(token, eid, jsonOutput)
+			} else {
+				globusListRecord(token, "", jsonOutput)
+			}
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "search [pattern]",
+		Short: "search Globus endpoints",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pat := ""
+			if len(args) == 1 {
+				pat = args[0]
+			}
+			token, _ := globusToken(cmd)
+			globusSearch(token, pat, outputOptions(cmd))
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "link [path]",
+		Short: "provide Globus link to given path",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pat := "CHESS Raw"
+			path := ""
+			if len(args) == 1 {
+				path = args[0]
+			}
+			globusLink(pat, path)
+		},
+	})
 	cmd.PersistentFlags().Bool("json", false, "json output")
 	cmd.PersistentFlags().Int("verbose", 0, "verbosity level")
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		globusUsage()
 		return nil
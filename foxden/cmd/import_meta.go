@@ -0,0 +1,392 @@
+package cmd
+
+// CHESComputing foxden tool: meta-data bulk import module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CHESSComputing/golib/beamlines"
+	services "github.com/CHESSComputing/golib/services"
+	utils "github.com/CHESSComputing/golib/utils"
+)
+
+// ImportRecord represents single record read from import source
+type ImportRecord struct {
+	Source string
+	Index  int
+	Record map[string]any
+}
+
+// ImportResult represents outcome of single record import, in dry-run mode
+// it carries prepared record which would be submitted to MetaData service
+type ImportResult struct {
+	Did    string               `json:"did"`
+	Source string               `json:"source"`
+	Index  int                  `json:"index"`
+	Status string               `json:"status"`
+	Error  string               `json:"error,omitempty"`
+	Record *services.MetaRecord `json:"record,omitempty"`
+}
+
+// ImportOptions represents options of bulk import
+type ImportOptions struct {
+	Schema     string
+	SchemaFile string
+	Attrs      string
+	Sep        string
+	Div        string
+	Workers    int
+	DryRun     bool
+	Results    string
+}
+
+// helper function to read import records from given file, the file can be
+// either NDJSON file, JSON file with single record or JSON list of records
+func readImportFile(fname string) ([]ImportRecord, error) {
+	var out []ImportRecord
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.HasSuffix(fname, ".ndjson") || strings.HasSuffix(fname, ".jsonl") {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		idx := 0
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return out, fmt.Errorf("unable to parse %s record %d: %w", fname, idx, err)
+			}
+			out = append(out, ImportRecord{Source: fname, Index: idx, Record: rec})
+			idx++
+		}
+		return out, scanner.Err()
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(data, &rec); err == nil {
+		return []ImportRecord{{Source: fname, Index: 0, Record: rec}}, nil
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("file %s must contain JSON object, JSON list of objects or NDJSON records", fname)
+	}
+	for idx, rec := range records {
+		out = append(out, ImportRecord{Source: fname, Index: idx, Record: rec})
+	}
+	return out, nil
+}
+
+// helper function to read import records from given directory or file
+func readImportRecords(input string) ([]ImportRecord, error) {
+	isDir, err := isDirectory(input)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		return readImportFile(input)
+	}
+	var files []string
+	for _, pat := range []string{"*.json", "*.ndjson", "*.jsonl"} {
+		matches, err := filepath.Glob(filepath.Join(input, pat))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	var out []ImportRecord
+	for _, fname := range files {
+		records, err := readImportFile(fname)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, records...)
+	}
+	return out, nil
+}
+
+// helper function to read failed records from results file of previous import
+func readFailedRecords(fname string) ([]ImportRecord, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	failed := make(map[string]map[int]bool)
+	dec := json.NewDecoder(file)
+	for {
+		var res ImportResult
+		if err := dec.Decode(&res); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to parse results file %s: %w", fname, err)
+		}
+		if res.Status == "ok" || res.Status == "dry-run" {
+			continue
+		}
+		if _, ok := failed[res.Source]; !ok {
+			failed[res.Source] = make(map[int]bool)
+		}
+		failed[res.Source][res.Index] = true
+	}
+	var sources []string
+	for src := range failed {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	var out []ImportRecord
+	for _, src := range sources {
+		records, err := readImportFile(src)
+		if err != nil {
+			return out, err
+		}
+		for _, rec := range records {
+			if failed[src][rec.Index] {
+				out = append(out, rec)
+			}
+		}
+	}
+	return out, nil
+}
+
+// schemaCache keeps loaded FOXDEN schemas, it either uses provided schema
+// file for all records or fetches schemas from FOXDEN Frontend service
+type schemaCache struct {
+	schemaFile string
+	schemas    map[string]*beamlines.Schema
+	records    []map[string][]beamlines.SchemaRecord
+}
+
+// helper function to get schema for given schema name
+func (c *schemaCache) get(name string) (*beamlines.Schema, error) {
+	key := name
+	if c.schemaFile != "" {
+		key = c.schemaFile
+	}
+	if s, ok := c.schemas[key]; ok {
+		return s, nil
+	}
+	fname := c.schemaFile
+	if fname == "" {
+		// fetch FOXDEN schemas once and write requested one into temporary file
+		// which we can load via beamlines.Schema
+		if c.records == nil {
+			records, err := foxdenClient().Schemas(context.Background())
+			if err != nil {
+				return nil, err
+			}
+			c.records = records
+		}
+		var srecords []beamlines.SchemaRecord
+		for _, smap := range c.records {
+			if val, ok := smap[name]; ok {
+				srecords = val
+				break
+			}
+		}
+		if srecords == nil {
+			return nil, fmt.Errorf("schema %s is not found in FOXDEN, please use --schema-file option", name)
+		}
+		data, err := json.Marshal(srecords)
+		if err != nil {
+			return nil, err
+		}
+		tmpFile, err := os.CreateTemp("", fmt.Sprintf("foxden-%s-*.json", name))
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmpFile.Name())
+		if _, err := tmpFile.Write(data); err != nil {
+			tmpFile.Close()
+			return nil, err
+		}
+		tmpFile.Close()
+		fname = tmpFile.Name()
+	}
+	s := &beamlines.Schema{FileName: fname}
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	c.schemas[key] = s
+	return s, nil
+}
+
+// helper function to prepare and validate import record
+func prepareImportRecord(user string, irec ImportRecord, opts ImportOptions, cache *schemaCache) (services.MetaRecord, ImportResult) {
+	record := irec.Record
+	res := ImportResult{Source: irec.Source, Index: irec.Index}
+	did, ok := record["did"]
+	if !ok || did == "" {
+		did = utils.CreateDID(record, opts.Attrs, opts.Sep, opts.Div)
+		record["did"] = did
+	}
+	res.Did = fmt.Sprintf("%v", did)
+	schemaName := opts.Schema
+	if schemaName == "" {
+		if val, ok := record["schema"]; ok {
+			schemaName = fmt.Sprintf("%v", val)
+		}
+	}
+	mrec := services.MetaRecord{Schema: schemaName, Record: record}
+	if schemaName == "" {
+		res.Status = "invalid"
+		res.Error = "schema is not provided"
+		return mrec, res
+	}
+	schema, err := cache.get(schemaName)
+	if err != nil {
+		res.Status = "invalid"
+		res.Error = err.Error()
+		return mrec, res
+	}
+	if report := schema.ValidateAll(record); report != "" {
+		res.Status = "invalid"
+		res.Error = report
+		return mrec, res
+	}
+	// add user info to metadata record
+	if _, ok := record["user"]; !ok {
+		record["user"] = user
+	}
+	return mrec, res
+}
+
+// helper function to submit import record to MetaData service
+func submitImportRecord(mrec services.MetaRecord, res ImportResult) ImportResult {
+	data, err := submitMetaRecord(mrec, false)
+	if err != nil {
+		res.Status = "failed"
+		res.Error = err.Error()
+		return res
+	}
+	var response services.ServiceResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		res.Status = "failed"
+		res.Error = string(data)
+		return res
+	}
+	if response.Status == "ok" {
+		res.Status = "ok"
+	} else {
+		res.Status = "failed"
+		res.Error = response.Error
+		if res.Error == "" {
+			res.Error = string(data)
+		}
+	}
+	return res
+}
+
+// helper function to import meta-data records in bulk
+func metaImportRecords(user, input, retryFile string, opts ImportOptions) {
+	var records []ImportRecord
+	var err error
+	if retryFile != "" {
+		records, err = readFailedRecords(retryFile)
+		exit(fmt.Sprintf("unable to read failed records from %s", retryFile), err)
+	} else {
+		records, err = readImportRecords(input)
+		exit(fmt.Sprintf("unable to read records from %s", input), err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No records to import")
+		return
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Results == "" {
+		opts.Results = "import-results.ndjson"
+	}
+	if retryFile != "" && opts.Results == retryFile {
+		exit("results file should differ from --retry-failed file", errors.New("wrong results file"))
+	}
+	rfile, err := os.Create(opts.Results)
+	exit(fmt.Sprintf("unable to create results file %s", opts.Results), err)
+	defer rfile.Close()
+	enc := json.NewEncoder(rfile)
+
+	// start workers which submit valid records
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	record := func(res ImportResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[res.Status]++
+		enc.Encode(res)
+		if res.Status == "ok" || res.Status == "dry-run" {
+			fmt.Printf("%-8s %s\n", res.Status, res.Did)
+		} else {
+			fmt.Printf("%-8s %s (%s:%d) %s\n", res.Status, res.Did, res.Source, res.Index, res.Error)
+		}
+	}
+	type job struct {
+		mrec services.MetaRecord
+		res  ImportResult
+	}
+	jobs := make(chan job, opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				record(submitImportRecord(j.mrec, j.res))
+			}
+		}()
+	}
+
+	// validate records and dispatch valid ones to workers
+	cache := &schemaCache{schemaFile: opts.SchemaFile, schemas: make(map[string]*beamlines.Schema)}
+	for _, irec := range records {
+		mrec, res := prepareImportRecord(user, irec, opts, cache)
+		if res.Status != "" {
+			record(res)
+			continue
+		}
+		if opts.DryRun {
+			res.Status = "dry-run"
+			res.Record = &mrec
+			record(res)
+			continue
+		}
+		jobs <- job{mrec: mrec, res: res}
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Println("---")
+	fmt.Printf("Total records: %d", len(records))
+	for _, status := range []string{"ok", "dry-run", "invalid", "failed"} {
+		if n, ok := counts[status]; ok {
+			fmt.Printf(", %s: %d", status, n)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("Results are written to %s\n", opts.Results)
+	if counts["invalid"] > 0 || counts["failed"] > 0 {
+		fmt.Printf("To re-submit failed records use: foxden meta import --retry-failed=%s\n", opts.Results)
+		os.Exit(1)
+	}
+}
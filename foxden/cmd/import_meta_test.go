@@ -0,0 +1,141 @@
+package cmd
+
+// CHESComputing foxden tool: tests of meta-data import module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CHESSComputing/golib/beamlines"
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// helper function to write test file with given content
+func writeTestFile(t *testing.T, fname, data string) string {
+	t.Helper()
+	if err := os.WriteFile(fname, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+// helper function to setup FOXDEN configuration and schema file used by import tests
+func importSetup(t *testing.T) ImportOptions {
+	t.Helper()
+	config := srvConfig.Config
+	srvConfig.Config = &srvConfig.SrvConfig{}
+	srvConfig.Config.CHESSMetaData.SkipKeys = []string{"did", "user", "schema"}
+	t.Cleanup(func() { srvConfig.Config = config })
+	schema := `[
+{"key":"btr","type":"string","optional":false},
+{"key":"cycle","type":"string","optional":false},
+{"key":"sample_name","type":"string","optional":true}
+]`
+	fname := writeTestFile(t, filepath.Join(t.TempDir(), "test.json"), schema)
+	return ImportOptions{SchemaFile: fname, Attrs: "btr,cycle", Sep: "/", Div: "="}
+}
+
+// TestPrepareImportRecord tests validation of import records and creation of their dids
+func TestPrepareImportRecord(t *testing.T) {
+	opts := importSetup(t)
+	tests := []struct {
+		record string
+		schema string
+		did    string
+		status string
+	}{
+		{`{"btr":"abc","cycle":"2024-1"}`, "test", "/btr=abc/cycle=2024-1", ""},
+		{`{"did":"/x=1","btr":"abc","cycle":"2024-1","schema":"test"}`, "", "/x=1", ""},
+		{`{"btr":"abc","cycle":"2024-1"}`, "", "/btr=abc/cycle=2024-1", "invalid"},
+		{`{"btr":"abc","cycle":"2024-1","unknown":1}`, "test", "/btr=abc/cycle=2024-1", "invalid"},
+		{`{"btr":"abc"}`, "test", "/btr=abc", "invalid"},
+	}
+	for _, tc := range tests {
+		opts.Schema = tc.schema
+		cache := &schemaCache{schemaFile: opts.SchemaFile, schemas: make(map[string]*beamlines.Schema)}
+		irec := ImportRecord{Source: "test.ndjson", Index: 1, Record: unmarshalTest(t, tc.record)}
+		mrec, res := prepareImportRecord("tester", irec, opts, cache)
+		if res.Did != tc.did || res.Status != tc.status || res.Source != "test.ndjson" || res.Index != 1 {
+			t.Errorf("%s: wrong result %+v", tc.record, res)
+			continue
+		}
+		if res.Status == "invalid" {
+			if res.Error == "" {
+				t.Errorf("%s: invalid record without error", tc.record)
+			}
+			continue
+		}
+		if mrec.Schema != "test" || mrec.Record["did"] != tc.did || mrec.Record["user"] != "tester" {
+			t.Errorf("%s: wrong prepared record %+v", tc.record, mrec)
+		}
+	}
+}
+
+// TestImportDryRun tests that dry-run results carry records which would be submitted
+func TestImportDryRun(t *testing.T) {
+	opts := importSetup(t)
+	dir := t.TempDir()
+	input := writeTestFile(t, filepath.Join(dir, "records.ndjson"), `{"btr":"abc","cycle":"2024-1"}
+{"btr":"xyz","cycle":"2024-2","sample_name":"Ti"}
+`)
+	opts.Schema = "test"
+	opts.DryRun = true
+	opts.Results = filepath.Join(dir, "results.ndjson")
+	metaImportRecords("tester", input, "", opts)
+	data, err := os.ReadFile(opts.Results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrong number of results %d", len(lines))
+	}
+	for i, did := range []string{"/btr=abc/cycle=2024-1", "/btr=xyz/cycle=2024-2"} {
+		var res ImportResult
+		if err := json.Unmarshal([]byte(lines[i]), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != "dry-run" || res.Record == nil {
+			t.Errorf("wrong dry-run result %+v", res)
+			continue
+		}
+		if res.Record.Schema != "test" || res.Record.Record["did"] != did || res.Record.Record["user"] != "tester" {
+			t.Errorf("wrong dry-run record %+v", res.Record)
+		}
+	}
+}
+
+// TestReadFailedRecords tests that only failed and invalid records are re-submitted
+func TestReadFailedRecords(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTestFile(t, filepath.Join(dir, "records.ndjson"), `{"btr":"a"}
+{"btr":"b"}
+{"btr":"c"}
+{"btr":"d"}
+`)
+	var results []string
+	for i, status := range []string{"ok", "failed", "dry-run", "invalid"} {
+		data, err := json.Marshal(ImportResult{Source: src, Index: i, Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, string(data))
+	}
+	rfile := writeTestFile(t, filepath.Join(dir, "results.ndjson"), strings.Join(results, "\n"))
+	records, err := readFailedRecords(rfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Record["btr"] != "b" || records[1].Record["btr"] != "d" {
+		t.Errorf("wrong failed records %+v", records)
+	}
+	bad := writeTestFile(t, filepath.Join(dir, "bad.ndjson"), "not json")
+	if _, err := readFailedRecords(bad); err == nil {
+		t.Error("malformed results file was accepted")
+	}
+}
@@ -6,12 +6,11 @@ package cmd
 //
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"strings"
 	"syscall"
 	"time"
@@ -21,8 +20,16 @@ import (
 	"gopkg.in/jcmturner/gokrb5.v7/client"
 	"gopkg.in/jcmturner/gokrb5.v7/config"
 	"gopkg.in/jcmturner/gokrb5.v7/credentials"
+	"gopkg.in/jcmturner/gokrb5.v7/keytab"
+	"gopkg.in/jcmturner/gokrb5.v7/messages"
+	"gopkg.in/jcmturner/gokrb5.v7/types"
 )
 
+// helper function to check if user can be asked for credentials
+func interactive() bool {
+	return !_noPrompt && terminal.IsTerminal(int(syscall.Stdin))
+}
+
 // helper function to return user and password
 func userPassword() (string, string) {
 	reader := bufio.NewReader(os.Stdin)
@@ -44,15 +51,29 @@ func userPassword() (string, string) {
 	return strings.TrimSpace(username), strings.TrimSpace(password)
 }
 
-// https://github.com/jcmturner/gokrb5/issues/7
-func kuserFromCache(cacheFile string) (*credentials.Credentials, error) {
+// helper function to load kerberos configuration
+func krb5Config() (*config.Config, error) {
 	kfile := srvConfig.Config.Kerberos.Krb5Conf
 	if kfile == "" {
 		kfile = "/etc/krb5.conf"
 	}
-	cfg, err := config.Load(kfile)
+	return config.Load(kfile)
+}
+
+// https://github.com/jcmturner/gokrb5/issues/7
+func kuserFromCache(cacheFile string) (*credentials.Credentials, error) {
+	cfg, err := krb5Config()
+	if err != nil {
+		return nil, err
+	}
 	ccache, err := credentials.LoadCCache(cacheFile)
+	if err != nil {
+		return nil, err
+	}
 	client, err := client.NewClientFromCCache(ccache, cfg)
+	if err != nil {
+		return nil, err
+	}
 	err = client.Login()
 	if err != nil {
 		return nil, err
@@ -61,49 +82,142 @@ func kuserFromCache(cacheFile string) (*credentials.Credentials, error) {
 
 }
 
-func userTicket() (string, []byte) {
-	// get user login/password
-	user, password := userPassword()
-	fname := fmt.Sprintf("krb5_%d_%v", os.Getuid(), time.Now().Unix())
-	//     tmpFile, err := ioutil.TempFile("/tmp", fname)
-	tmpFile, err := os.OpenFile(tempFilePath(fname), os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		exit("Unable to get tmp file", err)
+// helper function to split user principal into user name and realm, the
+// default realm of kerberos configuration is used if realm is not provided
+func userRealm(principal string, cfg *config.Config) (string, string) {
+	if user, realm, ok := strings.Cut(principal, "@"); ok {
+		return user, realm
 	}
-	defer os.Remove(tmpFile.Name())
+	return principal, cfg.LibDefaults.DefaultRealm
+}
 
-	cmd := exec.Command("kinit", "-c", tmpFile.Name(), user)
-	stdin, err := cmd.StdinPipe()
+// helper function to perform AS exchange with KDC and return obtained TGT
+// in credentials cache format, the ticket is kept in memory only
+func kerberosLogin(cl *client.Client) ([]byte, error) {
+	realm := cl.Credentials.Domain()
+	req, err := messages.NewASReqForTGT(realm, cl.Config, cl.Credentials.CName())
 	if err != nil {
-		msg := fmt.Sprintf("Fail to execute '%v'", cmd)
-		exit(msg, err)
+		return nil, err
 	}
-
-	// start command execution
-	err = cmd.Start()
+	rep, err := cl.ASExchange(realm, req, 0)
 	if err != nil {
-		exit("unable to start kinit", err)
+		return nil, err
 	}
+	return marshalCCache(rep)
+}
 
-	// write our input to our pipe for command
-	io.WriteString(stdin, password)
+// helper function to encode principal in credentials cache format
+func ccachePrincipal(buf *bytes.Buffer, name types.PrincipalName, realm string) {
+	binary.Write(buf, binary.BigEndian, name.NameType)
+	binary.Write(buf, binary.BigEndian, int32(len(name.NameString)))
+	ccacheData(buf, []byte(realm))
+	for _, comp := range name.NameString {
+		ccacheData(buf, []byte(comp))
+	}
+}
 
-	// explicitly close the input informing command that we're done
-	stdin.Close()
+// helper function to encode counted data in credentials cache format
+func ccacheData(buf *bytes.Buffer, data []byte) {
+	binary.Write(buf, binary.BigEndian, int32(len(data)))
+	buf.Write(data)
+}
 
-	// wait for command to finish its execution
-	cmd.Wait()
+// helper function to encode AS reply into credentials cache (version 4) used
+// by kinit, see https://web.mit.edu/kerberos/krb5-devel/doc/formats/ccache_file_format.html
+func marshalCCache(rep messages.ASRep) ([]byte, error) {
+	ticket, err := rep.Ticket.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	part := rep.DecryptedEncPart
+	buf := new(bytes.Buffer)
+	// file format version and empty header
+	buf.Write([]byte{5, 4, 0, 0})
+	ccachePrincipal(buf, rep.CName, rep.CRealm)
+	// TGT credential
+	ccachePrincipal(buf, rep.CName, rep.CRealm)
+	ccachePrincipal(buf, part.SName, part.SRealm)
+	binary.Write(buf, binary.BigEndian, int16(part.Key.KeyType))
+	ccacheData(buf, part.Key.KeyValue)
+	startTime := part.StartTime
+	if startTime.IsZero() {
+		startTime = part.AuthTime
+	}
+	for _, t := range []time.Time{part.AuthTime, startTime, part.EndTime, part.RenewTill} {
+		var ts uint32
+		if !t.IsZero() {
+			ts = uint32(t.Unix())
+		}
+		binary.Write(buf, binary.BigEndian, ts)
+	}
+	// is_skey
+	buf.WriteByte(0)
+	flags := make([]byte, 4)
+	copy(flags, part.Flags.Bytes)
+	buf.Write(flags)
+	// no addresses and authorization data
+	binary.Write(buf, binary.BigEndian, int32(0))
+	binary.Write(buf, binary.BigEndian, int32(0))
+	ccacheData(buf, ticket)
+	// no second ticket
+	ccacheData(buf, nil)
+	return buf.Bytes(), nil
+}
 
-	// read tmp file content and return the ticket
-	ticket, err := ioutil.ReadFile(tmpFile.Name())
-	if err != nil || len(ticket) == 0 {
-		exit("unable to read kerberos credentials", err)
+// helper function to check if given file is kerberos keytab
+func isKeytab(fname string) (*keytab.Keytab, bool) {
+	data, err := os.ReadFile(fname)
+	// keytab files start with 0x0502 while credentials caches use 0x0504
+	if err != nil || len(data) < 2 || data[0] != 5 || data[1] != 2 {
+		return nil, false
+	}
+	kt := keytab.New()
+	if err := kt.Unmarshal(data); err != nil || len(kt.Entries) == 0 {
+		return nil, false
 	}
+	return kt, true
+}
+
+// helper function to obtain kerberos ticket with principal of given keytab
+func keytabTicket(kt *keytab.Keytab) (string, []byte, error) {
+	cfg, err := krb5Config()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to load kerberos configuration: %w", err)
+	}
+	principal := kt.Entries[0].Principal
+	user := strings.Join(principal.Components, "/")
+	realm := principal.Realm
+	if realm == "" {
+		realm = cfg.LibDefaults.DefaultRealm
+	}
+	cl := client.NewClientWithKeytab(user, realm, kt, cfg, client.DisablePAFXFAST(true))
+	ticket, err := kerberosLogin(cl)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to login to kerberos with keytab of %s@%s: %w", user, realm, err)
+	}
+	return user, ticket, nil
+}
+
+// helper function to obtain kerberos ticket with user name and password,
+// the ticket is obtained in-process and never written to disk
+func userTicket() (string, []byte) {
+	cfg, err := krb5Config()
+	exit("unable to load kerberos configuration", err)
+	principal, password := userPassword()
+	user, realm := userRealm(principal, cfg)
+	cl := client.NewClientWithPassword(user, realm, password, cfg, client.DisablePAFXFAST(true))
+	ticket, err := kerberosLogin(cl)
+	exit(fmt.Sprintf("unable to login to kerberos as %s@%s", user, realm), err)
 	return user, ticket
 }
 
 // helper function to get kerberos ticket
 func getKerberosTicket(krbFile string) (string, []byte) {
+	if kt, ok := isKeytab(krbFile); ok {
+		user, ticket, err := keytabTicket(kt)
+		exit("unable to obtain kerberos ticket", err)
+		return user, ticket
+	}
 	if krbFile != "" {
 		// read krbFile and check user credentials
 		creds, err := kuserFromCache(krbFile)
@@ -118,7 +232,7 @@ func getKerberosTicket(krbFile string) (string, []byte) {
 			msg := fmt.Sprintf("user credentials are expired, please obtain new/valid kerberos file %s", krbFile)
 			exit(msg, nil)
 		}
-		ticket, err := ioutil.ReadFile(krbFile)
+		ticket, err := os.ReadFile(krbFile)
 		if err != nil {
 			exit("unable to read kerberos credentials", err)
 		}
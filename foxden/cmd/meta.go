@@ -5,11 +5,10 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -19,6 +18,7 @@ import (
 	srvConfig "github.com/CHESSComputing/golib/config"
 	services "github.com/CHESSComputing/golib/services"
 	utils "github.com/CHESSComputing/golib/utils"
+	client "github.com/CHESSComputing/gotools/foxden/client"
 	"github.com/spf13/cobra"
 )
 
@@ -61,71 +61,23 @@ func metadata(site string) MetaDataRecord {
 
 // helper function to get meta-data records
 func getMeta(murl, user, query string, skeys []string, sorder, idx, limit int) ([]map[string]any, int, error) {
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpReadRequest.Token = token
-			defer func() {
-				_httpReadRequest.Token = ""
-			}()
-		}
-	}
-	var records []map[string]any
-	if query == "" {
-		query = "{}"
-	}
-	rec := services.ServiceRequest{
-		Client:       "foxden",
-		ServiceQuery: services.ServiceQuery{Query: query, Idx: idx, Limit: limit, SortKeys: skeys, SortOrder: sorder},
+	if _offline {
+		return mirrorGetMeta(query, skeys, sorder, idx, limit)
 	}
-
-	data, err := json.Marshal(rec)
-	rurl := fmt.Sprintf("%s/search", murl)
 	if os.Getenv("FOXDEN_VERBOSE") != "" {
-		fmt.Println("FOXDEN query:", rurl)
-	}
-	resp, err := _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		exit("fail /search, unable to fetch data from meta-data service", err)
+		fmt.Println("FOXDEN query:", fmt.Sprintf("%s/search", murl))
 	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from meta-data service", err)
-	}
-	err = json.Unmarshal(data, &records)
-
-	//     var response services.ServiceResponse
-	//     err = json.Unmarshal(data, &response)
+	c := metaClient(murl)
+	ctx := context.Background()
+	opts := client.SearchOptions{Idx: idx, Limit: limit, SortKeys: skeys, SortOrder: sorder}
+	records, err := c.Search(ctx, query, opts)
 	if err != nil {
-		log.Println("response data", string(data))
-		exit("Unable to unmarshal the data", err)
+		return nil, 0, err
 	}
 
 	// get total number of records
-	rec = services.ServiceRequest{
-		Client:       "foxden",
-		ServiceQuery: services.ServiceQuery{Query: query},
-	}
-
-	data, err = json.Marshal(rec)
-	rurl = fmt.Sprintf("%s/count", murl)
-	resp, err = _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		exit("fail /count, unable to fetch data from meta-data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from meta-data service", err)
-	}
-	var nrecords int
-	err = json.Unmarshal(data, &nrecords)
-	if err != nil {
-		exit("unable to unmarshal the data", err)
-	}
-	return records, nrecords, nil
+	nrecords, err := c.Count(ctx, query)
+	return records, nrecords, err
 }
 
 func didMetaData() (string, string, string) {
@@ -150,19 +102,51 @@ func didMetaData() (string, string, string) {
 func metaUsage() {
 	attrs, sep, div := didMetaData()
 	fmt.Println("foxden meta <ls|rm|view> [options]")
-	fmt.Println("foxden meta <add|amend> <file.json> {options}")
-	fmt.Println("options: --schema=<schema> --did-attrs=<attrs> --did-sep=<separator> --did-div=<divider> --json --elapsed-time")
+	fmt.Println("foxden meta <add|amend|diff> <file.json> {options}")
+	fmt.Println("foxden meta diff <DID1> <DID2> {options}")
+	fmt.Println("foxden meta rm --query=<query> --workers=<N> --yes --dry-run")
+	fmt.Println("foxden meta import <dir|file.ndjson|file.json> --workers=<N> --dry-run --results=<file> --retry-failed=<file>")
+	fmt.Println("foxden meta restore <DID> --with-provenance")
+	fmt.Println("foxden meta trash <ls|purge> [DID]")
+	fmt.Println("foxden meta patch <DID> --set key=value --unset key --patch=<patch.json> --dry-run")
+	fmt.Println("foxden meta export <query> --format=<ndjson|csv|json|tar> --out=<file> --fields=<keys>")
+	fmt.Println("options: --schema=<schema> --did-attrs=<attrs> --did-sep=<separator> --did-div=<divider> --json --elapsed-time --offline")
+	fmt.Println("         --output=<table|wide|json|ndjson|yaml|csv|template> --fields=<keys> --template=<template>")
 	fmt.Println("\nExamples:")
 	fmt.Println("\n# list meta data records:")
 	fmt.Println("foxden meta ls")
 	fmt.Println("\n# list meta data records for specific range:")
 	fmt.Println("foxden meta ls --idx=10 --limit=20")
+	fmt.Println("\n# list all records fetching them page by page, records are shown as pages arrive:")
+	fmt.Println("foxden meta ls --all --page-size=500")
+	fmt.Println("\n# list meta data records as a table, other formats: wide, json, ndjson, yaml, csv, template")
+	fmt.Println("foxden meta ls --output=table")
+	fmt.Println("\n# list selected (dotted) fields of meta data records in CSV format")
+	fmt.Println("foxden meta ls --output=csv --fields=did,beamline,btr")
+	fmt.Println("\n# print only dids of meta data records using Go template")
+	fmt.Println("foxden meta ls --all --template='{{.did}}'")
 	fmt.Println("\n# list all meta data records using specific sorting key(s) and order:")
 	fmt.Println("foxden meta ls --sort-keys=date --sort-order=1")
 	fmt.Println("\n# list specific meta-data record:")
 	fmt.Println("foxden meta view <DID>")
+	fmt.Println("\n# list or view meta data records from local mirror, see 'foxden mirror' how to pull records")
+	fmt.Println("foxden meta ls beamline:3a --offline")
+	fmt.Println("foxden meta view <DID> --offline")
 	fmt.Println("\n# remove meta-data record:")
 	fmt.Println("foxden meta rm <DID>")
+	fmt.Println("\n# show meta-data records matching given query which would be removed")
+	fmt.Println("foxden meta rm --query='{\"beamline\":\"test\"}' --dry-run")
+	fmt.Println("\n# remove all meta-data records matching given query without confirmation using 8 concurrent workers")
+	fmt.Println("foxden meta rm --query=beamline:test --yes --workers=8")
+	fmt.Println("\n# removed records along with their provenance are kept in local trash ($HOME/.foxden.trash, $HOME/.foxden.trash/<context> or FOXDEN_TRASH)")
+	fmt.Println("foxden meta trash ls")
+	fmt.Println("\n# restore removed meta-data record from local trash, only meta-data record is restored,")
+	fmt.Println("# its provenance records are saved into <trash file>.provenance.json to be re-added via 'foxden prov add'")
+	fmt.Println("foxden meta restore <DID>")
+	fmt.Println("\n# restore removed meta-data record and re-register its provenance records in DataBookkeeping service")
+	fmt.Println("foxden meta restore <DID> --with-provenance")
+	fmt.Println("\n# purge all records or records of specific did from local trash:")
+	fmt.Println("foxden meta trash purge [DID]")
 	fmt.Println("\n# add meta-data record with given schema, file and did attributes which create a did value:")
 	fmt.Printf("foxden meta add <file.json> --schema=<schema> --did-attrs=%s --did-sep=%s --did-div=%s\n", attrs, sep, div)
 	fmt.Println("\n# the same as above since it is default values, schema is part of the record")
@@ -171,6 +155,28 @@ func metaUsage() {
 	fmt.Println("foxden meta add <file.json> --json")
 	fmt.Println("\n# amend record in Metadata record, schema is part of the record")
 	fmt.Println("foxden meta amend <file.json>")
+	fmt.Println("\n# show field-level difference between local record and stored one, like diff(1)")
+	fmt.Println("# it exits with code 0 if records are identical, 1 if they differ and 2 on errors")
+	fmt.Println("foxden meta diff <file.json>")
+	fmt.Println("\n# show field-level difference between two stored records in JSON format")
+	fmt.Println("foxden meta diff <DID1> <DID2> --json")
+	fmt.Println("\n# patch meta-data record: set (nested) keys, values are parsed as JSON if possible, and remove a key")
+	fmt.Println("foxden meta patch <DID> --set sample_name=Ti64 --set detectors.gain=2 --unset comment")
+	fmt.Println("\n# patch meta-data record with RFC 6902 JSON Patch (list) or RFC 7396 merge patch (object) and show changes only")
+	fmt.Println("foxden meta patch <DID> --patch=patch.json --dry-run")
+	fmt.Println("\n# import all records from given directory (*.json, *.ndjson files), records are validated against FOXDEN schemas")
+	fmt.Println("foxden meta import /path/records --workers=4")
+	fmt.Println("\n# validate records against local schema file, records which would be submitted are written into results file")
+	fmt.Println("foxden meta import records.ndjson --schema-file=/path/ID3A.json --dry-run")
+	fmt.Println("\n# re-submit records which failed in previous import")
+	fmt.Println("foxden meta import --retry-failed=import-results.ndjson --results=retry-results.ndjson")
+	fmt.Println("\n# export all meta-data records matching given query to NDJSON file")
+	fmt.Println("foxden meta export '{\"beamline\":\"3a\"}' --out=records.ndjson")
+	fmt.Println("\n# export selected (nested) fields of all records into CSV file, use --limit to change page size")
+	fmt.Println("# without --fields records are kept in memory to use keys of all records as CSV header")
+	fmt.Println("foxden meta export cycle:2024-1 --format=csv --fields=did,beamline,btr,sample_name --out=records.csv")
+	fmt.Println("\n# export all meta-data records into tarball with one JSON file per record")
+	fmt.Println("foxden meta export '{}' --format=tar --out=records.tar.gz")
 	fmt.Println("\n# show example of meta-data record")
 	fmt.Println("foxden meta info")
 	fmt.Println("\n# generate meta-data record for given FOXDEN schema")
@@ -180,17 +186,6 @@ func metaUsage() {
 // helper function to add meta data record
 func metaAddRecord(user, schemaName string, data []byte, attrs, sep, div string, jsonOutput bool, update bool, elapsedTime bool) {
 	defer TrackTime(elapsedTime)()
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpWriteRequest.Token = token
-			defer func() {
-				_httpWriteRequest.Token = ""
-			}()
-		}
-	}
-
 	var record map[string]any
 	err := json.Unmarshal(data, &record)
 	exit("unable to unmarshal data", err)
@@ -220,23 +215,11 @@ func metaAddRecord(user, schemaName string, data []byte, attrs, sep, div string,
 	}
 
 	mrec.Record = record
-	data, err = json.MarshalIndent(mrec, "", "  ")
-	exit("unable to marshal data", err)
-	rurl := fmt.Sprintf("%s", srvConfig.Config.Services.MetaDataURL)
-	var resp *http.Response
-	if update {
-		resp, err = _httpWriteRequest.Put(rurl, "application/json", bytes.NewBuffer(data))
-	} else {
-		resp, err = _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-	}
-	msg := fmt.Sprintf("fail %s unable to fetch data from meta-data service", rurl)
-	exit(msg, err)
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	exit("unable to read data from meta-data service", err)
+	data, err = submitMetaRecord(mrec, update)
+	exit(fmt.Sprintf("fail %s unable to fetch data from meta-data service", srvConfig.Config.Services.MetaDataURL), err)
 
 	if jsonOutput {
-		fmt.Printf(string(data))
+		fmt.Print(string(data))
 		return
 	}
 	var response services.ServiceResponse
@@ -255,6 +238,15 @@ func metaAddRecord(user, schemaName string, data []byte, attrs, sep, div string,
 	}
 }
 
+// helper function to submit meta data record to MetaData service, it returns service response
+func submitMetaRecord(mrec services.MetaRecord, update bool) ([]byte, error) {
+	c := foxdenClient().MetaData()
+	if update {
+		return responseBody(c.Update(context.Background(), mrec))
+	}
+	return responseBody(c.Add(context.Background(), mrec))
+}
+
 // helper function to delete meta-data record
 func metaDeleteRecord(user, did string, jsonOutput bool, elapsedTime bool) {
 	defer TrackTime(elapsedTime)()
@@ -262,57 +254,58 @@ func metaDeleteRecord(user, did string, jsonOutput bool, elapsedTime bool) {
 		metaUsage()
 		os.Exit(1)
 	}
-	token, err := deleteAccessToken()
-	exit("", err)
-	rurl := fmt.Sprintf("%s/record?did=%s&user=%s", srvConfig.Config.Services.MetaDataURL, did, user)
-	req, err := http.NewRequest("DELETE", rurl, nil)
-	exit("", err)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	exit("", err)
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	tfile, response, body, err := deleteMetaRecord(user, did)
 	exit("", err)
-	var response services.ServiceResponse
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		fmt.Println("ERROR", err, "response body", string(body))
-		os.Exit(1)
-	}
 	if jsonOutput {
 		fmt.Println(string(body))
 		return
 	}
 	if response.Status == "ok" {
 		fmt.Printf("SUCCESS: record %s was successfully removed\n", did)
+		fmt.Printf("record copy is saved in %s, use 'foxden meta restore %s' to restore it\n", tfile, did)
 	} else {
 		fmt.Printf("WARNING: record %s failed to be removed\n", did)
 	}
 
 }
 
-// helper funtion to list meta-data records
-func metaListRecord(user, spec string, skeys []string, sorder, idx, limit int, jsonOutput, elapsedTime bool) {
-	defer TrackTime(elapsedTime)()
-	rurl := srvConfig.Config.MetaDataURL
-	records, nrecords, err := getMeta(rurl, user, spec, skeys, sorder, idx, limit)
+// helper function to delete meta-data record, it keeps copy of the record and its
+// provenance in local trash and returns trash file name along with service response
+func deleteMetaRecord(user, did string) (string, services.ServiceResponse, []byte, error) {
+	var response services.ServiceResponse
+	// keep copy of the record and its provenance in local trash to be able to restore it
+	tfile, err := trashMetaRecord(user, did)
 	if err != nil {
-		fmt.Println("ERROR", err)
-		os.Exit(1)
+		return "", response, nil, fmt.Errorf("unable to save record %s into local trash, record is not deleted: %w", did, err)
 	}
-	if jsonOutput {
-		if data, err := json.MarshalIndent(records, "", " "); err == nil {
-			fmt.Println(string(data))
-		} else {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
-		}
-		return
+	// delete token is obtained by FOXDEN client for every request, it allows to
+	// refresh token during long bulk deletions
+	body, err := responseBody(foxdenClient().MetaData().Delete(context.Background(), did, user))
+	if err != nil {
+		os.Remove(tfile)
+		return "", response, nil, err
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		os.Remove(tfile)
+		return "", response, body, fmt.Errorf("unable to parse response body %s: %w", string(body), err)
 	}
+	if response.Status != "ok" {
+		os.Remove(tfile)
+		return "", response, body, nil
+	}
+	return tfile, response, body, nil
+}
 
-	for _, r := range records {
-		fmt.Println("---")
+// helper funtion to list meta-data records
+func metaListRecord(user, spec string, skeys []string, sorder, idx, limit, pageSize int, all bool, opts OutputOptions, elapsedTime bool) {
+	defer TrackTime(elapsedTime)()
+	rurl := srvConfig.Config.MetaDataURL
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		return getMeta(rurl, user, spec, skeys, sorder, idx, limit)
+	}
+	pager := NewPager(fetch, idx, limit, pageSize, all)
+	listPages(pager, opts, func(r map[string]any) {
 		fmt.Printf("did        : %v\n", r["did"])
 		fmt.Printf("schema     : %v\n", r["schema"])
 		fmt.Printf("cycle      : %v\n", r["cycle"])
@@ -325,10 +318,10 @@ func metaListRecord(user, spec string, skeys []string, sorder, idx, limit int, j
 			tstamp = time.Unix(secondsSinceEpoch, 0).Format(time.RFC3339)
 		}
 		fmt.Printf("date       : %v\n", tstamp)
-		//         fmt.Printf("%+v", r)
+	})
+	if opts.Default() {
+		listFooter(pager, idx, all)
 	}
-	fmt.Println("---")
-	fmt.Printf("Showing %d-%d out of %d records, for more records use --idx/--limit options\n", idx, idx+limit, nrecords)
 }
 
 // helper function to print meta data records in Json format
@@ -367,6 +360,15 @@ func metaJsonRecord(user, did string, skeys []string, sorder, idx, limit int, js
 	}
 }
 
+// helper function to get did options of meta command
+func metaDidOptions(cmd *cobra.Command) (string, string, string, string) {
+	schema, _ := cmd.Flags().GetString("schema")
+	attrs, _ := cmd.Flags().GetString("did-attrs")
+	sep, _ := cmd.Flags().GetString("did-sep")
+	div, _ := cmd.Flags().GetString("did-div")
+	return schema, attrs, sep, div
+}
+
 func metaCommand() *cobra.Command {
 	attrs, sep, div := didMetaData()
 	var schema string
@@ -375,94 +377,274 @@ func metaCommand() *cobra.Command {
 		Short: "foxden MetaData commands",
 		Long:  "foxden MetaData commands to access FOXDEN MetaData service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
-		Run: func(cmd *cobra.Command, args []string) {
-			schema, _ := cmd.Flags().GetString("schema")
-			attrs, _ := cmd.Flags().GetString("did-attrs")
-			sep, _ := cmd.Flags().GetString("did-sep")
-			div, _ := cmd.Flags().GetString("did-div")
-			sortKeys, _ := cmd.Flags().GetString("sort-keys")
-			sortOrder, _ := cmd.Flags().GetInt("sort-order")
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			jsonOutput, _ := cmd.Flags().GetBool("json")
-			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
-			idx, _ := cmd.Flags().GetInt("idx")
-			limit, _ := cmd.Flags().GetInt("limit")
 			if jsonOutput {
 				// set _jsonOutputError to properly handle error output in JSON format
 				_jsonOutputError = true
 			}
-			var skeys []string
-			if sortKeys != "" {
-				for _, k := range strings.Split(sortKeys, ",") {
-					skeys = append(skeys, k)
-				}
-			}
-			if sortOrder == 0 {
-				sortOrder = -1
-			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				metaUsage()
-			} else if args[0] == "ls" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					metaListRecord(user, args[1], skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				} else {
-					metaListRecord(user, "", skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				}
-			} else if args[0] == "view" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					metaJsonRecord(user, args[1], skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				} else {
-					metaJsonRecord(user, "", skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				}
-			} else if args[0] == "add" {
-				token, _ := writeToken()
-				var fname string
-				if len(args) == 2 {
-					fname = args[1]
-				} else {
-					metaUsage()
-					exit("please provide <file.json>", errors.New("no input file"))
-				}
-				user := getUserFromToken(token)
-				data, err := readJsonData(fname)
-				exit("unable to read data from input file", err)
-				metaAddRecord(user, schema, data, attrs, sep, div, jsonOutput, false, elapsedTime)
-			} else if args[0] == "amend" {
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v", args)
+			}
+		},
+	}
+	lsCmd := &cobra.Command{
+		Use:   "ls [spec]",
+		Short: "list meta-data records",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			pageSize, _ := cmd.Flags().GetInt("page-size")
+			all, _ := cmd.Flags().GetBool("all")
+			opts := outputOptions(cmd, "did", "schema", "cycle", "beamline", "btr", "sample_name", "date")
+			skeys, sortOrder := sortOptions(cmd)
+			user := onlineUser()
+			var spec string
+			if len(args) == 1 {
+				spec = args[0]
+			}
+			metaListRecord(user, spec, skeys, sortOrder, idx, limit, pageSize, all, opts, elapsedTime)
+		},
+	}
+	lsCmd.ValidArgsFunction = completeQuery
+	viewCmd := &cobra.Command{
+		Use:   "view [did]",
+		Short: "show meta-data record",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			skeys, sortOrder := sortOptions(cmd)
+			user := onlineUser()
+			var did string
+			if len(args) == 1 {
+				did = args[0]
+			}
+			metaJsonRecord(user, did, skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
+		},
+	}
+	viewCmd.ValidArgsFunction = completeDid
+	for _, action := range []string{"add", "amend"} {
+		update := action == "amend"
+		short := "add meta-data record"
+		if update {
+			short = "amend meta-data record"
+		}
+		cmd.AddCommand(&cobra.Command{
+			Use:         action + " <file.json>",
+			Short:       short,
+			Args:        cobra.ExactArgs(1),
+			Annotations: requireScopes("write"),
+			Run: func(cmd *cobra.Command, args []string) {
+				jsonOutput, _ := cmd.Flags().GetBool("json")
+				elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+				schema, attrs, sep, div := metaDidOptions(cmd)
 				token, _ := writeToken()
-				var fname string
-				if len(args) == 2 {
-					fname = args[1]
-				} else {
-					metaUsage()
-					exit("please provide <file.json>", errors.New("no input file"))
-				}
 				user := getUserFromToken(token)
-				data, err := readJsonData(fname)
+				data, err := readJsonData(args[0])
 				exit("unable to read data from input file", err)
-				metaAddRecord(user, schema, data, attrs, sep, div, jsonOutput, true, elapsedTime)
-			} else if args[0] == "info" {
-				recordInfo("metadata.json")
-			} else if args[0] == "generate" {
-				p := MetadataParameters{Schema: schema}
-				generateMetadataRecord(p)
-			} else if args[0] == "rm" {
-				deleteToken()
-				user, _ := getUserToken()
-				if user == "" {
-					exit("unable to get user name from token value", errors.New("unknown user"))
-				}
-				if len(args) != 2 {
-					metaUsage()
-					exit("please provide did", errors.New("no did"))
-				}
-				did := args[1]
-				metaDeleteRecord(user, did, jsonOutput, elapsedTime)
-			} else {
-				fmt.Printf("WARNING: unsupported option(s) %+v", args)
+				metaAddRecord(user, schema, data, attrs, sep, div, jsonOutput, update, elapsedTime)
+			},
+		})
+	}
+	diffCmd := &cobra.Command{
+		Use:   "diff <file.json|did> [did]",
+		Short: "compare local meta-data record or two dids",
+		// number of arguments is checked by metaDiffRecord which exits with code 2
+		// on errors, see diff(1)
+		Args: cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			_, attrs, sep, div := metaDidOptions(cmd)
+			user, _ := getUserToken()
+			metaDiffRecord(user, args, attrs, sep, div, jsonOutput)
+		},
+	}
+	diffCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// first argument can be either local file or did
+		if len(args) == 0 && !strings.HasPrefix(toComplete, "/") {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return completeDids(cmd, args, toComplete)
+	}
+	restoreCmd := &cobra.Command{
+		Use:         "restore <did>",
+		Short:       "restore removed meta-data record from local trash",
+		Long:        "restore removed meta-data record from local trash\n\nBy default only meta-data record is restored, provenance records of removed\nrecord are saved into <trash file>.provenance.json file and should be re-added\nvia 'foxden prov add' if necessary, use --with-provenance to re-register them\nin DataBookkeeping service",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			withProvenance, _ := cmd.Flags().GetBool("with-provenance")
+			token, _ := writeToken()
+			user := getUserFromToken(token)
+			metaRestoreRecord(user, args[0], withProvenance, jsonOutput)
+		},
+	}
+	restoreCmd.ValidArgsFunction = completeTrashDids
+	restoreCmd.Flags().Bool("with-provenance", false, "re-register provenance records of restored record in DataBookkeeping service")
+	trashCmd := &cobra.Command{
+		Use:   "trash",
+		Short: "manage local trash of removed meta-data records",
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			metaUsage()
+			exit("please provide trash command: ls or purge", errors.New("wrong trash command"))
+		},
+	}
+	trashCmd.AddCommand(&cobra.Command{
+		Use:               "ls [did]",
+		Short:             "list removed meta-data records",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeTrashDids,
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			var did string
+			if len(args) == 1 {
+				did = args[0]
+			}
+			metaTrashList(did, jsonOutput)
+		},
+	})
+	trashCmd.AddCommand(&cobra.Command{
+		Use:               "purge [did]",
+		Short:             "purge all or specific removed meta-data records",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeTrashDids,
+		Run: func(cmd *cobra.Command, args []string) {
+			var did string
+			if len(args) == 1 {
+				did = args[0]
+			}
+			metaTrashPurge(did)
+		},
+	})
+	patchCmd := &cobra.Command{
+		Use:         "patch <did>",
+		Short:       "patch meta-data record",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			setValues, _ := cmd.Flags().GetStringArray("set")
+			unsetKeys, _ := cmd.Flags().GetStringArray("unset")
+			patchFile, _ := cmd.Flags().GetString("patch")
+			token, _ := writeToken()
+			user := getUserFromToken(token)
+			metaPatchRecord(user, args[0], setValues, unsetKeys, patchFile, jsonOutput, dryRun)
+		},
+	}
+	patchCmd.ValidArgsFunction = completeDid
+	importCmd := &cobra.Command{
+		Use:         "import <dir|file.ndjson|file.json>",
+		Short:       "import meta-data records",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			schema, attrs, sep, div := metaDidOptions(cmd)
+			schemaFile, _ := cmd.Flags().GetString("schema-file")
+			workers, _ := cmd.Flags().GetInt("workers")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			results, _ := cmd.Flags().GetString("results")
+			retryFailed, _ := cmd.Flags().GetString("retry-failed")
+			token, _ := writeToken()
+			var input string
+			if len(args) == 1 {
+				input = args[0]
+			} else if retryFailed == "" {
+				metaUsage()
+				exit("please provide <dir|file.ndjson|file.json>", errors.New("no input"))
+			}
+			user := getUserFromToken(token)
+			opts := ImportOptions{
+				Schema:     schema,
+				SchemaFile: schemaFile,
+				Attrs:      attrs,
+				Sep:        sep,
+				Div:        div,
+				Workers:    workers,
+				DryRun:     dryRun,
+				Results:    results,
+			}
+			metaImportRecords(user, input, retryFailed, opts)
+		},
+	}
+	exportCmd := &cobra.Command{
+		Use:   "export [query]",
+		Short: "export meta-data records",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			limit, _ := cmd.Flags().GetInt("limit")
+			format, _ := cmd.Flags().GetString("format")
+			out, _ := cmd.Flags().GetString("out")
+			fields, _ := cmd.Flags().GetString("fields")
+			skeys, sortOrder := sortOptions(cmd)
+			user, _ := getUserToken()
+			var spec string
+			if len(args) == 1 {
+				spec = utils.NormalizeSpec(args[0])
+			}
+			metaExportRecords(user, spec, skeys, sortOrder, limit, format, out, fields)
+		},
+	}
+	exportCmd.ValidArgsFunction = completeQuery
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "show example of meta-data record",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			recordInfo("metadata.json")
+		},
+	}
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "generate meta-data record for given schema",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			schema, _, _, _ := metaDidOptions(cmd)
+			p := MetadataParameters{Schema: schema}
+			generateMetadataRecord(p)
+		},
+	}
+	rmCmd := &cobra.Command{
+		Use:         "rm [did]",
+		Short:       "remove meta-data record or records matching --query",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: requireScopes("delete"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			workers, _ := cmd.Flags().GetInt("workers")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			query, _ := cmd.Flags().GetString("query")
+			yes, _ := cmd.Flags().GetBool("yes")
+			skeys, sortOrder := sortOptions(cmd)
+			user, _ := getUserToken()
+			if user == "" {
+				exit("unable to get user name from token value", errors.New("unknown user"))
+			}
+			if query != "" {
+				metaBulkDelete(user, utils.NormalizeSpec(query), skeys, sortOrder, workers, yes, dryRun, jsonOutput)
+				return
+			}
+			if len(args) != 1 {
+				metaUsage()
+				exit("please provide did or --query", errors.New("no did"))
 			}
+			metaDeleteRecord(user, args[0], jsonOutput, elapsedTime)
 		},
 	}
+	rmCmd.ValidArgsFunction = completeDid
+	cmd.AddCommand(lsCmd, viewCmd, diffCmd, restoreCmd, trashCmd, patchCmd, importCmd, exportCmd, infoCmd, generateCmd, rmCmd)
 	cmd.PersistentFlags().String("schema", schema, "schema name (ID1A3, ID3A, ID4B)")
 	cmd.PersistentFlags().String("did-attrs", attrs, "did attributes")
 	cmd.PersistentFlags().String("did-sep", sep, "did separator")
@@ -473,6 +655,26 @@ func metaCommand() *cobra.Command {
 	cmd.PersistentFlags().Int("sort-order", -1, "sort order: 1 ascending, -1 desecnding (default)")
 	cmd.PersistentFlags().Int("idx", 0, "start index, default 0")
 	cmd.PersistentFlags().Int("limit", 100, "limit number of records to given value, default 100")
+	cmd.PersistentFlags().Int("page-size", 0, "number of records to fetch per request (default: limit)")
+	cmd.PersistentFlags().Bool("all", false, "list all records fetching them page by page")
+	cmd.PersistentFlags().BoolVar(&_offline, "offline", false, "read records from local mirror, see 'foxden mirror'")
+	cmd.PersistentFlags().String("format", "", "export format: ndjson, csv, json or tar (default: derived from --out or ndjson)")
+	cmd.PersistentFlags().String("out", "", "output file name (default: stdout)")
+	cmd.PersistentFlags().String("schema-file", "", "schema file to validate imported records (default: use FOXDEN schemas)")
+	cmd.PersistentFlags().Int("workers", 4, "number of concurrent workers to submit or remove records")
+	cmd.PersistentFlags().Bool("dry-run", false, "show what would be submitted or removed without changing records")
+	cmd.PersistentFlags().String("query", "", "query to select meta-data records to remove")
+	cmd.PersistentFlags().Bool("yes", false, "do not ask for confirmation to remove records")
+	cmd.PersistentFlags().StringArray("set", []string{}, "set record key to given value, e.g. --set key=value (can be repeated)")
+	cmd.PersistentFlags().StringArray("unset", []string{}, "remove record key (can be repeated)")
+	cmd.PersistentFlags().String("patch", "", "JSON Patch (RFC 6902) or JSON merge patch (RFC 7396) file")
+	cmd.PersistentFlags().String("results", "import-results.ndjson", "results file of import (did, status, error)")
+	cmd.PersistentFlags().String("retry-failed", "", "results file of previous import to re-submit its failed records")
+	cmd.RegisterFlagCompletionFunc("schema", completeSchemas)
+	cmd.RegisterFlagCompletionFunc("sort-keys", completeSearchKeys)
+	cmd.RegisterFlagCompletionFunc("query", completeSearchKeys)
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"ndjson", "csv", "json", "tar"}, cobra.ShellCompDirectiveNoFileComp))
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		metaUsage()
 		return nil
@@ -0,0 +1,729 @@
+package cmd
+
+// CHESComputing foxden tool: offline mirror module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	utils "github.com/CHESSComputing/golib/utils"
+	match "github.com/CHESSComputing/gotools/foxden/match"
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite" // pure Go SQLite driver, foxden is built with CGO_ENABLED=0
+)
+
+// _offline defines if commands should read records from local mirror
+var _offline bool
+
+// mirror database schema, records table keeps meta-data records (source=meta)
+// along with list of SpecScans (source=spec) and provenance (source=prov)
+// records of every did, keys table keeps keys of mirrored meta-data records
+var mirrorSchema = []string{
+	`CREATE TABLE IF NOT EXISTS records (
+		did TEXT NOT NULL,
+		source TEXT NOT NULL,
+		schema TEXT,
+		beamline TEXT,
+		cycle TEXT,
+		btr TEXT,
+		sample_name TEXT,
+		date INTEGER,
+		data TEXT NOT NULL,
+		pulled_at INTEGER NOT NULL,
+		PRIMARY KEY (did, source)
+	)`,
+	`CREATE TABLE IF NOT EXISTS pulls (
+		query TEXT PRIMARY KEY,
+		last_date INTEGER,
+		pulled_at INTEGER,
+		nrecords INTEGER
+	)`,
+	`CREATE TABLE IF NOT EXISTS keys (
+		key TEXT PRIMARY KEY
+	)`,
+	`CREATE INDEX IF NOT EXISTS records_beamline ON records (source, beamline)`,
+	`CREATE INDEX IF NOT EXISTS records_cycle ON records (source, cycle)`,
+	`CREATE INDEX IF NOT EXISTS records_btr ON records (source, btr)`,
+	`CREATE INDEX IF NOT EXISTS records_date ON records (source, date)`,
+}
+
+// mirrorColumns defines columns of records table which are used to filter
+// and sort meta-data records in SQL, all other keys are matched in Go
+var mirrorColumns = []string{"did", "schema", "beamline", "cycle", "btr", "sample_name", "date"}
+
+// _mirror keeps local mirror database used by offline commands
+var _mirror *sql.DB
+
+// MirrorPull represents status of pulled query
+type MirrorPull struct {
+	Query    string `json:"query"`
+	LastDate int64  `json:"last_date"`
+	PulledAt int64  `json:"pulled_at"`
+	Records  int    `json:"nrecords"`
+	Stale    bool   `json:"stale"`
+}
+
+// MirrorStatus represents status of local mirror
+type MirrorStatus struct {
+	File    string         `json:"file"`
+	Size    int64          `json:"size"`
+	Records map[string]int `json:"records"`
+	Pulls   []MirrorPull   `json:"pulls"`
+}
+
+// helper function to return location of local mirror database, every
+// configuration context has its own mirror
+func mirrorFile() string {
+	if fname := os.Getenv("FOXDEN_MIRROR"); fname != "" {
+		return fname
+	}
+	if _currentContext != "" {
+		return filepath.Join(os.Getenv("HOME"), fmt.Sprintf(".foxden.%s.mirror.db", _currentContext))
+	}
+	return filepath.Join(os.Getenv("HOME"), ".foxden.mirror.db")
+}
+
+// helper function to open local mirror database
+func openMirror(create bool) (*sql.DB, error) {
+	fname := mirrorFile()
+	if _, err := os.Stat(fname); err != nil && !create {
+		return nil, fmt.Errorf("local mirror %s does not exist, please run 'foxden mirror pull <query>' first", fname)
+	}
+	db, err := sql.Open("sqlite", fname)
+	if err != nil {
+		return nil, err
+	}
+	for _, stm := range mirrorSchema {
+		if _, err := db.Exec(stm); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// helper function to get local mirror database of offline commands, the
+// database is opened once and re-used by all pages of the command
+func offlineMirror() (*sql.DB, error) {
+	if _mirror != nil {
+		return _mirror, nil
+	}
+	db, err := openMirror(false)
+	if err != nil {
+		return nil, err
+	}
+	_mirror = db
+	return db, nil
+}
+
+// helper function to get user name from the token unless we run in offline mode
+func onlineUser() string {
+	if _offline {
+		return ""
+	}
+	user, _ := getUserToken()
+	return user
+}
+
+// helper function to convert record attribute into mirror column value, keys
+// are looked-up in the same (case-insensitive) way as they are matched
+func mirrorValue(rec map[string]any, key string) any {
+	val, ok := match.Value(rec, key)
+	if !ok || val == nil {
+		return nil
+	}
+	return csvValue(val)
+}
+
+// helper function to store record in mirror database
+func mirrorUpsert(tx *sql.Tx, did, source string, rec map[string]any, data any, now int64) error {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var date any
+	if val, ok := match.Value(rec, "date"); ok {
+		if v, ok := val.(float64); ok {
+			date = int64(v)
+		}
+	}
+	stm := `INSERT OR REPLACE INTO records
+		(did, source, schema, beamline, cycle, btr, sample_name, date, data, pulled_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.Exec(stm, did, source,
+		mirrorValue(rec, "schema"), mirrorValue(rec, "beamline"), mirrorValue(rec, "cycle"),
+		mirrorValue(rec, "btr"), mirrorValue(rec, "sample_name"), date, string(buf), now)
+	return err
+}
+
+// helper function to pull meta-data records matching given query into local mirror,
+// records are pulled incrementally, i.e. only records with date equal or newer than
+// last pulled record are fetched unless full flag is set
+func mirrorPull(user, spec string, withSpec, withProv, full bool, pageSize int) {
+	query := "{}"
+	if spec != "" {
+		query = searchSpec(spec)
+	}
+	db, err := openMirror(true)
+	exit("unable to open local mirror", err)
+	defer db.Close()
+
+	var lastDate int64
+	if !full {
+		row := db.QueryRow("SELECT last_date FROM pulls WHERE query=?", query)
+		if err := row.Scan(&lastDate); err != nil && err != sql.ErrNoRows {
+			exit("unable to read mirror status", err)
+		}
+	}
+	fetchSpec := query
+	if lastDate > 0 {
+		fetchSpec = fmt.Sprintf(`{"$and":[%s,{"date":{"$gte":%d}}]}`, query, lastDate)
+	}
+
+	rurl := srvConfig.Config.MetaDataURL
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		return getMeta(rurl, user, fetchSpec, []string{"date"}, 1, idx, limit)
+	}
+	pager := NewPager(fetch, 0, 0, pageSize, true)
+	now := time.Now().Unix()
+	maxDate := lastDate
+	var dids []string
+	for pager.Next() {
+		tx, err := db.Begin()
+		exit("unable to start mirror transaction", err)
+		for _, rec := range pager.Records() {
+			did := fmt.Sprintf("%v", rec["did"])
+			if err := mirrorUpsert(tx, did, "meta", rec, rec, now); err != nil {
+				tx.Rollback()
+				exit(fmt.Sprintf("unable to store record %s", did), err)
+			}
+			for key := range rec {
+				if _, err := tx.Exec("INSERT OR IGNORE INTO keys (key) VALUES (?)", key); err != nil {
+					tx.Rollback()
+					exit(fmt.Sprintf("unable to store keys of record %s", did), err)
+				}
+			}
+			if val, ok := rec["date"].(float64); ok && int64(val) > maxDate {
+				maxDate = int64(val)
+			}
+			dids = append(dids, did)
+		}
+		err = tx.Commit()
+		exit("unable to commit mirror transaction", err)
+		fmt.Fprintf(os.Stderr, "pulled %d/%d records\r", pager.Count(), pager.Total())
+	}
+	exit("unable to fetch meta-data records", pager.Err())
+	fmt.Fprintln(os.Stderr)
+
+	// pull SpecScans and provenance records of updated dids
+	nspec, nprov := 0, 0
+	if withSpec || withProv {
+		tx, err := db.Begin()
+		exit("unable to start mirror transaction", err)
+		for _, did := range dids {
+			if withSpec {
+				var records []map[string]any
+				specQuery := fmt.Sprintf(`{"did":%q}`, did)
+				fetch := func(idx, limit int) ([]map[string]any, int, error) {
+					records, err := getSpecScans(user, specQuery, idx, limit)
+					return records, -1, err
+				}
+				specPager := NewPager(fetch, 0, 0, pageSize, true)
+				for specPager.Next() {
+					records = append(records, specPager.Records()...)
+				}
+				exit("unable to fetch SpecScans records", specPager.Err())
+				if len(records) > 0 {
+					err := mirrorUpsert(tx, did, "spec", nil, records, now)
+					exit(fmt.Sprintf("unable to store SpecScans records of %s", did), err)
+					nspec += len(records)
+				}
+			}
+			if withProv {
+				records := getProvRecords(did, "provenance")
+				if len(records) > 0 {
+					err := mirrorUpsert(tx, did, "prov", nil, records, now)
+					exit(fmt.Sprintf("unable to store provenance records of %s", did), err)
+					nprov += len(records)
+				}
+			}
+		}
+		err = tx.Commit()
+		exit("unable to commit mirror transaction", err)
+	}
+
+	stm := "INSERT OR REPLACE INTO pulls (query, last_date, pulled_at, nrecords) VALUES (?, ?, ?, ?)"
+	_, err = db.Exec(stm, query, maxDate, now, len(dids))
+	exit("unable to update mirror status", err)
+	msg := fmt.Sprintf("SUCCESS: %d meta-data records", len(dids))
+	if withSpec {
+		msg += fmt.Sprintf(", %d SpecScans records", nspec)
+	}
+	if withProv {
+		msg += fmt.Sprintf(", %d provenance records", nprov)
+	}
+	fmt.Printf("%s pulled into %s\n", msg, mirrorFile())
+}
+
+// helper function to show status of local mirror
+func mirrorShowStatus(maxAge time.Duration, jsonOutput bool) {
+	db, err := openMirror(false)
+	exit("unable to open local mirror", err)
+	defer db.Close()
+	status := MirrorStatus{File: mirrorFile(), Records: make(map[string]int)}
+	if info, err := os.Stat(status.File); err == nil {
+		status.Size = info.Size()
+	}
+	rows, err := db.Query("SELECT source, COUNT(*) FROM records GROUP BY source")
+	exit("unable to read mirror records", err)
+	for rows.Next() {
+		var source string
+		var count int
+		err := rows.Scan(&source, &count)
+		exit("unable to read mirror records", err)
+		status.Records[source] = count
+	}
+	rows.Close()
+	rows, err = db.Query("SELECT query, last_date, pulled_at, nrecords FROM pulls ORDER BY pulled_at DESC")
+	exit("unable to read mirror status", err)
+	for rows.Next() {
+		var p MirrorPull
+		err := rows.Scan(&p.Query, &p.LastDate, &p.PulledAt, &p.Records)
+		exit("unable to read mirror status", err)
+		p.Stale = time.Since(time.Unix(p.PulledAt, 0)) > maxAge
+		status.Pulls = append(status.Pulls, p)
+	}
+	rows.Close()
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(status, "", "  ")
+		exit("unable to marshal mirror status", err)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("mirror  : %s (%d bytes)\n", status.File, status.Size)
+	fmt.Printf("records : meta %d, spec %d, prov %d\n", status.Records["meta"], status.Records["spec"], status.Records["prov"])
+	for _, p := range status.Pulls {
+		pulled := time.Unix(p.PulledAt, 0)
+		fmt.Println("---")
+		fmt.Printf("query     : %s\n", p.Query)
+		fmt.Printf("pulled at : %s (%s ago)\n", pulled.Format(time.RFC3339), time.Since(pulled).Round(time.Second))
+		if p.LastDate > 0 {
+			fmt.Printf("last date : %s\n", time.Unix(p.LastDate, 0).Format(time.RFC3339))
+		}
+		fmt.Printf("records   : %d\n", p.Records)
+		if p.Stale {
+			fmt.Printf("WARNING   : mirror is older than %s, please run 'foxden mirror pull %s'\n", maxAge, p.Query)
+		}
+	}
+}
+
+// helper function to read meta-data records from local mirror using given
+// SQL statement which selects data column
+func mirrorRecords(db *sql.DB, stm string, args ...any) ([]map[string]any, error) {
+	var records []map[string]any
+	rows, err := db.Query(stm, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return records, err
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// helper function to get keys of mirrored meta-data records, keys are
+// written at pull time and mirrors created before keys table are filled once
+func mirrorKeys(db *sql.DB) ([]string, error) {
+	var keys []string
+	rows, err := db.Query("SELECT key FROM keys")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil || len(keys) > 0 {
+		return keys, err
+	}
+	records, err := mirrorRecords(db, "SELECT data FROM records WHERE source='meta'")
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		for key := range rec {
+			keys = append(keys, key)
+		}
+	}
+	keys = utils.List2Set(keys)
+	for _, key := range keys {
+		if _, err := db.Exec("INSERT OR IGNORE INTO keys (key) VALUES (?)", key); err != nil {
+			return keys, err
+		}
+	}
+	return keys, nil
+}
+
+// helper function to convert search spec into query map for local mirror
+func mirrorSpec(db *sql.DB, spec string) (map[string]any, error) {
+	// validate keys against keys of mirrored records since we may run offline
+	return localSpec(spec, func() ([]string, error) { return mirrorKeys(db) })
+}
+
+// mirrorWhere represents SQL conditions of query spec on mirror columns
+type mirrorWhere struct {
+	conds   []string
+	args    []any
+	columns []string // text columns used by conditions
+	partial bool     // spec has conditions which are not expressed in SQL
+}
+
+// helper function to convert query spec into SQL conditions on mirror
+// columns, text columns hold JSON lists for list values and therefore rows
+// with list values are always selected and matched in Go
+func (w *mirrorWhere) add(query map[string]any) {
+	for key, cond := range query {
+		if key == "$and" {
+			list, _ := cond.([]any)
+			for _, item := range list {
+				if sub, ok := item.(map[string]any); ok {
+					w.add(sub)
+				} else {
+					w.partial = true
+				}
+			}
+			continue
+		}
+		if !utils.InList(key, mirrorColumns) || !w.condition(key, cond) {
+			w.partial = true
+		}
+	}
+}
+
+// helper function to add SQL condition of given column, it returns false if
+// condition can not be expressed in SQL
+func (w *mirrorWhere) condition(col string, cond any) bool {
+	ops, ok := cond.(map[string]any)
+	if !ok {
+		ops = map[string]any{"$eq": cond}
+	}
+	var conds []string
+	var args []any
+	for op, arg := range ops {
+		switch op {
+		case "$eq", "$in":
+			vals := []any{arg}
+			if op == "$in" {
+				if vals, ok = arg.([]any); !ok || len(vals) == 0 {
+					return false
+				}
+			}
+			for _, val := range vals {
+				// text columns are compared with strings and date with numbers
+				switch val.(type) {
+				case float64:
+					if col != "date" {
+						return false
+					}
+				case string:
+					if col == "date" {
+						return false
+					}
+				default:
+					return false
+				}
+			}
+			cond := fmt.Sprintf("%s IN (%s)", col, strings.TrimSuffix(strings.Repeat("?,", len(vals)), ","))
+			if col != "date" {
+				cond = fmt.Sprintf("(%s OR %s LIKE '[%%')", cond, col)
+			}
+			conds = append(conds, cond)
+			args = append(args, vals...)
+		case "$gt", "$gte", "$lt", "$lte":
+			if _, ok := arg.(float64); !ok || col != "date" {
+				return false
+			}
+			sqlOps := map[string]string{"$gt": ">", "$gte": ">=", "$lt": "<", "$lte": "<="}
+			conds = append(conds, fmt.Sprintf("date %s ?", sqlOps[op]))
+			args = append(args, arg)
+		default:
+			return false
+		}
+	}
+	w.conds = append(w.conds, conds...)
+	w.args = append(w.args, args...)
+	if col != "date" && !utils.InList(col, w.columns) {
+		w.columns = append(w.columns, col)
+	}
+	return true
+}
+
+// helper function to check if given text columns of mirrored meta-data
+// records hold list values
+func mirrorListValues(db *sql.DB, columns []string) (bool, error) {
+	if len(columns) == 0 {
+		return false, nil
+	}
+	var conds []string
+	for _, col := range columns {
+		conds = append(conds, fmt.Sprintf("%s LIKE '[%%'", col))
+	}
+	stm := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM records WHERE source='meta' AND (%s))", strings.Join(conds, " OR "))
+	var found bool
+	err := db.QueryRow(stm).Scan(&found)
+	return found, err
+}
+
+// helper function to search meta-data records in local mirror, it provides
+// the same interface as getMeta function, conditions on mirror columns,
+// sorting and pagination are done in SQL whenever they select exactly the
+// same records as query spec, otherwise SQL pre-selects records matched in Go
+func mirrorGetMeta(spec string, skeys []string, sorder, idx, limit int) ([]map[string]any, int, error) {
+	db, err := offlineMirror()
+	if err != nil {
+		return nil, 0, err
+	}
+	query, err := mirrorSpec(db, spec)
+	if err != nil {
+		return nil, 0, err
+	}
+	w := &mirrorWhere{}
+	w.add(query)
+	where := strings.Join(append([]string{"source='meta'"}, w.conds...), " AND ")
+
+	// SQL sorting and pagination require conditions and sort keys on mirror
+	// columns without list values
+	exact := !w.partial
+	columns := w.columns
+	var order []string
+	dir := "ASC"
+	if sorder < 0 {
+		dir = "DESC"
+	}
+	for _, key := range skeys {
+		if !utils.InList(key, mirrorColumns) {
+			exact = false
+			break
+		}
+		order = append(order, fmt.Sprintf("%s %s", key, dir))
+		if key != "date" && !utils.InList(key, columns) {
+			columns = append(columns, key)
+		}
+	}
+	if exact {
+		found, err := mirrorListValues(db, columns)
+		if err != nil {
+			return nil, 0, err
+		}
+		exact = !found
+	}
+	if exact {
+		var total int
+		stm := fmt.Sprintf("SELECT COUNT(*) FROM records WHERE %s", where)
+		if err := db.QueryRow(stm, w.args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+		if limit <= 0 {
+			limit = -1 // no limit in SQLite
+		}
+		stm = fmt.Sprintf("SELECT data FROM records WHERE %s ORDER BY %s LIMIT ? OFFSET ?",
+			where, strings.Join(append(order, "rowid"), ", "))
+		args := append(append([]any{}, w.args...), limit, idx)
+		records, err := mirrorRecords(db, stm, args...)
+		return records, total, err
+	}
+
+	candidates, err := mirrorRecords(db, fmt.Sprintf("SELECT data FROM records WHERE %s", where), w.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var records []map[string]any
+	for _, rec := range candidates {
+		if match.Spec(rec, query) {
+			records = append(records, rec)
+		}
+	}
+	match.Sort(records, skeys, sorder)
+	total := len(records)
+	if idx > total {
+		idx = total
+	}
+	if limit > 0 && idx+limit < total {
+		return records[idx : idx+limit], total, nil
+	}
+	return records[idx:], total, nil
+}
+
+// helper function to run SQL query against local mirror
+func mirrorSQL(db *sql.DB, stm string) ([]map[string]any, error) {
+	rows, err := db.Query(stm)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return records, err
+		}
+		rec := make(map[string]any)
+		for i, col := range columns {
+			if buf, ok := values[i].([]byte); ok {
+				rec[col] = string(buf)
+			} else {
+				rec[col] = values[i]
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// helper function to query local mirror either with SQL statement or search spec
+func mirrorQuery(spec string, skeys []string, sorder int, opts OutputOptions) {
+	stm := strings.ToLower(strings.TrimSpace(spec))
+	if strings.HasPrefix(stm, "select") || strings.HasPrefix(stm, "with") {
+		db, err := openMirror(false)
+		exit("unable to open local mirror", err)
+		defer db.Close()
+		records, err := mirrorSQL(db, spec)
+		exit("unable to run SQL query", err)
+		if opts.Default() {
+			opts.Format = "table"
+		}
+		writeRecords(records, opts)
+		return
+	}
+	records, _, err := mirrorGetMeta(spec, skeys, sorder, 0, -1)
+	exit("unable to query local mirror", err)
+	if opts.Default() {
+		opts.Format = "table"
+		opts.Columns = []string{"did", "schema", "cycle", "beamline", "btr", "sample_name", "date"}
+	}
+	writeRecords(records, opts)
+}
+
+// helper function to provide usage of mirror option
+func mirrorUsage() {
+	fmt.Println("foxden mirror <pull|query|status> [options]")
+	fmt.Println("options: --spec --prov --full --page-size=<N> --max-age=<duration> --json")
+	fmt.Println("         --output=<table|wide|json|ndjson|yaml|csv|template> --fields=<keys> --template=<template>")
+	fmt.Println("local mirror is stored in $HOME/.foxden.mirror.db (or $HOME/.foxden.<context>.mirror.db), use FOXDEN_MIRROR env to change it")
+	fmt.Println("\nExamples:")
+	fmt.Println("\n# pull meta-data records of given query into local mirror, subsequent pulls fetch only new records")
+	fmt.Println("foxden mirror pull 'beamline=3a and cycle=2024-1'")
+	fmt.Println("\n# pull meta-data records along with their SpecScans and provenance records")
+	fmt.Println("foxden mirror pull beamline:3a --spec --prov")
+	fmt.Println("\n# re-pull all records of given query, e.g. to pick up amended records")
+	fmt.Println("foxden mirror pull beamline:3a --full")
+	fmt.Println("\n# query local mirror using search spec or query expression")
+	fmt.Println("foxden mirror query 'btr=\"123\" and sample_name ~ \"^Ti\"'")
+	fmt.Println("\n# query local mirror using SQL, tables: records(did, source, schema, beamline, cycle, btr,")
+	fmt.Println("# sample_name, date, data, pulled_at), pulls(query, last_date, pulled_at, nrecords) and keys(key)")
+	fmt.Println("foxden mirror query \"SELECT beamline, COUNT(*) AS nrecords FROM records WHERE source='meta' GROUP BY beamline\"")
+	fmt.Println("\n# show status of local mirror, pulls older than --max-age are reported as stale")
+	fmt.Println("foxden mirror status --max-age=12h")
+	fmt.Println("\n# use local mirror instead of FOXDEN services")
+	fmt.Println("foxden meta ls --offline")
+	fmt.Println("foxden meta view <DID> --offline")
+	fmt.Println("foxden search 'beamline=3a' --offline")
+}
+
+func mirrorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "foxden mirror commands",
+		Long:  "foxden mirror commands to keep local copy of FOXDEN records for offline use\n" + doc,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				mirrorUsage()
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
+			}
+		},
+	}
+	pullCmd := &cobra.Command{
+		Use:   "pull [query]",
+		Short: "pull meta-data records into local mirror",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			withSpec, _ := cmd.Flags().GetBool("spec")
+			withProv, _ := cmd.Flags().GetBool("prov")
+			full, _ := cmd.Flags().GetBool("full")
+			pageSize, _ := cmd.Flags().GetInt("page-size")
+			user, _ := getUserToken()
+			var spec string
+			if len(args) == 1 {
+				spec = args[0]
+			}
+			mirrorPull(user, spec, withSpec, withProv, full, pageSize)
+		},
+	}
+	pullCmd.ValidArgsFunction = completeQuery
+	queryCmd := &cobra.Command{
+		Use:   "query <sql|query>",
+		Short: "query local mirror using SQL statement or search spec",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			skeys, sortOrder := sortOptions(cmd)
+			mirrorQuery(args[0], skeys, sortOrder, outputOptions(cmd))
+		},
+	}
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "show status of local mirror",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			maxAge, _ := cmd.Flags().GetDuration("max-age")
+			mirrorShowStatus(maxAge, jsonOutput)
+		},
+	}
+	cmd.AddCommand(pullCmd, queryCmd, statusCmd)
+	cmd.PersistentFlags().Bool("json", false, "json output")
+	cmd.PersistentFlags().Bool("spec", false, "pull SpecScans records of pulled dids")
+	cmd.PersistentFlags().Bool("prov", false, "pull provenance records of pulled dids")
+	cmd.PersistentFlags().Bool("full", false, "pull all records of given query instead of new ones")
+	cmd.PersistentFlags().Int("page-size", 100, "number of records to fetch per request")
+	cmd.PersistentFlags().Duration("max-age", 24*time.Hour, "age after which mirrored query is considered stale")
+	cmd.PersistentFlags().String("sort-keys", "date", "sort key(s), if multiple keys separate them by comma (default: date)")
+	cmd.PersistentFlags().Int("sort-order", -1, "sort order: 1 ascending, -1 desecnding (default)")
+	addOutputFlags(cmd)
+	cmd.SetUsageFunc(func(*cobra.Command) error {
+		mirrorUsage()
+		return nil
+	})
+	return cmd
+}
@@ -0,0 +1,197 @@
+package cmd
+
+// CHESComputing foxden tool: tests of offline mirror module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	match "github.com/CHESSComputing/gotools/foxden/match"
+)
+
+// helper function to create local mirror with given meta-data records
+func testMirror(t *testing.T, records []map[string]any, withKeys bool) *sql.DB {
+	t.Helper()
+	t.Setenv("FOXDEN_MIRROR", filepath.Join(t.TempDir(), "mirror.db"))
+	db, err := openMirror(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := mirrorUpsert(tx, fmt.Sprintf("%v", rec["did"]), "meta", rec, rec, 1); err != nil {
+			t.Fatal(err)
+		}
+		for key := range rec {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO keys (key) VALUES (?)", key); err != nil && withKeys {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !withKeys {
+		if _, err := tx.Exec("DELETE FROM keys"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	_mirror = nil
+	t.Cleanup(func() {
+		if _mirror != nil {
+			_mirror.Close()
+			_mirror = nil
+		}
+		db.Close()
+	})
+	return db
+}
+
+// helper function to provide records used by mirror tests
+func mirrorTestRecords(t *testing.T, withLists bool) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for i := 0; i < 12; i++ {
+		rec := map[string]any{
+			"did":         fmt.Sprintf("/beamline=3a/btr=btr-%d/cycle=2024-%d/sample_name=s%02d", i%3, i%2+1, i),
+			"beamline":    []string{"3a", "3b"}[i%2],
+			"btr":         fmt.Sprintf("btr-%d", i%3),
+			"cycle":       fmt.Sprintf("2024-%d", i%2+1),
+			"sample_name": fmt.Sprintf("s%02d", i),
+			"date":        float64(1700000000 + 100*(i%5)),
+			"detector":    map[string]any{"name": []string{"eiger", "pilatus"}[i%2]},
+		}
+		if withLists && i%4 == 0 {
+			rec["beamline"] = []any{"3a", "3b"}
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// helper function to get dids of given records
+func recordDids(records []map[string]any) []string {
+	var dids []string
+	for _, rec := range records {
+		dids = append(dids, fmt.Sprintf("%v", rec["did"]))
+	}
+	return dids
+}
+
+// TestMirrorGetMeta tests that search in local mirror matches records in the
+// same way as matching of all records in Go
+func TestMirrorGetMeta(t *testing.T) {
+	tests := []struct {
+		spec   string
+		skeys  []string
+		sorder int
+		idx    int
+		limit  int
+	}{
+		{"{}", []string{"date"}, -1, 0, 5},
+		{"{}", []string{"date", "did"}, 1, 3, 4},
+		{"beamline:3a", []string{"did"}, 1, 0, 0},
+		{"did:/beamline=3a/btr=btr-1/cycle=2024-2/sample_name=s01", nil, 0, 0, 10},
+		{`{"cycle":"2024-1","btr":{"$in":["btr-0","btr-2"]}}`, []string{"sample_name"}, -1, 1, 2},
+		{`{"date":{"$gte":1700000100,"$lt":1700000400}}`, []string{"date", "did"}, 1, 2, 3},
+		{"beamline=3a and cycle in (2024-1, 2024-2) and date >= 1700000200", []string{"did"}, 1, 0, 3},
+		// conditions and sort keys which are not mirror columns are matched in Go
+		{`{"detector.name":"eiger"}`, []string{"date", "did"}, -1, 1, 3},
+		{`{"$or":[{"btr":"btr-1"},{"sample_name":"s00"}]}`, []string{"did"}, 1, 0, 0},
+		{`{"sample_name":{"$regex":"^s0"}}`, []string{"detector.name", "did"}, 1, 0, 4},
+		{`{"btr":"btr-2"}`, []string{"did"}, 1, 10, 5},
+	}
+	for _, withLists := range []bool{false, true} {
+		records := mirrorTestRecords(t, withLists)
+		db := testMirror(t, records, true)
+		for _, tc := range tests {
+			name := fmt.Sprintf("%s lists=%v", tc.spec, withLists)
+			// expected records are matched and sorted in Go
+			query, err := mirrorSpec(db, tc.spec)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			var expect []map[string]any
+			for _, rec := range records {
+				if match.Spec(rec, query) {
+					expect = append(expect, rec)
+				}
+			}
+			match.Sort(expect, tc.skeys, tc.sorder)
+			total := len(expect)
+			expect = expect[min(tc.idx, total):]
+			if tc.limit > 0 && tc.limit < len(expect) {
+				expect = expect[:tc.limit]
+			}
+
+			found, ntotal, err := mirrorGetMeta(tc.spec, tc.skeys, tc.sorder, tc.idx, tc.limit)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if ntotal != total {
+				t.Errorf("%s: wrong total %d, expected %d", name, ntotal, total)
+			}
+			if dids, edids := recordDids(found), recordDids(expect); !reflect.DeepEqual(dids, edids) {
+				t.Errorf("%s: got %v, expected %v", name, dids, edids)
+			}
+		}
+	}
+	if _, _, err := mirrorGetMeta("unknown=1 and beamline=3a", nil, 0, 0, 0); err == nil {
+		t.Error("query expression with unknown key was accepted")
+	}
+}
+
+// TestMirrorWhere tests conversion of query spec into SQL conditions
+func TestMirrorWhere(t *testing.T) {
+	tests := []struct {
+		spec    string
+		conds   int
+		partial bool
+	}{
+		{`{}`, 0, false},
+		{`{"did":"/a=1","beamline":"3a"}`, 2, false},
+		{`{"$and":[{"cycle":{"$in":["2024-1","2024-2"]}},{"date":{"$gte":1,"$lt":2}}]}`, 3, false},
+		{`{"date":"2024"}`, 0, true},
+		{`{"cycle":2024}`, 0, true},
+		{`{"btr":{"$regex":"^abc"}}`, 0, true},
+		{`{"btr":"abc","sample":{"name":"Ti"}}`, 1, true},
+		{`{"$or":[{"btr":"abc"}]}`, 0, true},
+	}
+	for _, tc := range tests {
+		w := &mirrorWhere{}
+		w.add(unmarshalTest(t, tc.spec))
+		if len(w.conds) != tc.conds || len(w.args) < len(w.conds) || w.partial != tc.partial {
+			t.Errorf("%s: wrong conditions %+v", tc.spec, w)
+		}
+	}
+}
+
+// TestMirrorKeys tests that keys of mirrors without keys table are filled once
+func TestMirrorKeys(t *testing.T) {
+	db := testMirror(t, mirrorTestRecords(t, false), false)
+	for i := 0; i < 2; i++ {
+		keys, err := mirrorKeys(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(keys)
+		expect := []string{"beamline", "btr", "cycle", "date", "detector", "did", "sample_name"}
+		if !reflect.DeepEqual(keys, expect) {
+			t.Errorf("wrong keys %v, expected %v", keys, expect)
+		}
+	}
+	var nkeys int
+	if err := db.QueryRow("SELECT COUNT(*) FROM keys").Scan(&nkeys); err != nil || nkeys != 7 {
+		t.Errorf("keys are not stored in keys table %d, error %v", nkeys, err)
+	}
+}
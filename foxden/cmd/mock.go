@@ -0,0 +1,110 @@
+package cmd
+
+// CHESComputing foxden tool: mock server module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mock "github.com/CHESSComputing/gotools/foxden/mock"
+	"github.com/spf13/cobra"
+)
+
+// helper function to provide usage of mock option
+func mockUsage() {
+	fmt.Println("foxden mock serve [options]")
+	fmt.Println("runs local FOXDEN mock server which provides MetaData, UserMetaData,")
+	fmt.Println("DataBookkeeping, SpecScans, Discovery and Authz APIs on top of in-memory")
+	fmt.Println("or file based store, e.g. to run foxden tools without FOXDEN services")
+	fmt.Println("options: --port, --store, --secret, --auth")
+	fmt.Println("\nExamples:")
+	fmt.Println("\n# run mock server on port 8300 and keep records in memory")
+	fmt.Println("foxden mock serve --port=8300")
+	fmt.Println("\n# run mock server and keep records in given file between runs")
+	fmt.Println("foxden mock serve --store=/tmp/foxden-mock.json")
+	fmt.Println("\n# require tokens with proper scope, tokens are issued by mock Authz service")
+	fmt.Println("foxden mock serve --auth")
+	fmt.Println("\n# the mock server prints FOXDEN configuration to use it, e.g.")
+	fmt.Println("foxden meta ls --config=/tmp/foxden-mock.yaml")
+}
+
+// helper function to print FOXDEN configuration of mock services
+func mockConfig(base, secret string) string {
+	cfg := "Services:\n"
+	for _, svc := range []struct{ key, path string }{
+		{"FrontendURL", ""},
+		{"AuthzURL", "/authz"},
+		{"DiscoveryURL", "/discovery"},
+		{"MetaDataURL", "/meta"},
+		{"UserMetaDataURL", "/umeta"},
+		{"DataBookkeepingURL", "/dbs"},
+		{"SpecScansURL", "/spec"},
+	} {
+		cfg += fmt.Sprintf("  %s: %s%s\n", svc.key, base, svc.path)
+	}
+	cfg += fmt.Sprintf("Authz:\n  ClientID: %s\n", secret)
+	return cfg
+}
+
+// helper function to run FOXDEN mock server
+func mockServe(port int, store, secret string, auth bool) {
+	srv, err := mock.NewServer(store)
+	exit("unable to initialize mock server", err)
+	if secret == "" && srvConfig.Config != nil {
+		secret = srvConfig.Config.Authz.ClientID
+	}
+	if secret != "" {
+		srv.Secret = secret
+	}
+	srv.Auth = auth
+	srv.Verbose = verbose
+	base := fmt.Sprintf("http://localhost:%d", port)
+	fmt.Printf("FOXDEN mock server %s, store: %q, auth: %v\n", base, store, auth)
+	fmt.Println("\n# FOXDEN configuration to use mock services")
+	fmt.Println(mockConfig(base, srv.Secret))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), srv.Handler()))
+}
+
+func mockCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mock",
+		Short: "foxden mock commands",
+		Long:  "foxden mock commands to run local FOXDEN mock services\n" + doc,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				mockUsage()
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
+			}
+		},
+	}
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "run local FOXDEN mock server",
+		Args:  cobra.NoArgs,
+		// mock server can run without FOXDEN configuration
+		Annotations: map[string]string{"config": "optional"},
+		Run: func(cmd *cobra.Command, args []string) {
+			port, _ := cmd.Flags().GetInt("port")
+			store, _ := cmd.Flags().GetString("store")
+			secret, _ := cmd.Flags().GetString("secret")
+			auth, _ := cmd.Flags().GetBool("auth")
+			mockServe(port, store, secret, auth)
+		},
+	}
+	serveCmd.Flags().Int("port", 8300, "port of mock server")
+	serveCmd.Flags().String("store", "", "file to keep records of mock server, records are kept in memory if not provided")
+	serveCmd.Flags().String("secret", "", "secret to sign tokens (default: Authz ClientID of FOXDEN configuration)")
+	serveCmd.Flags().Bool("auth", false, "require tokens with proper scope")
+	cmd.AddCommand(serveCmd)
+	cmd.SetUsageFunc(func(*cobra.Command) error {
+		mockUsage()
+		return nil
+	})
+	return cmd
+}
@@ -0,0 +1,264 @@
+package cmd
+
+// CHESComputing foxden tool: output module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	utils "github.com/CHESSComputing/golib/utils"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// OutputFormats lists supported output formats
+var OutputFormats = []string{"table", "wide", "json", "ndjson", "yaml", "csv", "template"}
+
+// maximum width of table column value, wide format does not truncate values
+var maxTableValue = 50
+
+// OutputOptions represents options of record output
+type OutputOptions struct {
+	Format   string   // output format, empty format stands for default output of the command
+	Fields   []string // (dotted) record keys to output
+	Template string   // Go template to apply to every record
+	Columns  []string // default table columns of the command
+}
+
+// Default returns true if command should use its default output
+func (o OutputOptions) Default() bool {
+	return o.Format == ""
+}
+
+// TableWriter writes flatten records as aligned table
+type TableWriter struct {
+	tw      *tabwriter.Writer
+	columns []string
+	wide    bool
+	header  bool
+}
+
+// Write implements RecordWriter interface
+func (w *TableWriter) Write(rec map[string]any) error {
+	flat := make(map[string]any)
+	flattenRecord("", rec, flat)
+	if !w.header {
+		if len(w.columns) == 0 || w.wide {
+			// use keys of the first record, default columns go first
+			keys := utils.MapKeys(flat)
+			sort.Strings(keys)
+			for _, key := range keys {
+				if !utils.InList(key, w.columns) {
+					w.columns = append(w.columns, key)
+				}
+			}
+		}
+		var hdr []string
+		for _, col := range w.columns {
+			hdr = append(hdr, strings.ToUpper(col))
+		}
+		if _, err := fmt.Fprintln(w.tw, strings.Join(hdr, "\t")); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	row := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		val := ""
+		if v, ok := flat[col]; ok {
+			val = tableValue(v)
+		}
+		if !w.wide && len(val) > maxTableValue {
+			val = val[:maxTableValue-3] + "..."
+		}
+		if val == "" {
+			val = "-"
+		}
+		row[i] = val
+	}
+	_, err := fmt.Fprintln(w.tw, strings.Join(row, "\t"))
+	return err
+}
+
+// Close implements RecordWriter interface
+func (w *TableWriter) Close() error {
+	return w.tw.Flush()
+}
+
+// YamlWriter writes records as YAML list
+type YamlWriter struct {
+	w io.Writer
+}
+
+// Write implements RecordWriter interface
+func (w *YamlWriter) Write(rec map[string]any) error {
+	// marshal record as single item list to stream valid YAML list
+	data, err := yaml.Marshal([]map[string]any{rec})
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(data)
+	return err
+}
+
+// Close implements RecordWriter interface
+func (w *YamlWriter) Close() error {
+	return nil
+}
+
+// TemplateWriter writes records using Go template
+type TemplateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+// Write implements RecordWriter interface
+func (w *TemplateWriter) Write(rec map[string]any) error {
+	return w.tmpl.Execute(w.w, rec)
+}
+
+// Close implements RecordWriter interface
+func (w *TemplateWriter) Close() error {
+	return nil
+}
+
+// helper function to convert record value into table cell
+func tableValue(val any) string {
+	out := csvValue(val)
+	return strings.NewReplacer("\n", " ", "\t", " ").Replace(out)
+}
+
+// helper function to add output flags to given command
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("output", "", fmt.Sprintf("output format: %s", strings.Join(OutputFormats, ", ")))
+	cmd.PersistentFlags().String("fields", "", "comma separated list of (dotted) record keys to output")
+	cmd.PersistentFlags().String("template", "", "Go template applied to every record, e.g. '{{.did}}'")
+	cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(OutputFormats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// helper function to get output options of given command, --json flag is
+// equivalent to --output=json and --template implies --output=template
+func outputOptions(cmd *cobra.Command, columns ...string) OutputOptions {
+	format, _ := cmd.Flags().GetString("output")
+	fields, _ := cmd.Flags().GetString("fields")
+	tmpl, _ := cmd.Flags().GetString("template")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	if format == "" && jsonOutput {
+		format = "json"
+	}
+	if format == "" && tmpl != "" {
+		format = "template"
+	}
+	if format != "" && !utils.InList(format, OutputFormats) {
+		exit(fmt.Sprintf("unsupported output format '%s', please use one of %s", format, strings.Join(OutputFormats, ", ")), errors.New("wrong output format"))
+	}
+	if format == "template" && tmpl == "" {
+		exit("please provide --template option", errors.New("no template"))
+	}
+	if format == "json" || format == "ndjson" {
+		// set _jsonOutputError to properly handle error output in JSON format
+		_jsonOutputError = true
+	}
+	opts := OutputOptions{Format: format, Template: tmpl, Columns: columns}
+	for _, f := range strings.Split(fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			opts.Fields = append(opts.Fields, f)
+		}
+	}
+	return opts
+}
+
+// helper function to select given (dotted) fields from the record
+func selectFields(rec map[string]any, fields []string) map[string]any {
+	flat := make(map[string]any)
+	flattenRecord("", rec, flat)
+	out := make(map[string]any)
+	for _, key := range fields {
+		if val, ok := flat[key]; ok {
+			out[key] = val
+		} else if val, ok := rec[key]; ok {
+			out[key] = val
+		}
+	}
+	return out
+}
+
+// fieldsWriter applies fields selection to records of underlying writer
+type fieldsWriter struct {
+	RecordWriter
+	fields []string
+}
+
+// Write implements RecordWriter interface
+func (w *fieldsWriter) Write(rec map[string]any) error {
+	return w.RecordWriter.Write(selectFields(rec, w.fields))
+}
+
+// helper function to create record writer for given output options
+func newOutputWriter(w io.Writer, opts OutputOptions) (RecordWriter, error) {
+	switch opts.Format {
+	case "table", "wide":
+		columns := opts.Columns
+		if len(opts.Fields) > 0 {
+			columns = opts.Fields
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		return &TableWriter{tw: tw, columns: columns, wide: opts.Format == "wide" && len(opts.Fields) == 0}, nil
+	case "csv":
+		return newRecordWriter(w, "csv", opts.Fields)
+	case "template":
+		text := opts.Template
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		tmpl, err := template.New("output").Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return &TemplateWriter{w: w, tmpl: tmpl}, nil
+	case "json", "ndjson":
+		writer, err := newRecordWriter(w, opts.Format, nil)
+		if err != nil || len(opts.Fields) == 0 {
+			return writer, err
+		}
+		return &fieldsWriter{RecordWriter: writer, fields: opts.Fields}, nil
+	case "yaml":
+		var writer RecordWriter = &YamlWriter{w: w}
+		if len(opts.Fields) > 0 {
+			writer = &fieldsWriter{RecordWriter: writer, fields: opts.Fields}
+		}
+		return writer, nil
+	}
+	return nil, fmt.Errorf("unsupported output format '%s'", opts.Format)
+}
+
+// helper function to write records to stdout using given output options
+func writeRecords(records []map[string]any, opts OutputOptions) {
+	writer, err := newOutputWriter(os.Stdout, opts)
+	exit("unable to create output writer", err)
+	for _, rec := range records {
+		err := writer.Write(rec)
+		exit("unable to write record", err)
+	}
+	err = writer.Close()
+	exit("unable to write records", err)
+}
+
+// helper function to convert list of structs into list of records
+func toRecords(data any) []map[string]any {
+	var records []map[string]any
+	buf, err := json.Marshal(data)
+	exit("unable to marshal data", err)
+	err = json.Unmarshal(buf, &records)
+	exit("unable to unmarshal data", err)
+	return records
+}
@@ -0,0 +1,160 @@
+package cmd
+
+// CHESComputing foxden tool: tests of output module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// helper function to write records with output writer of given options
+func outputWrite(t *testing.T, opts OutputOptions, records []map[string]any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newOutputWriter(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// TestOutputWriters tests table, wide, yaml, csv, ndjson and template output formats
+func TestOutputWriters(t *testing.T) {
+	long := strings.Repeat("x", maxTableValue+10)
+	records := exportRecords(t)
+	records[1]["note"] = long
+	tests := []struct {
+		opts   OutputOptions
+		result string
+	}{
+		{OutputOptions{Format: "table", Columns: []string{"did", "cycle", "note"}},
+			`DID                   CYCLE   NOTE
+/beamline=3a/btr=abc  2024-1  -
+/beamline=3b/btr=xyz  -       ` + long[:maxTableValue-3] + `...
+`},
+		// wide format adds other keys of the first record and does not truncate values
+		{OutputOptions{Format: "wide", Columns: []string{"did"}},
+			`DID                   CYCLE   SAMPLE.MASS  SAMPLE.NAME
+/beamline=3a/btr=abc  2024-1  1.5          Ti
+/beamline=3b/btr=xyz  -       -            -
+`},
+		// fields replace default columns of table format
+		{OutputOptions{Format: "table", Fields: []string{"did", "note"}, Columns: []string{"cycle"}},
+			`DID                   NOTE
+/beamline=3a/btr=abc  -
+/beamline=3b/btr=xyz  ` + long[:maxTableValue-3] + `...
+`},
+		{OutputOptions{Format: "yaml", Fields: []string{"did", "sample.name"}},
+			`- did: /beamline=3a/btr=abc
+  sample.name: Ti
+- did: /beamline=3b/btr=xyz
+`},
+		{OutputOptions{Format: "csv", Fields: []string{"did", "date"}},
+			`did,date
+/beamline=3a/btr=abc,
+/beamline=3b/btr=xyz,1700000000
+`},
+		{OutputOptions{Format: "ndjson", Fields: []string{"did", "sample"}},
+			`{"did":"/beamline=3a/btr=abc","sample":{"mass":1.5,"name":"Ti"}}
+{"did":"/beamline=3b/btr=xyz"}
+`},
+		{OutputOptions{Format: "json", Fields: []string{"detectors"}},
+			`[
+ {},
+ {
+  "detectors": [
+   "eiger",
+   "pilatus"
+  ]
+ }
+]
+`},
+		{OutputOptions{Format: "template", Template: `{{.did}} {{.cycle}}`},
+			`/beamline=3a/btr=abc 2024-1
+/beamline=3b/btr=xyz <no value>
+`},
+	}
+	for _, tc := range tests {
+		if out := outputWrite(t, tc.opts, records); out != tc.result {
+			t.Errorf("%+v: got\n%s\nexpected\n%s", tc.opts, out, tc.result)
+		}
+	}
+	for _, opts := range []OutputOptions{{Format: "xml"}, {Format: "template", Template: "{{.did"}} {
+		if _, err := newOutputWriter(io.Discard, opts); err == nil {
+			t.Errorf("%+v: writer was created", opts)
+		}
+	}
+}
+
+// TestOutputOptions tests output options of command flags
+func TestOutputOptions(t *testing.T) {
+	jsonError := _jsonOutputError
+	t.Cleanup(func() { _jsonOutputError = jsonError })
+	tests := []struct {
+		args   []string
+		expect OutputOptions
+	}{
+		{nil, OutputOptions{Columns: []string{"did"}}},
+		{[]string{"--json"}, OutputOptions{Format: "json", Columns: []string{"did"}}},
+		{[]string{"--json", "--output=yaml"}, OutputOptions{Format: "yaml", Columns: []string{"did"}}},
+		{[]string{"--template={{.did}}"}, OutputOptions{Format: "template", Template: "{{.did}}", Columns: []string{"did"}}},
+		{[]string{"--output=csv", "--fields= did, sample.name ,,"},
+			OutputOptions{Format: "csv", Fields: []string{"did", "sample.name"}, Columns: []string{"did"}}},
+	}
+	for _, tc := range tests {
+		_jsonOutputError = false
+		cmd := &cobra.Command{Use: "test"}
+		addOutputFlags(cmd)
+		cmd.Flags().Bool("json", false, "json output")
+		if err := cmd.ParseFlags(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		opts := outputOptions(cmd, "did")
+		if !reflect.DeepEqual(opts, tc.expect) {
+			t.Errorf("%v: got %+v, expected %+v", tc.args, opts, tc.expect)
+		}
+		if opts.Default() != (tc.expect.Format == "") || _jsonOutputError != (opts.Format == "json") {
+			t.Errorf("%v: wrong default %v or JSON error output %v", tc.args, opts.Default(), _jsonOutputError)
+		}
+	}
+}
+
+// TestWriteRecords tests output of records and structs to stdout
+func TestWriteRecords(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	records := toRecords([]DeleteResult{{Did: "/a=1", Status: "ok"}, {Did: "/a=2", Status: "failed", Error: "denied"}})
+	writeRecords(records, OutputOptions{Format: "ndjson"})
+	os.Stdout = stdout
+	w.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"did":"/a=1","status":"ok"}
+{"did":"/a=2","error":"denied","status":"failed"}
+`
+	if string(data) != expect {
+		t.Errorf("got\n%s\nexpected\n%s", data, expect)
+	}
+}
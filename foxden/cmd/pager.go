@@ -0,0 +1,159 @@
+package cmd
+
+// CHESComputing foxden tool: paging module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"fmt"
+	"os"
+)
+
+// PageFetcher fetches page of records starting at given index, it returns
+// records along with total number of records or -1 if total is unknown
+type PageFetcher func(idx, limit int) ([]map[string]any, int, error)
+
+// Pager iterates over records provided by FOXDEN service page by page, e.g.
+//
+//	pager := NewPager(fetch, idx, limit, pageSize, all)
+//	for pager.Next() {
+//	    for _, rec := range pager.Records() {
+//	        ...
+//	    }
+//	}
+//	if err := pager.Err(); err != nil {
+//	    ...
+//	}
+type Pager struct {
+	fetch    PageFetcher
+	idx      int
+	limit    int
+	pageSize int
+	all      bool
+	total    int
+	nrecs    int
+	records  []map[string]any
+	err      error
+	done     bool
+}
+
+// NewPager creates new pager for given fetch function, it fetches records
+// starting at idx up to given limit (negative limit fetches all records in single
+// request), or all records if all flag is set, using requests of given page size
+// (default is limit)
+func NewPager(fetch PageFetcher, idx, limit, pageSize int, all bool) *Pager {
+	if pageSize <= 0 {
+		pageSize = limit
+	}
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	return &Pager{
+		fetch:    fetch,
+		idx:      idx,
+		limit:    limit,
+		pageSize: pageSize,
+		all:      all,
+		total:    -1,
+	}
+}
+
+// Next fetches next page of records, it returns false when there are no more records
+// or an error occured
+func (p *Pager) Next() bool {
+	if p.done {
+		return false
+	}
+	size := p.pageSize
+	if !p.all && p.limit < 0 {
+		// negative limit requests all records within single call
+		size = p.limit
+	} else if !p.all {
+		if remain := p.limit - p.nrecs; remain < size {
+			size = remain
+		}
+		if size <= 0 {
+			p.done = true
+			return false
+		}
+	}
+	records, total, err := p.fetch(p.idx, size)
+	if err != nil {
+		p.err = err
+		p.done = true
+		return false
+	}
+	p.total = total
+	p.records = records
+	p.idx += len(records)
+	p.nrecs += len(records)
+	// stop when page is not full or we reached total number of records
+	if size < 0 || len(records) < size || (total >= 0 && p.idx >= total) {
+		p.done = true
+	}
+	return len(records) > 0
+}
+
+// Records returns records of current page
+func (p *Pager) Records() []map[string]any {
+	return p.records
+}
+
+// Count returns number of records fetched so far
+func (p *Pager) Count() int {
+	return p.nrecs
+}
+
+// Total returns total number of records reported by the service or -1 if it is unknown
+func (p *Pager) Total() int {
+	return p.total
+}
+
+// Err returns error occured during fetching records
+func (p *Pager) Err() error {
+	return p.err
+}
+
+// helper function to list records provided by pager, records are streamed to stdout
+// as pages arrive either using given output options or given printer function
+func listPages(pager *Pager, opts OutputOptions, printer func(rec map[string]any)) {
+	var writer RecordWriter
+	if !opts.Default() {
+		var err error
+		writer, err = newOutputWriter(os.Stdout, opts)
+		exit("unable to create output writer", err)
+	}
+	for pager.Next() {
+		for _, rec := range pager.Records() {
+			if writer != nil {
+				err := writer.Write(rec)
+				exit("unable to write record", err)
+				continue
+			}
+			fmt.Println("---")
+			printer(rec)
+		}
+	}
+	if err := pager.Err(); err != nil {
+		fmt.Println("ERROR", err)
+		os.Exit(1)
+	}
+	if writer != nil {
+		err := writer.Close()
+		exit("unable to write records", err)
+	}
+}
+
+// helper function to print footer of listed records
+func listFooter(pager *Pager, idx int, all bool) {
+	fmt.Println("---")
+	if all {
+		fmt.Printf("Total %d records\n", pager.Count())
+		return
+	}
+	if pager.Total() < 0 {
+		fmt.Printf("Showing %d-%d records, for more records use --idx/--limit or --all options\n", idx, idx+pager.Count())
+		return
+	}
+	fmt.Printf("Showing %d-%d out of %d records, for more records use --idx/--limit or --all options\n", idx, idx+pager.Count(), pager.Total())
+}
@@ -0,0 +1,141 @@
+package cmd
+
+// CHESComputing foxden tool: tests of paging module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// helper function to provide fetch function over given number of records, it
+// records sizes of requested pages and reports total if withTotal is set
+func pagerFetch(nrecords int, withTotal bool, sizes *[]int) PageFetcher {
+	var records []map[string]any
+	for i := 0; i < nrecords; i++ {
+		records = append(records, map[string]any{"did": fmt.Sprintf("/a=%d", i)})
+	}
+	return func(idx, limit int) ([]map[string]any, int, error) {
+		*sizes = append(*sizes, limit)
+		total := -1
+		if withTotal {
+			total = nrecords
+		}
+		page, _, err := tmplPage(records, idx, limit)
+		return page, total, err
+	}
+}
+
+// TestPager tests that pager fetches requested range of records page by page
+func TestPager(t *testing.T) {
+	tests := []struct {
+		nrecords  int
+		withTotal bool
+		idx       int
+		limit     int
+		pageSize  int
+		all       bool
+		first     int   // first fetched record
+		count     int   // number of fetched records
+		sizes     []int // sizes of requested pages
+	}{
+		{10, true, 0, 5, 0, false, 0, 5, []int{5}},
+		{10, true, 2, 5, 2, false, 2, 5, []int{2, 2, 1}},
+		{10, true, 0, 0, 4, true, 0, 10, []int{4, 4, 4}},
+		{10, false, 0, 0, 4, true, 0, 10, []int{4, 4, 4}},
+		{8, false, 0, 0, 4, true, 0, 8, []int{4, 4, 4}},
+		{8, true, 0, 0, 4, true, 0, 8, []int{4, 4}},
+		{10, true, 3, -1, 0, false, 3, 7, []int{-1}},
+		{10, true, 8, 5, 0, false, 8, 2, []int{5}},
+		{10, true, 0, 0, 0, false, 0, 0, nil},
+	}
+	for _, tc := range tests {
+		name := fmt.Sprintf("%+v", tc)
+		var sizes []int
+		pager := NewPager(pagerFetch(tc.nrecords, tc.withTotal, &sizes), tc.idx, tc.limit, tc.pageSize, tc.all)
+		var dids []any
+		for pager.Next() {
+			for _, rec := range pager.Records() {
+				dids = append(dids, rec["did"])
+			}
+		}
+		if err := pager.Err(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		var expect []any
+		for i := tc.first; i < tc.first+tc.count; i++ {
+			expect = append(expect, fmt.Sprintf("/a=%d", i))
+		}
+		if !reflect.DeepEqual(dids, expect) || pager.Count() != tc.count {
+			t.Errorf("%s: got %v (count %d), expected %v", name, dids, pager.Count(), expect)
+		}
+		if !reflect.DeepEqual(sizes, tc.sizes) {
+			t.Errorf("%s: wrong page sizes %v, expected %v", name, sizes, tc.sizes)
+		}
+		if tc.withTotal && len(sizes) > 0 && pager.Total() != tc.nrecords {
+			t.Errorf("%s: wrong total %d", name, pager.Total())
+		}
+	}
+}
+
+// TestPagerError tests that pager stops on fetch error
+func TestPagerError(t *testing.T) {
+	var calls int
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		calls++
+		if calls > 1 {
+			return nil, 0, errors.New("service is not available")
+		}
+		return []map[string]any{{"did": "/a=1"}, {"did": "/a=2"}}, -1, nil
+	}
+	pager := NewPager(fetch, 0, 0, 2, true)
+	var npages int
+	for pager.Next() {
+		npages++
+	}
+	if npages != 1 || pager.Count() != 2 || pager.Err() == nil {
+		t.Errorf("wrong pager state pages %d count %d error %v", npages, pager.Count(), pager.Err())
+	}
+	if pager.Next() {
+		t.Error("pager continues after error")
+	}
+}
+
+// TestTmplMatch tests selection of template records by spec
+func TestTmplMatch(t *testing.T) {
+	records := []map[string]any{
+		{"did": "/beamline=3a/btr=abc", "tmpl_schema": "ID3A", "beamline": "3a", "cycle": "2024-1"},
+		{"did": "/beamline=3a/btr=xyz", "tmpl_schema": "ID3A", "beamline": "3a", "cycle": "2024-2"},
+		{"did": "/beamline=1b/btr=abc", "tmpl_schema": "ID1B", "beamline": "1b", "cycle": "2024-2"},
+	}
+	tests := []struct {
+		spec string
+		dids []string
+	}{
+		{"", []string{"/beamline=3a/btr=abc", "/beamline=3a/btr=xyz", "/beamline=1b/btr=abc"}},
+		{"beamline:3a", []string{"/beamline=3a/btr=abc", "/beamline=3a/btr=xyz"}},
+		{"beamline:3a cycle:2024-2", []string{"/beamline=3a/btr=xyz"}},
+		{`{"tmpl_schema":"ID1B"}`, []string{"/beamline=1b/btr=abc"}},
+		{"did:/beamline=3a/btr=abc", []string{"/beamline=3a/btr=abc"}},
+		{"tmpl_schema=ID3A and cycle in (2024-2, 2024-3)", []string{"/beamline=3a/btr=xyz"}},
+		{"beamline:4b", nil},
+	}
+	for _, tc := range tests {
+		found, err := tmplMatch(records, tc.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tc.spec, err)
+			continue
+		}
+		if dids := recordDids(found); !reflect.DeepEqual(dids, tc.dids) {
+			t.Errorf("%s: got %v, expected %v", tc.spec, dids, tc.dids)
+		}
+	}
+	for _, spec := range []string{"unknown=1 and beamline=3a", "{beamline", "beamline"} {
+		if _, err := tmplMatch(records, spec); err == nil {
+			t.Errorf("%s: invalid spec was accepted", spec)
+		}
+	}
+}
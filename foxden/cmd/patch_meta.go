@@ -0,0 +1,400 @@
+package cmd
+
+// CHESComputing foxden tool: meta-data patch module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// PatchOperation represents single RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// helper function to compute content hash of a record
+func recordHash(rec map[string]any) string {
+	// encoding/json sorts map keys and therefore provides stable representation
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// helper function to deep copy a record
+func copyRecord(rec map[string]any) map[string]any {
+	data, err := json.Marshal(rec)
+	exit("unable to marshal record", err)
+	var out map[string]any
+	err = json.Unmarshal(data, &out)
+	exit("unable to unmarshal record", err)
+	return out
+}
+
+// helper function to apply RFC 7396 JSON merge patch
+func mergePatch(target any, patch any) any {
+	pmap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	tmap, ok := target.(map[string]any)
+	if !ok {
+		tmap = make(map[string]any)
+	}
+	for key, val := range pmap {
+		if val == nil {
+			delete(tmap, key)
+			continue
+		}
+		tmap[key] = mergePatch(tmap[key], val)
+	}
+	return tmap
+}
+
+// helper function to split JSON pointer into its reference tokens
+func pointerTokens(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", path)
+	}
+	var tokens []string
+	for _, t := range strings.Split(path[1:], "/") {
+		t = strings.ReplaceAll(t, "~1", "/")
+		t = strings.ReplaceAll(t, "~0", "~")
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// helper function to parse array index of JSON pointer token
+func arrayIndex(token string, size int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return size, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	if idx > size || (idx == size && !allowEnd) {
+		return 0, fmt.Errorf("array index %d is out of range", idx)
+	}
+	return idx, nil
+}
+
+// helper function to get value of JSON pointer
+func pointerGet(doc any, path string) (any, error) {
+	tokens, err := pointerTokens(path)
+	if err != nil {
+		return nil, err
+	}
+	val := doc
+	for _, t := range tokens {
+		switch v := val.(type) {
+		case map[string]any:
+			nval, ok := v[t]
+			if !ok {
+				return nil, fmt.Errorf("path '%s' does not exist", path)
+			}
+			val = nval
+		case []any:
+			idx, err := arrayIndex(t, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			val = v[idx]
+		default:
+			return nil, fmt.Errorf("path '%s' does not exist", path)
+		}
+	}
+	return val, nil
+}
+
+// helper function to update value of JSON pointer, the op should be either
+// add, replace or remove; it returns updated document
+func pointerSet(doc any, tokens []string, op string, value any) (any, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, errors.New("unable to remove the whole document")
+		}
+		return value, nil
+	}
+	token := tokens[0]
+	last := len(tokens) == 1
+	switch v := doc.(type) {
+	case map[string]any:
+		if last {
+			_, exists := v[token]
+			if (op == "replace" || op == "remove") && !exists {
+				return nil, fmt.Errorf("key '%s' does not exist", token)
+			}
+			if op == "remove" {
+				delete(v, token)
+			} else {
+				v[token] = value
+			}
+			return v, nil
+		}
+		child, ok := v[token]
+		if !ok {
+			return nil, fmt.Errorf("key '%s' does not exist", token)
+		}
+		nval, err := pointerSet(child, tokens[1:], op, value)
+		if err != nil {
+			return nil, err
+		}
+		v[token] = nval
+		return v, nil
+	case []any:
+		if last {
+			idx, err := arrayIndex(token, len(v), op == "add")
+			if err != nil {
+				return nil, err
+			}
+			switch op {
+			case "add":
+				v = append(v, nil)
+				copy(v[idx+1:], v[idx:])
+				v[idx] = value
+			case "remove":
+				v = append(v[:idx], v[idx+1:]...)
+			default:
+				v[idx] = value
+			}
+			return v, nil
+		}
+		idx, err := arrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		nval, err := pointerSet(v[idx], tokens[1:], op, value)
+		if err != nil {
+			return nil, err
+		}
+		v[idx] = nval
+		return v, nil
+	}
+	return nil, fmt.Errorf("unable to resolve '%s' in non-container value", token)
+}
+
+// helper function to apply RFC 6902 JSON patch operations to a document
+func jsonPatch(doc any, ops []PatchOperation) (any, error) {
+	for _, op := range ops {
+		tokens, err := pointerTokens(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add", "replace", "remove":
+			doc, err = pointerSet(doc, tokens, op.Op, op.Value)
+		case "move", "copy":
+			var val any
+			val, err = pointerGet(doc, op.From)
+			if err != nil {
+				break
+			}
+			if op.Op == "move" {
+				if strings.HasPrefix(op.Path, op.From+"/") {
+					err = fmt.Errorf("unable to move '%s' into its own child '%s'", op.From, op.Path)
+					break
+				}
+				ftokens, _ := pointerTokens(op.From)
+				doc, err = pointerSet(doc, ftokens, "remove", nil)
+				if err != nil {
+					break
+				}
+			} else {
+				// copy value to avoid sharing the same object in two places
+				data, _ := json.Marshal(val)
+				json.Unmarshal(data, &val)
+			}
+			doc, err = pointerSet(doc, tokens, "add", val)
+		case "test":
+			var val any
+			val, err = pointerGet(doc, op.Path)
+			if err == nil && !reflect.DeepEqual(normalizeValue(val), normalizeValue(op.Value)) {
+				err = fmt.Errorf("test operation failed for path '%s'", op.Path)
+			}
+		default:
+			err = fmt.Errorf("unsupported JSON patch operation '%s'", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// helper function to normalize value to its JSON representation
+func normalizeValue(val any) any {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return val
+	}
+	var out any
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// helper function to convert dotted key, e.g. a.b.c, to JSON pointer /a/b/c
+func dottedPointer(key string) string {
+	var parts []string
+	for _, k := range strings.Split(key, ".") {
+		k = strings.ReplaceAll(k, "~", "~0")
+		k = strings.ReplaceAll(k, "/", "~1")
+		parts = append(parts, k)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// helper function to parse --set value, JSON values are used as-is, otherwise value is a string
+func parseSetValue(val string) any {
+	var out any
+	if err := json.Unmarshal([]byte(val), &out); err == nil {
+		return out
+	}
+	return val
+}
+
+// helper function to build patch operations from --set and --unset options
+func setUnsetOperations(rec map[string]any, setValues, unsetKeys []string) ([]PatchOperation, error) {
+	var ops []PatchOperation
+	for _, kv := range setValues {
+		arr := strings.SplitN(kv, "=", 2)
+		if len(arr) != 2 || arr[0] == "" {
+			return nil, fmt.Errorf("invalid --set value '%s', please use key=value", kv)
+		}
+		// create intermediate objects for nested keys
+		keys := strings.Split(arr[0], ".")
+		var cur any = rec
+		for i := 1; i < len(keys); i++ {
+			parent := strings.Join(keys[:i], ".")
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("unable to set '%s', '%s' is not an object", arr[0], parent)
+			}
+			if _, ok := m[keys[i-1]]; !ok {
+				ops = append(ops, PatchOperation{Op: "add", Path: dottedPointer(parent), Value: map[string]any{}})
+				m[keys[i-1]] = map[string]any{}
+			}
+			cur = m[keys[i-1]]
+		}
+		ops = append(ops, PatchOperation{Op: "add", Path: dottedPointer(arr[0]), Value: parseSetValue(arr[1])})
+	}
+	for _, key := range unsetKeys {
+		ops = append(ops, PatchOperation{Op: "remove", Path: dottedPointer(key)})
+	}
+	return ops, nil
+}
+
+// helper function to apply patch file content, JSON list is treated as
+// RFC 6902 JSON Patch and JSON object as RFC 7396 merge patch
+func applyPatchData(rec map[string]any, data []byte) (map[string]any, error) {
+	var ops []PatchOperation
+	var doc any
+	if err := json.Unmarshal(data, &ops); err == nil {
+		doc, err = jsonPatch(rec, ops)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var patch map[string]any
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return nil, errors.New("patch must be either JSON Patch (list of operations) or JSON merge patch (object)")
+		}
+		doc = mergePatch(rec, patch)
+	}
+	out, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("patched document is not a JSON object")
+	}
+	return out, nil
+}
+
+// helper function to patch meta-data record
+func metaPatchRecord(user, did string, setValues, unsetKeys []string, patchFile string, jsonOutput, dryRun bool) {
+	if len(setValues) == 0 && len(unsetKeys) == 0 && patchFile == "" {
+		metaUsage()
+		exit("please provide --set, --unset or --patch option", errors.New("no patch"))
+	}
+	orig := fetchMetaRecord(user, did)
+	hash := recordHash(orig)
+	record := copyRecord(orig)
+	var err error
+	if patchFile != "" {
+		data, err := readJsonData(patchFile)
+		exit("unable to read patch file", err)
+		record, err = applyPatchData(record, data)
+		exit("unable to apply patch", err)
+	}
+	ops, err := setUnsetOperations(record, setValues, unsetKeys)
+	exit("unable to parse --set/--unset options", err)
+	if len(ops) > 0 {
+		doc, err := jsonPatch(record, ops)
+		exit("unable to apply --set/--unset options", err)
+		record = doc.(map[string]any)
+	}
+	if val, ok := record["did"]; !ok || fmt.Sprintf("%v", val) != did {
+		exit("patch can not change or remove record did", errors.New("did mismatch"))
+	}
+
+	diff := diffRecords(orig, record)
+	diff.Source = did
+	diff.Target = "patched record"
+	if diff.Empty() {
+		fmt.Println("patch does not change the record, nothing to submit")
+		return
+	}
+	if !jsonOutput {
+		printMetaDiff(diff)
+	}
+	if dryRun {
+		if jsonOutput {
+			data, err := json.MarshalIndent(record, "", "  ")
+			exit("unable to marshal record", err)
+			fmt.Println(string(data))
+		}
+		return
+	}
+
+	// make sure that record was not changed since we fetched it
+	if recordHash(fetchMetaRecord(user, did)) != hash {
+		exit(fmt.Sprintf("record %s was changed by someone else, please repeat the patch", did), errors.New("record changed"))
+	}
+	schemaName := fmt.Sprintf("%v", record["schema"])
+	if _, ok := record["schema"]; !ok {
+		exit("schema is not present in the record", errors.New("no schema"))
+	}
+	data, err := submitMetaRecord(services.MetaRecord{Schema: schemaName, Record: record}, true)
+	exit("unable to submit patched record to meta-data service", err)
+	if jsonOutput {
+		fmt.Println(string(data))
+		return
+	}
+	var response services.ServiceResponse
+	err = json.Unmarshal(data, &response)
+	exit("Unable to unmarshal the data", err)
+	if response.Status == "ok" {
+		fmt.Printf("SUCCESS: record %s was successfully patched\n", did)
+	} else {
+		fmt.Printf("ERROR: failed to patch record in MetaData service\n%+v", response.String())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+// CHESComputing foxden tool: tests of meta-data patch module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// helper function to unmarshal JSON test data
+func unmarshalTest(t *testing.T, data string) map[string]any {
+	t.Helper()
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+// TestApplyPatchData tests JSON Patch and JSON merge patch of records
+func TestApplyPatchData(t *testing.T) {
+	doc := `{"did":"/beamline=3a","btr":"abc","detectors":["eiger","pilatus"],"sample":{"name":"Ti","mass":1.5}}`
+	tests := []struct {
+		patch  string
+		result string
+	}{
+		// JSON merge patch
+		{`{"btr":"xyz","sample":{"mass":null,"phase":"alpha"}}`,
+			`{"did":"/beamline=3a","btr":"xyz","detectors":["eiger","pilatus"],"sample":{"name":"Ti","phase":"alpha"}}`},
+		// JSON Patch
+		{`[{"op":"replace","path":"/btr","value":"xyz"},{"op":"add","path":"/detectors/-","value":"dexela"}]`,
+			`{"did":"/beamline=3a","btr":"xyz","detectors":["eiger","pilatus","dexela"],"sample":{"name":"Ti","mass":1.5}}`},
+		{`[{"op":"remove","path":"/detectors/0"},{"op":"move","from":"/sample/name","path":"/sample_name"}]`,
+			`{"did":"/beamline=3a","btr":"abc","detectors":["pilatus"],"sample":{"mass":1.5},"sample_name":"Ti"}`},
+		{`[{"op":"test","path":"/sample/mass","value":1.5},{"op":"copy","from":"/sample","path":"/parent"}]`,
+			`{"did":"/beamline=3a","btr":"abc","detectors":["eiger","pilatus"],"sample":{"name":"Ti","mass":1.5},"parent":{"name":"Ti","mass":1.5}}`},
+	}
+	for _, tc := range tests {
+		rec, err := applyPatchData(unmarshalTest(t, doc), []byte(tc.patch))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.patch, err)
+			continue
+		}
+		if expect := unmarshalTest(t, tc.result); !reflect.DeepEqual(rec, expect) {
+			t.Errorf("%s: got %v, expected %v", tc.patch, rec, expect)
+		}
+	}
+}
+
+// TestApplyPatchDataErrors tests errors of invalid patches
+func TestApplyPatchDataErrors(t *testing.T) {
+	doc := `{"did":"/beamline=3a","sample":{"name":"Ti"},"detectors":["eiger"]}`
+	for _, patch := range []string{
+		`[{"op":"replace","path":"/btr","value":"xyz"}]`,
+		`[{"op":"remove","path":"/detectors/1"}]`,
+		`[{"op":"test","path":"/sample/name","value":"Fe"}]`,
+		`[{"op":"move","from":"/sample","path":"/sample/parent"}]`,
+		`[{"op":"remove","path":""}]`,
+		`[{"op":"add","path":"btr","value":"xyz"}]`,
+		`[{"op":"merge","path":"/btr"}]`,
+		`"string"`,
+	} {
+		if _, err := applyPatchData(unmarshalTest(t, doc), []byte(patch)); err == nil {
+			t.Errorf("%s: expected error", patch)
+		}
+	}
+}
+
+// TestSetUnsetOperations tests conversion of --set and --unset options into JSON Patch
+func TestSetUnsetOperations(t *testing.T) {
+	rec := unmarshalTest(t, `{"did":"/beamline=3a","sample":{"name":"Ti"},"btr":"abc"}`)
+	ops, err := setUnsetOperations(copyRecord(rec), []string{"sample.mass=1.5", "detector.name=eiger", "note=a=b"}, []string{"btr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := jsonPatch(rec, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := unmarshalTest(t, `{"did":"/beamline=3a","sample":{"name":"Ti","mass":1.5},"detector":{"name":"eiger"},"note":"a=b"}`)
+	if !reflect.DeepEqual(doc, expect) {
+		t.Errorf("got %v, expected %v", doc, expect)
+	}
+	for _, val := range []string{"btr", "=abc", "did.a.b=x"} {
+		if _, err := setUnsetOperations(copyRecord(rec), []string{val}, nil); err == nil {
+			t.Errorf("%s: expected error", val)
+		}
+	}
+	if ptr := dottedPointer("a/b.c~d"); ptr != "/a~1b/c~0d" {
+		t.Errorf("wrong JSON pointer %s", ptr)
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+// CHESComputing foxden tool: scope preflight module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	authz "github.com/CHESSComputing/golib/authz"
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"github.com/spf13/cobra"
+)
+
+// annotation of commands which lists token scopes required by the command
+const scopesAnnotation = "scopes"
+
+// rank of token scopes, token of higher scope grants lower ones, e.g. delete
+// token can be used for write and read operations
+var scopeRank = map[string]int{"read": 1, "write": 2, "delete": 3}
+
+// helper function to declare token scopes required by command, e.g.
+// Annotations: requireScopes("read", "write")
+func requireScopes(scopes ...string) map[string]string {
+	return map[string]string{scopesAnnotation: strings.Join(scopes, ",")}
+}
+
+// helper function to get invoked command and token scopes it requires
+func commandScopes() (*cobra.Command, []string) {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	if err != nil || cmd.Annotations[scopesAnnotation] == "" {
+		return cmd, nil
+	}
+	return cmd, strings.Split(cmd.Annotations[scopesAnnotation], ",")
+}
+
+// helper function to get token source of given scope
+func scopeSource(scope string) (TokenSource, bool) {
+	for _, src := range tokenSources() {
+		if src.Scope == scope {
+			return src, true
+		}
+	}
+	return TokenSource{}, false
+}
+
+// helper function to check that token grants given scope
+func checkTokenScope(token, scope string) error {
+	claims, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
+	if err != nil {
+		return err
+	}
+	if claims == nil {
+		return nil
+	}
+	have := claims.CustomClaims.Scope
+	if scopeRank[have] < scopeRank[scope] {
+		return fmt.Errorf("token has %q scope while %q scope is required", have, scope)
+	}
+	return nil
+}
+
+// helper function to check if scope grants mutation of FOXDEN records
+func mutationScope(scope string) bool {
+	return scope == "write" || scope == "delete"
+}
+
+// helper function to obtain and validate tokens of scopes required by invoked
+// command before it makes any request, tokens are cached by refreshToken and
+// re-used by FOXDEN client requests of corresponding scope, see cliToken
+func preflight() {
+	cmd, scopes := commandScopes()
+	if len(scopes) == 0 || os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
+		return
+	}
+	hint, err := preflightScopes(cmd, scopes)
+	exit(hint, err)
+}
+
+// helper function to obtain and validate tokens of given scopes required by
+// command, it returns hint how to obtain proper token along with an error
+func preflightScopes(cmd *cobra.Command, scopes []string) (string, error) {
+	// command flags are already parsed when preflight is called, commands in
+	// dry-run mode do not change records and do not need write or delete tokens
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	for _, scope := range scopes {
+		if dryRun && mutationScope(scope) {
+			continue
+		}
+		src, ok := scopeSource(scope)
+		if !ok {
+			return fmt.Sprintf("%s declares unsupported token scope", cmd.CommandPath()), fmt.Errorf("scope %q", scope)
+		}
+		hint := fmt.Sprintf("%s requires %s token, please run 'foxden token create %s' or put %s token into %s env or file",
+			cmd.CommandPath(), scope, scope, scope, contextEnv(src.Env))
+		token, err := refreshToken(src)
+		if err == nil && token == "" {
+			err = errors.New("no token found")
+		}
+		if err != nil {
+			return hint, err
+		}
+		if err := checkTokenScope(token, scope); err != nil {
+			return hint + ", use 'foxden token status' to inspect existing tokens", err
+		}
+	}
+	return "", nil
+}
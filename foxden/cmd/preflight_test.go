@@ -0,0 +1,118 @@
+package cmd
+
+// CHESComputing foxden tool: tests of scope preflight module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mock "github.com/CHESSComputing/gotools/foxden/mock"
+	"github.com/spf13/cobra"
+)
+
+// helper function to issue tokens of all scopes signed with FOXDEN client id,
+// cached tokens are reset and tokens are not renewed from Kerberos cache
+func scopeTokens(t *testing.T) map[string]string {
+	t.Helper()
+	contextSetup(t, testContexts)
+	srv, err := mock.NewServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvConfig.Config.Authz.ClientID = srv.Secret
+	t.Setenv("KRB5CCNAME", filepath.Join(t.TempDir(), "krb5cc"))
+	tokens := make(map[string]string)
+	for _, src := range tokenSources() {
+		if tokens[src.Scope], err = srv.Token("tester", src.Scope, 0); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(src.Env, "")
+		token := src.Req.Token
+		src.Req.Token = ""
+		t.Cleanup(func() { src.Req.Token = token })
+		_tokenRenewed[src.Scope] = time.Now()
+	}
+	t.Cleanup(func() { _tokenRenewed = make(map[string]time.Time) })
+	return tokens
+}
+
+// TestCheckTokenScope tests that token of higher scope grants lower ones
+func TestCheckTokenScope(t *testing.T) {
+	tokens := scopeTokens(t)
+	tests := []struct {
+		token string
+		scope string
+		ok    bool
+	}{
+		{"read", "read", true},
+		{"read", "write", false},
+		{"read", "delete", false},
+		{"write", "read", true},
+		{"write", "write", true},
+		{"write", "delete", false},
+		{"delete", "write", true},
+		{"delete", "delete", true},
+	}
+	for _, tc := range tests {
+		if err := checkTokenScope(tokens[tc.token], tc.scope); (err == nil) != tc.ok {
+			t.Errorf("%s token for %s scope: wrong result %v", tc.token, tc.scope, err)
+		}
+	}
+	// tokens of other client and malformed tokens are rejected
+	srvConfig.Config.Authz.ClientID = "other-client"
+	if err := checkTokenScope(tokens["delete"], "read"); err == nil {
+		t.Error("token of other client was accepted")
+	}
+	if err := checkTokenScope("not-a-token", "read"); err == nil {
+		t.Error("malformed token was accepted")
+	}
+}
+
+// TestPreflightScopes tests that tokens of required scopes are checked and
+// commands in dry-run mode do not require write or delete tokens
+func TestPreflightScopes(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		tokens map[string]string // token scope of environment variables
+		dryRun bool
+		hint   string
+	}{
+		{[]string{"read", "write"}, map[string]string{"FOXDEN_TOKEN": "read", "FOXDEN_WRITE_TOKEN": "write"}, false, ""},
+		{[]string{"read", "write"}, map[string]string{"FOXDEN_TOKEN": "read"}, false, "requires write token"},
+		{[]string{"read", "write"}, map[string]string{"FOXDEN_TOKEN": "read"}, true, ""},
+		{[]string{"delete"}, nil, true, ""},
+		{[]string{"read", "delete"}, nil, true, "requires read token"},
+		{[]string{"write"}, map[string]string{"FOXDEN_WRITE_TOKEN": "read"}, false, "foxden token status"},
+		{[]string{"delete"}, map[string]string{"FOXDEN_DELETE_TOKEN": "delete"}, false, ""},
+		{[]string{"admin"}, nil, false, "unsupported token scope"},
+	}
+	for _, tc := range tests {
+		tokens := scopeTokens(t)
+		for env, scope := range tc.tokens {
+			t.Setenv(env, tokens[scope])
+		}
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().Bool("dry-run", false, "dry-run mode")
+		if tc.dryRun {
+			cmd.Flags().Set("dry-run", "true")
+		}
+		hint, err := preflightScopes(cmd, tc.scopes)
+		if (err == nil) != (tc.hint == "") || !strings.Contains(hint, tc.hint) {
+			t.Errorf("%v %v dry-run=%v: wrong hint %q, error %v", tc.scopes, tc.tokens, tc.dryRun, hint, err)
+		}
+		if err != nil || tc.dryRun {
+			continue
+		}
+		// tokens are cached and re-used by requests of FOXDEN client
+		for _, scope := range tc.scopes {
+			if src, _ := scopeSource(scope); src.Req.Token == "" {
+				t.Errorf("%v: %s token is not cached", tc.scopes, scope)
+			}
+		}
+	}
+}
@@ -5,13 +5,11 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
@@ -19,8 +17,8 @@ import (
 	"time"
 
 	dbs "github.com/CHESSComputing/DataBookkeeping/dbs"
-	srvConfig "github.com/CHESSComputing/golib/config"
 	utils "github.com/CHESSComputing/golib/utils"
+	client "github.com/CHESSComputing/gotools/foxden/client"
 	"github.com/spf13/cobra"
 )
 
@@ -37,13 +35,8 @@ type UrlParams struct {
 	Osname      string `url:"osname"`
 }
 
-// helper function to construct Url
-func buildUrl(rurl string, params UrlParams) string {
-	baseURL, err := url.Parse(rurl)
-	if err != nil {
-		return rurl // Return original if parsing fails
-	}
-
+// helper function to construct url parameters of provenance query
+func urlValues(params UrlParams) url.Values {
 	query := url.Values{}
 	val := reflect.ValueOf(params)
 	typ := reflect.TypeOf(params)
@@ -57,36 +50,17 @@ func buildUrl(rurl string, params UrlParams) string {
 			query.Set(tag, value)
 		}
 	}
-
-	baseURL.RawQuery = query.Encode()
-	return baseURL.String()
+	return query
 }
 
-// helper function to fetch data from DBS service
-func getData(rurl string) []MapRecord {
-	var results []MapRecord
-	if verbose > 0 {
-		fmt.Println("HTTP GET", rurl)
-	}
-	resp, err := _httpReadRequest.Get(rurl)
-	//     resp, err := http.Get(rurl)
+// helper function to list dataset information
+func provListRecord(endpoint string, params UrlParams, opts OutputOptions) {
+	var records []map[string]any
+	results, err := foxdenClient().DataBookkeeping().Records(context.Background(), endpoint, urlValues(params))
 	if err != nil {
 		fmt.Println("ERROR:", err)
-		return results
-	}
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&results); err != nil {
-		fmt.Println("ERROR:", err)
-		return results
 	}
-	return results
-}
-
-// helper function to list dataset information
-func provListRecord(endpoint string, params UrlParams, jsonOutput bool) {
-	rurl := fmt.Sprintf("%s/%s", srvConfig.Config.Services.DataBookkeepingURL, buildUrl(endpoint, params))
-	for _, rec := range getData(rurl) {
+	for _, rec := range results {
 		// convert seconds since epoch to human readable string
 		if v, ok := rec["create_at"]; ok {
 			if v != nil {
@@ -98,24 +72,22 @@ func provListRecord(endpoint string, params UrlParams, jsonOutput bool) {
 				rec["modify_at"] = parseTimestamp(fmt.Sprintf("%v", v))
 			}
 		}
-		if jsonOutput {
-			data, err := json.Marshal(rec)
-			if err == nil {
-				fmt.Println(string(data))
-			} else {
-				exit("unable to marshal data record", err)
-			}
-		} else {
-			// drop all _id fields to make more compact representation of the record
-			nrec := make(MapRecord)
-			for k, v := range rec {
-				if strings.HasSuffix(k, "_id") {
-					continue
-				}
-				nrec[k] = v
+		if !opts.Default() {
+			records = append(records, rec)
+			continue
+		}
+		// drop all _id fields to make more compact representation of the record
+		nrec := make(MapRecord)
+		for k, v := range rec {
+			if strings.HasSuffix(k, "_id") {
+				continue
 			}
-			printRecord(nrec, "---")
+			nrec[k] = v
 		}
+		printRecord(nrec, "---")
+	}
+	if !opts.Default() {
+		writeRecords(records, opts)
 	}
 }
 
@@ -142,39 +114,36 @@ func readInput(args []string) ([]byte, error) {
 	return data, err
 }
 
-// helper function to print HTTP response
-func printResponse(resp *http.Response, err error) {
-	if err == nil && resp.StatusCode == 200 {
+// helper function to print provenance service response
+func printResponse(resp *client.Response, err error) {
+	var cerr *client.Error
+	if err == nil {
 		fmt.Printf("SUCCESS: provenance record was successfully added\n")
-	} else {
-		if err != nil {
-			fmt.Printf("ERROR: fail to add provenance record, error: %v\n", err)
-		} else {
-			fmt.Printf("WARNING: fail to add provenance record\n\n")
-			defer resp.Body.Close()
-			data, err := io.ReadAll(resp.Body)
-			var records []map[string]any
-			err = json.Unmarshal(data, &records)
-			if err == nil {
-				keys := []string{"code", "function", "reason"}
-				for _, rec := range records {
-					if rrr, ok := rec["error"]; ok {
-						record := rrr.(map[string]any)
-						out := make(MapRecord)
-						for key, val := range record {
-							if utils.InList(key, keys) {
-								out[key] = val
-							}
+	} else if errors.As(err, &cerr) && cerr.StatusCode != 0 {
+		fmt.Printf("WARNING: fail to add provenance record\n\n")
+		var records []map[string]any
+		err = json.Unmarshal(cerr.Body, &records)
+		if err == nil {
+			keys := []string{"code", "function", "reason"}
+			for _, rec := range records {
+				if rrr, ok := rec["error"]; ok {
+					record := rrr.(map[string]any)
+					out := make(MapRecord)
+					for key, val := range record {
+						if utils.InList(key, keys) {
+							out[key] = val
 						}
-						printRecord(out, "---")
-					} else {
-						fmt.Println(rec)
 					}
+					printRecord(out, "---")
+				} else {
+					fmt.Println(rec)
 				}
-			} else {
-				fmt.Printf("HTTP response: %+v, error %v\n", string(data), err)
 			}
+		} else {
+			fmt.Printf("HTTP response: %+v, error %v\n", string(cerr.Body), err)
 		}
+	} else {
+		fmt.Printf("ERROR: fail to add provenance record, error: %v\n", err)
 	}
 }
 
@@ -186,19 +155,13 @@ func provAddParent(args []string) {
 	exit("", err)
 
 	// first, we need to check if requested parent did exists in MetaData
-	rurl := fmt.Sprintf("%s/record?did=%s", srvConfig.Config.Services.MetaDataURL, rec.Parent)
-	resp, err := _httpReadRequest.Get(rurl)
-	if resp.StatusCode != 200 {
-		log.Println("### rurl ", rurl, "status code ", resp.StatusCode)
-		err := errors.New("unable to find parent did")
+	ctx := context.Background()
+	if _, err := foxdenClient().MetaData().Record(ctx, rec.Parent); err != nil {
 		msg := fmt.Sprintf("For provided data=%+v there is no parent did=%s in MetaData service", rec, rec.Parent)
 		exit(msg, err)
 	}
 
-	rurl = fmt.Sprintf("%s/parent", srvConfig.Config.Services.DataBookkeepingURL)
-	resp, err = _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-
-	printResponse(resp, err)
+	printResponse(foxdenClient().DataBookkeeping().AddParent(ctx, data))
 }
 
 // helper function to add file information
@@ -208,10 +171,7 @@ func provAddFile(args []string) {
 	err = json.Unmarshal(data, &rec)
 	exit("", err)
 
-	rurl := fmt.Sprintf("%s/file", srvConfig.Config.Services.DataBookkeepingURL)
-	resp, err := _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-
-	printResponse(resp, err)
+	printResponse(foxdenClient().DataBookkeeping().AddFile(context.Background(), data))
 }
 
 // helper function to add dataset information
@@ -222,10 +182,7 @@ func provAddDataset(args []string, elapsedTime bool) {
 	err = json.Unmarshal(data, &rec)
 	exit("unable to unmarshal provenance record", err)
 
-	rurl := fmt.Sprintf("%s/dataset", srvConfig.Config.Services.DataBookkeepingURL)
-	resp, err := _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-
-	printResponse(resp, err)
+	printResponse(foxdenClient().DataBookkeeping().AddDataset(context.Background(), data))
 }
 
 // helper function to delete dataset information
@@ -246,6 +203,8 @@ func provUsage() {
 	fmt.Println("foxden prov ls provenance --did=<DID>")
 	fmt.Println("\n# find provenance information for given DID using in JSON format, --json option can be applied to any command below")
 	fmt.Println("foxden prov ls provenance --did=<DID> --json")
+	fmt.Println("\n# list provenance records as a table, other formats: wide, json, ndjson, yaml, csv, template")
+	fmt.Println("foxden prov ls provenance --did=<DID> --output=table")
 	fmt.Println("\n# find parts of provenance information for given DID using")
 	fmt.Println("foxden prov ls datasets --did=<DID>")
 	fmt.Println("foxden prov ls datasets --file=<filename>")
@@ -275,80 +234,100 @@ func provUsage() {
 	fmt.Println("foxden prov generate --inputDir /ipath --inputFilePattern \"*.jpg\" --outputDir /opath --did /a/b/c")
 }
 
+// helper function to get provenance url parameters from command flags
+func provParams(cmd *cobra.Command) UrlParams {
+	file, _ := cmd.Flags().GetString("file")
+	did, _ := cmd.Flags().GetString("did")
+	script, _ := cmd.Flags().GetString("script")
+	environment, _ := cmd.Flags().GetString("environment")
+	pkg, _ := cmd.Flags().GetString("pkg")
+	site, _ := cmd.Flags().GetString("site")
+	bucket, _ := cmd.Flags().GetString("bucket")
+	processing, _ := cmd.Flags().GetString("processing")
+	osname, _ := cmd.Flags().GetString("osname")
+	return UrlParams{
+		Did:         did,
+		File:        file,
+		Script:      script,
+		Environment: environment,
+		Package:     pkg,
+		Site:        site,
+		Bucket:      bucket,
+		Processing:  processing,
+		Osname:      osname,
+	}
+}
+
 func provCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "prov",
 		Short: "foxden provenance commands",
 		Long:  "foxden provenance commands to access FOXDEN Provenance service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				provUsage()
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v", args)
+			}
+		},
+	}
+	lsCmd := &cobra.Command{
+		Use:   "ls <endpoint>",
+		Short: "list provenance records of given endpoint",
+		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			jsonOutput, _ := cmd.Flags().GetBool("json")
-			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
-			file, _ := cmd.Flags().GetString("file")
+			opts := outputOptions(cmd)
+			if output, _ := cmd.Flags().GetString("output"); jsonOutput && output == "" {
+				// prov ls --json always provided one JSON record per line
+				opts.Format = "ndjson"
+			}
+			// obtain valid access token
+			accessToken()
+			provListRecord(args[0], provParams(cmd), opts)
+		},
+	}
+	lsCmd.ValidArgs = []string{"provenance", "datasets", "osinfo", "environments", "scripts", "files", "parents", "child"}
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "show example of provenance record",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			recordInfo("provenance.json")
+		},
+	}
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "generate provenance record",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
 			did, _ := cmd.Flags().GetString("did")
-			script, _ := cmd.Flags().GetString("script")
-			environment, _ := cmd.Flags().GetString("environment")
-			pkg, _ := cmd.Flags().GetString("pkg")
-			site, _ := cmd.Flags().GetString("site")
-			bucket, _ := cmd.Flags().GetString("bucket")
-			processing, _ := cmd.Flags().GetString("processing")
-			osname, _ := cmd.Flags().GetString("osname")
 			inputDir, _ := cmd.Flags().GetString("inputDir")
 			inputFilePattern, _ := cmd.Flags().GetString("inputFilePattern")
 			outputDir, _ := cmd.Flags().GetString("outputDir")
 			outputFilePattern, _ := cmd.Flags().GetString("outputFilePattern")
-			params := UrlParams{
-				Did:         did,
-				File:        file,
-				Script:      script,
-				Environment: environment,
-				Package:     pkg,
-				Site:        site,
-				Bucket:      bucket,
-				Processing:  processing,
-				Osname:      osname,
-			}
-
-			if jsonOutput {
-				// set _jsonOutputError to properly handle error output in JSON format
-				_jsonOutputError = true
-			}
-			if len(args) == 0 {
-				provUsage()
-			} else if args[0] == "ls" {
-				// obtain valid access token
-				accessToken()
-				if len(args) > 1 {
-					endpoint := args[1]
-					provListRecord(endpoint, params, jsonOutput)
-				}
-			} else if args[0] == "info" {
-				recordInfo("provenance.json")
-			} else if args[0] == "generate" {
-				p := ProvenanceParameters{
-					Did:      did,
-					App:      "YOUR_APPLICATION",
-					InputDir: inputDir, InputFilePattern: inputFilePattern,
-					OutputDir: outputDir, OutputFilePattern: outputFilePattern,
-				}
-				generateProvenanceRecord(p)
-			} else if args[0] == "add" {
-				accessToken()
-				writeToken()
-				provAddDataset(args, elapsedTime)
-				//             } else if args[0] == "add-file" {
-				//                 accessToken()
-				//                 writeToken()
-				//                 provAddFile(args)
-				//             } else if args[0] == "add-parent" {
-				//                 accessToken()
-				//                 writeToken()
-				//                 provAddParent(args)
-			} else {
-				fmt.Printf("WARNING: unsupported option(s) %+v", args)
+			p := ProvenanceParameters{
+				Did:      did,
+				App:      "YOUR_APPLICATION",
+				InputDir: inputDir, InputFilePattern: inputFilePattern,
+				OutputDir: outputDir, OutputFilePattern: outputFilePattern,
 			}
+			generateProvenanceRecord(p)
+		},
+	}
+	addCmd := &cobra.Command{
+		Use:         "add <provenance.json>",
+		Short:       "add provenance record",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("read", "write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			accessToken()
+			provAddDataset(args, elapsedTime)
 		},
 	}
+	cmd.AddCommand(lsCmd, infoCmd, generateCmd, addCmd)
 	cmd.PersistentFlags().String("did", "", "did to use")
 	cmd.PersistentFlags().String("file", "", "file to use")
 	cmd.PersistentFlags().String("script", "", "script to use")
@@ -364,6 +343,8 @@ func provCommand() *cobra.Command {
 	cmd.PersistentFlags().String("outputFilePattern", "", "file pattern to look in output directory")
 	cmd.PersistentFlags().Bool("json", false, "json output")
 	cmd.PersistentFlags().Bool("elapsed-time", false, "print out elapsed time")
+	cmd.RegisterFlagCompletionFunc("did", completeDids)
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		provUsage()
 		return nil
@@ -0,0 +1,451 @@
+package cmd
+
+// CHESComputing foxden tool: query expression module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+// The module compiles human-friendly query expressions into JSON spec accepted
+// by FOXDEN search APIs, e.g.
+//
+//	beamline=3a and cycle in (2024-1,2024-2) and date >= 2024-02-01 and sample_name ~ "^Ti"
+//
+// is compiled into
+//
+//	{"$and":[{"beamline":"3a"},{"cycle":{"$in":["2024-1","2024-2"]}},
+//	         {"date":{"$gte":1706745600}},{"sample_name":{"$regex":"^Ti"}}]}
+//
+// Supported operators: =, !=, >, >=, <, <=, ~ (regular expression), in, not in,
+// expressions can be combined with and, or, not and grouped by parentheses.
+// Bare values are converted to numbers (or booleans) when possible, use quotes
+// to keep them as strings, e.g. btr="123".
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// list of record keys which contain seconds since epoch
+var timeKeys = []string{"date", "create_at", "modify_at"}
+
+// mapping of expression operators to MongoDB operators
+var queryOperators = map[string]string{
+	"!=": "$ne",
+	">":  "$gt",
+	">=": "$gte",
+	"<":  "$lt",
+	"<=": "$lte",
+	"~":  "$regex",
+}
+
+// query expression token types
+const (
+	tokenWord = iota
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenEOF
+)
+
+// QueryToken represents single token of query expression
+type QueryToken struct {
+	Kind  int
+	Value string
+	Pos   int
+}
+
+// QueryError represents error of query expression
+type QueryError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+// Error implements error interface
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s\n  %s\n  %s^", e.Msg, e.Expr, strings.Repeat(" ", e.Pos))
+}
+
+// helper function to split query expression into tokens
+func lexQuery(expr string) ([]QueryToken, error) {
+	var tokens []QueryToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, QueryToken{Kind: tokenLParen, Value: "(", Pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, QueryToken{Kind: tokenRParen, Value: ")", Pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, QueryToken{Kind: tokenComma, Value: ",", Pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(runes) && runes[i] != c {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == c {
+					i++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, &QueryError{Expr: expr, Pos: start, Msg: "unterminated quoted string"}
+			}
+			i++
+			tokens = append(tokens, QueryToken{Kind: tokenString, Value: sb.String(), Pos: start})
+		case strings.ContainsRune("=!<>~", c):
+			start := i
+			op := string(c)
+			if i+1 < len(runes) && runes[i+1] == '=' && c != '=' && c != '~' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &QueryError{Expr: expr, Pos: start, Msg: "unknown operator '!', did you mean '!='?"}
+			}
+			i += len(op)
+			tokens = append(tokens, QueryToken{Kind: tokenOp, Value: op, Pos: start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()=!<>~,\"'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, QueryToken{Kind: tokenWord, Value: string(runes[start:i]), Pos: start})
+		}
+	}
+	tokens = append(tokens, QueryToken{Kind: tokenEOF, Pos: len(runes)})
+	return tokens, nil
+}
+
+// QueryParser compiles query expression into MongoDB query
+type QueryParser struct {
+	expr   string
+	tokens []QueryToken
+	pos    int
+	keys   []string // known search keys, if empty keys are not validated
+}
+
+// helper function to check if token is given keyword
+func isKeyword(tok QueryToken, kw string) bool {
+	return tok.Kind == tokenWord && strings.ToLower(tok.Value) == kw
+}
+
+func (p *QueryParser) peek() QueryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *QueryParser) next() QueryToken {
+	tok := p.tokens[p.pos]
+	if tok.Kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *QueryParser) errorf(pos int, format string, args ...any) error {
+	return &QueryError{Expr: p.expr, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Parse parses query expression and returns MongoDB query
+func (p *QueryParser) Parse() (map[string]any, error) {
+	query, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Kind != tokenEOF {
+		return nil, p.errorf(tok.Pos, "unexpected '%s', expected 'and', 'or' or end of expression", tok.Value)
+	}
+	return query, nil
+}
+
+func (p *QueryParser) parseOr() (map[string]any, error) {
+	var terms []map[string]any
+	for {
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		if !isKeyword(p.peek(), "or") {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return map[string]any{"$or": terms}, nil
+}
+
+func (p *QueryParser) parseAnd() (map[string]any, error) {
+	var terms []map[string]any
+	for {
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		if !isKeyword(p.peek(), "and") {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return map[string]any{"$and": terms}, nil
+}
+
+func (p *QueryParser) parseTerm() (map[string]any, error) {
+	tok := p.peek()
+	if tok.Kind == tokenLParen {
+		p.next()
+		query, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.Kind != tokenRParen {
+			return nil, p.errorf(tok.Pos, "missing closing parenthesis")
+		}
+		return query, nil
+	}
+	if isKeyword(tok, "not") {
+		p.next()
+		query, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"$nor": []map[string]any{query}}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *QueryParser) parseComparison() (map[string]any, error) {
+	tok := p.next()
+	if tok.Kind != tokenWord {
+		return nil, p.errorf(tok.Pos, "expected search key, got '%s'", tok.Value)
+	}
+	key, err := p.checkKey(tok)
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch {
+	case isKeyword(op, "in"):
+		values, err := p.parseList(key)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{key: map[string]any{"$in": values}}, nil
+	case isKeyword(op, "not"):
+		if tok := p.next(); !isKeyword(tok, "in") {
+			return nil, p.errorf(tok.Pos, "expected 'in' after 'not'")
+		}
+		values, err := p.parseList(key)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{key: map[string]any{"$nin": values}}, nil
+	case op.Kind == tokenOp:
+		vtok := p.next()
+		if vtok.Kind != tokenWord && vtok.Kind != tokenString {
+			return nil, p.errorf(vtok.Pos, "expected value after '%s'", op.Value)
+		}
+		value, err := p.convertValue(key, op.Value, vtok)
+		if err != nil {
+			return nil, err
+		}
+		if op.Value == "=" {
+			return map[string]any{key: value}, nil
+		}
+		return map[string]any{key: map[string]any{queryOperators[op.Value]: value}}, nil
+	}
+	return nil, p.errorf(op.Pos, "expected operator (=, !=, >, >=, <, <=, ~, in, not in) after key '%s'", key)
+}
+
+// helper function to parse list of values, e.g. (a, b, c)
+func (p *QueryParser) parseList(key string) ([]any, error) {
+	if tok := p.next(); tok.Kind != tokenLParen {
+		return nil, p.errorf(tok.Pos, "expected '(' to start list of values")
+	}
+	var values []any
+	for {
+		vtok := p.next()
+		if vtok.Kind != tokenWord && vtok.Kind != tokenString {
+			return nil, p.errorf(vtok.Pos, "expected value in the list")
+		}
+		value, err := p.convertValue(key, "in", vtok)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		tok := p.next()
+		if tok.Kind == tokenRParen {
+			break
+		}
+		if tok.Kind != tokenComma {
+			return nil, p.errorf(tok.Pos, "expected ',' or ')' in the list of values")
+		}
+	}
+	return values, nil
+}
+
+// helper function to validate search key against known keys, keys are matched
+// case-insensitively and canonical key is returned since MongoDB keys are
+// case-sensitive
+func (p *QueryParser) checkKey(tok QueryToken) (string, error) {
+	key := tok.Value
+	if len(p.keys) == 0 {
+		return key, nil
+	}
+	for _, k := range p.keys {
+		if k == key {
+			return k, nil
+		}
+	}
+	for _, k := range p.keys {
+		if strings.EqualFold(k, key) {
+			return k, nil
+		}
+	}
+	msg := fmt.Sprintf("unknown search key '%s'", key)
+	if s := closestKey(key, p.keys); s != "" {
+		msg += fmt.Sprintf(", did you mean '%s'?", s)
+	}
+	msg += " (use 'foxden search keys' to list all search keys)"
+	return key, &QueryError{Expr: p.expr, Pos: tok.Pos, Msg: msg}
+}
+
+// helper function to convert token value into typed value for given key and operator
+func (p *QueryParser) convertValue(key, op string, tok QueryToken) (any, error) {
+	val := tok.Value
+	if op == "~" {
+		if _, err := regexp.Compile(val); err != nil {
+			return nil, p.errorf(tok.Pos, "invalid regular expression for key '%s': %v", key, err)
+		}
+		return val, nil
+	}
+	if isTimeKey(key) {
+		if ts, ok := parseQueryTime(val); ok {
+			return ts, nil
+		}
+		return nil, p.errorf(tok.Pos, "type mismatch: key '%s' requires date (YYYY-MM-DD, RFC3339) or seconds since epoch, got '%s'", key, val)
+	}
+	var value any = val
+	if tok.Kind == tokenWord {
+		if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+			value = v
+		} else if v, err := strconv.ParseFloat(val, 64); err == nil {
+			value = v
+		} else if val == "true" || val == "false" {
+			value = val == "true"
+		}
+	}
+	if _, ok := value.(string); ok && (op == ">" || op == ">=" || op == "<" || op == "<=") {
+		return nil, p.errorf(tok.Pos, "type mismatch: operator '%s' requires numeric value for key '%s', got '%s'", op, key, val)
+	}
+	return value, nil
+}
+
+// helper function to check if key contains time value
+func isTimeKey(key string) bool {
+	for _, k := range timeKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// helper function to parse time value into seconds since epoch
+func parseQueryTime(val string) (int64, bool) {
+	if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return v, true
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t.Unix(), true
+		}
+	}
+	return 0, false
+}
+
+// helper function to find closest known key using edit distance
+func closestKey(key string, keys []string) string {
+	best := ""
+	bestDist := len(key)/2 + 1
+	for _, k := range keys {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(k)); d < bestDist {
+			best = k
+			bestDist = d
+		}
+	}
+	return best
+}
+
+// helper function to calculate Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// helper function to check if given spec is query expression, i.e. it starts
+// with parenthesis or with a key followed by an operator
+func isQueryExpression(spec string) bool {
+	tokens, err := lexQuery(spec)
+	if err != nil || len(tokens) < 2 {
+		return strings.HasPrefix(strings.TrimSpace(spec), "(")
+	}
+	if tokens[0].Kind == tokenLParen || isKeyword(tokens[0], "not") {
+		return true
+	}
+	if tokens[0].Kind != tokenWord || strings.Contains(tokens[0].Value, ":") {
+		return false
+	}
+	return tokens[1].Kind == tokenOp || isKeyword(tokens[1], "in") || isKeyword(tokens[1], "not")
+}
+
+// helper function to compile query expression into JSON spec, the keys are
+// validated against given list of known keys unless it is empty
+func compileQuery(expr string, keys []string) (string, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return "", err
+	}
+	parser := &QueryParser{expr: expr, tokens: tokens, keys: keys}
+	query, err := parser.Parse()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+// CHESComputing foxden tool: tests of query expression module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestCompileQuery tests compilation of query expressions into JSON specs
+func TestCompileQuery(t *testing.T) {
+	tests := []struct {
+		expr  string
+		query string
+	}{
+		{`beamline=3a`, `{"beamline":"3a"}`},
+		{`btr="123"`, `{"btr":"123"}`},
+		{`run=123`, `{"run":123}`},
+		{`energy >= 1.5`, `{"energy":{"$gte":1.5}}`},
+		{`in_situ = true`, `{"in_situ":true}`},
+		{`cycle != 2024-1`, `{"cycle":{"$ne":"2024-1"}}`},
+		{`sample_name ~ "^Ti"`, `{"sample_name":{"$regex":"^Ti"}}`},
+		{`date >= 2024-02-01`, `{"date":{"$gte":1706745600}}`},
+		{`cycle in (2024-1, 2024-2)`, `{"cycle":{"$in":["2024-1","2024-2"]}}`},
+		{`cycle not in (2024-1)`, `{"cycle":{"$nin":["2024-1"]}}`},
+		{`not beamline=3a`, `{"$nor":[{"beamline":"3a"}]}`},
+		{`beamline=3a and cycle=2024-1`, `{"$and":[{"beamline":"3a"},{"cycle":"2024-1"}]}`},
+		{`beamline=3a or beamline=3b and run=1`, `{"$or":[{"beamline":"3a"},{"$and":[{"beamline":"3b"},{"run":1}]}]}`},
+		{`(beamline=3a or beamline=3b) and run=1`, `{"$and":[{"$or":[{"beamline":"3a"},{"beamline":"3b"}]},{"run":1}]}`},
+	}
+	for _, tc := range tests {
+		query, err := compileQuery(tc.expr, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.expr, err)
+			continue
+		}
+		if query != tc.query {
+			t.Errorf("%s: got %s, expected %s", tc.expr, query, tc.query)
+		}
+	}
+}
+
+// TestCompileQueryKeys tests validation of query keys against known keys
+func TestCompileQueryKeys(t *testing.T) {
+	keys := []string{"beamline", "Cycle", "sample_name"}
+	query, err := compileQuery(`cycle=2024-1 and BEAMLINE=3a`, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"$and":[{"Cycle":"2024-1"},{"beamline":"3a"}]}`; query != expect {
+		t.Errorf("keys are not canonical, got %s, expected %s", query, expect)
+	}
+	_, err = compileQuery(`sample_nme=Ti`, keys)
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("expected query error, got %v", err)
+	}
+	if !strings.Contains(qerr.Msg, "did you mean 'sample_name'?") {
+		t.Errorf("no key suggestion in %q", qerr.Msg)
+	}
+}
+
+// TestCompileQueryErrors tests errors of malformed query expressions
+func TestCompileQueryErrors(t *testing.T) {
+	for _, expr := range []string{
+		`beamline=`,
+		`beamline 3a`,
+		`(beamline=3a`,
+		`beamline=3a cycle=2024-1`,
+		`cycle in 2024-1`,
+		`cycle not 2024-1`,
+		`run > abc`,
+		`date >= yesterday`,
+		`sample_name ~ "["`,
+	} {
+		if query, err := compileQuery(expr, nil); err == nil {
+			t.Errorf("%s: expected error, got %s", expr, query)
+		}
+	}
+}
+
+// TestIsQueryExpression tests detection of query expressions
+func TestIsQueryExpression(t *testing.T) {
+	tests := []struct {
+		spec string
+		expr bool
+	}{
+		{`beamline=3a`, true},
+		{`cycle in (2024-1)`, true},
+		{`(beamline=3a)`, true},
+		{`not beamline=3a`, true},
+		{`beamline:3a`, false},
+		{`{"beamline":"3a"}`, false},
+		{`did:/beamline=3a`, false},
+	}
+	for _, tc := range tests {
+		if isQueryExpression(tc.spec) != tc.expr {
+			t.Errorf("%s: expected %v", tc.spec, tc.expr)
+		}
+	}
+}
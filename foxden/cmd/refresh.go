@@ -0,0 +1,228 @@
+package cmd
+
+// CHESComputing foxden tool: token refresh module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	services "github.com/CHESSComputing/golib/services"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// default margin before token expiration when token is refreshed
+const defaultTokenMargin = 5 * time.Minute
+
+// minimal interval between attempts to refresh token of the same scope
+const tokenRenewInterval = time.Minute
+
+// _tokenMu protects tokens of FOXDEN HTTP requests during refresh
+var _tokenMu sync.Mutex
+
+// _tokenRenewed keeps time of last refresh attempt of every token scope
+var _tokenRenewed = make(map[string]time.Time)
+
+// TokenSource represents source of FOXDEN token of given scope
+type TokenSource struct {
+	Scope string                // token scope: read, write or delete
+	Env   string                // environment variable with token or token file
+	Req   *services.HttpRequest // FOXDEN HTTP request which carries the token
+}
+
+// helper function to get FOXDEN token sources
+func tokenSources() []TokenSource {
+	return []TokenSource{
+		{Scope: "read", Env: "FOXDEN_TOKEN", Req: _httpReadRequest},
+		{Scope: "write", Env: "FOXDEN_WRITE_TOKEN", Req: _httpWriteRequest},
+		{Scope: "delete", Env: "FOXDEN_DELETE_TOKEN", Req: _httpDeleteRequest},
+	}
+}
+
+// helper function to get default token file of given scope, token files
+// are namespaced by configuration context to keep dev and production
+// tokens apart
+func tokenFile(scope string) string {
+	if dir := contextTokenDir(); dir != "" {
+		return fmt.Sprintf("%s/.foxden.%s.token", dir, scope)
+	}
+	if _currentContext != "" {
+		return fmt.Sprintf("%s/.foxden.%s.%s.token", os.Getenv("HOME"), _currentContext, scope)
+	}
+	return fmt.Sprintf("%s/.foxden.%s.token", os.Getenv("HOME"), scope)
+}
+
+// _envWarned keeps global token environment variables which were reported as
+// shared by all configuration contexts
+var _envWarned sync.Map
+
+// helper function to get name of token environment variable in current
+// configuration context, e.g. FOXDEN_WRITE_TOKEN becomes FOXDEN_DEV_WRITE_TOKEN
+// in dev context, without context the global variable is used
+func contextEnv(env string) string {
+	if _currentContext == "" {
+		return env
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, _currentContext)
+	return "FOXDEN_" + name + strings.TrimPrefix(env, "FOXDEN")
+}
+
+// helper function to get name and value of token environment variable of given
+// source, the global variable is used in configuration context only if context
+// variable is not set and warning is printed since it is shared by all contexts
+func envToken(src TokenSource) (string, string) {
+	env := contextEnv(src.Env)
+	if val := os.Getenv(env); val != "" || env == src.Env {
+		return env, val
+	}
+	val := os.Getenv(src.Env)
+	if val == "" {
+		return env, val
+	}
+	if _, warned := _envWarned.LoadOrStore(src.Env, true); !warned {
+		fmt.Fprintf(os.Stderr, "WARNING: %s is shared by all contexts, please use %s for %s context\n", src.Env, env, _currentContext)
+	}
+	return src.Env, val
+}
+
+// helper function to get token refresh margin, it can be set via
+// FOXDEN_TOKEN_MARGIN environment, e.g. FOXDEN_TOKEN_MARGIN=10m
+func tokenMargin() time.Duration {
+	if val := os.Getenv("FOXDEN_TOKEN_MARGIN"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			return d
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: invalid FOXDEN_TOKEN_MARGIN value '%s', use %s\n", val, defaultTokenMargin)
+	}
+	return defaultTokenMargin
+}
+
+// helper function to get expiration time and lifetime of the token, expired
+// tokens do not pass validation of authz.TokenClaims and therefore claims are
+// always read without validation, the token is validated by FOXDEN services
+func tokenExpiration(token string) (time.Time, time.Duration, error) {
+	var rclaims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &rclaims); err != nil {
+		return time.Time{}, 0, err
+	}
+	if rclaims.ExpiresAt == nil {
+		return time.Time{}, 0, nil
+	}
+	exp := rclaims.ExpiresAt.Time
+	var lifetime time.Duration
+	if rclaims.IssuedAt != nil {
+		lifetime = exp.Sub(rclaims.IssuedAt.Time)
+	}
+	return exp, lifetime, nil
+}
+
+// helper function to check if token is expired or expires within given margin,
+// tokens without expiration never expire while malformed tokens are expired
+func tokenExpiring(token string, margin time.Duration) bool {
+	exp, _, err := tokenExpiration(token)
+	if err != nil {
+		return true
+	}
+	if exp.IsZero() {
+		return false
+	}
+	return time.Now().Add(margin).After(exp)
+}
+
+// helper function to obtain new token of given scope from Kerberos cache and
+// write it into token file, new token has the same lifetime as the old one
+func renewToken(scope, tfile, old string) (string, error) {
+	if last, ok := _tokenRenewed[scope]; ok && time.Since(last) < tokenRenewInterval {
+		return "", fmt.Errorf("%s token was refreshed less than %s ago", scope, tokenRenewInterval)
+	}
+	_tokenRenewed[scope] = time.Now()
+	kfile := keyFile()
+	if _, err := os.Stat(kfile); err != nil {
+		return "", fmt.Errorf("no kerberos ticket file %s found, please run kinit: %w", kfile, err)
+	}
+	var expires int
+	if old != "" {
+		if _, lifetime, err := tokenExpiration(old); err == nil && lifetime > 0 {
+			expires = int(lifetime.Seconds())
+		}
+	}
+	token, err := requestToken(scope, kfile, expires)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", errors.New("empty token from Authz service")
+	}
+	if err := saveToken(tfile, token); err != nil {
+		return "", err
+	}
+	if verbose > 0 {
+		fmt.Fprintf(os.Stderr, "refreshed %s token %s\n", scope, tfile)
+	}
+	return token, nil
+}
+
+// helper function to get valid token of given source, the token is read from
+// environment (token or token file) or default token file and it is
+// regenerated from Kerberos cache when it is expired or expires within the
+// refresh margin
+func refreshToken(src TokenSource) (string, error) {
+	_tokenMu.Lock()
+	defer _tokenMu.Unlock()
+	margin := tokenMargin()
+	if src.Req.Token != "" && !tokenExpiring(src.Req.Token, margin) {
+		return src.Req.Token, nil
+	}
+	tfile := tokenFile(src.Scope)
+	var token string
+	if _, val := envToken(src); val != "" {
+		token = readToken(val)
+		if _, err := os.Stat(val); err == nil {
+			// environment points to token file, keep refreshed token there
+			tfile = val
+		}
+	} else {
+		token = readToken(tfile)
+	}
+	if token == "" || tokenExpiring(token, margin) {
+		ntoken, err := renewToken(src.Scope, tfile, token)
+		switch {
+		case err == nil:
+			token = ntoken
+		case token == "":
+			return "", err
+		case tokenExpiring(token, 0):
+			return "", fmt.Errorf("%s token is expired and cannot be refreshed: %w", src.Scope, err)
+		default:
+			if verbose > 0 {
+				fmt.Fprintf(os.Stderr, "WARNING: unable to refresh %s token which expires soon: %v\n", src.Scope, err)
+			}
+		}
+	}
+	src.Req.Token = token
+	return token, nil
+}
+
+// helper function to refresh tokens of FOXDEN HTTP requests which expire soon,
+// it should be called by long-running operations between requests
+func refreshTokens() {
+	for _, src := range tokenSources() {
+		if src.Req.Token == "" || !tokenExpiring(src.Req.Token, tokenMargin()) {
+			continue
+		}
+		if _, err := refreshToken(src); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: unable to refresh %s token: %v\n", src.Scope, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+// CHESComputing foxden tool: meta-data bulk delete module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// DeleteResult represents outcome of single record deletion
+type DeleteResult struct {
+	Did    string `json:"did"`
+	Status string `json:"status"`
+	Trash  string `json:"trash,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// helper function to resolve dids of all meta-data records matching given query
+func queryDids(user, spec string, skeys []string, sorder int) ([]string, error) {
+	var dids []string
+	rurl := srvConfig.Config.MetaDataURL
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		return getMeta(rurl, user, spec, skeys, sorder, idx, limit)
+	}
+	pager := NewPager(fetch, 0, 0, 100, true)
+	for pager.Next() {
+		for _, rec := range pager.Records() {
+			if did, ok := rec["did"]; ok {
+				dids = append(dids, fmt.Sprintf("%v", did))
+			}
+		}
+	}
+	return dids, pager.Err()
+}
+
+// helper function to ask user to confirm deletion of given number of records
+func confirmDelete(nrecords int) bool {
+	fmt.Printf("\nType '%d' to confirm deletion of %d records: ", nrecords, nrecords)
+	reader := bufio.NewReader(os.Stdin)
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(answer) == fmt.Sprintf("%d", nrecords)
+}
+
+// helper function to delete all meta-data records matching given query
+func metaBulkDelete(user, spec string, skeys []string, sorder, workers int, yes, dryRun, jsonOutput bool) {
+	if spec == "" {
+		exit("please provide non-empty query", errors.New("empty query"))
+	}
+	dids, err := queryDids(user, spec, skeys, sorder)
+	exit("unable to look-up meta-data records", err)
+	if len(dids) == 0 {
+		if !jsonOutput {
+			fmt.Println("No records found for given query")
+		}
+		return
+	}
+	if jsonOutput && dryRun {
+		// report records which would be removed in the same format as removed ones
+		for _, did := range dids {
+			data, err := json.Marshal(DeleteResult{Did: did, Status: "dry-run"})
+			exit("unable to marshal delete result", err)
+			fmt.Println(string(data))
+		}
+		return
+	}
+	if !jsonOutput {
+		for _, did := range dids {
+			fmt.Println(did)
+		}
+		fmt.Println("---")
+		fmt.Printf("Total %d records match query %s\n", len(dids), spec)
+	}
+	if dryRun {
+		fmt.Println("dry-run: no records were removed")
+		return
+	}
+	if !yes {
+		if jsonOutput {
+			exit("please use --yes option along with --json", errors.New("no confirmation"))
+		}
+		if !confirmDelete(len(dids)) {
+			fmt.Println("Deletion is aborted")
+			os.Exit(1)
+		}
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	report := func(res DeleteResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[res.Status]++
+		if jsonOutput {
+			data, err := json.Marshal(res)
+			if err == nil {
+				fmt.Println(string(data))
+			}
+			return
+		}
+		if res.Status == "ok" {
+			fmt.Printf("%-8s %s\n", res.Status, res.Did)
+		} else {
+			fmt.Printf("%-8s %s %s\n", res.Status, res.Did, res.Error)
+		}
+	}
+	jobs := make(chan string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for did := range jobs {
+				res := DeleteResult{Did: did, Status: "ok"}
+				tfile, response, body, err := deleteMetaRecord(user, did)
+				if err != nil {
+					res.Status = "failed"
+					res.Error = err.Error()
+				} else if response.Status != "ok" {
+					res.Status = "failed"
+					res.Error = response.Error
+					if res.Error == "" {
+						res.Error = string(body)
+					}
+				} else {
+					res.Trash = tfile
+				}
+				report(res)
+			}
+		}()
+	}
+	for _, did := range dids {
+		jobs <- did
+	}
+	close(jobs)
+	wg.Wait()
+
+	if jsonOutput {
+		if counts["failed"] > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Println("---")
+	fmt.Printf("Total records: %d, removed: %d, failed: %d\n", len(dids), counts["ok"], counts["failed"])
+	if counts["ok"] > 0 {
+		fmt.Printf("removed records are kept in %s, use 'foxden meta restore <DID>' to restore them\n", trashDir())
+	}
+	if counts["failed"] > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+// CHESComputing foxden tool: tests of meta-data bulk delete module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	client "github.com/CHESSComputing/gotools/foxden/client"
+)
+
+// TestQueryDids tests that dids of all matching records are resolved page by page
+func TestQueryDids(t *testing.T) {
+	c := mockServices(t)
+	for i := 0; i < 105; i++ {
+		mockMetaRecord(t, c, fmt.Sprintf("/beamline=3a/btr=abc/sample_name=s%03d", i))
+	}
+	dids, err := queryDids("tester", `{"btr":"abc"}`, []string{"did"}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dids) != 105 || dids[0] != "/beamline=3a/btr=abc/sample_name=s104" || dids[104] != "/beamline=3a/btr=abc/sample_name=s000" {
+		t.Errorf("wrong dids %d: %v ... %v", len(dids), dids[0], dids[len(dids)-1])
+	}
+	if dids, err := queryDids("tester", `{"btr":"xyz"}`, []string{"did"}, 1); err != nil || len(dids) != 0 {
+		t.Errorf("wrong dids of query without records %v, error %v", dids, err)
+	}
+}
+
+// TestConfirmDelete tests that deletion is confirmed only by number of records
+func TestConfirmDelete(t *testing.T) {
+	stdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = stdin })
+	tests := []struct {
+		answer  string
+		confirm bool
+	}{
+		{"3\n", true},
+		{"  3 \n", true},
+		{"yes\n", false},
+		{"30\n", false},
+		{"3", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		f, err := os.Open(writeTestFile(t, filepath.Join(t.TempDir(), "answer"), tc.answer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdin = f
+		if confirm := confirmDelete(3); confirm != tc.confirm {
+			t.Errorf("%q: wrong confirmation %v", tc.answer, confirm)
+		}
+		f.Close()
+	}
+}
+
+// TestDeleteMetaRecord tests that record is deleted with delete token of FOXDEN client
+// and its copy is kept in local trash
+func TestDeleteMetaRecord(t *testing.T) {
+	c := mockServices(t)
+	did := "/beamline=3a/btr=abc"
+	mockMetaRecord(t, c, did)
+	var scopes []client.Scope
+	c.Tokens = client.TokenFunc(func(ctx context.Context, scope client.Scope) (string, error) {
+		scopes = append(scopes, scope)
+		return "", nil
+	})
+	tfile, response, _, err := deleteMetaRecord("tester", did)
+	if err != nil || response.Status != "ok" {
+		t.Fatalf("unable to delete record, response %+v, error %v", response, err)
+	}
+	if _, err := os.Stat(tfile); err != nil {
+		t.Errorf("record is not kept in trash: %v", err)
+	}
+	if len(scopes) == 0 || scopes[len(scopes)-1] != client.DeleteScope {
+		t.Errorf("wrong token scopes %v", scopes)
+	}
+	if nrecords, err := c.MetaData().Count(context.Background(), "{}"); err != nil || nrecords != 0 {
+		t.Errorf("wrong number of records after deletion %d, error %v", nrecords, err)
+	}
+	// record which does not exist is not saved into trash
+	if _, _, _, err := deleteMetaRecord("tester", did); err == nil {
+		t.Error("non-existing record was deleted")
+	}
+}
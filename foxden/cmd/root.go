@@ -27,30 +27,28 @@ var (
 	}
 )
 
+// defaultSiteConfig represents CHESS site wide FOXDEN configuration used when
+// user does not have its own one, it can be changed via FOXDEN_SITE_CONFIG environment
+const defaultSiteConfig = "/nfs/chess/user/chess_chapaas/.foxden.yaml"
+
 // Execute executes the root command.
 func Execute() error {
 	return rootCmd.Execute()
 }
 
 func init() {
-	defaultConfig := fmt.Sprintf("%s/.foxden.yaml", os.Getenv("HOME"))
-	chessUserConfig := "/nfs/chess/user/chess_chapaas/.foxden.yaml"
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.foxden.yaml)")
 	rootCmd.PersistentFlags().IntVar(&verbose, "verbose", 0, "verbosity level)")
-	if cfgFile != "" {
-		// will use cfgFile provided via --config option
-	} else if os.Getenv("FOXDEN_CONFIG") != "" {
-		// use config defined in FOXDEN_CONFIG environment
-		cfgFile = os.Getenv("FOXDEN_CONFIG")
-	} else if _, err := os.Stat(defaultConfig); err == nil {
-		// use config located in user's home area
-		cfgFile = defaultConfig
-	} else if _, err := os.Stat(chessUserConfig); err == nil {
-		// use CHESS based user's config
-		cfgFile = chessUserConfig
-	}
-	os.Setenv("FOXDEN_CONFIG", cfgFile)
+	rootCmd.PersistentFlags().StringVar(&_recordFile, "record", "", "record HTTP requests and responses into given cassette file")
+	rootCmd.PersistentFlags().StringVar(&_replayFile, "replay", "", "replay HTTP responses from given cassette file instead of FOXDEN services")
+	rootCmd.PersistentFlags().BoolVar(&_trace, "trace", false, "trace HTTP calls with equivalent curl commands and timings to stderr")
+	rootCmd.PersistentFlags().StringVar(&_context, "context", "", "configuration context to use, see foxden config get-contexts")
+	rootCmd.RegisterFlagCompletionFunc("context", completeContexts)
 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		retrySummary()
+		traceSummary()
+	}
 
 	_httpReadRequest = services.NewHttpRequest("read", 0)
 	_httpWriteRequest = services.NewHttpRequest("write", 0)
@@ -75,22 +73,73 @@ func init() {
 	rootCmd.AddCommand(fabricCommand())
 	rootCmd.AddCommand(validateCommand())
 	rootCmd.AddCommand(tmplCommand())
+	rootCmd.AddCommand(mirrorCommand())
+	rootCmd.AddCommand(completionCommand())
+	rootCmd.AddCommand(mockCommand())
+	rootCmd.AddCommand(doctorCommand())
+}
+
+// helper function to find FOXDEN configuration when --config option is not
+// provided, it is taken from FOXDEN_CONFIG environment, $HOME/.foxden.yaml or
+// site configuration (FOXDEN_SITE_CONFIG environment or defaultSiteConfig),
+// in this order
+func configFile() string {
+	if fname := os.Getenv("FOXDEN_CONFIG"); fname != "" {
+		return fname
+	}
+	fname := fmt.Sprintf("%s/.foxden.yaml", os.Getenv("HOME"))
+	if _, err := os.Stat(fname); err == nil {
+		return fname
+	}
+	fname = os.Getenv("FOXDEN_SITE_CONFIG")
+	if fname == "" {
+		fname = defaultSiteConfig
+	}
+	if _, err := os.Stat(fname); err != nil {
+		return ""
+	}
+	// site configuration is shared by all users, make its usage visible
+	fmt.Fprintf(os.Stderr, "foxden: using site configuration %s, use --config option or FOXDEN_CONFIG environment to use another one\n", fname)
+	return fname
 }
 
 func initConfig() {
+	if cfgFile == "" {
+		cfgFile = configFile()
+	}
+	os.Setenv("FOXDEN_CONFIG", cfgFile)
 	// check that our config file does not exist
 	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
+		if configOptional() {
+			srvConfig.Config = &srvConfig.SrvConfig{}
+			return
+		}
 		msg := fmt.Sprintf("FOXDEN config: '%s' does not exist.\n", cfgFile)
 		msg += "Please either use --config=<config> option or define FOXDEN_CONFIG environment with your configuration file"
 		log.Fatal(msg)
 	}
 	// parse our config file
 	config, err := srvConfig.ParseConfig(cfgFile)
+	if err != nil && configOptional() {
+		// let command report configuration problem, e.g. foxden doctor
+		_configError = err
+		srvConfig.Config = &srvConfig.SrvConfig{}
+		return
+	}
 	if err != nil {
 		fmt.Println("ERROR", err)
 		os.Exit(1)
 	}
 	srvConfig.Config = &config
+	if err := applyContext(cfgFile); err != nil {
+		if !contextOptional() {
+			exit("unable to apply FOXDEN configuration context", err)
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	}
+	setupTransport(cfgFile)
+	setupCassette(_recordFile, _replayFile)
+	setupTrace(_trace)
 	if os.Getenv("FOXDEN_VERBOSE") != "" {
 		fmt.Println("FOXDEN uses:", cfgFile)
 		fmt.Printf("FOXDEN services: %+v\n", srvConfig.Config.Services)
@@ -99,4 +148,12 @@ func initConfig() {
 	if verbose == "1" || verbose == "true" {
 		log.SetFlags(log.LstdFlags | log.Llongfile)
 	}
+	// validate tokens of invoked command before any request is made
+	preflight()
+}
+
+// helper function to check if invoked command can run without FOXDEN configuration
+func configOptional() bool {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	return err == nil && cmd.Annotations["config"] == "optional"
 }
@@ -0,0 +1,34 @@
+package cmd
+
+// CHESComputing foxden tool: tests of root module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestConfigFile tests look-up of FOXDEN configuration
+func TestConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("FOXDEN_CONFIG", "")
+	site := filepath.Join(t.TempDir(), "site.yaml")
+	t.Setenv("FOXDEN_SITE_CONFIG", site)
+	if fname := configFile(); fname != "" {
+		t.Errorf("missing site configuration is used %s", fname)
+	}
+	writeTestFile(t, site, "")
+	if fname := configFile(); fname != site {
+		t.Errorf("wrong site configuration %s", fname)
+	}
+	user := writeTestFile(t, filepath.Join(home, ".foxden.yaml"), "")
+	if fname := configFile(); fname != user {
+		t.Errorf("wrong user configuration %s", fname)
+	}
+	t.Setenv("FOXDEN_CONFIG", "/tmp/foxden.yaml")
+	if fname := configFile(); fname != "/tmp/foxden.yaml" {
+		t.Errorf("wrong configuration of FOXDEN_CONFIG %s", fname)
+	}
+}
@@ -5,35 +5,16 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"path/filepath"
 
-	srvConfig "github.com/CHESSComputing/golib/config"
 	utils "github.com/CHESSComputing/golib/utils"
 	"github.com/spf13/cobra"
 )
 
-// StorageRecord represents Storage record returned by datamanagement service
-type StorageRecord struct {
-	Status string `json:"status"`
-	Data   any    `json:"data"`
-}
-
-// UploadRecord represents Storage record returned by datamanagement service
-type UploadRecord struct {
-	Status string `json:"status"`
-	Error  string `json:"error"`
-	Msg    string `json:"msg"`
-	Object any    `json:"object"`
-}
-
 // helper function to provide s3 usage info
 func s3Usage() {
 	fmt.Println("foxden s3 <ls|create|delete|upload> [options]")
@@ -51,38 +32,31 @@ func s3Usage() {
 	fmt.Println("foxden s3 ls Cornell")
 	fmt.Println("\n# list specific bucket on s3 storage:")
 	fmt.Println("foxden s3 ls Cornell/bucket")
+	fmt.Println("\n# list specific bucket on s3 storage as a table or YAML:")
+	fmt.Println("foxden s3 ls Cornell/bucket --output=table")
+	fmt.Println("foxden s3 ls Cornell/bucket --output=yaml")
 }
 
 // helper function to list content of a bucket on s3 storage
-func s3List(args []string) {
-	// args contains [ls bucket]
-	if args[0] != "ls" {
-		fmt.Println("ERROR: wrong action", args)
-		os.Exit(1)
-	}
-	var bucketName string
-	rurl := fmt.Sprintf("%s/storage", srvConfig.Config.Services.DataManagementURL)
-	if len(args) > 1 {
-		bucketName = args[1]
-		rurl = fmt.Sprintf("%s/storage/%s", srvConfig.Config.Services.DataManagementURL, bucketName)
-	}
-
-	if verbose > 0 {
-		fmt.Println("HTTP GET", rurl)
-	}
-	resp, err := _httpReadRequest.Get(rurl)
-	if err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	var results StorageRecord
-	if err := dec.Decode(&results); err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
+func s3List(bucketName string, opts OutputOptions) {
+	results, err := foxdenClient().DataManagement().Storage(context.Background(), bucketName)
+	exit("unable to list s3 storage", err)
 	data := results.Data
+	if !opts.Default() {
+		var records []map[string]any
+		switch v := data.(type) {
+		case map[string]any:
+			records = append(records, v)
+		case []any:
+			for _, rec := range v {
+				if rmap, ok := rec.(map[string]any); ok {
+					records = append(records, rmap)
+				}
+			}
+		}
+		writeRecords(records, opts)
+		return
+	}
 	switch v := data.(type) {
 	case map[string]any:
 		printMap(v)
@@ -92,7 +66,7 @@ func s3List(args []string) {
 			case map[string]any:
 				printMap(vvv)
 			default:
-				fmt.Println("%+v %T", vvv, vvv)
+				fmt.Printf("%+v %T\n", vvv, vvv)
 			}
 		}
 	default:
@@ -129,35 +103,11 @@ func printMap(m map[string]any) {
 }
 
 // helper function to create new bucket on s3 storage
-func s3Create(args []string) {
-	// args contains [create bucket]
-	if len(args) != 2 {
-		fmt.Println("ERROR: wrong number of arguments")
-		os.Exit(1)
-	}
-	if args[0] != "create" {
-		fmt.Println("ERROR: wrong action", args)
-		os.Exit(1)
-	}
-	bucketName := args[1]
+func s3Create(bucketName string) {
 	fmt.Printf("INFO: create bucket %s\n", bucketName)
-	var results StorageRecord
-	rurl := fmt.Sprintf("%s/storage/%s", srvConfig.Config.Services.DataManagementURL, bucketName)
-	if verbose > 0 {
-		fmt.Println("HTTP POST", rurl)
-	}
-	resp, err := _httpWriteRequest.Post(rurl, "", bytes.NewBuffer([]byte{}))
-	if err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&results); err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	fmt.Printf("results: %+v\n", results)
+	results, err := foxdenClient().DataManagement().CreateBucket(context.Background(), bucketName)
+	exit(fmt.Sprintf("unable to create bucket %s", bucketName), err)
+	fmt.Printf("results: %+v\n", *results)
 }
 
 // isDirectory determines if a file represented
@@ -172,18 +122,7 @@ func isDirectory(path string) (bool, error) {
 }
 
 // helper function to upload file or directory to bucket on s3 storage
-func s3Upload(args []string) {
-	// args contains [upload bucket file|dir]
-	if len(args) != 3 {
-		fmt.Println("ERROR: wrong number of arguments")
-		os.Exit(1)
-	}
-	if args[0] != "upload" {
-		fmt.Println("ERROR: wrong action", args)
-		os.Exit(1)
-	}
-	bucketName := args[1]
-	fobj := args[2]
+func s3Upload(bucketName, fobj string) {
 	var files []string
 	isDir, err := isDirectory(fobj)
 	if err != nil {
@@ -203,114 +142,28 @@ func s3Upload(args []string) {
 	}
 	for _, f := range files {
 		fname := filepath.Join(fobj, f)
-		fmt.Printf("INFO: upload %s to bucket %s\n", fname, bucketName)
-		rurl := fmt.Sprintf("%s/storage/%s/%s", srvConfig.Config.Services.DataManagementURL, bucketName, f)
-		if verbose > 0 {
-			fmt.Println("HTTP POST", rurl)
+		if !isDir {
+			fname = fobj
+			f = filepath.Base(fobj)
 		}
+		fmt.Printf("INFO: upload %s to bucket %s\n", fname, bucketName)
 		// open file and read its content
 		// TODO: we may need buffer stream to reduce RAM utilization
 		file, err := os.Open(fname)
-		if err != nil {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
-		}
-		defer file.Close()
-
-		// send POST request to DataManagement service with file data content
-		// see https://stackoverflow.com/questions/20205796/post-data-using-the-content-type-multipart-form-data
-		/*
-		   ```
-		    curl -X POST http://localhost:8340/storage/cornell/s3-bucket/archive.zip \
-		     -F "file=@/path/test.zip" \
-		     -H "Content-Type: multipart/form-data"
-		   ```
-		*/
-		// prepare our payload by reading the local file and passing it to
-		// multipart writer
-		var buf bytes.Buffer
-		var errBuf error
-		w := multipart.NewWriter(&buf)
-		if fw, err := w.CreateFormFile("file", file.Name()); err == nil {
-			if _, err := io.Copy(fw, file); err != nil {
-				errBuf = err
-			}
-		} else {
-			errBuf = err
-		}
-		w.Close()
-		if errBuf != nil {
-			fmt.Println("ERROR:", errBuf)
-			os.Exit(1)
-		}
-
-		req, err := http.NewRequest("POST", rurl, &buf)
-		if err != nil {
-			fmt.Println("ERROR:", err)
-			os.Exit(1)
-		}
-		req.Header.Set("Content-Type", w.FormDataContentType())
-		accessToken := os.Getenv("FOXDEN_WRITE_TOKEN")
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-		client := http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Println("ERROR:", err)
-			os.Exit(1)
-		}
-		defer resp.Body.Close()
-		dec := json.NewDecoder(resp.Body)
-		var results UploadRecord
-		if err := dec.Decode(&results); err != nil {
-			fmt.Println("ERROR:", err)
-			os.Exit(1)
-		}
-		fmt.Printf("results: %+v\n", results)
+		exit(fmt.Sprintf("unable to open file %s", fname), err)
+		results, err := foxdenClient().DataManagement().Upload(context.Background(), bucketName, f, file)
+		file.Close()
+		exit(fmt.Sprintf("unable to upload %s to bucket %s", fname, bucketName), err)
+		fmt.Printf("results: %+v\n", *results)
 	}
 }
 
 // helper function to delete bucket on s3 storage
-func s3Delete(args []string) {
-	// args contains [delete bucket]
-	if len(args) != 2 {
-		fmt.Println("ERROR: wrong number of arguments")
-		os.Exit(1)
-	}
-	if args[0] != "delete" {
-		fmt.Println("ERROR: wrong action", args)
-		os.Exit(1)
-	}
-	bucketName := args[1]
+func s3Delete(bucketName string) {
 	fmt.Printf("INFO: delete bucket %s\n", bucketName)
-	var results StorageRecord
-	rurl := fmt.Sprintf("%s/storage/%s", srvConfig.Config.Services.DataManagementURL, bucketName)
-	if verbose > 0 {
-		fmt.Println("HTTP DELETE", rurl)
-	}
-	req, err := http.NewRequest("DELETE", rurl, nil)
-	if err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	accessToken := os.Getenv("FOXDEN_DELETE_TOKEN")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	client := http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	if err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&results); err != nil {
-		fmt.Println("ERROR:", err)
-		os.Exit(1)
-	}
-	fmt.Printf("results: %+v\n", results)
+	results, err := foxdenClient().DataManagement().DeleteBucket(context.Background(), bucketName)
+	exit(fmt.Sprintf("unable to delete bucket %s", bucketName), err)
+	fmt.Printf("results: %+v\n", *results)
 }
 
 func s3Command() *cobra.Command {
@@ -322,23 +175,57 @@ func s3Command() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				s3Usage()
-			} else if args[0] == "ls" {
-				accessToken()
-				s3List(args)
-			} else if args[0] == "create" {
-				writeToken()
-				s3Create(args)
-			} else if args[0] == "delete" {
-				deleteToken()
-				s3Delete(args)
-			} else if args[0] == "upload" {
-				writeToken()
-				s3Upload(args)
 			} else {
 				fmt.Printf("WARNING: unsupported option(s) %+v\n", args)
 			}
 		},
 	}
+	lsCmd := &cobra.Command{
+		Use:   "ls [storage/bucket]",
+		Short: "list content of s3 storage or bucket",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			accessToken()
+			var bucket string
+			if len(args) == 1 {
+				bucket = args[0]
+			}
+			s3List(bucket, outputOptions(cmd))
+		},
+	}
+	createCmd := &cobra.Command{
+		Use:         "create <storage/bucket>",
+		Short:       "create new bucket",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			s3Create(args[0])
+		},
+	}
+	deleteCmd := &cobra.Command{
+		Use:         "delete <storage/bucket>",
+		Short:       "remove bucket or file",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("delete"),
+		Run: func(cmd *cobra.Command, args []string) {
+			s3Delete(args[0])
+		},
+	}
+	uploadCmd := &cobra.Command{
+		Use:         "upload <storage/bucket> <file|dir>",
+		Short:       "upload file or directory to a bucket",
+		Args:        cobra.ExactArgs(2),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			s3Upload(args[0], args[1])
+		},
+	}
+	for _, c := range []*cobra.Command{lsCmd, createCmd, deleteCmd, uploadCmd} {
+		c.ValidArgsFunction = completeBuckets
+		cmd.AddCommand(c)
+	}
+	cmd.PersistentFlags().Bool("json", false, "json output")
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		s3Usage()
 		return nil
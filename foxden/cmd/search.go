@@ -5,57 +5,47 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"sort"
 	"strings"
 	"time"
 
-	srvConfig "github.com/CHESSComputing/golib/config"
-	services "github.com/CHESSComputing/golib/services"
 	utils "github.com/CHESSComputing/golib/utils"
+	client "github.com/CHESSComputing/gotools/foxden/client"
+	match "github.com/CHESSComputing/gotools/foxden/match"
 	"github.com/spf13/cobra"
 )
 
 func metaRecords(user, query string, skeys []string, sorder int) ([]map[string]any, error) {
-	var records []map[string]any
-	rec := services.ServiceRequest{
-		Client:       "foxden",
-		ServiceQuery: services.ServiceQuery{Query: query, Idx: 0, Limit: -1, SortKeys: skeys, SortOrder: sorder},
-	}
-	data, err := json.Marshal(rec)
-	rurl := fmt.Sprintf("%s/search", srvConfig.Config.Services.DiscoveryURL)
-	resp, err := _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		exit("unable to fetch data from search-data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from search-data service", err)
-	}
+	return metaRecordsPage(user, query, skeys, sorder, 0, -1)
+}
 
-	var response services.ServiceResponse
-	err = json.Unmarshal(data, &response)
-	if err != nil {
-		exit("Unable to unmarshal the data", err)
+// helper function to get page of meta-data records from DataDiscovery service
+func metaRecordsPage(user, query string, skeys []string, sorder, idx, limit int) ([]map[string]any, error) {
+	if _offline {
+		records, _, err := mirrorGetMeta(query, skeys, sorder, idx, limit)
+		return records, err
 	}
-	if response.HttpCode >= 400 {
-		fmt.Printf("Service %s returned error: %v\n", response.Service, response.Error)
+	opts := client.SearchOptions{Idx: idx, Limit: limit, SortKeys: skeys, SortOrder: sorder}
+	records, err := foxdenClient().Discovery().Search(context.Background(), query, opts)
+	var cerr *client.Error
+	if errors.As(err, &cerr) && cerr.Response != nil && cerr.Response.HttpCode >= 400 {
+		fmt.Printf("Service %s returned error: %v\n", cerr.Response.Service, cerr.Response.Error)
 		os.Exit(1)
 	}
-	records = response.Results.Records
+	exit("unable to fetch data from search-data service", err)
 	return records, nil
 }
 
 // helper function to provide usage of search option
 func searchUsage() {
-	fmt.Println("foxden search <spec>")
+	fmt.Println("foxden search <spec|expression>")
 	fmt.Println("       search keys are case-incensitive")
-	fmt.Println("options: --sort-key --sort-order --json --elapsed-time")
+	fmt.Println("options: --sort-key --sort-order --idx --limit --all --page-size --explain --offline --json --elapsed-time")
+	fmt.Println("         --output=<table|wide|json|ndjson|yaml|csv|template> --fields=<keys> --template=<template>")
 	fmt.Println("\nExamples:")
 	fmt.Println("\n# list all known search keys:")
 	fmt.Println("foxden search keys")
@@ -73,44 +63,88 @@ func searchUsage() {
 	fmt.Println("foxden search pi:name --json")
 	fmt.Println("\n# same as above but provide sorting order:")
 	fmt.Println("foxden search pi:name --sort-keys=date --sort-order=1")
+	fmt.Println("\n# search using query expression, supported operators: =, !=, >, >=, <, <=, ~ (regex), in, not in")
+	fmt.Println("# expressions can be combined with and, or, not and parentheses, keys are validated against search keys")
+	fmt.Println("# dates can be given as YYYY-MM-DD, quote values to keep them as strings, e.g. btr=\"123\"")
+	fmt.Println("foxden search 'beamline=3a and cycle in (2024-1,2024-2) and date >= 2024-02-01 and sample_name ~ \"^Ti\"'")
+	fmt.Println("\n# show JSON spec generated for given query expression without running it")
+	fmt.Println("foxden search 'beamline=3a or beamline=1b' --explain")
+	fmt.Println("\n# fetch all matching records page by page, records are shown as pages arrive:")
+	fmt.Println("foxden search pi:name --all --page-size=500")
+	fmt.Println("\n# show search results as a table with selected fields, other formats: wide, json, ndjson, yaml, csv, template")
+	fmt.Println("foxden search pi:name --output=table --fields=did,beamline,btr,cycle")
+	fmt.Println("\n# search records in local mirror, see 'foxden mirror' how to pull records")
+	fmt.Println("foxden search 'beamline=3a and cycle=2024-1' --offline")
 }
 
 // helper function to get all known search (QL) keys across all FOXDEN services
 func getSearchKeys() []string {
-	urls := []string{
-		srvConfig.Config.Services.DataBookkeepingURL,
-		srvConfig.Config.Services.DataManagementURL,
-		srvConfig.Config.Services.MetaDataURL,
-		srvConfig.Config.Services.SpecScansURL,
-	}
-	var skeys []string
-	for _, url := range urls {
-		rurl := fmt.Sprintf("%s/qlkeys", url)
-		resp, err := _httpReadRequest.Get(rurl)
-		if err != nil {
-			exit(fmt.Sprintf("unable to reach %s", rurl), err)
-		}
-		defer resp.Body.Close()
-		data, err := io.ReadAll(resp.Body)
+	skeys, err := searchKeys()
+	exit("unable to get search keys", err)
+	return skeys
+}
+
+// helper function to fetch search (QL) keys from all FOXDEN services
+func searchKeys() ([]string, error) {
+	return foxdenClient().SearchKeys(context.Background())
+}
+
+// helper function to convert search spec into query spec matched on client side,
+// keys of query expression are validated against keys provided by given function
+func localSpec(spec string, keys func() ([]string, error)) (map[string]any, error) {
+	query := map[string]any{}
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "{}" {
+		return query, nil
+	}
+	if strings.HasPrefix(spec, "did:") {
+		query["did"] = strings.TrimPrefix(spec, "did:")
+		return query, nil
+	}
+	jsonSpec := spec
+	if isQueryExpression(spec) {
+		qkeys, err := keys()
 		if err != nil {
-			exit("unable to read HTTP response", err)
+			return nil, err
 		}
-		var records []string
-		err = json.Unmarshal(data, &records)
+		jsonSpec, err = compileQuery(spec, qkeys)
 		if err != nil {
-			exit("unable to unmarshal HTTP response", err)
+			return nil, err
 		}
-		for _, k := range records {
-			skeys = append(skeys, k)
+	} else if !strings.HasPrefix(spec, "{") {
+		return match.Pairs(spec)
+	}
+	if err := json.Unmarshal([]byte(jsonSpec), &query); err != nil {
+		return nil, fmt.Errorf("unable to parse query spec %s: %w", jsonSpec, err)
+	}
+	return query, nil
+}
+
+// helper function to convert search spec into JSON spec, spec can be either
+// JSON, key:value pairs or query expression, e.g. beamline=3a and cycle in (2024-1,2024-2)
+func searchSpec(spec string) string {
+	if isQueryExpression(spec) {
+		query, err := compileQuery(spec, getSearchKeys())
+		exit("invalid query expression", err)
+		return query
+	}
+	return utils.NormalizeSpec(spec)
+}
+
+// helper function to print JSON spec of given search spec
+func searchExplain(spec string) {
+	query := searchSpec(spec)
+	var rec any
+	if err := json.Unmarshal([]byte(query), &rec); err == nil {
+		if data, err := json.MarshalIndent(rec, "", "  "); err == nil {
+			query = string(data)
 		}
 	}
-	skeys = utils.List2Set(skeys)
-	sort.Strings(skeys)
-	return skeys
+	fmt.Println(query)
 }
 
 // helper function to list search-data records
-func searchListRecord(user, spec string, skeys []string, sorder int, jsonOutput, elapsedTime bool) {
+func searchListRecord(user, spec string, skeys []string, sorder, idx, limit, pageSize int, all bool, opts OutputOptions, elapsedTime bool) {
 	defer TrackTime(elapsedTime)()
 	if spec == "keys" {
 		skeys := getSearchKeys()
@@ -120,23 +154,21 @@ func searchListRecord(user, spec string, skeys []string, sorder int, jsonOutput,
 		}
 		return
 	}
-	spec = utils.NormalizeSpec(spec)
-	records, err := metaRecords(user, spec, skeys, sorder)
-	if err != nil {
-		fmt.Println("ERROR", err)
-		os.Exit(1)
+	if !_offline {
+		// local mirror compiles query expressions against keys of mirrored records
+		spec = searchSpec(spec)
 	}
-	if jsonOutput {
-		if data, err := json.MarshalIndent(records, "", " "); err == nil {
-			fmt.Println(string(data))
-		} else {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
-		}
-		return
+	if !all && limit <= 0 {
+		// fetch all records in single request
+		limit = -1
 	}
-	for _, r := range records {
-		fmt.Println("---")
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		// DataDiscovery service does not provide total number of records
+		records, err := metaRecordsPage(user, spec, skeys, sorder, idx, limit)
+		return records, -1, err
+	}
+	pager := NewPager(fetch, idx, limit, pageSize, all)
+	listPages(pager, opts, func(r map[string]any) {
 		val := r["did"]
 		var did string
 		switch vvv := val.(type) {
@@ -159,10 +191,12 @@ func searchListRecord(user, spec string, skeys []string, sorder int, jsonOutput,
 			tstamp = time.Unix(secondsSinceEpoch, 0).Format(time.RFC3339)
 		}
 		fmt.Printf("date       : %v\n", tstamp)
-		//         fmt.Println(fmt.Sprintf("%f", val), int64(val.(float64)), did)
+	})
+	if !opts.Default() {
+		return
 	}
 	fmt.Println("---")
-	fmt.Println("Total   :", len(records), "records")
+	fmt.Println("Total   :", pager.Count(), "records")
 }
 
 // helper function to print search data records in Json format
@@ -192,26 +226,24 @@ func searchCommand() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			jsonOutput, _ := cmd.Flags().GetBool("json")
 			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
-			sortKeys, _ := cmd.Flags().GetString("sort-keys")
-			sortOrder, _ := cmd.Flags().GetInt("sort-order")
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			pageSize, _ := cmd.Flags().GetInt("page-size")
+			all, _ := cmd.Flags().GetBool("all")
+			explain, _ := cmd.Flags().GetBool("explain")
+			opts := outputOptions(cmd, "did", "schema", "cycle", "beamline", "btr", "sample_name", "date")
 			if jsonOutput {
 				// set _jsonOutputError to properly handle error output in JSON format
 				_jsonOutputError = true
 			}
-			var skeys []string
-			if sortKeys != "" {
-				for _, k := range strings.Split(sortKeys, ",") {
-					skeys = append(skeys, k)
-				}
-			}
-			if sortOrder == 0 {
-				sortOrder = -1
-			}
-			user, _ := getUserToken()
+			skeys, sortOrder := sortOptions(cmd)
+			user := onlineUser()
 			if len(args) == 0 {
 				searchUsage()
+			} else if explain {
+				searchExplain(args[0])
 			} else {
-				searchListRecord(user, args[0], skeys, sortOrder, jsonOutput, elapsedTime)
+				searchListRecord(user, args[0], skeys, sortOrder, idx, limit, pageSize, all, opts, elapsedTime)
 			}
 		},
 	}
@@ -219,6 +251,15 @@ func searchCommand() *cobra.Command {
 	cmd.PersistentFlags().Bool("elapsed-time", false, "print out elapsed time")
 	cmd.PersistentFlags().String("sort-keys", "date", "sort key(s), if multiple keys separate them by comma (default: date)")
 	cmd.PersistentFlags().Int("sort-order", -1, "sort order: 1 ascending, -1 desecnding (default)")
+	cmd.PersistentFlags().Int("idx", 0, "start index, default 0")
+	cmd.PersistentFlags().Int("limit", 0, "limit number of records to given value, default 0 (all records)")
+	cmd.PersistentFlags().Int("page-size", 0, "number of records to fetch per request when --all option is used (default 100)")
+	cmd.PersistentFlags().Bool("all", false, "list all records fetching them page by page")
+	cmd.PersistentFlags().BoolVar(&_offline, "offline", false, "search records in local mirror, see 'foxden mirror'")
+	cmd.PersistentFlags().Bool("explain", false, "print JSON spec generated for given query without running it")
+	cmd.ValidArgsFunction = completeQuery
+	cmd.RegisterFlagCompletionFunc("sort-keys", completeSearchKeys)
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		searchUsage()
 		return nil
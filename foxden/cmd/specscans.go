@@ -5,9 +5,8 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,6 +16,7 @@ import (
 
 	srvConfig "github.com/CHESSComputing/golib/config"
 	services "github.com/CHESSComputing/golib/services"
+	client "github.com/CHESSComputing/gotools/foxden/client"
 	"github.com/spf13/cobra"
 )
 
@@ -59,51 +59,8 @@ func specdata(did string) SpecScansRecord {
 
 // helper function to get SpecScans data records
 func getSpecScans(user, query string, idx, limit int) ([]map[string]any, error) {
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpReadRequest.Token = token
-			defer func() {
-				_httpReadRequest.Token = ""
-			}()
-		}
-	}
-
-	var records []map[string]any
-	if query == "" {
-		query = "{}"
-	}
-	rec := services.ServiceRequest{
-		Client:       "foxden",
-		ServiceQuery: services.ServiceQuery{Query: query, Idx: idx, Limit: limit},
-	}
-
-	data, err := json.Marshal(rec)
-	rurl := fmt.Sprintf("%s/search", srvConfig.Config.Services.SpecScansURL)
-	resp, err := _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		exit("unable to fetch data from SpecScans data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from SpecScans data service", err)
-	}
-	var response services.ServiceResponse
-	err = json.Unmarshal(data, &response)
-
-	//     var response services.ServiceResponse
-	//     err = json.Unmarshal(data, &response)
-	if err != nil {
-		log.Println("response data", string(data))
-		exit("Unable to unmarshal the data", err)
-	}
-	//     if response.HttpCode == 200 {
-	//         records = response.Results.Records
-	//     }
-	records = response.Results.Records
-	return records, nil
+	opts := client.SearchOptions{Idx: idx, Limit: limit}
+	return foxdenClient().SpecScans().Search(context.Background(), query, opts)
 }
 
 // helper function to provide usage of spec option
@@ -115,6 +72,10 @@ func specUsage() {
 	fmt.Println("foxden spec ls")
 	fmt.Println("\n# list spec data for specific range:")
 	fmt.Println("foxden spec ls --idx=10 --limit=20")
+	fmt.Println("\n# list all records fetching them page by page, records are shown as pages arrive:")
+	fmt.Println("foxden spec ls --all --page-size=500")
+	fmt.Println("\n# list SpecScans records as a table, other formats: wide, json, ndjson, yaml, csv, template")
+	fmt.Println("foxden spec ls --output=table")
 	fmt.Println("\n# list specific SpecScans data record:")
 	fmt.Println("foxden spec view <DID>")
 	fmt.Println("\n# add new SpecScans data record")
@@ -126,25 +87,13 @@ func specUsage() {
 }
 
 // helper function to add spec data record
-func specAddRecord(args []string, jsonOutput bool) {
-	if len(args) == 1 {
+func specAddRecord(fname string, jsonOutput bool) {
+	// user must provide client spec add schema file.json
+	if fname == "" {
 		fmt.Println("manual insertion is not implemented yet")
 		specUsage()
 		os.Exit(1)
 	}
-	// user must provide client spec add schema file.json
-	fname := args[1]
-
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpWriteRequest.Token = token
-			defer func() {
-				_httpWriteRequest.Token = ""
-			}()
-		}
-	}
 
 	// check if given fname is a file
 	_, err := os.Stat(fname)
@@ -166,21 +115,17 @@ func specAddRecord(args []string, jsonOutput bool) {
 	exit(fmt.Sprintf("unable to unmarshal data, file %s", fname), err)
 
 	// add new SpecScans record
-	rurl := fmt.Sprintf("%s/add", srvConfig.Config.Services.SpecScansURL)
-	resp, err := _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
+	data, err = responseBody(foxdenClient().SpecScans().Add(context.Background(), data))
 	exit("unable to fetch data from SpecScans data service", err)
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	exit("unable to read data from SpecScans data service", err)
 
 	if jsonOutput {
-		fmt.Printf(string(data))
+		fmt.Print(string(data))
 		return
 	}
 	var response services.ServiceResponse
 	err = json.Unmarshal(data, &response)
 	if err != nil {
-		log.Println("unable to Unarshal data into ServiceResponse, the response is %+s", string(data))
+		log.Printf("unable to Unarshal data into ServiceResponse, the response is %s", string(data))
 	}
 	exit("Unable to unmarshal the data", err)
 	if response.Status == "ok" || response.HttpCode == 200 {
@@ -210,36 +155,25 @@ func parseSpec(spec string) string {
 }
 
 // helper function to list SpecScans data records
-func specListRecord(user, spec string, idx, limit int, jsonOutput bool) {
+func specListRecord(user, spec string, idx, limit, pageSize int, all bool, opts OutputOptions) {
 	query := parseSpec(spec)
-	records, err := getSpecScans(user, query, idx, limit)
-	if err != nil {
-		fmt.Println("ERROR", err)
-		os.Exit(1)
-	}
-	if jsonOutput {
-		if data, err := json.MarshalIndent(records, "", " "); err == nil {
-			fmt.Println(string(data))
-		} else {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
-		}
-		return
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		// SpecScans service does not provide total number of records
+		records, err := getSpecScans(user, query, idx, limit)
+		return records, -1, err
 	}
-
-	for _, r := range records {
-		fmt.Println("---")
+	pager := NewPager(fetch, idx, limit, pageSize, all)
+	listPages(pager, opts, func(r map[string]any) {
 		fmt.Printf("did        : %v\n", r["did"])
 		fmt.Printf("schema     : %v\n", r["schema"])
 		fmt.Printf("cycle      : %v\n", r["cycle"])
 		fmt.Printf("beamline   : %v\n", r["beamline"])
 		fmt.Printf("btr        : %v\n", r["btr"])
 		fmt.Printf("sample_name: %v\n", r["sample_name"])
-		//         fmt.Printf("%+v", r)
+	})
+	if opts.Default() {
+		listFooter(pager, idx, all)
 	}
-	fmt.Println("---")
-	fmt.Printf("Showing %d-%d records, for more records use --idx/--limit options\n", idx, idx+limit)
-
 }
 
 // helper function to print spec data records in Json format
@@ -269,6 +203,21 @@ func specJsonRecord(user, spec string, idx, limit int, jsonOutput bool) {
 	}
 }
 
+// helper function to get listing options of spec command
+func specListOptions(cmd *cobra.Command) (int, int, int, bool, OutputOptions) {
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	idx, _ := cmd.Flags().GetInt("idx")
+	limit, _ := cmd.Flags().GetInt("limit")
+	pageSize, _ := cmd.Flags().GetInt("page-size")
+	all, _ := cmd.Flags().GetBool("all")
+	opts := outputOptions(cmd, "did", "schema", "cycle", "beamline", "btr", "sample_name")
+	if jsonOutput {
+		// set _jsonOutputError to properly handle error output in JSON format
+		_jsonOutputError = true
+	}
+	return idx, limit, pageSize, all, opts
+}
+
 func specCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "spec",
@@ -276,42 +225,68 @@ func specCommand() *cobra.Command {
 		Long:  "foxden SpecScans commands to access FOXDEN SpecScans service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonOutput, _ := cmd.Flags().GetBool("json")
-			idx, _ := cmd.Flags().GetInt("idx")
-			limit, _ := cmd.Flags().GetInt("limit")
-			if jsonOutput {
-				// set _jsonOutputError to properly handle error output in JSON format
-				_jsonOutputError = true
-			}
 			if len(args) == 0 {
 				specUsage()
-			} else if args[0] == "ls" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					specListRecord(user, args[1], idx, limit, jsonOutput)
-				} else {
-					specListRecord(user, "", idx, limit, jsonOutput)
-				}
-			} else if args[0] == "view" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					specListRecord(user, args[1], idx, limit, jsonOutput)
-				} else {
-					exit("not supported", errors.New("not supported options"))
-				}
-			} else if args[0] == "add" {
-				writeToken()
-				specAddRecord(args, jsonOutput)
-			} else if args[0] == "info" {
-				recordInfo("specscan.json")
 			} else {
 				fmt.Printf("WARNING: unsupported option(s) %+v", args)
 			}
 		},
 	}
+	lsCmd := &cobra.Command{
+		Use:   "ls [spec]",
+		Short: "list SpecScans records",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			idx, limit, pageSize, all, opts := specListOptions(cmd)
+			user, _ := getUserToken()
+			var spec string
+			if len(args) == 1 {
+				spec = args[0]
+			}
+			specListRecord(user, spec, idx, limit, pageSize, all, opts)
+		},
+	}
+	lsCmd.ValidArgsFunction = completeQuery
+	viewCmd := &cobra.Command{
+		Use:   "view <did>",
+		Short: "show specific SpecScans record",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			idx, limit, pageSize, all, opts := specListOptions(cmd)
+			user, _ := getUserToken()
+			specListRecord(user, args[0], idx, limit, pageSize, all, opts)
+		},
+	}
+	viewCmd.ValidArgsFunction = completeDid
+	addCmd := &cobra.Command{
+		Use:         "add <file.json>",
+		Short:       "add new SpecScans record",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			var fname string
+			if len(args) == 1 {
+				fname = args[0]
+			}
+			specAddRecord(fname, jsonOutput)
+		},
+	}
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "show example of SpecScans record",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			recordInfo("specscan.json")
+		},
+	}
+	cmd.AddCommand(lsCmd, viewCmd, addCmd, infoCmd)
 	cmd.PersistentFlags().Bool("json", false, "json output")
 	cmd.PersistentFlags().Int("idx", 0, "start index, default 0")
 	cmd.PersistentFlags().Int("limit", 100, "limit number of records to given value, default 100")
+	cmd.PersistentFlags().Int("page-size", 0, "number of records to fetch per request (default: limit)")
+	cmd.PersistentFlags().Bool("all", false, "list all records fetching them page by page")
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		specUsage()
 		return nil
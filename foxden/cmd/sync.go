@@ -5,14 +5,13 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"sync"
 
+	client "github.com/CHESSComputing/gotools/foxden/client"
 	"github.com/spf13/cobra"
 )
 
@@ -31,19 +30,7 @@ func syncUsage() {
 // the spec is a query in JSON format to fetch records
 func syncRecords(src, dst, spec string, poolSize, batchSize int, elapsedTime bool) {
 	defer TrackTime(elapsedTime)()
-	// fetch data from src uri
-	data, err := json.Marshal(spec)
-	rurl := fmt.Sprintf("%s/records", src)
-	resp, err := _httpReadRequest.Request("GET", rurl, "application/x-ndjson", bytes.NewBuffer(data))
-	if err != nil {
-		msg := fmt.Sprintf("fail /records, unable to fetch data from service %s", src)
-		exit(msg, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Error fetching records: received status %s", resp.Status)
-	}
+	sc := foxdenClient().Sync(src, dst)
 
 	// Create a worker pool
 	var wg sync.WaitGroup
@@ -55,26 +42,13 @@ func syncRecords(src, dst, spec string, poolSize, batchSize int, elapsedTime boo
 		go func() {
 			defer wg.Done()
 			for record := range recordChan {
-				injectRecord(dst, record)
+				injectRecord(sc, record)
 			}
 		}()
 	}
-	// Read records in batches and send them to the worker pool
-	decoder := json.NewDecoder(resp.Body)
+	// Read records from src uri in batches and send them to the worker pool
 	batch := make([]map[string]interface{}, 0, batchSize)
-	for {
-		var record map[string]interface{}
-		if err := decoder.Decode(&record); err == io.EOF {
-			// Handle any remaining records in the last batch
-			for _, r := range batch {
-				recordChan <- r
-			}
-			break
-		} else if err != nil {
-			log.Printf("Error decoding NDJSON data: %v", err)
-			continue
-		}
-
+	err := sc.Records(context.Background(), spec, func(record map[string]any) error {
 		batch = append(batch, record)
 		if len(batch) == batchSize {
 			for _, r := range batch {
@@ -82,40 +56,44 @@ func syncRecords(src, dst, spec string, poolSize, batchSize int, elapsedTime boo
 			}
 			batch = batch[:0] // Clear the batch
 		}
+		return nil
+	}, func(err error) {
+		log.Printf("Error decoding NDJSON data: %v", err)
+	})
+	// Handle any remaining records in the last batch
+	for _, r := range batch {
+		recordChan <- r
 	}
-
 	close(recordChan)
 	wg.Wait()
+	if err != nil {
+		exit(fmt.Sprintf("fail /records, unable to fetch data from service %s", src), err)
+	}
 
 	log.Println("Records synced successfully!")
 }
 
 // helper function to inject record into destination URI
-func injectRecord(dst string, record map[string]interface{}) {
-	data, err := json.Marshal(record)
-	if err != nil {
-		log.Printf("Error marshalling record: %v", err)
+func injectRecord(sc *client.SyncClient, record map[string]interface{}) {
+	// records are injected with write token validated by preflight, the
+	// client refreshes tokens which may expire during long sync
+	_, err := sc.Inject(context.Background(), record)
+	if code := client.StatusCode(err); code != 0 {
+		log.Printf("Failed to inject record: received status %d %s", code, http.StatusText(code))
 		return
 	}
-
-	rurl := fmt.Sprintf("%s", dst)
-	resp, err := _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		exit("error injecting record", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to inject record: received status %s", resp.Status)
-	}
 }
 
 func syncCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "sync",
-		Short: "foxden sync command",
-		Long:  "foxden sync-data command\n" + doc,
-		Args:  cobra.MinimumNArgs(0),
+		Use:         "sync",
+		Short:       "foxden sync command",
+		Long:        "foxden sync-data command\n" + doc,
+		Args:        cobra.MinimumNArgs(0),
+		Annotations: requireScopes("read", "write"),
 		Run: func(cmd *cobra.Command, args []string) {
 			spec, _ := cmd.Flags().GetString("spec")
 			src, _ := cmd.Flags().GetString("src")
@@ -123,7 +101,6 @@ func syncCommand() *cobra.Command {
 			poolSize, _ := cmd.Flags().GetInt("pool-size")
 			batchSize, _ := cmd.Flags().GetInt("batch-size")
 			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
-			writeToken()
 			if len(args) == 0 {
 				syncUsage()
 			} else if args[1] == "meta" || args[1] == "prov" {
@@ -5,12 +5,10 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -18,38 +16,62 @@ import (
 	srvConfig "github.com/CHESSComputing/golib/config"
 	services "github.com/CHESSComputing/golib/services"
 	utils "github.com/CHESSComputing/golib/utils"
+	match "github.com/CHESSComputing/gotools/foxden/match"
 	"github.com/spf13/cobra"
 )
 
 // helper function to get meta-data records
 func tmplGet(murl, user string, idx, limit int) ([]map[string]any, int, error) {
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpReadRequest.Token = token
-			defer func() {
-				_httpReadRequest.Token = ""
-			}()
-		}
+	records, err := tmplRecords(murl)
+	if err != nil {
+		return records, 0, err
 	}
-	var records []map[string]any
+	return tmplPage(records, idx, limit)
+}
 
-	rurl := fmt.Sprintf("%s/tmpl/records", murl)
+// helper function to fetch all template records, /tmpl/records API does not
+// support pagination and always returns all records
+func tmplRecords(murl string) ([]map[string]any, error) {
 	if os.Getenv("FOXDEN_VERBOSE") != "" {
-		fmt.Println("FOXDEN query:", rurl)
+		fmt.Println("FOXDEN query:", murl+"/tmpl/records")
 	}
-	resp, err := _httpReadRequest.Get(rurl)
-	if err != nil {
-		exit("fail /tmpl/records, unable to fetch data from meta-data service", err)
+	return metaClient(murl).Templates(context.Background())
+}
+
+// helper function to select template records matching given spec, the spec
+// is matched on client side since /tmpl/records API does not support queries
+func tmplMatch(records []map[string]any, spec string) ([]map[string]any, error) {
+	query, err := localSpec(spec, func() ([]string, error) {
+		var keys []string
+		for _, rec := range records {
+			for key := range rec {
+				keys = append(keys, key)
+			}
+		}
+		return utils.List2Set(keys), nil
+	})
+	if err != nil || len(query) == 0 {
+		return records, err
 	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from meta-data service", err)
+	var out []map[string]any
+	for _, rec := range records {
+		if match.Spec(rec, query) {
+			out = append(out, rec)
+		}
 	}
-	err = json.Unmarshal(data, &records)
-	return records, len(records), err
+	return out, nil
+}
+
+// helper function to apply idx/limit to template records on client side
+func tmplPage(records []map[string]any, idx, limit int) ([]map[string]any, int, error) {
+	nrecords := len(records)
+	if idx > nrecords {
+		idx = nrecords
+	}
+	if limit > 0 && idx+limit < nrecords {
+		return records[idx : idx+limit], nrecords, nil
+	}
+	return records[idx:], nrecords, nil
 }
 
 // helper function to provide usage of meta option
@@ -60,8 +82,15 @@ func tmplMetaUsage() {
 	fmt.Println("\nExamples:")
 	fmt.Println("\n# list template metadata records:")
 	fmt.Println("foxden tmpl ls")
+	fmt.Println("\n# list template records matching spec, spec can be JSON, key:value pairs or query expression:")
+	fmt.Println("foxden tmpl ls beamline:3a")
+	fmt.Println("foxden tmpl ls 'tmpl_schema=ID3A and cycle in (2024-1, 2024-2)'")
 	fmt.Println("\n# list meta data records for specific range:")
 	fmt.Println("foxden tmpl ls --idx=10 --limit=20")
+	fmt.Println("\n# list all records fetching them page by page, records are shown as pages arrive:")
+	fmt.Println("foxden tmpl ls --all --page-size=500")
+	fmt.Println("\n# list template records as a table, other formats: wide, json, ndjson, yaml, csv, template")
+	fmt.Println("foxden tmpl ls --output=table")
 	fmt.Println("\n# list specific meta-data record:")
 	fmt.Println("foxden tmpl view <DID>")
 	fmt.Println("\n# remove meta-data record:")
@@ -72,17 +101,6 @@ func tmplMetaUsage() {
 
 // helper function to add meta data record
 func tmplMetaAddRecord(user string, data []byte, jsonOutput bool, update bool) {
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpWriteRequest.Token = token
-			defer func() {
-				_httpWriteRequest.Token = ""
-			}()
-		}
-	}
-
 	var record map[string]any
 	err := json.Unmarshal(data, &record)
 	exit("unable to unmarshal data", err)
@@ -111,23 +129,16 @@ func tmplMetaAddRecord(user string, data []byte, jsonOutput bool, update bool) {
 		record["user"] = user
 	}
 
-	data, err = json.MarshalIndent(record, "", "  ")
-	exit("unable to marshal data", err)
-	rurl := fmt.Sprintf("%s/tmpl/record", srvConfig.Config.Services.MetaDataURL)
-	var resp *http.Response
+	c := foxdenClient().MetaData()
 	if update {
-		resp, err = _httpWriteRequest.Put(rurl, "application/json", bytes.NewBuffer(data))
+		data, err = responseBody(c.UpdateTemplate(context.Background(), record))
 	} else {
-		resp, err = _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
+		data, err = responseBody(c.AddTemplate(context.Background(), record))
 	}
-	msg := fmt.Sprintf("fail %s unable to fetch data from meta-data service", rurl)
-	exit(msg, err)
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	exit("unable to read data from meta-data service", err)
+	exit(fmt.Sprintf("fail %s/tmpl/record unable to fetch data from meta-data service", srvConfig.Config.Services.MetaDataURL), err)
 
 	if jsonOutput {
-		fmt.Printf(string(data))
+		fmt.Print(string(data))
 		return
 	}
 	var response services.ServiceResponse
@@ -152,17 +163,7 @@ func tmplMetaDeleteRecord(user, did string, jsonOutput bool) {
 		tmplMetaUsage()
 		os.Exit(1)
 	}
-	token, err := deleteAccessToken()
-	exit("", err)
-	rurl := fmt.Sprintf("%s/tmpl/record?did=%s&user=%s", srvConfig.Config.Services.MetaDataURL, did, user)
-	req, err := http.NewRequest("DELETE", rurl, nil)
-	exit("", err)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	exit("", err)
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := responseBody(foxdenClient().MetaData().DeleteTemplate(context.Background(), did, user))
 	exit("", err)
 	var response services.ServiceResponse
 	err = json.Unmarshal(body, &response)
@@ -183,25 +184,27 @@ func tmplMetaDeleteRecord(user, did string, jsonOutput bool) {
 }
 
 // helper funtion to list meta-data records
-func tmplMetaListRecord(user, spec string, idx, limit int, jsonOutput bool) {
+func tmplMetaListRecord(user, spec string, idx, limit, pageSize int, all bool, opts OutputOptions) {
 	rurl := srvConfig.Config.MetaDataURL
-	records, nrecords, err := tmplGet(rurl, user, idx, limit)
-	if err != nil {
-		fmt.Println("ERROR", err)
-		os.Exit(1)
-	}
-	if jsonOutput {
-		if data, err := json.MarshalIndent(records, "", " "); err == nil {
-			fmt.Println(string(data))
-		} else {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
+	// all template records are fetched and matched against spec once and pager
+	// walks through them locally
+	var records []map[string]any
+	fetched := false
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		if !fetched {
+			var err error
+			if records, err = tmplRecords(rurl); err != nil {
+				return nil, 0, err
+			}
+			if records, err = tmplMatch(records, spec); err != nil {
+				return nil, 0, err
+			}
+			fetched = true
 		}
-		return
+		return tmplPage(records, idx, limit)
 	}
-
-	for _, r := range records {
-		fmt.Println("---")
+	pager := NewPager(fetch, idx, limit, pageSize, all)
+	listPages(pager, opts, func(r map[string]any) {
 		fmt.Printf("did        : %v\n", r["did"])
 		fmt.Printf("schema     : %v\n", r["tmpl_schema"])
 		fmt.Printf("cycle      : %v\n", r["cycle"])
@@ -214,10 +217,10 @@ func tmplMetaListRecord(user, spec string, idx, limit int, jsonOutput bool) {
 			tstamp = time.Unix(secondsSinceEpoch, 0).Format(time.RFC3339)
 		}
 		fmt.Printf("date       : %v\n", tstamp)
-		//         fmt.Printf("%+v", r)
+	})
+	if opts.Default() {
+		listFooter(pager, idx, all)
 	}
-	fmt.Println("---")
-	fmt.Printf("Showing %d-%d out of %d records, for more records use --idx/--limit options\n", idx, idx+limit, nrecords)
 }
 
 // helper function to print meta data records in Json format
@@ -257,60 +260,92 @@ func tmplCommand() *cobra.Command {
 		Short: "foxden template metadata commands",
 		Long:  "foxden template metadata commands to access FOXDEN MetaData service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
-		Run: func(cmd *cobra.Command, args []string) {
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			jsonOutput, _ := cmd.Flags().GetBool("json")
-			idx, _ := cmd.Flags().GetInt("idx")
-			limit, _ := cmd.Flags().GetInt("limit")
 			if jsonOutput {
 				// set _jsonOutputError to properly handle error output in JSON format
 				_jsonOutputError = true
 			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				tmplMetaUsage()
-			} else if args[0] == "ls" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					tmplMetaListRecord(user, args[1], idx, limit, jsonOutput)
-				} else {
-					tmplMetaListRecord(user, "", idx, limit, jsonOutput)
-				}
-			} else if args[0] == "view" {
-				user, _ := getUserToken()
-				tmplMetaRecord(user, idx, limit, jsonOutput)
-			} else if args[0] == "add" {
-				token, _ := writeToken()
-				var fname string
-				if len(args) == 2 {
-					fname = args[1]
-				} else {
-					tmplMetaUsage()
-					exit("please provide <file.json>", errors.New("no input file"))
-				}
-				user := getUserFromToken(token)
-				data, err := readJsonData(fname)
-				exit("unable to read data from input file", err)
-				tmplMetaAddRecord(user, data, jsonOutput, false)
-			} else if args[0] == "info" {
-				recordInfo("tmpl_metadata.json")
-			} else if args[0] == "rm" {
-				deleteToken()
-				user, _ := getUserToken()
-				if user == "" {
-					exit("unable to get user name from token value", errors.New("unknown user"))
-				}
-				if len(args) != 2 {
-					tmplMetaUsage()
-					exit("please provide did", errors.New("no did"))
-				}
-				did := args[1]
-				tmplMetaDeleteRecord(user, did, jsonOutput)
 			} else {
 				fmt.Printf("WARNING: unsupported option(s) %+v", args)
 			}
 		},
 	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "ls [spec]",
+		Short: "list template meta-data records",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			pageSize, _ := cmd.Flags().GetInt("page-size")
+			all, _ := cmd.Flags().GetBool("all")
+			opts := outputOptions(cmd, "did", "tmpl_schema", "cycle", "beamline", "btr", "sample_name", "date")
+			user, _ := getUserToken()
+			var spec string
+			if len(args) == 1 {
+				spec = args[0]
+			}
+			tmplMetaListRecord(user, spec, idx, limit, pageSize, all, opts)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "view",
+		Short: "show template meta-data records",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			user, _ := getUserToken()
+			tmplMetaRecord(user, idx, limit, jsonOutput)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:         "add <file.json>",
+		Short:       "add template meta-data record",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("write"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			token, _ := writeToken()
+			user := getUserFromToken(token)
+			data, err := readJsonData(args[0])
+			exit("unable to read data from input file", err)
+			tmplMetaAddRecord(user, data, jsonOutput, false)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "info",
+		Short: "show example of template meta-data record",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			recordInfo("tmpl_metadata.json")
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:         "rm <did>",
+		Short:       "remove template meta-data record",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("delete"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			user, _ := getUserToken()
+			if user == "" {
+				exit("unable to get user name from token value", errors.New("unknown user"))
+			}
+			tmplMetaDeleteRecord(user, args[0], jsonOutput)
+		},
+	})
 	cmd.PersistentFlags().Int("idx", 0, "start index, default 0")
 	cmd.PersistentFlags().Int("limit", 100, "limit number of records to given value, default 100")
+	cmd.PersistentFlags().Int("page-size", 0, "number of records to fetch per request (default: limit)")
+	cmd.PersistentFlags().Bool("all", false, "list all records fetching them page by page")
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		tmplMetaUsage()
 		return nil
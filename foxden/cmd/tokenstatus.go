@@ -0,0 +1,201 @@
+package cmd
+
+// CHESComputing foxden tool: token status module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	authz "github.com/CHESSComputing/golib/authz"
+	srvConfig "github.com/CHESSComputing/golib/config"
+	utils "github.com/CHESSComputing/golib/utils"
+	client "github.com/CHESSComputing/gotools/foxden/client"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenClaims represents claims of FOXDEN token, it is decoded by jwt/v5
+// parser regardless of jwt version used by authz.Claims
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	CustomClaims authz.CustomClaims `json:"custom_claims"`
+}
+
+// token statuses reported by foxden token status command
+const (
+	TokenOK       = "ok"
+	TokenExpiring = "expiring"
+	TokenExpired  = "expired"
+	TokenMissing  = "missing"
+	TokenInvalid  = "invalid"
+)
+
+// exit codes of foxden token status command, when several tokens are checked
+// the code of the most severe status is used
+var tokenExitCodes = map[string]int{
+	TokenOK:       0,
+	TokenMissing:  2,
+	TokenExpired:  3,
+	TokenExpiring: 4,
+	TokenInvalid:  5,
+}
+
+// severity of token statuses used to choose exit code
+var tokenSeverity = map[string]int{
+	TokenOK:       0,
+	TokenExpiring: 1,
+	TokenMissing:  2,
+	TokenExpired:  3,
+	TokenInvalid:  4,
+}
+
+// TokenStatus represents status of FOXDEN token of given scope
+type TokenStatus struct {
+	Scope            string     `json:"scope"`
+	Status           string     `json:"status"`
+	Source           string     `json:"source,omitempty"`
+	User             string     `json:"user,omitempty"`
+	TokenScope       string     `json:"token_scope,omitempty"`
+	Kind             string     `json:"kind,omitempty"`
+	Issuer           string     `json:"issuer,omitempty"`
+	IssuedAt         *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	Remaining        string     `json:"remaining,omitempty"`
+	RemainingSeconds *int64     `json:"remaining_seconds,omitempty"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// helper function to read token of given source in the same way as foxden
+// commands do it, i.e. from environment (token or token file) or default
+// token file, it returns token and its source
+func statusToken(src TokenSource) (string, string, error) {
+	if env, val := envToken(src); val != "" {
+		if _, err := os.Stat(val); err != nil {
+			return val, env, nil
+		}
+		token, err := client.ReadTokenFile(val)
+		return token, fmt.Sprintf("%s=%s", env, val), err
+	}
+	tfile := tokenFile(src.Scope)
+	if _, err := os.Stat(tfile); err != nil {
+		return "", "", nil
+	}
+	token, err := client.ReadTokenFile(tfile)
+	return token, tfile, err
+}
+
+// helper function to get status of token of given source, claims of expired
+// tokens are read without validation
+func tokenStatus(src TokenSource, margin time.Duration) TokenStatus {
+	status := TokenStatus{Scope: src.Scope, Status: TokenMissing}
+	token, source, err := statusToken(src)
+	status.Source = source
+	if err != nil {
+		status.Status = TokenInvalid
+		status.Error = err.Error()
+		return status
+	}
+	if token == "" {
+		return status
+	}
+	var claims tokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		status.Status = TokenInvalid
+		status.Error = err.Error()
+		return status
+	}
+	status.User = claims.CustomClaims.User
+	status.TokenScope = claims.CustomClaims.Scope
+	status.Kind = claims.CustomClaims.Kind
+	status.Issuer = claims.Issuer
+	if claims.IssuedAt != nil {
+		status.IssuedAt = &claims.IssuedAt.Time
+	}
+	status.Status = TokenOK
+	if claims.ExpiresAt != nil {
+		exp := claims.ExpiresAt.Time
+		remaining := time.Until(exp).Round(time.Second)
+		seconds := int64(remaining.Seconds())
+		status.ExpiresAt = &exp
+		status.Remaining = remaining.String()
+		status.RemainingSeconds = &seconds
+		if remaining <= 0 {
+			status.Status = TokenExpired
+			return status
+		}
+		if remaining <= margin {
+			status.Status = TokenExpiring
+		}
+	}
+	// validate signature of not expired tokens
+	if _, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID); err != nil {
+		status.Status = TokenInvalid
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// helper function to print token status
+func printTokenStatus(status TokenStatus) {
+	fmt.Println()
+	fmt.Println("Scope        : ", status.Scope)
+	fmt.Println("Status       : ", status.Status)
+	if status.Source != "" {
+		fmt.Println("Source       : ", status.Source)
+	}
+	if status.Error != "" {
+		fmt.Println("ERROR        : ", status.Error)
+	}
+	if status.Status == TokenMissing {
+		return
+	}
+	fmt.Println("User         : ", status.User)
+	fmt.Println("Token Scope  : ", status.TokenScope)
+	fmt.Println("Issuer       : ", status.Issuer)
+	if status.IssuedAt != nil {
+		fmt.Println("IssuedAt     : ", status.IssuedAt.Format(time.RFC3339))
+	}
+	if status.ExpiresAt != nil {
+		fmt.Println("ExpiresAt    : ", status.ExpiresAt.Format(time.RFC3339))
+		fmt.Println("Remaining    : ", status.Remaining)
+	}
+}
+
+// helper function to report status of FOXDEN tokens of given scopes and exit
+// with code of the most severe status
+func tokenStatusReport(scopes []string, margin time.Duration, jsonOutput bool) {
+	if margin <= 0 {
+		margin = tokenMargin()
+	}
+	var statuses []TokenStatus
+	for _, src := range tokenSources() {
+		if len(scopes) > 0 && !utils.InList(src.Scope, scopes) {
+			continue
+		}
+		statuses = append(statuses, tokenStatus(src, margin))
+	}
+	if len(statuses) == 0 {
+		exit("no tokens to check, please use read, write or delete scopes", fmt.Errorf("scopes %v", scopes))
+	}
+	worst := TokenOK
+	for _, status := range statuses {
+		if tokenSeverity[status.Status] > tokenSeverity[worst] {
+			worst = status.Status
+		}
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		exit("unable to marshal token status", err)
+		fmt.Println(string(data))
+	} else {
+		for _, status := range statuses {
+			printTokenStatus(status)
+		}
+	}
+	if code := tokenExitCodes[worst]; code != 0 {
+		os.Exit(code)
+	}
+}
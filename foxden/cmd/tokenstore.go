@@ -0,0 +1,190 @@
+package cmd
+
+// CHESComputing foxden tool: token storage module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	client "github.com/CHESSComputing/gotools/foxden/client"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// _noPrompt disables interactive prompts, e.g. for shell completion
+var _noPrompt bool
+
+// _passphrase keeps passphrase of encrypted token files entered by the user
+var _passphrase struct {
+	sync.Mutex
+	value string
+}
+
+func init() {
+	client.Passphrase = tokenPassphrase
+}
+
+// helper function to read passphrase from the terminal
+func readPassphrase(prompt string) (string, error) {
+	if !interactive() {
+		return "", errors.New("token file is encrypted with passphrase, please set FOXDEN_TOKEN_PASSPHRASE env")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	passphrase := strings.TrimSpace(string(data))
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	return passphrase, nil
+}
+
+// helper function to provide passphrase of encrypted token files, it is read
+// from FOXDEN_TOKEN_PASSPHRASE environment or asked once per command
+func tokenPassphrase() (string, error) {
+	if val := os.Getenv("FOXDEN_TOKEN_PASSPHRASE"); val != "" {
+		return val, nil
+	}
+	_passphrase.Lock()
+	defer _passphrase.Unlock()
+	if _passphrase.value != "" {
+		return _passphrase.value, nil
+	}
+	passphrase, err := readPassphrase("Enter FOXDEN token passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	_passphrase.value = passphrase
+	return passphrase, nil
+}
+
+// helper function to read token from given token or token file, encrypted
+// token files are decrypted transparently
+func readToken(val string) string {
+	if val == "" {
+		return ""
+	}
+	if _, err := os.Stat(val); err != nil {
+		return val
+	}
+	token, err := client.ReadTokenFile(val)
+	if err != nil {
+		if !_noPrompt {
+			fmt.Fprintf(os.Stderr, "WARNING: unable to read token file %s: %v\n", val, err)
+		}
+		return ""
+	}
+	return token
+}
+
+// helper function to get encryption of new token files, it is defined by
+// FOXDEN_TOKEN_ENCRYPTION (host or passphrase) and FOXDEN_TOKEN_CIPHER
+// (aes or nacl) environment
+func tokenEncryption() (string, string) {
+	source := os.Getenv("FOXDEN_TOKEN_ENCRYPTION")
+	cipher := os.Getenv("FOXDEN_TOKEN_CIPHER")
+	if source == "none" {
+		source = ""
+	}
+	if cipher == "" {
+		cipher = "aes"
+	}
+	return source, cipher
+}
+
+// helper function to write token into given file with 0600 permissions, the
+// existing file keeps its encryption and new files follow tokenEncryption,
+// the directory of the file, e.g. TokenDir of the context, is created if necessary
+func saveToken(fname, token string) error {
+	if err := os.MkdirAll(filepath.Dir(fname), 0700); err != nil {
+		return err
+	}
+	source, cipher := tokenEncryption()
+	if data, err := os.ReadFile(fname); err == nil {
+		source, cipher = "", ""
+		if src, cph, ok := client.Encrypted(data); ok {
+			source, cipher = src, cph
+		}
+	}
+	return client.WriteTokenFile(fname, token, source, cipher)
+}
+
+// helper function to get token files for lock and unlock commands
+func lockFiles(tkn string) []string {
+	if tkn != "" {
+		return []string{tkn}
+	}
+	var files []string
+	for _, scope := range []string{"read", "write", "delete"} {
+		tfile := tokenFile(scope)
+		if _, err := os.Stat(tfile); err == nil {
+			files = append(files, tfile)
+		}
+	}
+	return files
+}
+
+// helper function to encrypt token files, tokens are read before new
+// passphrase is set since files may be encrypted with the old one
+func lockTokens(tkn string, passphrase bool, cipher string) {
+	if cipher != "aes" && cipher != "nacl" {
+		exit("unsupported cipher, please use aes or nacl", fmt.Errorf("cipher %q", cipher))
+	}
+	files := lockFiles(tkn)
+	if len(files) == 0 {
+		fmt.Println("No token files found")
+		return
+	}
+	tokens := make([]string, len(files))
+	for i, tfile := range files {
+		token, err := client.ReadTokenFile(tfile)
+		exit(fmt.Sprintf("unable to read token file %s", tfile), err)
+		tokens[i] = token
+	}
+	source := client.HostKey
+	if passphrase {
+		source = client.PassphraseKey
+		if os.Getenv("FOXDEN_TOKEN_PASSPHRASE") == "" {
+			val, err := readPassphrase("Enter new FOXDEN token passphrase: ")
+			exit("unable to read passphrase", err)
+			confirm, err := readPassphrase("Confirm FOXDEN token passphrase: ")
+			exit("unable to read passphrase", err)
+			if val != confirm {
+				exit("passphrases do not match", errors.New("passphrase mismatch"))
+			}
+			_passphrase.Lock()
+			_passphrase.value = val
+			_passphrase.Unlock()
+		}
+	}
+	for i, tfile := range files {
+		err := client.WriteTokenFile(tfile, tokens[i], source, cipher)
+		exit(fmt.Sprintf("unable to encrypt token file %s", tfile), err)
+		fmt.Printf("%s: encrypted with %s key (%s)\n", tfile, source, cipher)
+	}
+}
+
+// helper function to decrypt token files
+func unlockTokens(tkn string) {
+	files := lockFiles(tkn)
+	if len(files) == 0 {
+		fmt.Println("No token files found")
+		return
+	}
+	for _, tfile := range files {
+		token, err := client.ReadTokenFile(tfile)
+		exit(fmt.Sprintf("unable to read token file %s", tfile), err)
+		err = client.WriteTokenFile(tfile, token, "", "")
+		exit(fmt.Sprintf("unable to write token file %s", tfile), err)
+		fmt.Printf("%s: decrypted\n", tfile)
+	}
+}
@@ -0,0 +1,226 @@
+package cmd
+
+// CHESComputing foxden tool: HTTP trace module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// _trace defines if HTTP calls should be traced
+var _trace bool
+
+// maximum size of request body included into curl command
+const traceMaxBody = 4096
+
+// TraceRecord represents traced HTTP call
+type TraceRecord struct {
+	Service string
+	Method  string
+	Url     string
+	Status  int
+	Size    int
+	Latency time.Duration
+	Error   string
+}
+
+// TraceTransport implements http.RoundTripper which logs every HTTP call
+// along with equivalent curl command to stderr
+type TraceTransport struct {
+	Base http.RoundTripper
+
+	mu      sync.Mutex
+	records []TraceRecord
+}
+
+// _tracer represents trace transport of foxden commands
+var _tracer *TraceTransport
+
+// helper function to setup trace mode of shared transport
+func setupTrace(trace bool) {
+	if !trace {
+		return
+	}
+	_tracer = &TraceTransport{Base: http.DefaultTransport}
+	http.DefaultTransport = _tracer
+}
+
+// helper function to get name of FOXDEN service of given url, the longest
+// matching service url is used and url host otherwise
+func traceService(req *http.Request) string {
+	rurl := req.URL.String()
+	name, prefix := req.URL.Host, ""
+	for srv, surl := range serviceUrls() {
+		if surl != "" && strings.HasPrefix(rurl, surl) && len(surl) > len(prefix) {
+			name, prefix = srv, surl
+		}
+	}
+	return name
+}
+
+// helper function to quote shell argument
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// _tokenScopes keeps scopes of tokens obtained by cliToken
+var _tokenScopes sync.Map
+
+// helper function to get scope of given token, it is either scope the token
+// was obtained for by cliToken or scope claim of the token
+func tokenScope(token string) string {
+	if scope, ok := _tokenScopes.Load(token); ok {
+		return scope.(string)
+	}
+	var claims struct {
+		jwt.RegisteredClaims
+		CustomClaims struct {
+			Scope string `json:"scope"`
+		} `json:"custom_claims"`
+	}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err == nil {
+		return claims.CustomClaims.Scope
+	}
+	return ""
+}
+
+// helper function to get environment variable which holds given token
+func tokenEnv(token string) string {
+	if src, ok := scopeSource(tokenScope(token)); ok {
+		return contextEnv(src.Env)
+	}
+	return contextEnv("FOXDEN_TOKEN")
+}
+
+// helper function to construct curl command equivalent to given request,
+// bearer token is replaced by environment variable which holds it and
+// sensitive attributes of request body are redacted
+func curlCommand(req *http.Request, body []byte) string {
+	args := []string{"curl", "-X", req.Method, shellQuote(req.URL.String())}
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, val := range req.Header[key] {
+			if key == "Authorization" {
+				if token, ok := strings.CutPrefix(val, "Bearer "); ok && token != "" {
+					args = append(args, "-H", fmt.Sprintf(`"Authorization: Bearer $%s"`, tokenEnv(token)))
+				} else {
+					args = append(args, "-H", shellQuote("Authorization: ***"))
+				}
+				continue
+			}
+			args = append(args, "-H", shellQuote(key+": "+val))
+		}
+	}
+	switch {
+	case len(body) == 0:
+	case len(body) > traceMaxBody || !utf8.Valid(body):
+		args = append(args, "--data-binary", fmt.Sprintf("@request.body # %d bytes", len(body)))
+	default:
+		args = append(args, "--data-raw", shellQuote(string(redactBody(body))))
+	}
+	return strings.Join(args, " ")
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	rec := TraceRecord{
+		Service: traceService(req),
+		Method:  req.Method,
+		Url:     req.URL.String(),
+		Size:    len(body),
+	}
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	rec.Latency = time.Since(start)
+	status := "error"
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Status = resp.StatusCode
+		status = fmt.Sprintf("%d", resp.StatusCode)
+	}
+	t.mu.Lock()
+	t.records = append(t.records, rec)
+	fmt.Fprintf(os.Stderr, "TRACE %s %s status=%s request=%dB latency=%s\n",
+		rec.Method, rec.Url, status, rec.Size, traceDuration(rec.Latency))
+	if rec.Error != "" {
+		fmt.Fprintf(os.Stderr, "TRACE error: %s\n", rec.Error)
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", curlCommand(req, body))
+	t.mu.Unlock()
+	return resp, err
+}
+
+// helper function to round duration for trace output
+func traceDuration(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// helper function to print summary of traced calls by service
+func traceSummary() {
+	if _tracer == nil {
+		return
+	}
+	_tracer.mu.Lock()
+	defer _tracer.mu.Unlock()
+	if len(_tracer.records) == 0 {
+		return
+	}
+	type summary struct {
+		calls, errors int
+		total, max    time.Duration
+	}
+	stats := make(map[string]*summary)
+	var services []string
+	for _, rec := range _tracer.records {
+		s, ok := stats[rec.Service]
+		if !ok {
+			s = &summary{}
+			stats[rec.Service] = s
+			services = append(services, rec.Service)
+		}
+		s.calls++
+		if rec.Error != "" || rec.Status >= 400 {
+			s.errors++
+		}
+		s.total += rec.Latency
+		if rec.Latency > s.max {
+			s.max = rec.Latency
+		}
+	}
+	sort.Strings(services)
+	fmt.Fprintf(os.Stderr, "\nHTTP calls: %d\n", len(_tracer.records))
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tCALLS\tERRORS\tTOTAL\tAVG\tMAX")
+	for _, srv := range services {
+		s := stats[srv]
+		avg := s.total / time.Duration(s.calls)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", srv, s.calls, s.errors,
+			traceDuration(s.total), traceDuration(avg), traceDuration(s.max))
+	}
+	w.Flush()
+	// summary is printed only once, e.g. if command exits with an error
+	_tracer.records = nil
+}
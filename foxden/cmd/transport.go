@@ -0,0 +1,353 @@
+package cmd
+
+// CHESComputing foxden tool: HTTP transport module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// HttpClientConfig represents HttpClient section of FOXDEN configuration, e.g.
+//
+//	HttpClient:
+//	  Retries: 3
+//	  MinBackoff: 500ms
+//	  MaxBackoff: 10s
+//	  Timeout: 60s
+//	  Timeouts:
+//	    MetaData: 30s
+//	    DataManagement: 5m
+//	  SafePost: ["/search", "/count", "/foxden/ingest"]
+type HttpClientConfig struct {
+	Retries       int               `yaml:"Retries"`       // number of retries, 0 disables retries
+	MinBackoff    string            `yaml:"MinBackoff"`    // initial backoff interval
+	MaxBackoff    string            `yaml:"MaxBackoff"`    // maximum backoff interval
+	MaxRetryAfter string            `yaml:"MaxRetryAfter"` // maximum interval we accept from Retry-After header
+	Timeout       string            `yaml:"Timeout"`       // default timeout of single request
+	Timeouts      map[string]string `yaml:"Timeouts"`      // per-service timeouts, keys are service names
+	SafePost      []string          `yaml:"SafePost"`      // url paths of POST requests which are safe to retry
+}
+
+// RetryRecord represents retried HTTP call
+type RetryRecord struct {
+	Method   string
+	Url      string
+	Attempts int
+	Reason   string
+	Success  bool
+}
+
+// RetryTransport implements http.RoundTripper which retries failed idempotent
+// requests with exponential backoff and jitter honoring Retry-After header
+type RetryTransport struct {
+	Base          http.RoundTripper
+	Retries       int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration
+	Timeout       time.Duration
+	Timeouts      map[string]time.Duration // service url to timeout map
+	SafePost      []string
+
+	mu      sync.Mutex
+	retried []RetryRecord
+}
+
+// _transport represents shared HTTP transport of all foxden commands
+var _transport *RetryTransport
+
+// helper function to map FOXDEN service names to their urls
+func serviceUrls() map[string]string {
+	s := srvConfig.Config.Services
+	return map[string]string{
+		"Authz":             s.AuthzURL,
+		"DOI":               s.DOIServiceURL,
+		"DataBookkeeping":   s.DataBookkeepingURL,
+		"DataManagement":    s.DataManagementURL,
+		"Discovery":         s.DiscoveryURL,
+		"FabricCatalog":     s.FabricCatalogURL,
+		"FabricDataService": s.FabricDataServiceURL,
+		"Frontend":          s.FrontendURL,
+		"MLHub":             s.MLHubURL,
+		"MetaData":          s.MetaDataURL,
+		"SpecScans":         s.SpecScansURL,
+		"UserMetaData":      s.UserMetaDataURL,
+	}
+}
+
+// helper function to parse duration value of HttpClient configuration
+func configDuration(key, val string, def time.Duration) time.Duration {
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.Printf("WARNING: invalid HttpClient %s value '%s', use %s", key, val, def)
+		return def
+	}
+	return d
+}
+
+// helper function to read HttpClient section of given FOXDEN configuration file
+func readHttpClientConfig(fname string) HttpClientConfig {
+	cfg := HttpClientConfig{Retries: 3}
+	var rec struct {
+		HttpClient *HttpClientConfig `yaml:"HttpClient"`
+	}
+	if data, err := os.ReadFile(fname); err == nil {
+		if err := yaml.Unmarshal(data, &rec); err == nil && rec.HttpClient != nil {
+			cfg = *rec.HttpClient
+		}
+	}
+	if val := os.Getenv("FOXDEN_HTTP_RETRIES"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.Retries = n
+		}
+	}
+	return cfg
+}
+
+// helper function to setup shared HTTP transport, it replaces default transport
+// which is used by all HTTP clients without explicit transport, including clients
+// of FOXDEN HttpRequest
+func setupTransport(fname string) {
+	cfg := readHttpClientConfig(fname)
+	t := &RetryTransport{
+		Base:          http.DefaultTransport,
+		Retries:       cfg.Retries,
+		MinBackoff:    configDuration("MinBackoff", cfg.MinBackoff, 500*time.Millisecond),
+		MaxBackoff:    configDuration("MaxBackoff", cfg.MaxBackoff, 10*time.Second),
+		MaxRetryAfter: configDuration("MaxRetryAfter", cfg.MaxRetryAfter, time.Minute),
+		Timeout:       configDuration("Timeout", cfg.Timeout, 0),
+		Timeouts:      make(map[string]time.Duration),
+		SafePost:      cfg.SafePost,
+	}
+	if len(t.SafePost) == 0 {
+		// search and count requests do not change state of FOXDEN services
+		// and FabricNode ingestion of the same did can be safely repeated
+		t.SafePost = []string{"/search", "/count", "/foxden/ingest"}
+	}
+	urls := serviceUrls()
+	for srv, val := range cfg.Timeouts {
+		rurl, ok := urls[srv]
+		if !ok || rurl == "" {
+			log.Printf("WARNING: unknown service '%s' in HttpClient Timeouts", srv)
+			continue
+		}
+		t.Timeouts[rurl] = configDuration("Timeouts."+srv, val, t.Timeout)
+	}
+	if _transport != nil {
+		// keep original transport if setup is called again
+		t.Base = _transport.Base
+	}
+	_transport = t
+	http.DefaultTransport = t
+}
+
+// helper function to check if request can be retried
+func (t *RetryTransport) retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.GetBody != nil
+	case http.MethodPost:
+		if req.Body != nil && req.GetBody == nil {
+			return false
+		}
+		for _, path := range t.SafePost {
+			if strings.HasSuffix(req.URL.Path, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// helper function to get timeout of given request
+func (t *RetryTransport) timeout(req *http.Request) time.Duration {
+	rurl := req.URL.String()
+	timeout := t.Timeout
+	prefix := ""
+	for srv, val := range t.Timeouts {
+		// use longest matching service url
+		if strings.HasPrefix(rurl, srv) && len(srv) > len(prefix) {
+			prefix = srv
+			timeout = val
+		}
+	}
+	return timeout
+}
+
+// helper function to calculate backoff interval of given attempt with random jitter
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	d := t.MinBackoff << uint(attempt)
+	if d <= 0 || d > t.MaxBackoff {
+		d = t.MaxBackoff
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > t.MaxRetryAfter {
+				wait = t.MaxRetryAfter
+			}
+			if wait > d {
+				d = wait
+			}
+		}
+	}
+	return d
+}
+
+// helper function to parse Retry-After header, it can be either seconds or HTTP date
+func retryAfter(val string) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(strings.TrimSpace(val)); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if date, err := http.ParseTime(val); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// helper function to check if response status should be retried
+func retryStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// cancelBody cancels request context once response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer interface
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// helper function to perform single attempt of the request
+func (t *RetryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	timeout := t.timeout(req)
+	if timeout <= 0 {
+		return t.Base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.Base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Retries <= 0 || !t.retryable(req) {
+		return t.roundTrip(req)
+	}
+	var resp *http.Response
+	var err error
+	var reason string
+	attempt := 0
+	for {
+		resp, err = t.roundTrip(req)
+		if err == nil && !retryStatus(resp.StatusCode) {
+			break
+		}
+		if err != nil && (errors.Is(err, context.Canceled) || req.Context().Err() != nil) {
+			// request was cancelled by the caller
+			break
+		}
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		if attempt >= t.Retries {
+			break
+		}
+		wait := t.backoff(attempt, resp)
+		if resp != nil {
+			// drain and close body to reuse connection
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		attempt++
+		if verbose > 0 {
+			log.Printf("retry %s %s in %s (%d/%d): %s", req.Method, req.URL, wait.Round(time.Millisecond), attempt, t.Retries, reason)
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, berr := req.GetBody()
+			if berr != nil {
+				return nil, berr
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+	if attempt > 0 {
+		rec := RetryRecord{
+			Method:   req.Method,
+			Url:      req.URL.String(),
+			Attempts: attempt,
+			Reason:   reason,
+			Success:  err == nil && !retryStatus(resp.StatusCode),
+		}
+		t.mu.Lock()
+		t.retried = append(t.retried, rec)
+		t.mu.Unlock()
+	}
+	return resp, err
+}
+
+// helper function to print summary of retried calls in verbose mode
+func retrySummary() {
+	if verbose == 0 || _transport == nil {
+		return
+	}
+	_transport.mu.Lock()
+	defer _transport.mu.Unlock()
+	if len(_transport.retried) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "HTTP retries: %d call(s)\n", len(_transport.retried))
+	for _, rec := range _transport.retried {
+		status := "failed"
+		if rec.Success {
+			status = "ok"
+		}
+		fmt.Fprintf(os.Stderr, "%-6s %s %s retries=%d last error: %s\n", status, rec.Method, rec.Url, rec.Attempts, rec.Reason)
+	}
+}
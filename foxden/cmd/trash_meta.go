@@ -0,0 +1,311 @@
+package cmd
+
+// CHESComputing foxden tool: meta-data trash module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	dbs "github.com/CHESSComputing/DataBookkeeping/dbs"
+	srvConfig "github.com/CHESSComputing/golib/config"
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// TrashRecord represents meta-data record saved in local trash before its deletion
+type TrashRecord struct {
+	Did        string           `json:"did"`
+	User       string           `json:"user"`
+	DeletedAt  int64            `json:"deleted_at"`
+	Record     map[string]any   `json:"record"`
+	Provenance []map[string]any `json:"provenance"`
+	File       string           `json:"-"`
+}
+
+// helper function to return location of local trash directory, records
+// deleted in configuration context are kept in its own sub-directory
+func trashDir() string {
+	if dir := os.Getenv("FOXDEN_TRASH"); dir != "" {
+		return dir
+	}
+	if _currentContext != "" {
+		return filepath.Join(os.Getenv("HOME"), ".foxden.trash", _currentContext)
+	}
+	return filepath.Join(os.Getenv("HOME"), ".foxden.trash")
+}
+
+// helper function to save meta-data record and its provenance into local trash
+func trashMetaRecord(user, did string) (string, error) {
+	rurl := srvConfig.Config.MetaDataURL
+	records, _, err := getMeta(rurl, user, "did:"+did, []string{}, 0, 0, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(records) != 1 {
+		return "", fmt.Errorf("unable to find meta-data record for did=%s", did)
+	}
+	provRecords, err := fetchProvRecords(did, "provenance")
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch provenance records: %w", err)
+	}
+	now := time.Now()
+	trec := TrashRecord{
+		Did:        did,
+		User:       user,
+		DeletedAt:  now.Unix(),
+		Record:     records[0],
+		Provenance: provRecords,
+	}
+	data, err := json.MarshalIndent(trec, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir := trashDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// file name starts with nanosecond timestamp to keep deletions of the
+	// same did apart and CreateTemp guarantees that existing file is not overwritten
+	tstamp := now.Format("20060102T150405.000000000")
+	file, err := os.CreateTemp(dir, fmt.Sprintf("%s_%s_*.trash.json", tstamp, didFileName(did)))
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// helper function to read all trash records sorted by deletion time (newest first)
+func trashRecords() ([]TrashRecord, error) {
+	files, err := filepath.Glob(filepath.Join(trashDir(), "*.trash.json"))
+	if err != nil {
+		return nil, err
+	}
+	var records []TrashRecord
+	for _, fname := range files {
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return records, err
+		}
+		var trec TrashRecord
+		if err := json.Unmarshal(data, &trec); err != nil {
+			fmt.Printf("WARNING: skip malformed trash file %s: %v\n", fname, err)
+			continue
+		}
+		trec.File = fname
+		records = append(records, trec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].DeletedAt == records[j].DeletedAt {
+			// file names start with nanosecond timestamp of deletion
+			return filepath.Base(records[i].File) > filepath.Base(records[j].File)
+		}
+		return records[i].DeletedAt > records[j].DeletedAt
+	})
+	return records, nil
+}
+
+// helper function to list content of local trash
+func metaTrashList(did string, jsonOutput bool) {
+	records, err := trashRecords()
+	exit("unable to read local trash", err)
+	if jsonOutput {
+		var out []TrashRecord
+		for _, r := range records {
+			if did == "" || r.Did == did {
+				out = append(out, r)
+			}
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		exit("unable to marshal trash records", err)
+		fmt.Println(string(data))
+		return
+	}
+	nrec := 0
+	for _, r := range records {
+		if did != "" && r.Did != did {
+			continue
+		}
+		fmt.Println("---")
+		fmt.Printf("did        : %v\n", r.Did)
+		fmt.Printf("schema     : %v\n", r.Record["schema"])
+		fmt.Printf("deleted by : %v\n", r.User)
+		fmt.Printf("deleted at : %v\n", time.Unix(r.DeletedAt, 0).Format(time.RFC3339))
+		fmt.Printf("provenance : %d records\n", len(r.Provenance))
+		fmt.Printf("file       : %v\n", r.File)
+		nrec++
+	}
+	fmt.Println("---")
+	fmt.Printf("Total %d records in %s\n", nrec, trashDir())
+}
+
+// helper function to purge local trash, either all records or records of specific did
+func metaTrashPurge(did string) {
+	records, err := trashRecords()
+	exit("unable to read local trash", err)
+	nrec := 0
+	for _, r := range records {
+		if did != "" && r.Did != did {
+			continue
+		}
+		err := os.Remove(r.File)
+		exit(fmt.Sprintf("unable to remove %s", r.File), err)
+		nrec++
+	}
+	fmt.Printf("SUCCESS: %d records were purged from %s\n", nrec, trashDir())
+}
+
+// helper function to convert provenance record saved in local trash into
+// DataBookkeeping dataset record, service managed keys are dropped and input
+// and output files are converted into file records
+func provenanceDataset(prov map[string]any) map[string]any {
+	rec := make(map[string]any)
+	for key, val := range prov {
+		switch key {
+		case "_id", "dataset_id", "create_at", "modify_at", "parents", "children":
+			continue
+		case "input_files", "output_files":
+			var files []any
+			vals, _ := val.([]any)
+			for _, f := range vals {
+				if name, ok := f.(string); ok {
+					files = append(files, map[string]any{"name": name})
+				} else {
+					files = append(files, f)
+				}
+			}
+			rec[key] = files
+		default:
+			rec[key] = val
+		}
+	}
+	return rec
+}
+
+// helper function to re-register provenance records of restored meta-data
+// record in DataBookkeeping service, provenance which is still known to the
+// service is left untouched, it returns number of registered dataset records
+func restoreProvenance(did string, provRecords []map[string]any) (int, error) {
+	records, err := fetchProvRecords(did, "datasets")
+	if err != nil {
+		return 0, err
+	}
+	if len(records) > 0 {
+		return 0, nil
+	}
+	ctx := context.Background()
+	c := foxdenClient().DataBookkeeping()
+	nrec := 0
+	for _, prov := range provRecords {
+		if _, err := c.AddDataset(ctx, provenanceDataset(prov)); err != nil {
+			return nrec, err
+		}
+		nrec++
+		parents, _ := prov["parents"].([]any)
+		for _, parent := range parents {
+			rec := dbs.ParentRecord{Did: did, Parent: fmt.Sprintf("%v", parent)}
+			if _, err := c.AddParent(ctx, rec); err != nil {
+				return nrec, err
+			}
+		}
+	}
+	return nrec, nil
+}
+
+// helper function to restore meta-data record from local trash, provenance
+// records of the record are either re-registered in DataBookkeeping service
+// or saved next to trash file
+func metaRestoreRecord(user, did string, withProvenance, jsonOutput bool) {
+	records, err := trashRecords()
+	exit("unable to read local trash", err)
+	var trec *TrashRecord
+	for i := range records {
+		if records[i].Did == did {
+			// records are sorted by deletion time, i.e. we use the latest one
+			trec = &records[i]
+			break
+		}
+	}
+	if trec == nil {
+		exit(fmt.Sprintf("no record for did=%s found in %s", did, trashDir()), errors.New("not found"))
+	}
+
+	// make sure that record does not exist in MetaData service
+	rurl := srvConfig.Config.MetaDataURL
+	_, nrecords, err := getMeta(rurl, user, "did:"+did, []string{}, 0, 0, 1)
+	exit("unable to look-up meta-data record", err)
+	if nrecords > 0 {
+		exit(fmt.Sprintf("record did=%s already exists in MetaData service", did), errors.New("record exists"))
+	}
+
+	record := trec.Record
+	// drop internal MongoDB identifier of the deleted record
+	delete(record, "_id")
+	schemaName, ok := record["schema"].(string)
+	if !ok || schemaName == "" {
+		exit("schema is not present in trash record", errors.New("no schema"))
+	}
+	data, err := submitMetaRecord(services.MetaRecord{Schema: schemaName, Record: record}, false)
+	exit("unable to submit record to meta-data service", err)
+	if jsonOutput {
+		fmt.Println(string(data))
+	}
+	var response services.ServiceResponse
+	err = json.Unmarshal(data, &response)
+	exit("Unable to unmarshal the data", err)
+	if response.Status != "ok" {
+		if !jsonOutput {
+			fmt.Printf("ERROR: failed to restore record in MetaData service\n%+v", response.String())
+		}
+		os.Exit(1)
+	}
+	err = os.Remove(trec.File)
+	exit(fmt.Sprintf("unable to remove %s", trec.File), err)
+	var nprov int
+	var perr error
+	if withProvenance && len(trec.Provenance) > 0 {
+		nprov, perr = restoreProvenance(did, trec.Provenance)
+	}
+	var pfile string
+	if len(trec.Provenance) > 0 && (!withProvenance || perr != nil) {
+		// provenance records are kept for reference if they are not
+		// re-registered in DataBookkeeping service
+		pfile = strings.TrimSuffix(trec.File, ".trash.json") + ".provenance.json"
+		data, err := json.MarshalIndent(trec.Provenance, "", "  ")
+		if err == nil {
+			err = os.WriteFile(pfile, data, 0600)
+		}
+		exit(fmt.Sprintf("unable to save provenance records to %s", pfile), err)
+	}
+	if jsonOutput {
+		return
+	}
+	fmt.Printf("SUCCESS: record %s was successfully restored\n", did)
+	if perr != nil {
+		fmt.Printf("WARNING: unable to re-register provenance records in DataBookkeeping service: %v\n", perr)
+	} else if withProvenance && nprov > 0 {
+		fmt.Printf("%d provenance records were re-registered in DataBookkeeping service\n", nprov)
+	} else if withProvenance && len(trec.Provenance) > 0 {
+		fmt.Println("provenance records are still present in DataBookkeeping service")
+	}
+	if pfile != "" {
+		fmt.Printf("Provenance records of deleted record are saved in %s\n", pfile)
+		fmt.Println("please inspect them and use 'foxden prov add' to re-add them if necessary")
+	}
+}
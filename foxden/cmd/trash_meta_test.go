@@ -0,0 +1,134 @@
+package cmd
+
+// CHESComputing foxden tool: tests of meta-data trash module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	services "github.com/CHESSComputing/golib/services"
+	client "github.com/CHESSComputing/gotools/foxden/client"
+	mock "github.com/CHESSComputing/gotools/foxden/mock"
+)
+
+// helper function to start mock server and use it as FOXDEN services of
+// foxden commands, commands use local trash in temporary directory
+func mockServices(t *testing.T) *client.Client {
+	t.Helper()
+	srv, err := mock.NewServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	config, fclient := srvConfig.Config, _client
+	t.Cleanup(func() { srvConfig.Config, _client = config, fclient })
+	urls := mock.URLs(ts.URL)
+	srvConfig.Config = &srvConfig.SrvConfig{}
+	srvConfig.Config.Services.MetaDataURL = urls.MetaData
+	srvConfig.Config.Services.DataBookkeepingURL = urls.DataBookkeeping
+	_client = client.New(urls, nil)
+	t.Setenv("FOXDEN_TRASH", t.TempDir())
+	return _client
+}
+
+// helper function to add meta-data record to mock server
+func mockMetaRecord(t *testing.T, c *client.Client, did string) {
+	t.Helper()
+	rec := services.MetaRecord{Schema: "test", Record: map[string]any{"did": did, "btr": "abc"}}
+	if _, err := c.MetaData().Add(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTrashMetaRecord tests that every deletion of the same did keeps its own trash file
+func TestTrashMetaRecord(t *testing.T) {
+	c := mockServices(t)
+	did := "/beamline=3a/btr=abc"
+	mockMetaRecord(t, c, did)
+	var files []string
+	for i := 0; i < 3; i++ {
+		fname, err := trashMetaRecord("tester", did)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, fname)
+	}
+	if files[0] == files[1] || files[1] == files[2] {
+		t.Fatalf("trash files are overwritten %v", files)
+	}
+	for _, fname := range files {
+		if info, err := os.Stat(fname); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("wrong trash file %s, error %v", fname, err)
+		}
+	}
+	records, err := trashRecords()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("wrong trash records %v, error %v", records, err)
+	}
+	// the latest deletion comes first
+	if records[0].File != files[2] || records[2].File != files[0] {
+		t.Errorf("wrong order of trash records %s %s %s", records[0].File, records[1].File, records[2].File)
+	}
+	if _, err := trashMetaRecord("tester", "/beamline=none"); err == nil {
+		t.Error("unknown record was saved into trash")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(trashDir(), "*")); len(matches) != 3 {
+		t.Errorf("wrong trash files %v", matches)
+	}
+}
+
+// TestProvenanceDataset tests conversion of saved provenance into dataset record
+func TestProvenanceDataset(t *testing.T) {
+	prov := unmarshalTest(t, `{"did":"/a=1","dataset_id":3,"create_at":1,"modify_at":2,"_id":"x",
+"site":"chess","input_files":["f1",{"name":"f2"}],"output_files":["f3"],"parents":["/p=1"],"children":["/c=1"]}`)
+	expect := unmarshalTest(t, `{"did":"/a=1","site":"chess",
+"input_files":[{"name":"f1"},{"name":"f2"}],"output_files":[{"name":"f3"}]}`)
+	if rec := provenanceDataset(prov); !reflect.DeepEqual(rec, expect) {
+		t.Errorf("got %v, expected %v", rec, expect)
+	}
+}
+
+// TestRestoreProvenance tests that provenance is re-registered only if it is missing
+func TestRestoreProvenance(t *testing.T) {
+	c := mockServices(t)
+	ctx := context.Background()
+	did := "/beamline=3a/btr=abc"
+	dbs := c.DataBookkeeping()
+	if _, err := dbs.AddDataset(ctx, map[string]any{"did": did, "site": "chess", "input_files": []map[string]any{{"name": "f1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbs.AddParent(ctx, map[string]any{"did": did, "parent_did": "/beamline=3a/btr=parent"}); err != nil {
+		t.Fatal(err)
+	}
+	provRecords, err := fetchProvRecords(did, "provenance")
+	if err != nil || len(provRecords) != 1 {
+		t.Fatalf("wrong provenance records %v, error %v", provRecords, err)
+	}
+	// provenance is still present in DataBookkeeping service
+	if nrec, err := restoreProvenance(did, provRecords); err != nil || nrec != 0 {
+		t.Errorf("existing provenance was registered %d, error %v", nrec, err)
+	}
+
+	// provenance is registered in new DataBookkeeping service
+	mockServices(t)
+	if nrec, err := restoreProvenance(did, provRecords); err != nil || nrec != 1 {
+		t.Fatalf("wrong number of registered provenance records %d, error %v", nrec, err)
+	}
+	restored, err := fetchProvRecords(did, "provenance")
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("wrong restored provenance %v, error %v", restored, err)
+	}
+	for _, key := range []string{"site", "input_files", "parents"} {
+		if !reflect.DeepEqual(restored[0][key], provRecords[0][key]) {
+			t.Errorf("wrong restored %s %v, expected %v", key, restored[0][key], provRecords[0][key])
+		}
+	}
+}
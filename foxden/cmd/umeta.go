@@ -5,11 +5,10 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -18,6 +17,7 @@ import (
 	srvConfig "github.com/CHESSComputing/golib/config"
 	services "github.com/CHESSComputing/golib/services"
 	utils "github.com/CHESSComputing/golib/utils"
+	client "github.com/CHESSComputing/gotools/foxden/client"
 	"github.com/spf13/cobra"
 )
 
@@ -60,71 +60,17 @@ func userMetadata(site string) UserMetaDataRecord {
 
 // helper function to get meta-data records
 func getUserMeta(user, query string, skeys []string, sorder, idx, limit int) ([]map[string]any, int, error) {
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpReadRequest.Token = token
-			defer func() {
-				_httpReadRequest.Token = ""
-			}()
-		}
-	}
-	var records []map[string]any
-	if query == "" {
-		query = "{}"
-	}
-	rec := services.ServiceRequest{
-		Client:       "foxden",
-		ServiceQuery: services.ServiceQuery{Query: query, Idx: idx, Limit: limit, SortKeys: skeys, SortOrder: sorder},
-	}
-
-	data, err := json.Marshal(rec)
-	rurl := fmt.Sprintf("%s/search", srvConfig.Config.Services.UserMetaDataURL)
-	if os.Getenv("FOXDEN_VERBOSE") != "" {
-		fmt.Println("FOXDEN query:", rurl)
-	}
-	resp, err := _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
+	c := foxdenClient().UserMetaData()
+	ctx := context.Background()
+	opts := client.SearchOptions{Idx: idx, Limit: limit, SortKeys: skeys, SortOrder: sorder}
+	records, err := c.Search(ctx, query, opts)
 	if err != nil {
-		exit("fail /search, unable to fetch data from meta-data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from meta-data service", err)
-	}
-	err = json.Unmarshal(data, &records)
-
-	//     var response services.ServiceResponse
-	//     err = json.Unmarshal(data, &response)
-	if err != nil {
-		log.Println("response data", string(data))
-		exit("Unable to unmarshal the data", err)
+		return nil, 0, err
 	}
 
 	// get total number of records
-	rec = services.ServiceRequest{
-		Client:       "foxden",
-		ServiceQuery: services.ServiceQuery{Query: query},
-	}
-
-	data, err = json.Marshal(rec)
-	rurl = fmt.Sprintf("%s/count", srvConfig.Config.Services.UserMetaDataURL)
-	resp, err = _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		exit("fail /count, unable to fetch data from meta-data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from meta-data service", err)
-	}
-	var nrecords int
-	err = json.Unmarshal(data, &nrecords)
-	if err != nil {
-		exit("unable to unmarshal the data", err)
-	}
-	return records, nrecords, nil
+	nrecords, err := c.Count(ctx, query)
+	return records, nrecords, err
 }
 
 func didUserMetaData() (string, string, string) {
@@ -155,6 +101,10 @@ func userMetaUsage() {
 	fmt.Println("foxden umeta ls")
 	fmt.Println("\n# list user metadata records for specific range:")
 	fmt.Println("foxden umeta ls --idx=10 --limit=20")
+	fmt.Println("\n# list all records fetching them page by page, records are shown as pages arrive:")
+	fmt.Println("foxden umeta ls --all --page-size=500")
+	fmt.Println("\n# list user meta data records in YAML format, other formats: table, wide, json, ndjson, csv, template")
+	fmt.Println("foxden umeta ls --output=yaml")
 	fmt.Println("\n# list all user metadata records using specific sorting key(s) and order:")
 	fmt.Println("foxden umeta ls --sort-keys=date --sort-order=1")
 	fmt.Println("\n# list specific user metadata record:")
@@ -170,17 +120,6 @@ func userMetaUsage() {
 // helper function to add meta data record
 func userMetaAddRecord(user string, data []byte, jsonOutput bool, update bool, elapsedTime bool) {
 	defer TrackTime(elapsedTime)()
-	// check if we got request from trusted client
-	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
-		// get trusted token and assign it to http write request
-		if token, err := trustedUser(); err == nil {
-			_httpWriteRequest.Token = token
-			defer func() {
-				_httpWriteRequest.Token = ""
-			}()
-		}
-	}
-
 	var record map[string]any
 	err := json.Unmarshal(data, &record)
 	exit("unable to unmarshal data", err)
@@ -196,23 +135,16 @@ func userMetaAddRecord(user string, data []byte, jsonOutput bool, update bool, e
 		record["user"] = user
 	}
 
-	data, err = json.MarshalIndent(record, "", "  ")
-	exit("unable to marshal data", err)
-	rurl := fmt.Sprintf("%s/record", srvConfig.Config.Services.UserMetaDataURL)
-	var resp *http.Response
+	c := foxdenClient().UserMetaData()
 	if update {
-		resp, err = _httpWriteRequest.Put(rurl, "application/json", bytes.NewBuffer(data))
+		data, err = responseBody(c.Update(context.Background(), record))
 	} else {
-		resp, err = _httpWriteRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
+		data, err = responseBody(c.Add(context.Background(), record))
 	}
-	msg := fmt.Sprintf("fail %s unable to fetch data from meta-data service", rurl)
-	exit(msg, err)
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	exit("unable to read data from meta-data service", err)
+	exit(fmt.Sprintf("fail %s unable to fetch data from meta-data service", srvConfig.Config.Services.UserMetaDataURL), err)
 
 	if jsonOutput {
-		fmt.Printf(string(data))
+		fmt.Print(string(data))
 		return
 	}
 	var response services.ServiceResponse
@@ -238,17 +170,7 @@ func userMetaDeleteRecord(user, did string, jsonOutput bool, elapsedTime bool) {
 		userMetaUsage()
 		os.Exit(1)
 	}
-	token, err := deleteAccessToken()
-	exit("", err)
-	rurl := fmt.Sprintf("%s/record?did=%s&user=%s", srvConfig.Config.Services.UserMetaDataURL, did, user)
-	req, err := http.NewRequest("DELETE", rurl, nil)
-	exit("", err)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	exit("", err)
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := responseBody(foxdenClient().UserMetaData().Delete(context.Background(), did, user))
 	exit("", err)
 	var response services.ServiceResponse
 	err = json.Unmarshal(body, &response)
@@ -269,34 +191,23 @@ func userMetaDeleteRecord(user, did string, jsonOutput bool, elapsedTime bool) {
 }
 
 // helper funtion to list meta-data records
-func userMetaListRecord(user, spec string, skeys []string, sorder, idx, limit int, jsonOutput, elapsedTime bool) {
+func userMetaListRecord(user, spec string, skeys []string, sorder, idx, limit, pageSize int, all bool, opts OutputOptions, elapsedTime bool) {
 	defer TrackTime(elapsedTime)()
 	rurl := srvConfig.Config.UserMetaDataURL
-	records, nrecords, err := getMeta(rurl, user, spec, skeys, sorder, idx, limit)
-	if err != nil {
-		fmt.Println("ERROR", err)
-		os.Exit(1)
+	fetch := func(idx, limit int) ([]map[string]any, int, error) {
+		return getMeta(rurl, user, spec, skeys, sorder, idx, limit)
 	}
-	if jsonOutput {
-		if data, err := json.MarshalIndent(records, "", " "); err == nil {
-			fmt.Println(string(data))
-		} else {
-			fmt.Println("ERROR", err)
-			os.Exit(1)
-		}
-		return
-	}
-
-	for _, r := range records {
-		fmt.Println("---")
+	pager := NewPager(fetch, idx, limit, pageSize, all)
+	listPages(pager, opts, func(r map[string]any) {
 		if data, err := json.MarshalIndent(r, "", "   "); err == nil {
 			fmt.Printf("%s\n", string(data))
 		} else {
 			fmt.Printf("%+v\n", r)
 		}
+	})
+	if opts.Default() {
+		listFooter(pager, idx, all)
 	}
-	fmt.Println("---")
-	fmt.Printf("Showing %d-%d out of %d records, for more records use --idx/--limit options\n", idx, idx+limit, nrecords)
 }
 
 // helper function to print meta data records in Json format
@@ -341,91 +252,110 @@ func userMetaCommand() *cobra.Command {
 		Short: "foxden user MetaData commands",
 		Long:  "foxden user MetaData commands to access FOXDEN user metadata service\n" + doc,
 		Args:  cobra.MinimumNArgs(0),
-		Run: func(cmd *cobra.Command, args []string) {
-			sortKeys, _ := cmd.Flags().GetString("sort-keys")
-			sortOrder, _ := cmd.Flags().GetInt("sort-order")
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			jsonOutput, _ := cmd.Flags().GetBool("json")
-			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
-			idx, _ := cmd.Flags().GetInt("idx")
-			limit, _ := cmd.Flags().GetInt("limit")
 			if jsonOutput {
 				// set _jsonOutputError to properly handle error output in JSON format
 				_jsonOutputError = true
 			}
-			var skeys []string
-			if sortKeys != "" {
-				for _, k := range strings.Split(sortKeys, ",") {
-					skeys = append(skeys, k)
-				}
-			}
-			if sortOrder == 0 {
-				sortOrder = -1
-			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				userMetaUsage()
-			} else if args[0] == "ls" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					userMetaListRecord(user, args[1], skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				} else {
-					userMetaListRecord(user, "", skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				}
-			} else if args[0] == "view" {
-				user, _ := getUserToken()
-				if len(args) == 2 {
-					userMetaJsonRecord(user, args[1], skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				} else {
-					userMetaJsonRecord(user, "", skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
-				}
-			} else if args[0] == "add" {
-				token, _ := writeToken()
-				var fname string
-				if len(args) == 2 {
-					fname = args[1]
-				} else {
-					userMetaUsage()
-					exit("please provide <file.json>", errors.New("no input file"))
-				}
-				user := getUserFromToken(token)
-				data, err := readJsonData(fname)
-				exit("unable to read data from input file", err)
-				userMetaAddRecord(user, data, jsonOutput, false, elapsedTime)
-			} else if args[0] == "amend" {
+			} else {
+				fmt.Printf("WARNING: unsupported option(s) %+v", args)
+			}
+		},
+	}
+	lsCmd := &cobra.Command{
+		Use:   "ls [spec]",
+		Short: "list user meta-data records",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			pageSize, _ := cmd.Flags().GetInt("page-size")
+			all, _ := cmd.Flags().GetBool("all")
+			opts := outputOptions(cmd, "did")
+			skeys, sortOrder := sortOptions(cmd)
+			user, _ := getUserToken()
+			var spec string
+			if len(args) == 1 {
+				spec = args[0]
+			}
+			userMetaListRecord(user, spec, skeys, sortOrder, idx, limit, pageSize, all, opts, elapsedTime)
+		},
+	}
+	lsCmd.ValidArgsFunction = completeQuery
+	viewCmd := &cobra.Command{
+		Use:   "view [did]",
+		Short: "show user meta-data records",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			idx, _ := cmd.Flags().GetInt("idx")
+			limit, _ := cmd.Flags().GetInt("limit")
+			skeys, sortOrder := sortOptions(cmd)
+			user, _ := getUserToken()
+			var did string
+			if len(args) == 1 {
+				did = args[0]
+			}
+			userMetaJsonRecord(user, did, skeys, sortOrder, idx, limit, jsonOutput, elapsedTime)
+		},
+	}
+	viewCmd.ValidArgsFunction = completeDid
+	for _, action := range []string{"add", "amend"} {
+		update := action == "amend"
+		short := "add user meta-data record"
+		if update {
+			short = "amend user meta-data record"
+		}
+		cmd.AddCommand(&cobra.Command{
+			Use:         action + " <file.json>",
+			Short:       short,
+			Args:        cobra.ExactArgs(1),
+			Annotations: requireScopes("write"),
+			Run: func(cmd *cobra.Command, args []string) {
+				jsonOutput, _ := cmd.Flags().GetBool("json")
+				elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
 				token, _ := writeToken()
-				var fname string
-				if len(args) == 2 {
-					fname = args[1]
-				} else {
-					userMetaUsage()
-					exit("please provide <file.json>", errors.New("no input file"))
-				}
 				user := getUserFromToken(token)
-				data, err := readJsonData(fname)
+				data, err := readJsonData(args[0])
 				exit("unable to read data from input file", err)
-				userMetaAddRecord(user, data, jsonOutput, true, elapsedTime)
-			} else if args[0] == "rm" {
-				deleteToken()
-				user, _ := getUserToken()
-				if user == "" {
-					exit("unable to get user name from token value", errors.New("unknown user"))
-				}
-				if len(args) != 2 {
-					userMetaUsage()
-					exit("please provide did", errors.New("no did"))
-				}
-				did := args[1]
-				metaDeleteRecord(user, did, jsonOutput, elapsedTime)
-			} else {
-				fmt.Printf("WARNING: unsupported option(s) %+v", args)
+				userMetaAddRecord(user, data, jsonOutput, update, elapsedTime)
+			},
+		})
+	}
+	rmCmd := &cobra.Command{
+		Use:         "rm <did>",
+		Short:       "remove user meta-data record",
+		Args:        cobra.ExactArgs(1),
+		Annotations: requireScopes("delete"),
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			elapsedTime, _ := cmd.Flags().GetBool("elapsed-time")
+			user, _ := getUserToken()
+			if user == "" {
+				exit("unable to get user name from token value", errors.New("unknown user"))
 			}
+			metaDeleteRecord(user, args[0], jsonOutput, elapsedTime)
 		},
 	}
+	rmCmd.ValidArgsFunction = completeDid
+	cmd.AddCommand(lsCmd, viewCmd, rmCmd)
 	cmd.PersistentFlags().Bool("json", false, "json output")
 	cmd.PersistentFlags().Bool("elapsed-time", false, "print out elapsed time")
 	cmd.PersistentFlags().String("sort-keys", "date", "sort key(s), if multiple keys separate them by comma (default: date)")
 	cmd.PersistentFlags().Int("sort-order", -1, "sort order: 1 ascending, -1 desecnding (default)")
 	cmd.PersistentFlags().Int("idx", 0, "start index, default 0")
 	cmd.PersistentFlags().Int("limit", 100, "limit number of records to given value, default 100")
+	cmd.PersistentFlags().Int("page-size", 0, "number of records to fetch per request (default: limit)")
+	cmd.PersistentFlags().Bool("all", false, "list all records fetching them page by page")
+	cmd.RegisterFlagCompletionFunc("sort-keys", completeSearchKeys)
+	addOutputFlags(cmd)
 	cmd.SetUsageFunc(func(*cobra.Command) error {
 		userMetaUsage()
 		return nil
@@ -5,7 +5,7 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
-	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
 	"errors"
@@ -25,6 +25,7 @@ import (
 	srvConfig "github.com/CHESSComputing/golib/config"
 	services "github.com/CHESSComputing/golib/services"
 	"github.com/CHESSComputing/golib/utils"
+	"github.com/spf13/cobra"
 )
 
 //go:embed static
@@ -40,7 +41,15 @@ var _jsonOutputError bool
 
 // helper function to exit with message and error
 func exit(msg string, err error) {
+	exitCode(1, msg, err)
+}
+
+// helper function to exit with given code if error occured, e.g. meta diff
+// follows diff(1) convention and exits with code 2 on errors
+func exitCode(code int, msg string, err error) {
 	if err != nil {
+		retrySummary()
+		traceSummary()
 		verbose := strings.ToLower(fmt.Sprintf("%v", os.Getenv("FOXDEN_VERBOSE")))
 		if verbose == "1" || verbose == "true" {
 			log.Println(utils.Stack())
@@ -54,10 +63,11 @@ func exit(msg string, err error) {
 			if data, err := json.Marshal(resp); err == nil {
 				fmt.Println(string(data))
 			}
-			os.Exit(1)
+			os.Exit(code)
 		}
 		reason := fmt.Sprintf("\n\nReason: %s", msg)
-		log.Fatal("ERROR: ", err, reason)
+		log.Print("ERROR: ", err, reason)
+		os.Exit(code)
 	}
 }
 
@@ -80,22 +90,12 @@ func accessToken() (string, error) {
 	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
 		return "", nil
 	}
-	tfile := fmt.Sprintf("%s/.foxden.read.token", os.Getenv("HOME"))
-	var token string
-	if _httpReadRequest.Token == "" {
-		if os.Getenv("FOXDEN_TOKEN") != "" {
-			token = utils.ReadToken(os.Getenv("FOXDEN_TOKEN"))
-		} else {
-			err := generateToken(tfile, "", 0)
-			exit("Unable to generate access token", err)
-			token = utils.ReadToken(tfile)
-		}
-		if token == "" {
-			exit("Please obtain read access token and put it into FOXDEN_TOKEN env or file", nil)
-		}
-		_httpReadRequest.Token = token
+	token, err := refreshToken(tokenSources()[0])
+	exit("Unable to generate access token", err)
+	if token == "" {
+		exit(fmt.Sprintf("Please obtain read access token and put it into %s env or file", contextEnv("FOXDEN_TOKEN")), errors.New("no token found"))
 	}
-	return _httpReadRequest.Token, nil
+	return token, nil
 }
 
 // helper function to obtain write access token
@@ -103,42 +103,22 @@ func writeAccessToken() (string, error) {
 	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
 		return "", nil
 	}
-	tfile := fmt.Sprintf("%s/.foxden.write.token", os.Getenv("HOME"))
-	var token string
-	if _httpWriteRequest.Token == "" {
-		if os.Getenv("FOXDEN_WRITE_TOKEN") != "" {
-			token = utils.ReadToken(os.Getenv("FOXDEN_WRITE_TOKEN"))
-		} else {
-			err := generateToken(tfile, "", 0)
-			exit("Unable to generate write token", err)
-			token = utils.ReadToken(tfile)
-		}
-		if token == "" {
-			exit("Please obtain read access token and put it into FOXDEN_WRITE_TOKEN env or file", nil)
-		}
-		_httpWriteRequest.Token = token
+	token, err := refreshToken(tokenSources()[1])
+	exit("Unable to generate write token", err)
+	if token == "" {
+		exit(fmt.Sprintf("Please obtain write access token and put it into %s env or file", contextEnv("FOXDEN_WRITE_TOKEN")), errors.New("no token found"))
 	}
-	return _httpWriteRequest.Token, nil
+	return token, nil
 }
 
 // helper function to obtain delete access token
 func deleteAccessToken() (string, error) {
-	tfile := fmt.Sprintf("%s/.foxden.delete.token", os.Getenv("HOME"))
-	var token string
-	if _httpDeleteRequest.Token == "" {
-		if os.Getenv("FOXDEN_DELETE_TOKEN") != "" {
-			token = utils.ReadToken(os.Getenv("FOXDEN_DELETE_TOKEN"))
-		} else {
-			err := generateToken(tfile, "", 0)
-			exit("Unable to generate write token", err)
-			token = utils.ReadToken(tfile)
-		}
-		if token == "" {
-			exit("Please obtain read access token and put it into FOXDEN_DELETE_TOKEN env or file", nil)
-		}
-		_httpDeleteRequest.Token = token
+	token, err := refreshToken(tokenSources()[2])
+	exit("Unable to generate delete token", err)
+	if token == "" {
+		exit(fmt.Sprintf("Please obtain delete access token and put it into %s env or file", contextEnv("FOXDEN_DELETE_TOKEN")), errors.New("no token found"))
 	}
-	return _httpDeleteRequest.Token, nil
+	return token, nil
 }
 
 // helper function to get user and token
@@ -202,38 +182,37 @@ func GetSystemInfo() (string, []string, []string, error) {
 
 // helper function to get trusted user name
 func getTrustedUser() string {
+	trustedUser, err := trustedClientUser()
+	if err != nil {
+		exit("unable to check trusted user info in FOXDEN", err)
+	}
+	if trustedUser == "" {
+		exit("No trusted user info found in FOXDEN", errors.New("auth failure"))
+	}
+	return trustedUser
+}
+
+// helper function to find trusted user of this client, it returns empty
+// user name if client is not trusted
+func trustedClientUser() (string, error) {
 	var trustedUser string
 	user, ips, macs, err := GetSystemInfo()
 	if err != nil {
-		exit("unable to obtain system info for trusted user", err)
+		return "", fmt.Errorf("unable to obtain system info for trusted user: %w", err)
 	}
 
 	// to check system info we can either use TrustedUsers of server configuration
 	// or, rely on FOXDEN Authz /trusted_client end-point
 	if len(srvConfig.Config.TrustedUsers) == 0 {
-		// use Authx /trusted_client end-point
-		rec := make(map[string]any)
-		rec["user"] = user
-		rec["ips"] = ips
-		rec["macs"] = macs
-		data, err := json.Marshal(rec)
-		if err != nil {
-			exit("Unable to marshal system infor record", err)
-		}
-		rurl := fmt.Sprintf("%s/trusted_client", srvConfig.Config.AuthzURL)
-		resp, err := _httpReadRequest.Post(rurl, "application/json", bytes.NewBuffer(data))
-		if err != nil {
-			exit(fmt.Sprintf("fail to check trusted user info in FOXDEN Authz server, data=%v", string(data)), err)
-		}
-		defer resp.Body.Close()
-		data, err = io.ReadAll(resp.Body)
+		// use Authz /trusted_client end-point
+		data, err := responseBody(foxdenClient().Authz().TrustedClient(context.Background(), user, ips, macs))
 		if err != nil {
-			exit(fmt.Sprintf("Unable to read response body %v", data), err)
+			return "", fmt.Errorf("fail to check trusted user info in FOXDEN Authz server: %w", err)
 		}
 		var response services.ServiceResponse
 		err = json.Unmarshal(data, &response)
 		if err != nil {
-			exit("Unable to unmarshal response body", err)
+			return "", fmt.Errorf("unable to unmarshal response body: %w", err)
 		}
 		if response.SrvCode == 0 && response.Status == "ok" {
 			trustedUser = user
@@ -251,10 +230,7 @@ func getTrustedUser() string {
 			}
 		}
 	}
-	if trustedUser == "" {
-		exit("No trusted user info found in FOXDEN", errors.New("auth failure"))
-	}
-	return trustedUser
+	return trustedUser, nil
 }
 
 // helper function to get user from the token
@@ -270,40 +246,23 @@ func getUserFromToken(token string) string {
 	return user
 }
 
-// helper function to obtain write access token
+// helper function to obtain write access token, the token should have write scope
 func writeToken() (string, error) {
 	if os.Getenv("FOXDEN_TRUSTED_CLIENT") != "" {
 		return "", nil
 	}
-	token, err := writeAccessToken()
-	if _httpWriteRequest.Token == "" {
-		token = utils.ReadToken(os.Getenv("FOXDEN_WRITE_TOKEN"))
-		if token == "" {
-			exit("Please obtain write access token and put it into FOXDEN_WRITE_TOKEN env or file", nil)
-		}
-		_, err = authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
-		if err != nil {
-			exit("unable to use write token claims\nPlease check FOXDEN_WRITE_TOKEN env and set it up with token from 'foxden token create write' command", err)
-		}
-		_httpWriteRequest.Token = token
-	}
-	return _httpWriteRequest.Token, nil
+	token, _ := writeAccessToken()
+	err := checkTokenScope(token, "write")
+	exit(fmt.Sprintf("unable to use write token\nPlease check %s env and set it up with token from 'foxden token create write' command", contextEnv("FOXDEN_WRITE_TOKEN")), err)
+	return token, nil
 }
 
-// helper function to obtain delete access token
+// helper function to obtain delete access token, the token should have delete scope
 func deleteToken() (string, error) {
-	if _httpDeleteRequest.Token == "" {
-		token := utils.ReadToken(os.Getenv("FOXDEN_DELETE_TOKEN"))
-		if token == "" {
-			exit("Please obtain delete access token and put it into FOXDEN_DELETE_TOKEN env or file", nil)
-		}
-		_, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
-		if err != nil {
-			exit("unable to use delete token claims\nPlease check FOXDEN_DELETE_TOKEN env and set it up with token from 'foxden token create delete' command", err)
-		}
-		_httpDeleteRequest.Token = token
-	}
-	return _httpDeleteRequest.Token, nil
+	token, _ := deleteAccessToken()
+	err := checkTokenScope(token, "delete")
+	exit(fmt.Sprintf("unable to use delete token\nPlease check %s env and set it up with token from 'foxden token create delete' command", contextEnv("FOXDEN_DELETE_TOKEN")), err)
+	return token, nil
 }
 
 // helper function to print map record
@@ -414,3 +373,19 @@ func readJsonData(fname string) ([]byte, error) {
 	}
 	return data, nil
 }
+
+// helper function to get sort keys and sort order from command flags
+func sortOptions(cmd *cobra.Command) ([]string, int) {
+	sortKeys, _ := cmd.Flags().GetString("sort-keys")
+	sortOrder, _ := cmd.Flags().GetInt("sort-order")
+	var skeys []string
+	if sortKeys != "" {
+		for _, k := range strings.Split(sortKeys, ",") {
+			skeys = append(skeys, k)
+		}
+	}
+	if sortOrder == 0 {
+		sortOrder = -1
+	}
+	return skeys, sortOrder
+}
@@ -12,10 +12,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// helper function to get version string of foxden tool
+func versionString() string {
+	return fmt.Sprintf("git={{VERSION}} commit={{COMMIT}} go=%s", runtime.Version())
+}
+
 func version() {
-	goVersion := runtime.Version()
 	tstamp := time.Now()
-	fmt.Printf("git={{VERSION}} commit={{COMMIT}} go=%s date=%s\n", goVersion, tstamp)
+	fmt.Printf("%s date=%s\n", versionString(), tstamp)
 }
 
 func versionCommand() *cobra.Command {
@@ -5,12 +5,13 @@ package cmd
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"net/url"
 	"os"
+	"strings"
 
-	srvConfig "github.com/CHESSComputing/golib/config"
 	"github.com/spf13/cobra"
 )
 
@@ -36,9 +37,9 @@ func viewRecord(user, did string, parents, children, jsonOutput, elapsedTime boo
 	if err2 != nil {
 		exit("unable to serialize metadata records", err2)
 	}
-	fmt.Println("\n### Metadata records\n")
+	fmt.Print("\n### Metadata records\n\n")
 	print(string(metadata))
-	fmt.Println("\n\n### Provenance records\n")
+	fmt.Print("\n\n### Provenance records\n\n")
 	print(string(provdata))
 }
 
@@ -92,24 +93,21 @@ func getRecursiveRecords(did string, api, key string, seen map[string]bool) []ma
 }
 
 func getProvRecords(did, api string) []map[string]any {
-	rurl := fmt.Sprintf("%s/%s?did=%s", srvConfig.Config.Services.DataBookkeepingURL, api, did)
-	resp, err := _httpReadRequest.Get(rurl)
+	provRecords, err := fetchProvRecords(did, api)
 	if err != nil {
 		exit(fmt.Sprintf("unable to fetch data from provenance service API %s", api), err)
 	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from provenance service", err)
-	}
-	var provRecords []map[string]any
-	err = json.Unmarshal(data, &provRecords)
-	if err != nil {
-		exit("unable to read data from provenance service", err)
-	}
 	return provRecords
 }
 
+// helper function to fetch provenance records of given did, unlike
+// getProvRecords it returns error to be used in concurrent workers
+func fetchProvRecords(did, api string) ([]map[string]any, error) {
+	params := url.Values{}
+	params.Set("did", did)
+	return foxdenClient().DataBookkeeping().Records(context.Background(), api, params)
+}
+
 func printJsonRecords(records []map[string]any, jsonOutput bool) {
 	if jsonOutput {
 		if data, err := json.MarshalIndent(records, "", " "); err == nil {
@@ -162,47 +160,14 @@ func viewMetaRecord(user, did string, jsonOutput bool) {
 
 // helper function to look-up DBS records
 func viewDBSRecord(user, did string, parents, children, jsonOutput bool) {
-	// look-up dataset records
-	rurl := fmt.Sprintf("%s/datasets?did=%s", srvConfig.Config.Services.DataBookkeepingURL, did)
-	resp, err := _httpReadRequest.Get(rurl)
-	if err != nil {
+	for _, api := range []string{"datasets", "files", "parents"} {
+		records, err := fetchProvRecords(did, api)
 		exit("unable to fetch data from search-data service", err)
+		data, err := json.Marshal(records)
+		exit("unable to marshal data", err)
+		fmt.Printf("### Provenance %s records:\n", strings.TrimSuffix(api, "s"))
+		fmt.Println(string(data))
 	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from search-data service", err)
-	}
-	fmt.Println("### Provenance dataset records:")
-	fmt.Println(string(data))
-
-	// look-up files records
-	rurl = fmt.Sprintf("%s/files?did=%s", srvConfig.Config.Services.DataBookkeepingURL, did)
-	resp, err = _httpReadRequest.Get(rurl)
-	if err != nil {
-		exit("unable to fetch data from search-data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from search-data service", err)
-	}
-	fmt.Println("### Provenance files records:")
-	fmt.Println(string(data))
-
-	// look-up parents info
-	rurl = fmt.Sprintf("%s/parents?did=%s", srvConfig.Config.Services.DataBookkeepingURL, did)
-	resp, err = _httpReadRequest.Get(rurl)
-	if err != nil {
-		exit("unable to fetch data from search-data service", err)
-	}
-	defer resp.Body.Close()
-	data, err = io.ReadAll(resp.Body)
-	if err != nil {
-		exit("unable to read data from search-data service", err)
-	}
-	fmt.Println("### Provenance parents records:")
-	fmt.Println(string(data))
 }
 
 func viewCommand() *cobra.Command {
@@ -0,0 +1,218 @@
+// Package match provides matching of FOXDEN records against MongoDB like
+// query specs, it is used by foxden local mirror and mock server
+package match
+
+// CHESComputing foxden tool: match module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Value looks-up record value by (dotted) key, keys are case-insensitive
+func Value(rec map[string]any, key string) (any, bool) {
+	if val, ok := rec[key]; ok {
+		return val, true
+	}
+	for k, val := range rec {
+		if strings.EqualFold(k, key) {
+			return val, true
+		}
+	}
+	if idx := strings.Index(key, "."); idx > 0 {
+		if val, ok := Value(rec, key[:idx]); ok {
+			if nrec, ok := val.(map[string]any); ok {
+				return Value(nrec, key[idx+1:])
+			}
+		}
+	}
+	return nil, false
+}
+
+// Compare compares two values, it returns false if values are not comparable
+func Compare(a, b any) (int, bool) {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+// helper function to convert numeric value to float64
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// helper function to check if value is equal to given one, lists match if any of their values is equal
+func equalValue(val, cond any) bool {
+	if list, ok := val.([]any); ok {
+		for _, v := range list {
+			if equalValue(v, cond) {
+				return true
+			}
+		}
+		return false
+	}
+	if c, ok := Compare(val, cond); ok {
+		return c == 0
+	}
+	return fmt.Sprintf("%v", val) == fmt.Sprintf("%v", cond)
+}
+
+// helper function to match record value against given condition
+func matchCondition(val any, exists bool, cond any) bool {
+	ops, ok := cond.(map[string]any)
+	if !ok {
+		return exists && equalValue(val, cond)
+	}
+	for op, arg := range ops {
+		switch op {
+		case "$eq":
+			if !exists || !equalValue(val, arg) {
+				return false
+			}
+		case "$ne":
+			if exists && equalValue(val, arg) {
+				return false
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			c, ok := Compare(val, arg)
+			if !exists || !ok {
+				return false
+			}
+			if (op == "$gt" && c <= 0) || (op == "$gte" && c < 0) || (op == "$lt" && c >= 0) || (op == "$lte" && c > 0) {
+				return false
+			}
+		case "$in", "$nin":
+			found := false
+			if list, ok := arg.([]any); ok && exists {
+				for _, v := range list {
+					if equalValue(val, v) {
+						found = true
+						break
+					}
+				}
+			}
+			if found != (op == "$in") {
+				return false
+			}
+		case "$regex":
+			pat := fmt.Sprintf("%v", arg)
+			if opt, ok := ops["$options"].(string); ok && strings.Contains(opt, "i") {
+				pat = "(?i)" + pat
+			}
+			if !exists || !matchRegex(val, pat) {
+				return false
+			}
+		case "$exists":
+			if flag, ok := arg.(bool); ok && flag != exists {
+				return false
+			}
+		case "$options":
+		default:
+			// nested record
+			if !exists {
+				return false
+			}
+			nrec, ok := val.(map[string]any)
+			if !ok || !Spec(nrec, map[string]any{op: arg}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// helper function to match value or list of values against regular expression
+func matchRegex(val any, pat string) bool {
+	if list, ok := val.([]any); ok {
+		for _, v := range list {
+			if matchRegex(v, pat) {
+				return true
+			}
+		}
+		return false
+	}
+	matched, err := regexp.MatchString(pat, fmt.Sprintf("%v", val))
+	return err == nil && matched
+}
+
+// Spec checks if record matches MongoDB like query spec
+func Spec(rec map[string]any, spec map[string]any) bool {
+	for key, cond := range spec {
+		switch key {
+		case "$and", "$or", "$nor":
+			list, _ := cond.([]any)
+			nmatch := 0
+			for _, item := range list {
+				if sub, ok := item.(map[string]any); ok && Spec(rec, sub) {
+					nmatch++
+				}
+			}
+			if (key == "$and" && nmatch != len(list)) || (key == "$or" && nmatch == 0) || (key == "$nor" && nmatch > 0) {
+				return false
+			}
+		default:
+			val, exists := Value(rec, key)
+			if !matchCondition(val, exists, cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Pairs converts space separated key:value pairs, e.g. beamline:3a cycle:2024-1,
+// into query spec, keys are lower-cased and values are kept as strings
+func Pairs(spec string) (map[string]any, error) {
+	query := make(map[string]any)
+	for _, pair := range strings.Fields(spec) {
+		idx := strings.Index(pair, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid key:value pair '%s'", pair)
+		}
+		query[strings.ToLower(pair[:idx])] = pair[idx+1:]
+	}
+	return query, nil
+}
+
+// Sort sorts records by given keys, negative sort order sorts records in descending order
+func Sort(records []map[string]any, skeys []string, sorder int) {
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, key := range skeys {
+			vi, _ := Value(records[i], key)
+			vj, _ := Value(records[j], key)
+			if c, ok := Compare(vi, vj); ok && c != 0 {
+				if sorder < 0 {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,96 @@
+package match
+
+// CHESComputing foxden tool: tests of match module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestSpec tests matching of records against query specs
+func TestSpec(t *testing.T) {
+	rec := map[string]any{
+		"did":         "/beamline=3a/btr=abc-123/cycle=2024-1/sample_name=Ti-1",
+		"Beamline":    []any{"3a", "3b"},
+		"cycle":       "2024-1",
+		"date":        float64(1706745600),
+		"sample_name": "Ti-1",
+		"detector":    map[string]any{"name": "eiger", "pixels": float64(1024)},
+	}
+	tests := []struct {
+		spec  string
+		match bool
+	}{
+		{`{}`, true},
+		{`{"cycle":"2024-1"}`, true},
+		{`{"cycle":"2024-2"}`, false},
+		{`{"beamline":"3b"}`, true},
+		{`{"detector.name":"eiger"}`, true},
+		{`{"detector":{"pixels":{"$gt":1000}}}`, true},
+		{`{"date":{"$gte":1706745600,"$lt":1706832000}}`, true},
+		{`{"date":{"$gt":1706745600}}`, false},
+		{`{"cycle":{"$in":["2024-1","2024-2"]}}`, true},
+		{`{"cycle":{"$nin":["2024-1","2024-2"]}}`, false},
+		{`{"cycle":{"$ne":"2024-1"}}`, false},
+		{`{"sample_name":{"$regex":"^ti","$options":"i"}}`, true},
+		{`{"sample_name":{"$regex":"^ti"}}`, false},
+		{`{"btr":{"$exists":false}}`, true},
+		{`{"cycle":{"$exists":true}}`, true},
+		{`{"$or":[{"cycle":"2024-2"},{"sample_name":"Ti-1"}]}`, true},
+		{`{"$and":[{"cycle":"2024-1"},{"sample_name":"Ti-2"}]}`, false},
+		{`{"$nor":[{"cycle":"2024-2"}]}`, true},
+	}
+	for _, tc := range tests {
+		var spec map[string]any
+		if err := json.Unmarshal([]byte(tc.spec), &spec); err != nil {
+			t.Fatal(err)
+		}
+		if Spec(rec, spec) != tc.match {
+			t.Errorf("spec %s: expected match=%v", tc.spec, tc.match)
+		}
+	}
+}
+
+// TestSort tests sorting of records by multiple keys
+func TestSort(t *testing.T) {
+	records := []map[string]any{
+		{"did": "c", "cycle": "2024-1", "run": float64(2)},
+		{"did": "a", "cycle": "2024-2", "run": float64(1)},
+		{"did": "b", "cycle": "2024-1", "run": float64(1)},
+	}
+	check := func(expect ...string) {
+		t.Helper()
+		for i, did := range expect {
+			if records[i]["did"] != did {
+				t.Errorf("wrong order of records %v, expected %v", records, expect)
+				return
+			}
+		}
+	}
+	Sort(records, []string{"did"}, 1)
+	check("a", "b", "c")
+	Sort(records, []string{"did"}, -1)
+	check("c", "b", "a")
+	Sort(records, []string{"cycle", "run"}, 1)
+	check("b", "c", "a")
+}
+
+// TestPairs tests conversion of key:value pairs into query spec
+func TestPairs(t *testing.T) {
+	spec, err := Pairs("Beamline:3a  cycle:2024-1 did:/beamline=3a/btr=x:y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]any{"beamline": "3a", "cycle": "2024-1", "did": "/beamline=3a/btr=x:y"}
+	if !reflect.DeepEqual(spec, expect) {
+		t.Errorf("got %v, expected %v", spec, expect)
+	}
+	for _, val := range []string{"beamline", ":3a", "beamline:3a cycle"} {
+		if _, err := Pairs(val); err == nil {
+			t.Errorf("%s: expected error", val)
+		}
+	}
+}
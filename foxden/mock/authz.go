@@ -0,0 +1,149 @@
+package mock
+
+// CHESComputing foxden tool: mock Authz module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	authz "github.com/CHESSComputing/golib/authz"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// default token expiration in seconds
+const tokenExpires = 3600
+
+// helper function to register Authz service routes
+func (s *Server) authzRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /authz/oauth/authorize", s.authorize)
+	mux.HandleFunc("POST /authz/oauth/trusted", s.trusted)
+	mux.HandleFunc("POST /authz/trusted_client", func(w http.ResponseWriter, r *http.Request) {
+		writeOK(w, "Authz")
+	})
+}
+
+// Token issues HS256 token for given user and scope signed with server
+// secret, the token carries the same custom claims as FOXDEN Authz tokens
+func (s *Server) Token(user, scope string, expires int64) (string, error) {
+	if expires <= 0 {
+		expires = tokenExpires
+	}
+	now := time.Now().Unix()
+	claims := jwt.MapClaims{
+		"iat": now,
+		"exp": now + expires,
+		"iss": "foxden-mock",
+		"sub": user,
+		"custom_claims": map[string]any{
+			"user":  user,
+			"scope": scope,
+			"kind":  "client_credentials",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.Secret))
+}
+
+// helper function to write token response of Authz service
+func (s *Server) writeToken(w http.ResponseWriter, user, scope string, expires int64) {
+	token, err := s.Token(user, scope, expires)
+	if err != nil {
+		writeError(w, "Authz", http.StatusInternalServerError, err)
+		return
+	}
+	resp := map[string]any{
+		"access_token": token,
+		"scope":        scope,
+		"token_type":   "bearer",
+		"expires_in":   expires,
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// helper function to issue token for kerberos request, the kerberos ticket is
+// not validated by mock server
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	var rec authz.Kerberos
+	if err := decode(r, &rec); err != nil {
+		writeError(w, "Authz", http.StatusBadRequest, err)
+		return
+	}
+	if rec.User == "" {
+		writeError(w, "Authz", http.StatusBadRequest, errors.New("user is not provided"))
+		return
+	}
+	scope := rec.Scope
+	if scope == "" {
+		scope = "read"
+	}
+	expires := rec.Expires
+	if expires <= 0 {
+		expires = tokenExpires
+	}
+	s.writeToken(w, rec.User, scope, expires)
+}
+
+// helper function to issue write token for trusted client, the encrypted
+// trusted client data is not validated by mock server
+func (s *Server) trusted(w http.ResponseWriter, r *http.Request) {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "foxden"
+	}
+	s.writeToken(w, user, "write", tokenExpires)
+}
+
+// helper function to check if token is allowed to access given scope, delete
+// tokens are allowed to write and all valid tokens are allowed to read
+func allowedScope(tokenScope, scope string) bool {
+	switch scope {
+	case "write":
+		return tokenScope == "write" || tokenScope == "delete"
+	case "delete":
+		return tokenScope == "delete"
+	}
+	return true
+}
+
+// helper function to check authorization of request, it writes error
+// response and returns false if request is not authorized
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request, service, scope string) bool {
+	if !s.Auth {
+		return true
+	}
+	err := s.checkToken(r, scope)
+	if err == nil {
+		return true
+	}
+	writeError(w, service, http.StatusUnauthorized, err)
+	return false
+}
+
+// helper function to validate bearer token of request against required scope
+func (s *Server) checkToken(r *http.Request, scope string) error {
+	bearer := r.Header.Get("Authorization")
+	if !strings.HasPrefix(bearer, "Bearer ") {
+		return errors.New("no bearer token")
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(bearer, "Bearer "), claims, func(t *jwt.Token) (any, error) {
+		return []byte(s.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	var tokenScope string
+	if cc, ok := claims["custom_claims"].(map[string]any); ok {
+		tokenScope, _ = cc["scope"].(string)
+	}
+	if !allowedScope(tokenScope, scope) {
+		return fmt.Errorf("token scope %q does not allow %s access", tokenScope, scope)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package mock
+
+// CHESComputing foxden tool: mock DataBookkeeping module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	match "github.com/CHESSComputing/gotools/foxden/match"
+)
+
+// helper function to register DataBookkeeping service routes
+func (s *Server) dbsRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /dbs/dataset", s.dbsAddDataset)
+	mux.HandleFunc("POST /dbs/file", s.dbsAddFile)
+	mux.HandleFunc("POST /dbs/parent", s.dbsAddParent)
+	mux.HandleFunc("GET /dbs/datasets", s.dbsDatasets)
+	mux.HandleFunc("GET /dbs/files", s.dbsFiles)
+	mux.HandleFunc("GET /dbs/parents", s.dbsParents)
+	mux.HandleFunc("GET /dbs/children", s.dbsChildren)
+	mux.HandleFunc("GET /dbs/child", s.dbsChildren)
+	mux.HandleFunc("GET /dbs/provenance", s.dbsProvenance)
+	mux.HandleFunc("GET /dbs/qlkeys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []string{"did", "file", "site", "processing", "osname"})
+	})
+	// attributes of dataset records, e.g. osinfo, environments, scripts
+	mux.HandleFunc("GET /dbs/{api}", s.dbsAttributes)
+}
+
+// helper function to match provenance records against url parameters
+func (s *Server) dbsFind(collection string, r *http.Request) []map[string]any {
+	spec := make(map[string]any)
+	for key, vals := range r.URL.Query() {
+		if len(vals) > 0 && vals[0] != "" {
+			spec[key] = vals[0]
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.find(collection, spec)
+}
+
+// helper function to add provenance dataset record, input and output files
+// of the dataset are kept as separate file records
+func (s *Server) dbsAddDataset(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "write") {
+		return
+	}
+	var rec map[string]any
+	if err := decode(r, &rec); err != nil {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, err)
+		return
+	}
+	did, ok := rec["did"].(string)
+	if !ok || did == "" {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, errors.New("dataset record does not contain did"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index(datasetsCollection, did) >= 0 {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, fmt.Errorf("dataset with did=%s already exists", did))
+		return
+	}
+	now := time.Now().Unix()
+	rec["dataset_id"] = len(s.store[datasetsCollection]) + 1
+	rec["create_at"] = now
+	rec["modify_at"] = now
+	for _, ftype := range []string{"input", "output"} {
+		files, _ := rec[ftype+"_files"].([]any)
+		for _, f := range files {
+			if frec, ok := f.(map[string]any); ok {
+				s.addFile(did, fmt.Sprintf("%v", frec["name"]), ftype)
+			}
+		}
+	}
+	if parent, ok := rec["parent_did"].(string); ok && parent != "" {
+		s.store[parentsCollection] = append(s.store[parentsCollection], map[string]any{"did": did, "parent": parent})
+	}
+	s.store[datasetsCollection] = append(s.store[datasetsCollection], rec)
+	if err := s.save(); err != nil {
+		writeError(w, "DataBookkeeping", http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, "DataBookkeeping")
+}
+
+// helper function to add file record, it should be called while holding the lock
+func (s *Server) addFile(did, name, ftype string) {
+	rec := map[string]any{
+		"did":       did,
+		"file":      name,
+		"file_type": ftype,
+		"file_id":   len(s.store[filesCollection]) + 1,
+	}
+	s.store[filesCollection] = append(s.store[filesCollection], rec)
+}
+
+// helper function to add provenance file record
+func (s *Server) dbsAddFile(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "write") {
+		return
+	}
+	var rec map[string]any
+	if err := decode(r, &rec); err != nil {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, err)
+		return
+	}
+	did, _ := rec["did"].(string)
+	name, _ := rec["file"].(string)
+	if name == "" {
+		name, _ = rec["name"].(string)
+	}
+	if did == "" || name == "" {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, errors.New("file record should contain did and file"))
+		return
+	}
+	ftype, _ := rec["file_type"].(string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addFile(did, name, ftype)
+	if err := s.save(); err != nil {
+		writeError(w, "DataBookkeeping", http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, "DataBookkeeping")
+}
+
+// helper function to add provenance parent record
+func (s *Server) dbsAddParent(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "write") {
+		return
+	}
+	var rec map[string]any
+	if err := decode(r, &rec); err != nil {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, err)
+		return
+	}
+	did, _ := rec["did"].(string)
+	parent, _ := rec["parent"].(string)
+	if parent == "" {
+		// DataBookkeeping parent records provide parent did as parent_did
+		parent, _ = rec["parent_did"].(string)
+	}
+	if did == "" || parent == "" {
+		writeError(w, "DataBookkeeping", http.StatusBadRequest, errors.New("parent record should contain did and parent"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store[parentsCollection] = append(s.store[parentsCollection], map[string]any{"did": did, "parent": parent})
+	if err := s.save(); err != nil {
+		writeError(w, "DataBookkeeping", http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, "DataBookkeeping")
+}
+
+// helper function to provide datasets API
+func (s *Server) dbsDatasets(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "read") {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.dbsFind(datasetsCollection, r))
+}
+
+// helper function to provide files API
+func (s *Server) dbsFiles(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "read") {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.dbsFind(filesCollection, r))
+}
+
+// helper function to look-up parents or children of given did, the did of
+// related dataset is provided by both <key>_did and <key>_id attributes
+func (s *Server) relatives(did, key string) []map[string]any {
+	out := []map[string]any{}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rec := range s.store[parentsCollection] {
+		child, parent := fmt.Sprintf("%v", rec["did"]), fmt.Sprintf("%v", rec["parent"])
+		switch {
+		case key == "parent" && child == did:
+			out = append(out, map[string]any{"did": did, "parent_did": parent, "parent_id": parent})
+		case key == "child" && parent == did:
+			out = append(out, map[string]any{"did": did, "child_did": child, "child_id": child})
+		}
+	}
+	return out
+}
+
+// helper function to provide parents API
+func (s *Server) dbsParents(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "read") {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.relatives(r.URL.Query().Get("did"), "parent"))
+}
+
+// helper function to provide children API
+func (s *Server) dbsChildren(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "read") {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.relatives(r.URL.Query().Get("did"), "child"))
+}
+
+// helper function to provide provenance API, it returns dataset records
+// along with their files, parents and children
+func (s *Server) dbsProvenance(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "read") {
+		return
+	}
+	out := []map[string]any{}
+	for _, rec := range s.dbsFind(datasetsCollection, r) {
+		did := fmt.Sprintf("%v", rec["did"])
+		prov := make(map[string]any)
+		for key, val := range rec {
+			prov[key] = val
+		}
+		s.mu.RLock()
+		var inputs, outputs []string
+		for _, frec := range s.find(filesCollection, map[string]any{"did": did}) {
+			name := fmt.Sprintf("%v", frec["file"])
+			if frec["file_type"] == "output" {
+				outputs = append(outputs, name)
+			} else {
+				inputs = append(inputs, name)
+			}
+		}
+		s.mu.RUnlock()
+		prov["input_files"] = inputs
+		prov["output_files"] = outputs
+		var parents, children []string
+		for _, p := range s.relatives(did, "parent") {
+			parents = append(parents, fmt.Sprintf("%v", p["parent_did"]))
+		}
+		for _, c := range s.relatives(did, "child") {
+			children = append(children, fmt.Sprintf("%v", c["child_did"]))
+		}
+		prov["parents"] = parents
+		prov["children"] = children
+		out = append(out, prov)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// helper function to provide attributes of dataset records, e.g. osinfo,
+// environments or scripts
+func (s *Server) dbsAttributes(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "DataBookkeeping", "read") {
+		return
+	}
+	api := r.PathValue("api")
+	out := []map[string]any{}
+	for _, rec := range s.dbsFind(datasetsCollection, r) {
+		val, ok := match.Value(rec, api)
+		if !ok {
+			continue
+		}
+		vals, ok := val.([]any)
+		if !ok {
+			vals = []any{val}
+		}
+		for _, v := range vals {
+			arec := map[string]any{"did": rec["did"]}
+			if m, ok := v.(map[string]any); ok {
+				for key, val := range m {
+					arec[key] = val
+				}
+			} else {
+				arec[api] = v
+			}
+			out = append(out, arec)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,199 @@
+package mock
+
+// CHESComputing foxden tool: mock OAuth device authorization module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// lifetime of device codes in seconds and polling interval
+const (
+	deviceExpires  = 600
+	deviceInterval = 1
+)
+
+// deviceGrant represents pending OAuth device authorization
+type deviceGrant struct {
+	userCode string
+	scopes   []string
+	user     string
+	approved bool
+	expires  time.Time
+}
+
+// helper function to register OAuth device authorization routes
+func (s *Server) deviceRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /authz/oauth/device/code", s.deviceCode)
+	mux.HandleFunc("GET /authz/oauth/device", s.deviceApprove)
+	mux.HandleFunc("POST /authz/oauth/token", s.oauthToken)
+}
+
+// helper function to generate random code
+func randomCode(size int) string {
+	buf := make([]byte, size)
+	rand.Read(buf)
+	return strings.ToUpper(hex.EncodeToString(buf))
+}
+
+// helper function to write OAuth error response
+func writeOAuthError(w http.ResponseWriter, code, desc string) {
+	writeJSON(w, http.StatusBadRequest, map[string]any{"error": code, "error_description": desc})
+}
+
+// helper function to start device authorization
+func (s *Server) deviceCode(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+	scopes := strings.Fields(r.PostForm.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = []string{"read"}
+	}
+	deviceCode := randomCode(16)
+	userCode := randomCode(4)
+	s.mu.Lock()
+	if s.devices == nil {
+		s.devices = make(map[string]*deviceGrant)
+	}
+	s.devices[deviceCode] = &deviceGrant{
+		userCode: userCode,
+		scopes:   scopes,
+		expires:  time.Now().Add(deviceExpires * time.Second),
+	}
+	s.mu.Unlock()
+	base := fmt.Sprintf("http://%s/authz/oauth/device", r.Host)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          base,
+		"verification_uri_complete": base + "?user_code=" + userCode,
+		"expires_in":                deviceExpires,
+		"interval":                  deviceInterval,
+	})
+}
+
+// helper function to approve device authorization, the mock server approves
+// any user code without authentication, user name can be passed via user
+// parameter
+func (s *Server) deviceApprove(w http.ResponseWriter, r *http.Request) {
+	userCode := r.URL.Query().Get("user_code")
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	if user == "" {
+		user = "foxden"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, grant := range s.devices {
+		if grant.userCode == userCode && time.Now().Before(grant.expires) {
+			grant.approved = true
+			grant.user = user
+			fmt.Fprintf(w, "device %s is approved for user %s, scopes %s\n", userCode, user, strings.Join(grant.scopes, " "))
+			return
+		}
+	}
+	http.Error(w, "unknown or expired user code", http.StatusNotFound)
+}
+
+// helper function to issue refresh token which grants given scopes
+func (s *Server) refreshToken(user string, scopes []string) (string, error) {
+	claims := jwt.MapClaims{
+		"iat":    time.Now().Unix(),
+		"iss":    "foxden-mock",
+		"sub":    user,
+		"kind":   "refresh",
+		"scopes": strings.Join(scopes, " "),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.Secret))
+}
+
+// helper function to issue tokens for device code and refresh token grants
+func (s *Server) oauthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		s.deviceToken(w, r.PostForm.Get("device_code"))
+	case "refresh_token":
+		s.refreshGrant(w, r.PostForm.Get("refresh_token"), r.PostForm.Get("scope"))
+	default:
+		writeOAuthError(w, "unsupported_grant_type", "unsupported grant type")
+	}
+}
+
+// helper function to issue tokens for approved device authorization
+func (s *Server) deviceToken(w http.ResponseWriter, deviceCode string) {
+	s.mu.Lock()
+	grant, ok := s.devices[deviceCode]
+	if ok && grant.approved {
+		delete(s.devices, deviceCode)
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		writeOAuthError(w, "invalid_grant", "unknown device code")
+		return
+	case time.Now().After(grant.expires):
+		writeOAuthError(w, "expired_token", "device code is expired")
+		return
+	case !grant.approved:
+		writeOAuthError(w, "authorization_pending", "device is not approved yet")
+		return
+	}
+	token, err := s.Token(grant.user, grant.scopes[0], tokenExpires)
+	if err != nil {
+		writeError(w, "Authz", http.StatusInternalServerError, err)
+		return
+	}
+	refresh, err := s.refreshToken(grant.user, grant.scopes)
+	if err != nil {
+		writeError(w, "Authz", http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  token,
+		"refresh_token": refresh,
+		"scope":         grant.scopes[0],
+		"token_type":    "bearer",
+		"expires_in":    tokenExpires,
+	})
+}
+
+// helper function to issue token of given scope for refresh token
+func (s *Server) refreshGrant(w http.ResponseWriter, refresh, scope string) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(refresh, claims, func(t *jwt.Token) (any, error) {
+		return []byte(s.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || claims["kind"] != "refresh" {
+		writeOAuthError(w, "invalid_grant", "invalid refresh token")
+		return
+	}
+	scopes, _ := claims["scopes"].(string)
+	if scope == "" {
+		scope = "read"
+	}
+	if !slices.Contains(strings.Fields(scopes), scope) {
+		writeOAuthError(w, "invalid_scope", fmt.Sprintf("scope %q is not granted", scope))
+		return
+	}
+	user, _ := claims["sub"].(string)
+	s.writeToken(w, user, scope, tokenExpires)
+}
@@ -0,0 +1,105 @@
+package mock
+
+// CHESComputing foxden tool: tests of mock OAuth device authorization module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// helper function to post form and decode JSON response
+func postForm(t *testing.T, rurl string, form url.Values) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.PostForm(rurl, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	rec := make(map[string]any)
+	if err := json.NewDecoder(resp.Body).Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, rec
+}
+
+// TestDeviceFlow tests OAuth device authorization and refresh token grants
+func TestDeviceFlow(t *testing.T) {
+	srv, err := NewServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	tokenUrl := ts.URL + "/authz/oauth/token"
+
+	code, rec := postForm(t, ts.URL+"/authz/oauth/device/code", url.Values{"scope": {"read write"}})
+	if code != http.StatusOK {
+		t.Fatalf("wrong status of device code request %d: %v", code, rec)
+	}
+	deviceCode, _ := rec["device_code"].(string)
+	verifyUrl, _ := rec["verification_uri_complete"].(string)
+	if deviceCode == "" || verifyUrl == "" {
+		t.Fatalf("wrong device code response %v", rec)
+	}
+
+	// polling before approval
+	grant := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {deviceCode},
+	}
+	if code, rec = postForm(t, tokenUrl, grant); rec["error"] != "authorization_pending" {
+		t.Fatalf("wrong response of pending authorization %d: %v", code, rec)
+	}
+
+	resp, err := http.Get(verifyUrl + "&user=test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status of device approval %d", resp.StatusCode)
+	}
+
+	code, rec = postForm(t, tokenUrl, grant)
+	if code != http.StatusOK || rec["scope"] != "read" {
+		t.Fatalf("wrong token response %d: %v", code, rec)
+	}
+	refresh, _ := rec["refresh_token"].(string)
+	if refresh == "" {
+		t.Fatalf("no refresh token in %v", rec)
+	}
+	// device code can be used only once
+	if _, rec = postForm(t, tokenUrl, grant); rec["error"] != "invalid_grant" {
+		t.Errorf("device code was used twice: %v", rec)
+	}
+
+	// refresh token grants only scopes of device authorization
+	for _, tc := range []struct {
+		scope string
+		ok    bool
+	}{
+		{"read", true},
+		{"write", true},
+		{"delete", false},
+	} {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}, "scope": {tc.scope}}
+		code, rec = postForm(t, tokenUrl, form)
+		if ok := code == http.StatusOK; ok != tc.ok {
+			t.Errorf("wrong status %d of refresh grant of %s scope: %v", code, tc.scope, rec)
+			continue
+		}
+		if tc.ok && rec["scope"] != tc.scope {
+			t.Errorf("wrong scope of refreshed token: %v", rec)
+		}
+	}
+
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"invalid"}}
+	if _, rec = postForm(t, tokenUrl, form); rec["error"] != "invalid_grant" {
+		t.Errorf("invalid refresh token was accepted: %v", rec)
+	}
+}
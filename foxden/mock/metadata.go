@@ -0,0 +1,238 @@
+package mock
+
+// CHESComputing foxden tool: mock MetaData module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// helper function to register MetaData and UserMetaData service routes
+func (s *Server) metaRoutes(mux *http.ServeMux) {
+	for _, svc := range []struct{ prefix, service, collection string }{
+		{"/meta", "MetaData", metaCollection},
+		{"/umeta", "UserMetaData", umetaCollection},
+	} {
+		mux.HandleFunc("POST "+svc.prefix+"/search", s.metaSearch(svc.service, svc.collection))
+		mux.HandleFunc("POST "+svc.prefix+"/count", s.metaCount(svc.service, svc.collection))
+		mux.HandleFunc("GET "+svc.prefix+"/records", s.metaRecords(svc.service, svc.collection))
+		mux.HandleFunc("GET "+svc.prefix+"/record", s.metaRecord(svc.service, svc.collection))
+		mux.HandleFunc("DELETE "+svc.prefix+"/record", s.metaDelete(svc.service, svc.collection))
+		mux.HandleFunc("GET "+svc.prefix+"/qlkeys", s.metaKeys(svc.service, svc.collection))
+	}
+	// MetaData service accepts MetaRecord at its base url, while
+	// UserMetaData service accepts plain records at /record end-point
+	mux.HandleFunc("POST /meta", s.metaAdd(false))
+	mux.HandleFunc("PUT /meta", s.metaAdd(true))
+	mux.HandleFunc("POST /umeta/record", s.recordAdd("UserMetaData", umetaCollection, false))
+	mux.HandleFunc("PUT /umeta/record", s.recordAdd("UserMetaData", umetaCollection, true))
+
+	// meta-data templates
+	mux.HandleFunc("GET /meta/tmpl/records", s.metaRecords("MetaData", tmplCollection))
+	mux.HandleFunc("GET /meta/tmpl/record", s.metaRecord("MetaData", tmplCollection))
+	mux.HandleFunc("POST /meta/tmpl/record", s.recordAdd("MetaData", tmplCollection, false))
+	mux.HandleFunc("PUT /meta/tmpl/record", s.recordAdd("MetaData", tmplCollection, true))
+	mux.HandleFunc("DELETE /meta/tmpl/record", s.metaDelete("MetaData", tmplCollection))
+}
+
+// helper function to decode service request and its query spec
+func serviceRequest(r *http.Request) (services.ServiceRequest, map[string]any, error) {
+	var req services.ServiceRequest
+	if err := decode(r, &req); err != nil {
+		return req, nil, err
+	}
+	spec, err := parseQuery(req.ServiceQuery.Query)
+	return req, spec, err
+}
+
+// helper function to provide search API of meta-data services
+func (s *Server) metaSearch(service, collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, service, "read") {
+			return
+		}
+		req, spec, err := serviceRequest(r)
+		if err != nil {
+			writeError(w, service, http.StatusBadRequest, err)
+			return
+		}
+		s.mu.RLock()
+		records := page(s.find(collection, spec), req.ServiceQuery)
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, records)
+	}
+}
+
+// helper function to provide count API of meta-data services
+func (s *Server) metaCount(service, collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, service, "read") {
+			return
+		}
+		_, spec, err := serviceRequest(r)
+		if err != nil {
+			writeError(w, service, http.StatusBadRequest, err)
+			return
+		}
+		s.mu.RLock()
+		nrecords := len(s.find(collection, spec))
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, nrecords)
+	}
+}
+
+// helper function to provide records API of meta-data services, the request
+// body may contain JSON encoded query spec, records are returned in JSON
+// format or in ndjson one if client accepts it
+func (s *Server) metaRecords(service, collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, service, "read") {
+			return
+		}
+		var query string
+		data, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err == nil && len(data) > 0 {
+			// spec is sent either as JSON string or as JSON object
+			if err = json.Unmarshal(data, &query); err != nil {
+				query, err = string(data), nil
+			}
+		}
+		var spec map[string]any
+		if err == nil {
+			spec, err = parseQuery(query)
+		}
+		if err != nil {
+			writeError(w, service, http.StatusBadRequest, err)
+			return
+		}
+		s.mu.RLock()
+		records := s.find(collection, spec)
+		s.mu.RUnlock()
+		ctype := r.Header.Get("Accept") + r.Header.Get("Content-Type")
+		if !strings.Contains(ctype, "ndjson") {
+			writeJSON(w, http.StatusOK, records)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// helper function to provide record API of meta-data services
+func (s *Server) metaRecord(service, collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, service, "read") {
+			return
+		}
+		did := r.URL.Query().Get("did")
+		s.mu.RLock()
+		records := s.find(collection, map[string]any{"did": did})
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, records)
+	}
+}
+
+// helper function to add or update MetaRecord of MetaData service
+func (s *Server) metaAdd(update bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, "MetaData", "write") {
+			return
+		}
+		var mrec services.MetaRecord
+		if err := decode(r, &mrec); err != nil {
+			writeError(w, "MetaData", http.StatusBadRequest, err)
+			return
+		}
+		if mrec.Schema == "" {
+			writeError(w, "MetaData", http.StatusBadRequest, errors.New("record schema is not provided"))
+			return
+		}
+		rec := mrec.Record
+		if rec == nil {
+			writeError(w, "MetaData", http.StatusBadRequest, errors.New("empty record"))
+			return
+		}
+		if _, ok := rec["schema"]; !ok {
+			rec["schema"] = mrec.Schema
+		}
+		if err := s.upsert(metaCollection, rec, update); err != nil {
+			writeError(w, "MetaData", http.StatusBadRequest, err)
+			return
+		}
+		writeOK(w, "MetaData")
+	}
+}
+
+// helper function to add or update plain records of meta-data services
+func (s *Server) recordAdd(service, collection string, update bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, service, "write") {
+			return
+		}
+		var rec map[string]any
+		if err := decode(r, &rec); err != nil {
+			writeError(w, service, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.upsert(collection, rec, update); err != nil {
+			writeError(w, service, http.StatusBadRequest, err)
+			return
+		}
+		writeOK(w, service)
+	}
+}
+
+// helper function to delete records of meta-data services
+func (s *Server) metaDelete(service, collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r, service, "delete") {
+			return
+		}
+		did := r.URL.Query().Get("did")
+		found, err := s.remove(collection, did)
+		if err != nil {
+			writeError(w, service, http.StatusInternalServerError, err)
+			return
+		}
+		if !found {
+			writeError(w, service, http.StatusNotFound, fmt.Errorf("no record found for did=%s", did))
+			return
+		}
+		writeOK(w, service)
+	}
+}
+
+// helper function to provide list of query keys of meta-data services
+func (s *Server) metaKeys(service, collection string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys := make(map[string]bool)
+		s.mu.RLock()
+		for _, rec := range s.store[collection] {
+			for key := range rec {
+				keys[key] = true
+			}
+		}
+		s.mu.RUnlock()
+		out := []string{}
+		for key := range keys {
+			out = append(out, key)
+		}
+		sort.Strings(out)
+		writeJSON(w, http.StatusOK, out)
+	}
+}
@@ -0,0 +1,290 @@
+// Package mock provides local FOXDEN server which implements APIs of FOXDEN
+// services used by foxden tools, e.g. MetaData, DataBookkeeping, SpecScans
+// and Authz, on top of in-memory or file based store. It allows to run foxden
+// tools end-to-end without live FOXDEN services, e.g.
+//
+//	srv, err := mock.NewServer("")
+//	ts := httptest.NewServer(srv.Handler())
+//	c := client.New(mock.URLs(ts.URL), nil)
+package mock
+
+// CHESComputing foxden tool: mock server module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	services "github.com/CHESSComputing/golib/services"
+	client "github.com/CHESSComputing/gotools/foxden/client"
+	match "github.com/CHESSComputing/gotools/foxden/match"
+)
+
+// names of store collections
+const (
+	metaCollection     = "meta"
+	umetaCollection    = "umeta"
+	tmplCollection     = "tmpl"
+	specCollection     = "spec"
+	datasetsCollection = "datasets"
+	filesCollection    = "files"
+	parentsCollection  = "parents"
+)
+
+// Server represents local FOXDEN mock server
+type Server struct {
+	Secret  string // secret to sign and verify tokens, e.g. Authz ClientID of FOXDEN configuration
+	Auth    bool   // require valid tokens of proper scope for all requests
+	Verbose int    // verbosity level, requests are logged if it is positive
+
+	mu      sync.RWMutex
+	fname   string
+	store   map[string][]map[string]any
+	devices map[string]*deviceGrant // pending OAuth device authorizations
+}
+
+// NewServer creates new mock server, if file name is provided the store is
+// loaded from it (if it exists) and all changes are persisted into it,
+// otherwise records are kept in memory
+func NewServer(fname string) (*Server, error) {
+	s := &Server{Secret: "foxden-mock", fname: fname, store: make(map[string][]map[string]any)}
+	if fname == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.store); err != nil {
+			return nil, fmt.Errorf("unable to load mock store %s: %w", fname, err)
+		}
+	}
+	return s, nil
+}
+
+// URLs returns urls of FOXDEN services provided by mock server with given base url
+func URLs(base string) client.URLs {
+	base = strings.TrimSuffix(base, "/")
+	return client.URLs{
+		MetaData:        base + "/meta",
+		UserMetaData:    base + "/umeta",
+		DataBookkeeping: base + "/dbs",
+		SpecScans:       base + "/spec",
+		Discovery:       base + "/discovery",
+		Authz:           base + "/authz",
+	}
+}
+
+// Handler returns HTTP handler of mock server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	s.metaRoutes(mux)
+	s.dbsRoutes(mux)
+	s.specRoutes(mux)
+	s.authzRoutes(mux)
+	s.deviceRoutes(mux)
+	mux.HandleFunc("POST /discovery/search", s.discoverySearch)
+	if s.Verbose == 0 {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Println(r.Method, r.URL.String())
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// helper function to persist store into mock file, it should be called
+// while holding the lock
+func (s *Server) save() error {
+	if s.fname == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.store, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write store into temporary file first to avoid partial writes
+	tmp, err := os.CreateTemp(filepath.Dir(s.fname), ".foxden-mock-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.fname)
+}
+
+// helper function to find records of given collection matching query spec
+func (s *Server) find(collection string, spec map[string]any) []map[string]any {
+	records := []map[string]any{}
+	for _, rec := range s.store[collection] {
+		if match.Spec(rec, spec) {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// helper function to find index of record with given did in given collection
+func (s *Server) index(collection, did string) int {
+	for i, rec := range s.store[collection] {
+		if fmt.Sprintf("%v", rec["did"]) == did {
+			return i
+		}
+	}
+	return -1
+}
+
+// helper function to insert or update record with given did in given
+// collection, it returns an error if record exists and update is not requested
+func (s *Server) upsert(collection string, rec map[string]any, update bool) error {
+	did, ok := rec["did"].(string)
+	if !ok || did == "" {
+		return fmt.Errorf("record does not contain did")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := s.index(collection, did)
+	switch {
+	case idx >= 0 && !update:
+		return fmt.Errorf("record with did=%s already exists", did)
+	case idx >= 0:
+		s.store[collection][idx] = rec
+	default:
+		s.store[collection] = append(s.store[collection], rec)
+	}
+	return s.save()
+}
+
+// helper function to delete record with given did from given collection
+func (s *Server) remove(collection, did string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := s.index(collection, did)
+	if idx < 0 {
+		return false, nil
+	}
+	records := s.store[collection]
+	s.store[collection] = append(records[:idx], records[idx+1:]...)
+	return true, s.save()
+}
+
+// helper function to parse query of FOXDEN services, the query can be
+// either JSON spec, did:<did> or key:value pairs
+func parseQuery(query string) (map[string]any, error) {
+	spec := make(map[string]any)
+	query = strings.TrimSpace(query)
+	if query == "" || query == "{}" {
+		return spec, nil
+	}
+	if strings.HasPrefix(query, "did:") {
+		spec["did"] = strings.TrimPrefix(query, "did:")
+		return spec, nil
+	}
+	if !strings.HasPrefix(query, "{") {
+		return match.Pairs(query)
+	}
+	if err := json.Unmarshal([]byte(query), &spec); err != nil {
+		return nil, fmt.Errorf("unable to parse query %s: %w", query, err)
+	}
+	return spec, nil
+}
+
+// helper function to apply pagination and sorting options of service query
+func page(records []map[string]any, q services.ServiceQuery) []map[string]any {
+	match.Sort(records, q.SortKeys, q.SortOrder)
+	idx := q.Idx
+	if idx < 0 || idx > len(records) {
+		idx = len(records)
+	}
+	if q.Limit > 0 && idx+q.Limit < len(records) {
+		return records[idx : idx+q.Limit]
+	}
+	return records[idx:]
+}
+
+// helper function to decode request body into given object
+func decode(r *http.Request, out any) error {
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode request body: %w", err)
+	}
+	return nil
+}
+
+// helper function to write JSON response
+func writeJSON(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
+
+// helper function to write service response of successful request
+func writeOK(w http.ResponseWriter, service string) {
+	resp := services.ServiceResponse{
+		Service:   service,
+		Status:    "ok",
+		HttpCode:  http.StatusOK,
+		Timestamp: time.Now().String(),
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// helper function to write service response of failed request
+func writeError(w http.ResponseWriter, service string, code int, err error) {
+	resp := services.ServiceResponse{
+		Service:   service,
+		Status:    "fail",
+		HttpCode:  code,
+		SrvCode:   code,
+		Error:     err.Error(),
+		Timestamp: time.Now().String(),
+	}
+	writeJSON(w, code, resp)
+}
+
+// helper function to search FOXDEN meta-data records via Discovery service API
+func (s *Server) discoverySearch(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "Discovery", "read") {
+		return
+	}
+	var req services.ServiceRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, "Discovery", http.StatusBadRequest, err)
+		return
+	}
+	spec, err := parseQuery(req.ServiceQuery.Query)
+	if err != nil {
+		writeError(w, "Discovery", http.StatusBadRequest, err)
+		return
+	}
+	s.mu.RLock()
+	records := page(s.find(metaCollection, spec), req.ServiceQuery)
+	s.mu.RUnlock()
+	resp := services.ServiceResponse{
+		Service:   "Discovery",
+		Status:    "ok",
+		HttpCode:  http.StatusOK,
+		Timestamp: time.Now().String(),
+	}
+	resp.Results.Records = records
+	writeJSON(w, http.StatusOK, resp)
+}
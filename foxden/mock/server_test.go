@@ -0,0 +1,39 @@
+package mock
+
+// CHESComputing foxden tool: tests of mock server module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseQuery tests conversion of JSON, did and key:value queries into query spec
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect map[string]any
+	}{
+		{"", map[string]any{}},
+		{"{}", map[string]any{}},
+		{`{"btr":"abc"}`, map[string]any{"btr": "abc"}},
+		{"did:/beamline=3a/btr=abc", map[string]any{"did": "/beamline=3a/btr=abc"}},
+		{"beamline:3a cycle:2024-1", map[string]any{"beamline": "3a", "cycle": "2024-1"}},
+	}
+	for _, tc := range tests {
+		spec, err := parseQuery(tc.query)
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, tc.expect) {
+			t.Errorf("%s: got %v, expected %v", tc.query, spec, tc.expect)
+		}
+	}
+	for _, query := range []string{"{btr", "beamline"} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("%s: malformed query was accepted", query)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package mock
+
+// CHESComputing foxden tool: mock SpecScans module
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// helper function to register SpecScans service routes
+func (s *Server) specRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /spec/search", s.specSearch)
+	mux.HandleFunc("POST /spec/add", s.specAdd)
+	mux.HandleFunc("GET /spec/qlkeys", s.metaKeys("SpecScans", specCollection))
+}
+
+// helper function to search SpecScans records, records are returned as part
+// of service response
+func (s *Server) specSearch(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "SpecScans", "read") {
+		return
+	}
+	req, spec, err := serviceRequest(r)
+	if err != nil {
+		writeError(w, "SpecScans", http.StatusBadRequest, err)
+		return
+	}
+	s.mu.RLock()
+	records := page(s.find(specCollection, spec), req.ServiceQuery)
+	s.mu.RUnlock()
+	resp := services.ServiceResponse{
+		Service:   "SpecScans",
+		Status:    "ok",
+		HttpCode:  http.StatusOK,
+		Timestamp: time.Now().String(),
+	}
+	resp.Results.Records = records
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// helper function to add SpecScans record or list of records
+func (s *Server) specAdd(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r, "SpecScans", "write") {
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		writeError(w, "SpecScans", http.StatusBadRequest, err)
+		return
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(data, &records); err != nil {
+		var rec map[string]any
+		if err := json.Unmarshal(data, &rec); err != nil {
+			writeError(w, "SpecScans", http.StatusBadRequest, err)
+			return
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		writeError(w, "SpecScans", http.StatusBadRequest, errors.New("no records provided"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store[specCollection] = append(s.store[specCollection], records...)
+	if err := s.save(); err != nil {
+		writeError(w, "SpecScans", http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, "SpecScans")
+}